@@ -35,6 +35,36 @@ type stats struct {
 	Blkio    blkio              `json:"blkio"`
 	Hugetlb  map[string]hugetlb `json:"hugetlb"`
 	IntelRdt intelRdt           `json:"intel_rdt"`
+	// Filesystem is not part of the runc stats, it reports the usage of
+	// the container filesystems which only live inside the VM.
+	Filesystem []filesystem `json:"filesystem,omitempty"`
+	// NetworkInterfaces reports the guest interfaces, including the drops
+	// and errors of the host interfaces connecting them to the VM.
+	NetworkInterfaces []*networkInterface `json:"network_interfaces,omitempty"`
+}
+
+type networkInterface struct {
+	// Name is the name of the network interface.
+	Name string `json:"name"`
+
+	RxBytes   uint64 `json:"rx_bytes"`
+	RxPackets uint64 `json:"rx_packets"`
+	RxErrors  uint64 `json:"rx_errors"`
+	RxDropped uint64 `json:"rx_dropped"`
+	TxBytes   uint64 `json:"tx_bytes"`
+	TxPackets uint64 `json:"tx_packets"`
+	TxErrors  uint64 `json:"tx_errors"`
+	TxDropped uint64 `json:"tx_dropped"`
+}
+
+type filesystem struct {
+	Path           string `json:"path"`
+	CapacityBytes  uint64 `json:"capacityBytes,omitempty"`
+	AvailableBytes uint64 `json:"availableBytes,omitempty"`
+	UsedBytes      uint64 `json:"usedBytes,omitempty"`
+	Inodes         uint64 `json:"inodes,omitempty"`
+	InodesFree     uint64 `json:"inodesFree,omitempty"`
+	InodesUsed     uint64 `json:"inodesUsed,omitempty"`
 }
 
 type hugetlb struct {
@@ -261,6 +291,32 @@ func convertVirtcontainerStats(containerStats *vc.ContainerStats) *stats {
 		s.Hugetlb[k] = convertHugtlb(v)
 	}
 
+	for _, fs := range containerStats.FilesystemStats {
+		s.Filesystem = append(s.Filesystem, filesystem{
+			Path:           fs.Path,
+			CapacityBytes:  fs.CapacityBytes,
+			AvailableBytes: fs.AvailableBytes,
+			UsedBytes:      fs.UsedBytes,
+			Inodes:         fs.Inodes,
+			InodesFree:     fs.InodesFree,
+			InodesUsed:     fs.InodesUsed,
+		})
+	}
+
+	for _, n := range containerStats.NetworkStats {
+		s.NetworkInterfaces = append(s.NetworkInterfaces, &networkInterface{
+			Name:      n.Name,
+			RxBytes:   n.RxBytes,
+			RxPackets: n.RxPackets,
+			RxErrors:  n.RxErrors,
+			RxDropped: n.RxDropped,
+			TxBytes:   n.TxBytes,
+			TxPackets: n.TxPackets,
+			TxErrors:  n.TxErrors,
+			TxDropped: n.TxDropped,
+		})
+	}
+
 	return &s
 }
 
@@ -142,3 +142,61 @@ func TestEventsCLISuccessful(t *testing.T) {
 	err = actionFunc(ctx)
 	assert.NoError(err)
 }
+
+func TestEventsConvertFilesystemStats(t *testing.T) {
+	assert := assert.New(t)
+
+	s := convertVirtcontainerStats(&vc.ContainerStats{
+		CgroupStats: &vc.CgroupStats{},
+		FilesystemStats: []*vc.FilesystemStats{
+			{
+				Path:          "/",
+				CapacityBytes: 4096,
+				UsedBytes:     1024,
+				InodesUsed:    8,
+			},
+		},
+	})
+	assert.NotNil(s)
+	assert.Equal([]filesystem{
+		{
+			Path:          "/",
+			CapacityBytes: 4096,
+			UsedBytes:     1024,
+			InodesUsed:    8,
+		},
+	}, s.Filesystem)
+}
+
+func TestEventsConvertNetworkStats(t *testing.T) {
+	assert := assert.New(t)
+
+	s := convertVirtcontainerStats(&vc.ContainerStats{
+		CgroupStats: &vc.CgroupStats{},
+		NetworkStats: []*vc.NetworkStats{
+			{
+				Name:      "eth0",
+				RxBytes:   2048,
+				TxPackets: 16,
+			},
+			{
+				Name:      "eth1",
+				TxBytes:   2048,
+				TxDropped: 4,
+			},
+		},
+	})
+	assert.NotNil(s)
+	assert.Equal([]*networkInterface{
+		{
+			Name:      "eth0",
+			RxBytes:   2048,
+			TxPackets: 16,
+		},
+		{
+			Name:      "eth1",
+			TxBytes:   2048,
+			TxDropped: 4,
+		},
+	}, s.NetworkInterfaces)
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2020 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/kata-containers/runtime/pkg/katautils"
+	vc "github.com/kata-containers/runtime/virtcontainers"
+	"github.com/kata-containers/runtime/virtcontainers/pkg/oci"
+	"github.com/urfave/cli"
+)
+
+var fsckCLICommand = cli.Command{
+	Name:  "fsck",
+	Usage: "check and repair the consistency of the sandboxes on the host",
+	Description: `The fsck command cross-checks the sandboxes known by ` + project + ` against the
+   host resources they use: hypervisor processes, network namespaces, mounts under
+   the shared directory, cgroups and container bundles. Each inconsistency found
+   is reported. With --repair, broken sandboxes and containers are stopped and
+   deleted, and the resources of unknown sandboxes are released.`,
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "format, f",
+			Value: "table",
+			Usage: `select one of: ` + formatOptions,
+		},
+		cli.BoolFlag{
+			Name:  "repair",
+			Usage: "clean up the inconsistencies found",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		ctx, err := cliContextToContext(context)
+		if err != nil {
+			return err
+		}
+
+		runtimeConfig, ok := context.App.Metadata["runtimeConfig"].(oci.RuntimeConfig)
+		if !ok {
+			return errors.New("invalid runtime config")
+		}
+
+		return fsck(ctx, runtimeConfig, context.Bool("repair"), context.String("format"), defaultOutputFile)
+	},
+}
+
+func fsck(ctx context.Context, runtimeConfig oci.RuntimeConfig, repair bool, format string, file io.Writer) error {
+	span, ctx := katautils.Trace(ctx, "fsck")
+	defer span.Finish()
+
+	span.SetTag("repair", repair)
+
+	// Only the processes of unknown sandboxes running one of the
+	// configured programs are reported.
+	binaries := []string{
+		runtimeConfig.HypervisorConfig.HypervisorPath,
+		runtimeConfig.HypervisorConfig.JailerPath,
+		runtimeConfig.HypervisorConfig.VirtioFSDaemon,
+	}
+
+	issues, err := vci.CheckSandboxes(ctx, repair, binaries)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "table":
+		return fsckTable(issues, repair, file)
+	case "json":
+		if issues == nil {
+			issues = []vc.Inconsistency{}
+		}
+		return json.NewEncoder(file).Encode(issues)
+	default:
+		return fmt.Errorf("invalid format option")
+	}
+}
+
+func fsckTable(issues []vc.Inconsistency, repair bool, file io.Writer) error {
+	// values used by runc
+	flags := uint(0)
+	minWidth := 12
+	tabWidth := 1
+	padding := 3
+
+	w := tabwriter.NewWriter(file, minWidth, tabWidth, padding, ' ', flags)
+
+	fmt.Fprint(w, "TYPE\tSANDBOX\tCONTAINER\tRESOURCE")
+	if repair {
+		fmt.Fprint(w, "\tREPAIRED")
+	}
+	fmt.Fprint(w, "\n")
+
+	for _, issue := range issues {
+		containerID := issue.ContainerID
+		if containerID == "" {
+			containerID = "-"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s", issue.Type, issue.SandboxID, containerID, issue.Resource)
+
+		if repair {
+			repaired := "yes"
+			if !issue.Repaired {
+				repaired = "no: " + issue.RepairError
+			}
+			fmt.Fprintf(w, "\t%s", repaired)
+		}
+		fmt.Fprint(w, "\n")
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,80 @@
+// Copyright (c) 2020 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	vc "github.com/kata-containers/runtime/virtcontainers"
+	"github.com/kata-containers/runtime/virtcontainers/pkg/oci"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFsck(t *testing.T) {
+	assert := assert.New(t)
+
+	runtimeConfig := oci.RuntimeConfig{
+		HypervisorConfig: vc.HypervisorConfig{
+			HypervisorPath: "/usr/bin/qemu-system-x86_64",
+		},
+	}
+
+	issues := []vc.Inconsistency{
+		{
+			Type:        vc.OrphanedContainer,
+			SandboxID:   testSandboxID,
+			ContainerID: testContainerID,
+			Resource:    "/run/bundle",
+			Repaired:    true,
+		},
+		{
+			Type:        vc.LeakedMount,
+			SandboxID:   "foo",
+			Resource:    "/run/kata-containers/shared/sandboxes/foo/shared",
+			RepairError: "device or resource busy",
+		},
+	}
+
+	var repairRequested bool
+	var binariesRequested []string
+	testingImpl.CheckSandboxesFunc = func(ctx context.Context, repair bool, binaries []string) ([]vc.Inconsistency, error) {
+		repairRequested = repair
+		binariesRequested = binaries
+		return issues, nil
+	}
+	defer func() {
+		testingImpl.CheckSandboxesFunc = nil
+	}()
+
+	var out bytes.Buffer
+	err := fsck(context.Background(), runtimeConfig, false, "table", &out)
+	assert.NoError(err)
+	assert.False(repairRequested)
+	assert.Contains(binariesRequested, runtimeConfig.HypervisorConfig.HypervisorPath)
+	assert.Contains(out.String(), string(vc.OrphanedContainer))
+	assert.NotContains(out.String(), "REPAIRED")
+
+	out.Reset()
+	err = fsck(context.Background(), runtimeConfig, true, "table", &out)
+	assert.NoError(err)
+	assert.True(repairRequested)
+	assert.Contains(out.String(), "REPAIRED")
+	assert.Contains(out.String(), "no: device or resource busy")
+
+	out.Reset()
+	err = fsck(context.Background(), runtimeConfig, false, "json", &out)
+	assert.NoError(err)
+
+	var decoded []vc.Inconsistency
+	assert.NoError(json.Unmarshal(out.Bytes(), &decoded))
+	assert.Equal(issues, decoded)
+
+	err = fsck(context.Background(), runtimeConfig, false, "foo", &out)
+	assert.Error(err)
+}
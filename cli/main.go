@@ -140,8 +140,10 @@ var runtimeCommands = []cli.Command{
 	kataCheckCLICommand,
 	kataEnvCLICommand,
 	kataNetworkCLICommand,
+	kataVolumeCLICommand,
 	kataOverheadCLICommand,
 	factoryCLICommand,
+	fsckCLICommand,
 }
 
 // runtimeBeforeSubcommands is the function to run before command-line
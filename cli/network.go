@@ -24,17 +24,25 @@ const (
 	interfaceType networkType = iota
 
 	routeType
+
+	// addressType for interface addresses update operation
+	addressType
+
+	// neighborType for ARP neighbors operation
+	neighborType
 )
 
 var kataNetworkCLICommand = cli.Command{
 	Name:  "kata-network",
-	Usage: "manage interfaces and routes for container",
+	Usage: "manage interfaces, routes and neighbors for container",
 	Subcommands: []cli.Command{
 		addIfaceCommand,
 		delIfaceCommand,
+		updateIfaceCommand,
 		listIfacesCommand,
 		updateRoutesCommand,
 		listRoutesCommand,
+		updateNeighborsCommand,
 	},
 	Action: func(context *cli.Context) error {
 		return cli.ShowSubcommandHelp(context)
@@ -71,6 +79,21 @@ var delIfaceCommand = cli.Command{
 	},
 }
 
+var updateIfaceCommand = cli.Command{
+	Name:      "update-iface",
+	Usage:     "update the IP addresses of a container interface",
+	ArgsUsage: `update-iface <container-id> file or - for stdin`,
+	Flags:     []cli.Flag{},
+	Action: func(context *cli.Context) error {
+		ctx, err := cliContextToContext(context)
+		if err != nil {
+			return err
+		}
+
+		return networkModifyCommand(ctx, context.Args().First(), context.Args().Get(1), addressType, true)
+	},
+}
+
 var listIfacesCommand = cli.Command{
 	Name:      "list-ifaces",
 	Usage:     "list network interfaces in a container",
@@ -116,6 +139,21 @@ var listRoutesCommand = cli.Command{
 	},
 }
 
+var updateNeighborsCommand = cli.Command{
+	Name:      "update-neighbors",
+	Usage:     "add or replace static ARP neighbors of a container",
+	ArgsUsage: `update-neighbors <container-id> file or - for stdin`,
+	Flags:     []cli.Flag{},
+	Action: func(context *cli.Context) error {
+		ctx, err := cliContextToContext(context)
+		if err != nil {
+			return err
+		}
+
+		return networkModifyCommand(ctx, context.Args().First(), context.Args().Get(1), neighborType, true)
+	},
+}
+
 func networkModifyCommand(ctx context.Context, containerID, input string, opType networkType, add bool) (err error) {
 	status, sandboxID, err := getExistingContainerInfo(ctx, containerID)
 	if err != nil {
@@ -181,6 +219,26 @@ func networkModifyCommand(ctx context.Context, containerID, input string, opType
 			kataLog.WithField("resulting-routes", fmt.Sprintf("%+v", resultingRoutes)).
 				WithError(err).Error("update routes failed")
 		}
+	case addressType:
+		var inf, resultingInf *vcTypes.Interface
+		if err = json.NewDecoder(f).Decode(&inf); err != nil {
+			return err
+		}
+		resultingInf, err = vci.UpdateInterface(ctx, sandboxID, inf)
+		json.NewEncoder(output).Encode(resultingInf)
+		if err != nil {
+			kataLog.WithField("resulting-interface", fmt.Sprintf("%+v", resultingInf)).
+				WithError(err).Error("update interface failed")
+		}
+	case neighborType:
+		var neighs []*vcTypes.ARPNeighbor
+		if err = json.NewDecoder(f).Decode(&neighs); err != nil {
+			return err
+		}
+		if err = vci.UpdateNeighbors(ctx, sandboxID, neighs); err != nil {
+			kataLog.WithField("neighbors", fmt.Sprintf("%+v", neighs)).
+				WithError(err).Error("update neighbors failed")
+		}
 	}
 	return err
 }
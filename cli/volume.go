@@ -0,0 +1,132 @@
+// Copyright (c) 2020 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/kata-containers/runtime/pkg/katautils"
+	vc "github.com/kata-containers/runtime/virtcontainers"
+	"github.com/kata-containers/runtime/virtcontainers/types"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+var kataVolumeCLICommand = cli.Command{
+	Name:  "kata-volume",
+	Usage: "manage the volumes of a running container",
+	Subcommands: []cli.Command{
+		addVolumeCommand,
+		removeVolumeCommand,
+	},
+	Action: func(context *cli.Context) error {
+		return cli.ShowSubcommandHelp(context)
+	},
+}
+
+var addVolumeCommand = cli.Command{
+	Name:  "add",
+	Usage: "add a volume to a running container",
+	ArgsUsage: `add <container-id> <source> <destination>
+
+Where "<source>" is the host file or directory to mount at "<destination>"
+in the container.`,
+	Description: `The add command bind mounts the source at its destination in the
+   container rootfs shared with the guest. The container rootfs must not be
+   a block device, and the hypervisor must support filesystem sharing.`,
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "readonly",
+			Usage: "mount the volume read-only",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		ctx, err := cliContextToContext(context)
+		if err != nil {
+			return err
+		}
+
+		args := context.Args()
+		if len(args) != 3 {
+			return fmt.Errorf("Expecting a container ID, a source and a destination")
+		}
+
+		source, err := filepath.Abs(args.Get(1))
+		if err != nil {
+			return err
+		}
+
+		readonly := context.Bool("readonly")
+		options := []string{"rbind", "rw"}
+		if readonly {
+			options[1] = "ro"
+		}
+
+		mount := vc.Mount{
+			Source:      source,
+			Destination: args.Get(2),
+			Type:        "bind",
+			Options:     options,
+			ReadOnly:    readonly,
+		}
+
+		return volumeModifyCommand(ctx, args.First(), mount, true)
+	},
+}
+
+var removeVolumeCommand = cli.Command{
+	Name:      "remove",
+	Usage:     "remove a volume from a running container",
+	ArgsUsage: `remove <container-id> <destination>`,
+	Action: func(context *cli.Context) error {
+		ctx, err := cliContextToContext(context)
+		if err != nil {
+			return err
+		}
+
+		args := context.Args()
+		if len(args) != 2 {
+			return fmt.Errorf("Expecting a container ID and a destination")
+		}
+
+		return volumeModifyCommand(ctx, args.First(), vc.Mount{Destination: args.Get(1)}, false)
+	},
+}
+
+func volumeModifyCommand(ctx context.Context, containerID string, mount vc.Mount, add bool) error {
+	span, _ := katautils.Trace(ctx, "kata-volume")
+	defer span.Finish()
+
+	status, sandboxID, err := getExistingContainerInfo(ctx, containerID)
+	if err != nil {
+		return err
+	}
+
+	containerID = status.ID
+
+	kataLog = kataLog.WithFields(logrus.Fields{
+		"container":   containerID,
+		"sandbox":     sandboxID,
+		"destination": mount.Destination,
+	})
+
+	setExternalLoggers(ctx, kataLog)
+	span.SetTag("container", containerID)
+	span.SetTag("sandbox", sandboxID)
+
+	// container MUST be running
+	if status.State.State != types.StateRunning {
+		return fmt.Errorf("container %s is not running", containerID)
+	}
+
+	if add {
+		return vci.AddMount(ctx, sandboxID, containerID, mount)
+	}
+
+	return vci.RemoveMount(ctx, sandboxID, containerID, mount.Destination)
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2020 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"testing"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/assert"
+
+	vc "github.com/kata-containers/runtime/virtcontainers"
+	"github.com/kata-containers/runtime/virtcontainers/types"
+)
+
+func TestVolumeCliFunction(t *testing.T) {
+	assert := assert.New(t)
+
+	state := types.ContainerState{
+		State: types.StateRunning,
+	}
+
+	var added vc.Mount
+	var removed string
+
+	testingImpl.AddMountFunc = func(ctx context.Context, sandboxID, containerID string, mount vc.Mount) error {
+		added = mount
+		return nil
+	}
+	testingImpl.RemoveMountFunc = func(ctx context.Context, sandboxID, containerID, destination string) error {
+		removed = destination
+		return nil
+	}
+
+	path, err := createTempContainerIDMapping(testContainerID, testSandboxID)
+	assert.NoError(err)
+	defer os.RemoveAll(path)
+
+	testingImpl.StatusContainerFunc = func(ctx context.Context, sandboxID, containerID string) (vc.ContainerStatus, error) {
+		return newSingleContainerStatus(testContainerID, state, map[string]string{}, &specs.Spec{}), nil
+	}
+
+	defer func() {
+		testingImpl.AddMountFunc = nil
+		testingImpl.RemoveMountFunc = nil
+		testingImpl.StatusContainerFunc = nil
+	}()
+
+	set := flag.NewFlagSet("", 0)
+	set.Bool("readonly", false, "")
+	execCLICommandFunc(assert, addVolumeCommand, set, true)
+	execCLICommandFunc(assert, removeVolumeCommand, set, true)
+
+	set.Parse([]string{"--readonly", testContainerID, "/host/volume", "/volume"})
+	execCLICommandFunc(assert, addVolumeCommand, set, false)
+	assert.Equal(vc.Mount{
+		Source:      "/host/volume",
+		Destination: "/volume",
+		Type:        "bind",
+		Options:     []string{"rbind", "ro"},
+		ReadOnly:    true,
+	}, added)
+
+	set = flag.NewFlagSet("", 0)
+	set.Parse([]string{testContainerID, "/volume"})
+	execCLICommandFunc(assert, removeVolumeCommand, set, false)
+	assert.Equal("/volume", removed)
+
+	// The container must be running.
+	state.State = types.StateStopped
+	execCLICommandFunc(assert, removeVolumeCommand, set, true)
+}
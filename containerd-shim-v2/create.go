@@ -89,6 +89,12 @@ func create(ctx context.Context, s *service, r *taskAPI.CreateTaskRequest) (*con
 		}
 		s.sandbox = sandbox
 
+		// The caller holds s.mu, network changes are applied once the
+		// sandbox creation is done.
+		if err = sandbox.ServeNetworkChanges(&s.mu); err != nil {
+			return nil, err
+		}
+
 	case vc.PodContainer:
 		if s.sandbox == nil {
 			return nil, fmt.Errorf("BUG: Cannot start the container, since the sandbox hasn't been created")
@@ -172,14 +178,24 @@ func loadRuntimeConfig(s *service, r *taskAPI.CreateTaskRequest, anno map[string
 	return &runtimeConfig, nil
 }
 
-func checkAndMount(s *service, r *taskAPI.CreateTaskRequest) (bool, error) {
+// needMount tells if the rootfs of a task is mounted on the host, rather
+// than passed to the VM as a block device.
+func needMount(s *service, r *taskAPI.CreateTaskRequest) bool {
 	if len(r.Rootfs) == 1 {
 		m := r.Rootfs[0]
 
 		if katautils.IsBlockDevice(m.Source) && !s.config.HypervisorConfig.DisableBlockDeviceUse {
-			return false, nil
+			return false
 		}
 	}
+
+	return true
+}
+
+func checkAndMount(s *service, r *taskAPI.CreateTaskRequest) (bool, error) {
+	if !needMount(s, r) {
+		return false, nil
+	}
 	rootfs := filepath.Join(r.Bundle, "rootfs")
 	if err := doMount(r.Rootfs, rootfs); err != nil {
 		return false, err
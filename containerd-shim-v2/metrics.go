@@ -43,6 +43,7 @@ func statsToMetrics(stats *vc.ContainerStats) *cgroups.Metrics {
 	}
 
 	metrics.Network = setNetworkStats(stats.NetworkStats)
+	metrics.Filesystem = setFilesystemStats(stats.FilesystemStats)
 
 	return metrics
 }
@@ -190,3 +191,20 @@ func setNetworkStats(vcNetwork []*vc.NetworkStats) []*cgroups.NetworkStat {
 
 	return networkStats
 }
+
+func setFilesystemStats(vcFilesystem []*vc.FilesystemStats) []*cgroups.FilesystemStat {
+	filesystemStats := make([]*cgroups.FilesystemStat, len(vcFilesystem))
+	for i, v := range vcFilesystem {
+		filesystemStats[i] = &cgroups.FilesystemStat{
+			Path:           v.Path,
+			CapacityBytes:  v.CapacityBytes,
+			AvailableBytes: v.AvailableBytes,
+			UsedBytes:      v.UsedBytes,
+			Inodes:         v.Inodes,
+			InodesFree:     v.InodesFree,
+			InodesUsed:     v.InodesUsed,
+		}
+	}
+
+	return filesystemStats
+}
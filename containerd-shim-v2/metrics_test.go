@@ -54,3 +54,38 @@ func TestStatNetworkMetric(t *testing.T) {
 	metrics := statsToMetrics(&resp)
 	assert.Equal(expectedNetwork, metrics.Network)
 }
+
+func TestStatFilesystemMetric(t *testing.T) {
+	assert := assert.New(t)
+
+	mockFilesystem := []*vc.FilesystemStats{
+		{
+			Path:          "/",
+			CapacityBytes: 4096,
+			UsedBytes:     1024,
+			InodesUsed:    8,
+		},
+	}
+
+	expectedFilesystem := []*cgroups.FilesystemStat{
+		{
+			Path:          "/",
+			CapacityBytes: 4096,
+			UsedBytes:     1024,
+			InodesUsed:    8,
+		},
+	}
+
+	metrics := statsToMetrics(&vc.ContainerStats{
+		FilesystemStats: mockFilesystem,
+	})
+	assert.Equal(expectedFilesystem, metrics.Filesystem)
+
+	// The filesystem stats must survive the trip through the shim API.
+	data, err := metrics.Marshal()
+	assert.NoError(err)
+
+	var decoded cgroups.Metrics
+	assert.NoError(decoded.Unmarshal(data))
+	assert.Equal(expectedFilesystem, decoded.Filesystem)
+}
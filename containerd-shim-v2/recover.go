@@ -0,0 +1,264 @@
+// Copyright (c) 2020 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package containerdshim
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/containerd/containerd/api/types/task"
+	taskAPI "github.com/containerd/containerd/runtime/v2/task"
+	"github.com/sirupsen/logrus"
+
+	vc "github.com/kata-containers/runtime/virtcontainers"
+	"github.com/kata-containers/runtime/virtcontainers/persist"
+	vcAnnotations "github.com/kata-containers/runtime/virtcontainers/pkg/annotations"
+	"github.com/kata-containers/runtime/virtcontainers/types"
+)
+
+// sandboxPersisted tells if a sandbox has been created and stored by a
+// previous shim.
+func sandboxPersisted(sandboxID string) bool {
+	store, err := persist.GetDriver()
+	if err != nil {
+		return false
+	}
+
+	_, err = os.Stat(filepath.Join(store.RunStoragePath(), sandboxID))
+	return err == nil
+}
+
+// execsFile is the file the shim saves its started exec processes in, in
+// the sandbox runtime directory.
+const execsFile = "shim-execs.json"
+
+// execState is the part of a started exec process saved by the shim, for
+// the next shim serving the sandbox to rebuild it.
+type execState struct {
+	// ID is the agent process ID of the exec process.
+	ID       string
+	Stdin    string
+	Stdout   string
+	Stderr   string
+	Terminal bool
+}
+
+func execsPath(sandboxID string) (string, error) {
+	store, err := persist.GetDriver()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(store.RunStoragePath(), sandboxID, execsFile), nil
+}
+
+// saveExecs saves the running exec processes of the containers, indexed by
+// container and exec IDs. s must be locked.
+func saveExecs(s *service) error {
+	states := make(map[string]map[string]execState)
+	for _, c := range s.containers {
+		for execID, e := range c.execs {
+			if e.status != task.StatusRunning {
+				continue
+			}
+
+			if states[c.id] == nil {
+				states[c.id] = make(map[string]execState)
+			}
+			states[c.id][execID] = execState{
+				ID:       e.id,
+				Stdin:    e.tty.stdin,
+				Stdout:   e.tty.stdout,
+				Stderr:   e.tty.stderr,
+				Terminal: e.tty.terminal,
+			}
+		}
+	}
+
+	path, err := execsPath(s.id)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(states)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, data, 0600)
+}
+
+// loadExecs returns the exec processes saved by a previous shim.
+func loadExecs(sandboxID string) (map[string]map[string]execState, error) {
+	path, err := execsPath(sandboxID)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var states map[string]map[string]execState
+	if err := json.Unmarshal(data, &states); err != nil {
+		return nil, err
+	}
+
+	return states, nil
+}
+
+// recoverSandbox re-attaches the shim to a sandbox created by a previous
+// shim, so that the tasks running in the sandbox can be served again. The
+// runtime configuration is loaded the same way the sandbox creation does,
+// from the task being created again.
+func recoverSandbox(s *service, r *taskAPI.CreateTaskRequest) error {
+	ociSpec, _, err := loadSpec(r)
+	if err != nil {
+		return err
+	}
+
+	if _, err := loadRuntimeConfig(s, r, ociSpec.Annotations); err != nil {
+		return err
+	}
+
+	// Pass service's context instead of local ctx, the sandbox lives across
+	// multiple rpc service calls.
+	sandbox, err := vci.FetchSandbox(s.ctx, s.id)
+	if err != nil {
+		return err
+	}
+	s.sandbox = sandbox
+
+	// The network monitor of the sandbox sends its changes to the
+	// previous shim, they are received by this one from now on.
+	if err := sandbox.ServeNetworkChanges(&s.mu); err != nil {
+		return err
+	}
+
+	if sandbox.Status().State.State != types.StateRunning {
+		return nil
+	}
+
+	s.monitor, err = sandbox.Monitor()
+	if err != nil {
+		return err
+	}
+	go watchSandbox(s)
+
+	go watchOOMEvents(s.ctx, s)
+
+	if s.config.EnableShimMetrics {
+		if err := startMetricsServer(s); err != nil {
+			logrus.WithError(err).Warn("failed to start metrics server")
+		}
+	}
+
+	logrus.WithField("sandbox", s.id).Info("sandbox recovered")
+
+	return nil
+}
+
+// recoverContainer rebuilds the shim container of a task created again by
+// containerd from the virtcontainers one, and re-opens the streams of its
+// process with the ones of the new task. The exec processes saved by the
+// previous shim are rebuilt with their former streams.
+func recoverContainer(ctx context.Context, s *service, r *taskAPI.CreateTaskRequest, vcContainer vc.VCContainer) (*container, error) {
+	annotations := vcContainer.GetAnnotations()
+	containerType := vc.ContainerType(annotations[vcAnnotations.ContainerTypeKey])
+
+	status, err := s.sandbox.StatusContainer(r.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := newContainer(s, r, containerType, status.Spec, needMount(s, r))
+	if err != nil {
+		return nil, err
+	}
+
+	switch status.State.State {
+	case types.StateReady:
+		c.status = task.StatusCreated
+	case types.StateRunning, types.StatePaused:
+		c.status = task.StatusRunning
+		if status.State.State == types.StatePaused {
+			c.status = task.StatusPaused
+		}
+
+		// The agent keeps the exit code of a process which exited
+		// while no shim was serving it until it is waited for.
+		if err := attachContainerIO(ctx, s, c); err != nil {
+			return nil, err
+		}
+
+		recoverExecs(ctx, s, c)
+	default:
+		// The previous shim has waited for the container process
+		// and reported its exit code already.
+		c.status = task.StatusStopped
+		c.exit = exitCode255
+		c.exitTime = time.Now()
+		c.exitCh <- c.exit
+	}
+
+	return c, nil
+}
+
+// recoverExecs rebuilds the exec processes of a container saved by the
+// previous shim. The exec processes whose streams can not be opened again
+// are left to the agent, which keeps them running until they exit.
+func recoverExecs(ctx context.Context, s *service, c *container) {
+	states, err := loadExecs(s.id)
+	if err != nil {
+		logrus.WithError(err).WithField("container", c.id).Warn("could not load exec processes")
+		return
+	}
+
+	for execID, state := range states[c.id] {
+		execs := &exec{
+			container: c,
+			id:        state.ID,
+			tty: &tty{
+				stdin:    state.Stdin,
+				stdout:   state.Stdout,
+				stderr:   state.Stderr,
+				terminal: state.Terminal,
+			},
+			exitCode:    exitCode255,
+			exitIOch:    make(chan struct{}),
+			stdinCloser: make(chan struct{}),
+			exitCh:      make(chan uint32, 1),
+			status:      task.StatusRunning,
+		}
+
+		c.execs[execID] = execs
+		if err := attachExecIO(ctx, s, c, execs, execID); err != nil {
+			logrus.WithError(err).WithFields(logrus.Fields{
+				"container": c.id,
+				"exec":      execID,
+			}).Warn("could not recover exec process")
+			delete(c.execs, execID)
+		}
+	}
+}
+
+// findContainer returns the container of the sandbox with the given ID, if any.
+func findContainer(sandbox vc.VCSandbox, containerID string) vc.VCContainer {
+	for _, c := range sandbox.GetAllContainers() {
+		if c.ID() == containerID {
+			return c
+		}
+	}
+
+	return nil
+}
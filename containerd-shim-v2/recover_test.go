@@ -0,0 +1,242 @@
+// Copyright (c) 2020 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package containerdshim
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	containerd_types "github.com/containerd/containerd/api/types"
+	"github.com/containerd/containerd/api/types/task"
+	taskAPI "github.com/containerd/containerd/runtime/v2/task"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+
+	ktu "github.com/kata-containers/runtime/pkg/katatestutils"
+	vc "github.com/kata-containers/runtime/virtcontainers"
+	"github.com/kata-containers/runtime/virtcontainers/persist"
+	"github.com/kata-containers/runtime/virtcontainers/persist/fs"
+	vcAnnotations "github.com/kata-containers/runtime/virtcontainers/pkg/annotations"
+	"github.com/kata-containers/runtime/virtcontainers/pkg/vcmock"
+	"github.com/kata-containers/runtime/virtcontainers/types"
+)
+
+// writeRecoverBundle writes the spec of a task created again in
+// bundlePath, which points to a runtime configuration written in dir.
+func writeRecoverBundle(dir, bundlePath string, enableShimMetrics bool) error {
+	configPath, err := createAllRuntimeConfigFiles(dir, "qemu")
+	if err != nil {
+		return err
+	}
+
+	if enableShimMetrics {
+		data, err := ioutil.ReadFile(configPath)
+		if err != nil {
+			return err
+		}
+
+		data = []byte(strings.Replace(string(data), "[runtime]", "[runtime]\n\tenable_shim_metrics = true", 1))
+		if err := ioutil.WriteFile(configPath, data, testFileMode); err != nil {
+			return err
+		}
+	}
+
+	spec := specs.Spec{
+		Process: &specs.Process{
+			Capabilities: &specs.LinuxCapabilities{},
+		},
+		Annotations: map[string]string{
+			testContainerTypeAnnotation:        testContainerTypeContainer,
+			vcAnnotations.SandboxConfigPathKey: configPath,
+		},
+	}
+
+	return writeOCIConfigFile(spec, filepath.Join(bundlePath, "config.json"))
+}
+
+func TestRecoverSandbox(t *testing.T) {
+	assert := assert.New(t)
+
+	bundlePath, err := ioutil.TempDir("", "kata-recover")
+	assert.NoError(err)
+	defer os.RemoveAll(bundlePath)
+
+	configDir, err := ioutil.TempDir("", "kata-recover-config")
+	assert.NoError(err)
+	defer os.RemoveAll(configDir)
+
+	assert.NoError(writeRecoverBundle(configDir, bundlePath, false))
+
+	sandbox := &vcmock.Sandbox{
+		MockID: testSandboxID,
+	}
+	sandbox.MockContainers = []*vcmock.Container{
+		{
+			MockID:      testContainerID,
+			MockSandbox: sandbox,
+			MockAnnotations: map[string]string{
+				vcAnnotations.BundlePathKey:    bundlePath,
+				vcAnnotations.ContainerTypeKey: string(vc.PodContainer),
+			},
+		},
+	}
+
+	testingImpl.FetchSandboxFunc = func(ctx context.Context, id string) (vc.VCSandbox, error) {
+		return sandbox, nil
+	}
+	defer func() {
+		testingImpl.FetchSandboxFunc = nil
+	}()
+
+	persist.EnableMockTesting()
+	sandboxDir := filepath.Join(fs.MockRunStoragePath(), testSandboxID)
+
+	s := &service{
+		id:         testSandboxID,
+		containers: make(map[string]*container),
+		ctx:        context.Background(),
+	}
+
+	// Nothing to recover from a sandbox which has not been stored.
+	assert.False(sandboxPersisted(testSandboxID))
+
+	assert.NoError(os.MkdirAll(sandboxDir, 0700))
+	defer os.RemoveAll(sandboxDir)
+	assert.True(sandboxPersisted(testSandboxID))
+
+	_, err = s.Create(context.Background(), &taskAPI.CreateTaskRequest{
+		ID:     testContainerID,
+		Bundle: bundlePath,
+		Stdout: "/run/stdout",
+	})
+	assert.NoError(err)
+	assert.Equal(sandbox, s.sandbox)
+
+	// The runtime configuration is loaded again.
+	assert.NotNil(s.config)
+
+	c, err := s.getContainer(testContainerID)
+	assert.NoError(err)
+	assert.Equal(bundlePath, c.bundle)
+	assert.Equal("/run/stdout", c.stdout)
+	assert.Equal(vc.PodContainer, c.cType)
+
+	// The mock sandbox does not report the container state, which is
+	// handled as a container stopped by the previous shim.
+	assert.Equal(task.StatusStopped, c.status)
+	assert.Equal(uint32(exitCode255), <-c.exitCh)
+}
+
+func TestRecoverSandboxBlockDevice(t *testing.T) {
+	if tc.NotValid(ktu.NeedRoot()) {
+		t.Skip(ktu.TestDisabledNeedRoot)
+	}
+
+	assert := assert.New(t)
+
+	bundlePath, err := ioutil.TempDir("", "kata-recover")
+	assert.NoError(err)
+	defer os.RemoveAll(bundlePath)
+
+	configDir, err := ioutil.TempDir("", "kata-recover-config")
+	assert.NoError(err)
+	defer os.RemoveAll(configDir)
+
+	assert.NoError(writeRecoverBundle(configDir, bundlePath, true))
+
+	device := filepath.Join(configDir, "rootfs-device")
+	assert.NoError(unix.Mknod(device, unix.S_IFBLK|0600, int(unix.Mkdev(7, 0))))
+
+	sandbox := &vcmock.Sandbox{
+		MockID:    testSandboxID,
+		MockState: types.SandboxState{State: types.StateRunning},
+	}
+	sandbox.MockContainers = []*vcmock.Container{
+		{
+			MockID:      testContainerID,
+			MockSandbox: sandbox,
+			MockAnnotations: map[string]string{
+				vcAnnotations.BundlePathKey:    bundlePath,
+				vcAnnotations.ContainerTypeKey: string(vc.PodContainer),
+			},
+			MockState: types.ContainerState{State: types.StateRunning},
+		},
+	}
+
+	testingImpl.FetchSandboxFunc = func(ctx context.Context, id string) (vc.VCSandbox, error) {
+		return sandbox, nil
+	}
+	defer func() {
+		testingImpl.FetchSandboxFunc = nil
+	}()
+
+	persist.EnableMockTesting()
+	sandboxDir := filepath.Join(fs.MockRunStoragePath(), testSandboxID)
+	assert.NoError(os.MkdirAll(sandboxDir, 0700))
+	defer os.RemoveAll(sandboxDir)
+
+	// An exec process started through the previous shim.
+	s := &service{
+		id:         testSandboxID,
+		containers: make(map[string]*container),
+		ctx:        context.Background(),
+	}
+	s.containers[testContainerID] = &container{
+		id: testContainerID,
+		execs: map[string]*exec{
+			"exec": {
+				id:     "agent-exec",
+				tty:    &tty{},
+				status: task.StatusRunning,
+			},
+		},
+	}
+	assert.NoError(saveExecs(s))
+
+	s = &service{
+		id:         testSandboxID,
+		containers: make(map[string]*container),
+		ctx:        context.Background(),
+	}
+	defer func() {
+		if s.metricsServer != nil {
+			s.metricsServer.Close()
+		}
+	}()
+
+	s.mu.Lock()
+	assert.NoError(recoverSandbox(s, &taskAPI.CreateTaskRequest{
+		ID:     testContainerID,
+		Bundle: bundlePath,
+	}))
+
+	// The metrics are served again.
+	assert.NotNil(s.metricsServer)
+
+	c, err := recoverContainer(context.Background(), s, &taskAPI.CreateTaskRequest{
+		ID:     testContainerID,
+		Bundle: bundlePath,
+		Rootfs: []*containerd_types.Mount{{Source: device, Type: "ext4"}},
+	}, sandbox.MockContainers[0])
+	assert.NoError(err)
+	s.containers[testContainerID] = c
+	s.mu.Unlock()
+
+	assert.Equal(task.StatusRunning, c.status)
+
+	execs, err := c.getExec("exec")
+	assert.NoError(err)
+	assert.Equal("agent-exec", execs.id)
+
+	// The mock agent reports the exec process has exited.
+	assert.Equal(uint32(0), <-execs.exitCh)
+	assert.Equal(uint32(0), <-c.exitCh)
+}
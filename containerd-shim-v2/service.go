@@ -8,6 +8,8 @@ package containerdshim
 import (
 	"context"
 	"io/ioutil"
+	"net"
+	"net/http"
 	"os"
 	sysexec "os/exec"
 	"sync"
@@ -133,6 +135,9 @@ type service struct {
 	events     chan interface{}
 	monitor    chan error
 
+	metricsServer   *http.Server
+	metricsListener net.Listener
+
 	cancel func()
 
 	ec chan exit
@@ -379,6 +384,28 @@ func (s *service) Create(ctx context.Context, r *taskAPI.CreateTaskRequest) (_ *
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	// Only the shim daemon serves the task API, a task created again
+	// after its shim has been killed re-attaches to its sandbox.
+	if s.sandbox == nil && sandboxPersisted(s.id) {
+		if err := recoverSandbox(s, r); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, ok := s.containers[r.ID]; !ok && s.sandbox != nil {
+		if vcContainer := findContainer(s.sandbox, r.ID); vcContainer != nil {
+			c, err := recoverContainer(ctx, s, r, vcContainer)
+			if err != nil {
+				return nil, err
+			}
+			s.containers[r.ID] = c
+
+			return &taskAPI.CreateTaskResponse{
+				Pid: s.pid,
+			}, nil
+		}
+	}
+
 	type Result struct {
 		container *container
 		err       error
@@ -458,6 +485,9 @@ func (s *service) Start(ctx context.Context, r *taskAPI.StartRequest) (_ *taskAP
 		if err != nil {
 			return nil, errdefs.ToGRPC(err)
 		}
+		if err := saveExecs(s); err != nil {
+			logrus.WithError(err).WithField("exec", r.ExecID).Warn("could not save exec processes")
+		}
 		s.send(&eventstypes.TaskExecStarted{
 			ContainerID: c.id,
 			ExecID:      r.ExecID,
@@ -512,6 +542,9 @@ func (s *service) Delete(ctx context.Context, r *taskAPI.DeleteRequest) (_ *task
 	}
 
 	delete(c.execs, r.ExecID)
+	if err := saveExecs(s); err != nil {
+		logrus.WithError(err).WithField("exec", r.ExecID).Warn("could not save exec processes")
+	}
 
 	return &taskAPI.DeleteResponse{
 		ExitStatus: uint32(execs.exitCode),
@@ -873,6 +906,7 @@ func (s *service) Shutdown(ctx context.Context, r *taskAPI.ShutdownRequest) (_ *
 		s.mu.Unlock()
 		return empty, nil
 	}
+	stopMetricsServer(s)
 	s.mu.Unlock()
 
 	span.Finish()
@@ -0,0 +1,184 @@
+// Copyright (c) 2020 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package containerdshim
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/prometheus/procfs"
+	"github.com/sirupsen/logrus"
+
+	vc "github.com/kata-containers/runtime/virtcontainers"
+	"github.com/kata-containers/runtime/virtcontainers/persist"
+	"github.com/kata-containers/runtime/virtcontainers/pkg/metrics"
+)
+
+// metricsSocket is the unix socket, in the sandbox directory, the shim
+// serves Prometheus metrics on.
+const metricsSocket = "shim-metrics.sock"
+
+// startMetricsServer serves the sandbox metrics over HTTP, it must be called
+// once the sandbox is running.
+func startMetricsServer(s *service) error {
+	store, err := persist.GetDriver()
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(store.RunStoragePath(), s.sandbox.ID(), metricsSocket)
+
+	// The socket may be left by a previous shim serving the same sandbox.
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		var buf bytes.Buffer
+		s.mu.Lock()
+		err := writeSandboxMetrics(s, &buf)
+		s.mu.Unlock()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", metrics.ContentType)
+		w.Write(buf.Bytes())
+	})
+
+	server := &http.Server{Handler: mux}
+	s.metricsServer = server
+	s.metricsListener = listener
+
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			logrus.WithError(err).Warn("metrics server stopped")
+		}
+	}()
+
+	logrus.WithField("socket", path).Info("serving metrics")
+
+	return nil
+}
+
+// stopMetricsServer closes the metrics server and its socket, if any.
+func stopMetricsServer(s *service) {
+	if s.metricsServer == nil {
+		return
+	}
+
+	if err := s.metricsServer.Close(); err != nil {
+		logrus.WithError(err).Warn("failed to close metrics server")
+	}
+
+	// The server only closes the listener once serving it, which
+	// may not have happened yet.
+	s.metricsListener.Close()
+
+	s.metricsServer = nil
+	s.metricsListener = nil
+}
+
+// writeSandboxMetrics must be called with s.mu held, the sandbox being
+// used by the task API concurrently.
+func writeSandboxMetrics(s *service, w io.Writer) error {
+	sandbox := s.sandbox
+	if sandbox == nil {
+		return nil
+	}
+
+	var cpu, memory, pids []metrics.Sample
+	for id := range s.containers {
+		stats, err := sandbox.StatsContainer(id)
+		if err != nil || stats.CgroupStats == nil {
+			continue
+		}
+
+		labels := map[string]string{"container": id}
+		cgroupStats := stats.CgroupStats
+		cpu = append(cpu, metrics.Sample{Labels: labels, Value: float64(cgroupStats.CPUStats.CPUUsage.TotalUsage) / 1e9})
+		memory = append(memory, metrics.Sample{Labels: labels, Value: float64(cgroupStats.MemoryStats.Usage.Usage)})
+		pids = append(pids, metrics.Sample{Labels: labels, Value: float64(cgroupStats.PidsStats.Current)})
+	}
+
+	if err := metrics.WriteMetric(w, "kata_guest_cpu_seconds_total",
+		"CPU time consumed by the container in the guest.", metrics.Counter, cpu); err != nil {
+		return err
+	}
+
+	if err := metrics.WriteMetric(w, "kata_guest_memory_usage_bytes",
+		"Memory used by the container in the guest.", metrics.Gauge, memory); err != nil {
+		return err
+	}
+
+	if err := metrics.WriteMetric(w, "kata_guest_pids",
+		"Number of processes of the container in the guest.", metrics.Gauge, pids); err != nil {
+		return err
+	}
+
+	hypervisorPids, err := sandbox.GetHypervisorPids()
+	if err != nil {
+		logrus.WithError(err).Warn("failed to get hypervisor pids")
+	}
+
+	var processes []procfs.Proc
+	for _, pid := range hypervisorPids {
+		proc, err := procfs.NewProc(pid)
+		if err != nil {
+			continue
+		}
+		processes = append(processes, proc)
+	}
+
+	if self, err := procfs.Self(); err == nil {
+		processes = append(processes, self)
+	}
+
+	if err := writeProcessMetrics(w, processes); err != nil {
+		return err
+	}
+
+	return vc.WriteMetrics(w)
+}
+
+// writeProcessMetrics writes the CPU and memory usage of the host processes
+// running the sandbox: the hypervisor, its helper daemons and the shim.
+func writeProcessMetrics(w io.Writer, processes []procfs.Proc) error {
+	var cpu, rss []metrics.Sample
+	for _, proc := range processes {
+		stat, err := proc.NewStat()
+		if err != nil {
+			continue
+		}
+
+		labels := map[string]string{
+			"pid":     strconv.Itoa(proc.PID),
+			"process": stat.Comm,
+		}
+		cpu = append(cpu, metrics.Sample{Labels: labels, Value: stat.CPUTime()})
+		rss = append(rss, metrics.Sample{Labels: labels, Value: float64(stat.ResidentMemory())})
+	}
+
+	if err := metrics.WriteMetric(w, "kata_host_process_cpu_seconds_total",
+		"CPU time consumed by the host processes of the sandbox.", metrics.Counter, cpu); err != nil {
+		return err
+	}
+
+	return metrics.WriteMetric(w, "kata_host_process_resident_memory_bytes",
+		"Resident memory of the host processes of the sandbox.", metrics.Gauge, rss)
+}
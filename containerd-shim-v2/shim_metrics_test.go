@@ -0,0 +1,79 @@
+// Copyright (c) 2020 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package containerdshim
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kata-containers/runtime/virtcontainers/persist"
+	"github.com/kata-containers/runtime/virtcontainers/persist/fs"
+	"github.com/kata-containers/runtime/virtcontainers/pkg/vcmock"
+)
+
+func TestWriteSandboxMetrics(t *testing.T) {
+	assert := assert.New(t)
+
+	s := &service{
+		id:         testSandboxID,
+		containers: make(map[string]*container),
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(writeSandboxMetrics(s, &buf))
+	assert.Empty(buf.String())
+
+	s.sandbox = &vcmock.Sandbox{
+		MockID: testSandboxID,
+	}
+	s.containers[testContainerID] = &container{id: testContainerID}
+
+	assert.NoError(writeSandboxMetrics(s, &buf))
+	out := buf.String()
+	assert.Contains(out, "# TYPE kata_guest_cpu_seconds_total counter")
+	assert.Contains(out, fmt.Sprintf(`kata_host_process_resident_memory_bytes{pid="%d"`, os.Getpid()))
+	assert.Contains(out, "# TYPE kata_agent_rpc_duration_seconds histogram")
+}
+
+func TestStopMetricsServer(t *testing.T) {
+	assert := assert.New(t)
+
+	persist.EnableMockTesting()
+	sandboxDir := filepath.Join(fs.MockRunStoragePath(), testSandboxID)
+	assert.NoError(os.MkdirAll(sandboxDir, 0700))
+	defer os.RemoveAll(sandboxDir)
+
+	s := &service{
+		id:         testSandboxID,
+		containers: make(map[string]*container),
+		sandbox: &vcmock.Sandbox{
+			MockID: testSandboxID,
+		},
+	}
+
+	// Nothing to stop when the server has not been started.
+	stopMetricsServer(s)
+
+	assert.NoError(startMetricsServer(s))
+	assert.NotNil(s.metricsServer)
+
+	path := filepath.Join(sandboxDir, metricsSocket)
+	conn, err := net.Dial("unix", path)
+	assert.NoError(err)
+	conn.Close()
+
+	stopMetricsServer(s)
+	assert.Nil(s.metricsServer)
+
+	_, err = net.Dial("unix", path)
+	assert.Error(err)
+}
@@ -11,6 +11,7 @@ import (
 
 	"github.com/containerd/containerd/api/types/task"
 	"github.com/kata-containers/runtime/pkg/katautils"
+	"github.com/sirupsen/logrus"
 )
 
 func startContainer(ctx context.Context, s *service, c *container) error {
@@ -40,6 +41,12 @@ func startContainer(ctx context.Context, s *service, c *container) error {
 		// We don't rely on the context passed to startContainer as it can be cancelled after
 		// this rpc call.
 		go watchOOMEvents(s.ctx, s)
+
+		if s.config != nil && s.config.EnableShimMetrics {
+			if err := startMetricsServer(s); err != nil {
+				logrus.WithError(err).Warn("failed to start metrics server")
+			}
+		}
 	} else {
 		_, err := s.sandbox.StartContainer(c.id)
 		if err != nil {
@@ -57,6 +64,12 @@ func startContainer(ctx context.Context, s *service, c *container) error {
 
 	c.status = task.StatusRunning
 
+	return attachContainerIO(ctx, s, c)
+}
+
+// attachContainerIO connects the container process streams to the task
+// fifos, and waits for the container process in the background.
+func attachContainerIO(ctx context.Context, s *service, c *container) error {
 	stdin, stdout, stderr, err := s.sandbox.IOStream(c.id, c.id)
 	if err != nil {
 		return err
@@ -112,16 +125,26 @@ func startExec(ctx context.Context, s *service, containerID, execID string) (*ex
 		}
 	}
 
+	if err = attachExecIO(ctx, s, c, execs, execID); err != nil {
+		return nil, err
+	}
+
+	return execs, nil
+}
+
+// attachExecIO connects the exec process streams to the exec fifos, and
+// waits for the exec process in the background.
+func attachExecIO(ctx context.Context, s *service, c *container, execs *exec, execID string) error {
 	stdin, stdout, stderr, err := s.sandbox.IOStream(c.id, execs.id)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	execs.stdinPipe = stdin
 
 	tty, err := newTtyIO(ctx, execs.tty.stdin, execs.tty.stdout, execs.tty.stderr, execs.tty.terminal)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	execs.ttyio = tty
 
@@ -129,5 +152,5 @@ func startExec(ctx context.Context, s *service, containerID, execID string) (*ex
 
 	go wait(s, c, execID)
 
-	return execs, nil
+	return nil
 }
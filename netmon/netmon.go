@@ -17,6 +17,8 @@ import (
 	"os/exec"
 	"os/signal"
 	"path/filepath"
+	"reflect"
+	"sort"
 	"strings"
 	"syscall"
 	"time"
@@ -36,6 +38,8 @@ const (
 	kataCLIAddIfaceCmd   = "add-iface"
 	kataCLIDelIfaceCmd   = "del-iface"
 	kataCLIUpdtRoutesCmd = "update-routes"
+	kataCLIUpdtIfaceCmd  = "update-iface"
+	kataCLIUpdtNeighsCmd = "update-neighbors"
 
 	kataSuffix = "kata"
 
@@ -45,23 +49,41 @@ const (
 	sharedFile      = "shared.json"
 	storageFilePerm = os.FileMode(0640)
 	storageDirPerm  = os.FileMode(0750)
+
+	// controlBatchDelay is how long the changes are batched before being
+	// sent through the control socket, so that a burst of netlink events
+	// results in a single request.
+	controlBatchDelay = 100 * time.Millisecond
+
+	// controlTimeout bounds the time the runtime takes to apply a batch
+	// of changes, which may involve hotplugging several interfaces.
+	controlTimeout = 60 * time.Second
 )
 
 var (
 	// version is the netmon version. This variable is populated at build time.
 	version = "unknown"
 
-	// For simplicity the code will only focus on IPv4 addresses for now.
+	// Both IPv4 and IPv6 are monitored, for dual-stack sandboxes.
 	netlinkFamily = netlink.FAMILY_ALL
 
 	storageParentPath = "/var/run/kata-containers/netmon/sbs"
 )
 
 type netmonParams struct {
-	sandboxID   string
-	runtimePath string
-	debug       bool
-	logLevel    string
+	sandboxID     string
+	runtimePath   string
+	controlSocket string
+	debug         bool
+	logLevel      string
+}
+
+// queuedChange is a change waiting to be sent through the control socket.
+// undo reverts the internal list of interfaces if the runtime fails to
+// apply the change.
+type queuedChange struct {
+	change vcTypes.NetmonChange
+	undo   func()
 }
 
 type netmon struct {
@@ -78,7 +100,22 @@ type netmon struct {
 	rtUpdateCh chan netlink.RouteUpdate
 	rtDoneCh   chan struct{}
 
+	addrUpdateCh chan netlink.AddrUpdate
+	addrDoneCh   chan struct{}
+
+	neighUpdateCh chan netlink.NeighUpdate
+	neighDoneCh   chan struct{}
+
+	// netNeighs tracks the permanent neighbors already sent to the
+	// runtime, indexed by link index and IP address, so that they are
+	// not sent again on every neighbor event.
+	netNeighs map[string]string
+
 	netHandler *netlink.Handle
+
+	// changes are batched until they are sent through the control socket.
+	changes     []queuedChange
+	controlConn net.Conn
 }
 
 var netmonLog = logrus.New()
@@ -90,8 +127,9 @@ func printVersion() {
 const componentDescription = `is a network monitoring process that is intended to be started in the
 appropriate network namespace so that it can listen to any event related to
 link and routes. Whenever a new interface or route is created/updated, it is
-responsible for calling into the kata-runtime CLI to ask for the actual
-creation/update of the given interface or route.
+responsible for asking kata-runtime for the actual creation/update of the
+given interface or route, either through the runtime control socket or by
+calling into the kata-runtime CLI.
 `
 
 func printComponentDescription() {
@@ -110,6 +148,7 @@ func parseOptions() netmonParams {
 	flag.BoolVar(&version, "version", false, "")
 	flag.StringVar(&params.sandboxID, "s", "", "sandbox id (required)")
 	flag.StringVar(&params.runtimePath, "r", "", "runtime path (required)")
+	flag.StringVar(&params.controlSocket, "c", "", "runtime control socket, the runtime CLI is called if empty")
 	flag.StringVar(&params.logLevel, "log", "warn",
 		"log messages above specified level: debug, warn, error, fatal or panic")
 
@@ -148,15 +187,20 @@ func newNetmon(params netmonParams) (*netmon, error) {
 	}
 
 	n := &netmon{
-		netmonParams: params,
-		storagePath:  filepath.Join(storageParentPath, params.sandboxID),
-		sharedFile:   filepath.Join(storageParentPath, params.sandboxID, sharedFile),
-		netIfaces:    make(map[int]vcTypes.Interface),
-		linkUpdateCh: make(chan netlink.LinkUpdate),
-		linkDoneCh:   make(chan struct{}),
-		rtUpdateCh:   make(chan netlink.RouteUpdate),
-		rtDoneCh:     make(chan struct{}),
-		netHandler:   handler,
+		netmonParams:  params,
+		storagePath:   filepath.Join(storageParentPath, params.sandboxID),
+		sharedFile:    filepath.Join(storageParentPath, params.sandboxID, sharedFile),
+		netIfaces:     make(map[int]vcTypes.Interface),
+		linkUpdateCh:  make(chan netlink.LinkUpdate),
+		linkDoneCh:    make(chan struct{}),
+		rtUpdateCh:    make(chan netlink.RouteUpdate),
+		rtDoneCh:      make(chan struct{}),
+		addrUpdateCh:  make(chan netlink.AddrUpdate),
+		addrDoneCh:    make(chan struct{}),
+		neighUpdateCh: make(chan netlink.NeighUpdate),
+		neighDoneCh:   make(chan struct{}),
+		netNeighs:     make(map[string]string),
+		netHandler:    handler,
 	}
 
 	if err := os.MkdirAll(n.storagePath, storageDirPerm); err != nil {
@@ -167,10 +211,15 @@ func newNetmon(params netmonParams) (*netmon, error) {
 }
 
 func (n *netmon) cleanup() {
+	if n.controlConn != nil {
+		n.controlConn.Close()
+	}
 	os.RemoveAll(n.storagePath)
 	n.netHandler.Delete()
 	close(n.linkDoneCh)
 	close(n.rtDoneCh)
+	close(n.addrDoneCh)
+	close(n.neighDoneCh)
 }
 
 // setupSignalHandler sets up signal handling, starting a go routine to deal
@@ -238,9 +287,10 @@ func (n *netmon) setupLogger() error {
 	netmonLog.AddHook(hook)
 
 	announceFields := logrus.Fields{
-		"runtime-path": n.runtimePath,
-		"debug":        n.debug,
-		"log-level":    n.logLevel,
+		"runtime-path":   n.runtimePath,
+		"control-socket": n.controlSocket,
+		"debug":          n.debug,
+		"log-level":      n.logLevel,
 	}
 
 	n.logger().WithFields(announceFields).Info("announce")
@@ -253,7 +303,15 @@ func (n *netmon) listenNetlinkEvents() error {
 		return err
 	}
 
-	return netlink.RouteSubscribe(n.rtUpdateCh, n.rtDoneCh)
+	if err := netlink.RouteSubscribe(n.rtUpdateCh, n.rtDoneCh); err != nil {
+		return err
+	}
+
+	if err := netlink.AddrSubscribe(n.addrUpdateCh, n.addrDoneCh); err != nil {
+		return err
+	}
+
+	return netlink.NeighSubscribe(n.neighUpdateCh, n.neighDoneCh)
 }
 
 // convertInterface converts a link and its IP addresses as defined by netlink
@@ -272,6 +330,10 @@ func convertInterface(linkAttrs *netlink.LinkAttrs, linkType string, addrs []net
 			continue
 		}
 
+		if !vcTypes.IsGuestAddr(addr) {
+			continue
+		}
+
 		netMask, _ := addr.Mask.Size()
 
 		ipAddr := &vcTypes.IPAddress{
@@ -359,11 +421,48 @@ func convertRoutes(netRoutes []netlink.Route) []vcTypes.Route {
 		routes = append(routes, route)
 	}
 
+	// The on-link routes must be set before the routes going through a
+	// gateway they lead to.
+	sort.SliceStable(routes, func(i, j int) bool {
+		return routes[i].Gateway == "" && routes[j].Gateway != ""
+	})
+
 	netmonLog.WithField("routes", routes).Debug("Routes converted")
 
 	return routes
 }
 
+// convertNeighbor converts a neighbor as defined by netlink package, into
+// the ARPNeighbor structure format expected by kata-runtime to describe a
+// static neighbor of the given device.
+func convertNeighbor(neigh netlink.Neigh, device string) vcTypes.ARPNeighbor {
+	arpNeigh := vcTypes.ARPNeighbor{
+		ToIPAddress: &vcTypes.IPAddress{
+			Family:  netlink.FAMILY_V4,
+			Address: neigh.IP.String(),
+		},
+		Device: device,
+		State:  neigh.State,
+		Flags:  neigh.Flags,
+	}
+
+	if neigh.IP.To4() == nil {
+		arpNeigh.ToIPAddress.Family = netlink.FAMILY_V6
+	}
+
+	if neigh.HardwareAddr != nil {
+		arpNeigh.LLAddr = neigh.HardwareAddr.String()
+	}
+
+	netmonLog.WithField("neighbor", arpNeigh).Debug("Neighbor converted")
+
+	return arpNeigh
+}
+
+func neighborKey(index int, ip net.IP) string {
+	return fmt.Sprintf("%d/%s", index, ip)
+}
+
 // scanNetwork lists all the interfaces it can find inside the current
 // network namespace, and store them in-memory to keep track of them.
 func (n *netmon) scanNetwork() error {
@@ -385,6 +484,19 @@ func (n *netmon) scanNetwork() error {
 
 		iface := convertInterface(linkAttrs, link.Type(), addrs)
 		n.netIfaces[linkAttrs.Index] = iface
+
+		// The permanent neighbors found at this point have already been
+		// sent by the runtime when the sandbox was started.
+		neighs, err := n.netHandler.NeighList(linkAttrs.Index, netlinkFamily)
+		if err != nil {
+			return err
+		}
+
+		for _, neigh := range neighs {
+			if neigh.State == netlink.NUD_PERMANENT {
+				n.netNeighs[neighborKey(linkAttrs.Index, neigh.IP)] = neigh.HardwareAddr.String()
+			}
+		}
 	}
 
 	n.logger().Debug("Network scanned")
@@ -444,9 +556,155 @@ func (n *netmon) updateRoutesCLI(routes []vcTypes.Route) error {
 	return n.execKataCmd(kataCLIUpdtRoutesCmd)
 }
 
+func (n *netmon) updateInterfaceCLI(iface vcTypes.Interface) error {
+	if err := n.storeDataToSend(iface); err != nil {
+		return err
+	}
+
+	return n.execKataCmd(kataCLIUpdtIfaceCmd)
+}
+
+func (n *netmon) updateNeighborsCLI(neighs []vcTypes.ARPNeighbor) error {
+	if err := n.storeDataToSend(neighs); err != nil {
+		return err
+	}
+
+	return n.execKataCmd(kataCLIUpdtNeighsCmd)
+}
+
+// queueChange batches a change to be sent through the control socket. As
+// the routes are updated as a whole, a routes update replaces the ones
+// already queued.
+func (n *netmon) queueChange(change vcTypes.NetmonChange, undo func()) {
+	if change.Op == vcTypes.NetmonUpdateRoutes {
+		var changes []queuedChange
+		for _, c := range n.changes {
+			if c.change.Op != vcTypes.NetmonUpdateRoutes {
+				changes = append(changes, c)
+			}
+		}
+		n.changes = changes
+	}
+
+	n.changes = append(n.changes, queuedChange{change: change, undo: undo})
+}
+
+// sendChanges sends the batched changes through the control socket, and
+// waits for the runtime to acknowledge each of them. The changes the
+// runtime failed to apply are reverted from the internal list of
+// interfaces.
+func (n *netmon) sendChanges() error {
+	if len(n.changes) == 0 {
+		return nil
+	}
+
+	changes := n.changes
+	n.changes = nil
+
+	req := vcTypes.NetmonRequest{}
+	for _, c := range changes {
+		req.Changes = append(req.Changes, c.change)
+	}
+
+	resp, err := n.control(req)
+	if err != nil {
+		return err
+	}
+
+	if len(resp.Errors) != len(changes) {
+		return fmt.Errorf("Runtime acknowledged %d changes out of %d", len(resp.Errors), len(changes))
+	}
+
+	for i, e := range resp.Errors {
+		if e == "" {
+			continue
+		}
+
+		n.logger().WithField("operation", changes[i].change.Op).WithError(errors.New(e)).Error("Runtime failed to apply network change")
+		if changes[i].undo != nil {
+			changes[i].undo()
+		}
+	}
+
+	return nil
+}
+
+// control sends a request through the control socket and returns the
+// response of the runtime. The connection is dropped on error, and opened
+// again by the next request.
+func (n *netmon) control(req vcTypes.NetmonRequest) (resp vcTypes.NetmonResponse, err error) {
+	if n.controlConn == nil {
+		if n.controlConn, err = net.Dial("unix", n.controlSocket); err != nil {
+			n.controlConn = nil
+			return resp, err
+		}
+	}
+
+	defer func() {
+		if err != nil {
+			n.controlConn.Close()
+			n.controlConn = nil
+		}
+	}()
+
+	if err = n.controlConn.SetDeadline(time.Now().Add(controlTimeout)); err != nil {
+		return resp, err
+	}
+
+	n.logger().WithField("changes", len(req.Changes)).Debug("Sending network changes to the runtime")
+
+	if err = json.NewEncoder(n.controlConn).Encode(req); err != nil {
+		return resp, err
+	}
+
+	err = json.NewDecoder(n.controlConn).Decode(&resp)
+
+	return resp, err
+}
+
+func (n *netmon) addInterface(iface vcTypes.Interface, index int) error {
+	if n.controlSocket == "" {
+		if err := n.addInterfaceCLI(iface); err != nil {
+			return err
+		}
+	} else {
+		n.queueChange(vcTypes.NetmonChange{
+			Op:        vcTypes.NetmonAddInterface,
+			Interface: &iface,
+		}, func() {
+			delete(n.netIfaces, index)
+		})
+	}
+
+	// Add the interface to the internal list.
+	n.netIfaces[index] = iface
+
+	return nil
+}
+
+func (n *netmon) delInterface(iface vcTypes.Interface, index int) error {
+	if n.controlSocket == "" {
+		if err := n.delInterfaceCLI(iface); err != nil {
+			return err
+		}
+	} else {
+		n.queueChange(vcTypes.NetmonChange{
+			Op:        vcTypes.NetmonDelInterface,
+			Interface: &iface,
+		}, func() {
+			n.netIfaces[index] = iface
+		})
+	}
+
+	// Delete the interface from the internal list.
+	delete(n.netIfaces, index)
+
+	return nil
+}
+
 func (n *netmon) updateRoutes() error {
 	// Get all the routes.
-	netlinkRoutes, err := n.netHandler.RouteList(nil, netlinkFamily)
+	netlinkRoutes, err := vcTypes.ListRoutes(n.netHandler, nil)
 	if err != nil {
 		return err
 	}
@@ -454,17 +712,147 @@ func (n *netmon) updateRoutes() error {
 	// Translate them into Route structures.
 	routes := convertRoutes(netlinkRoutes)
 
+	if n.controlSocket != "" {
+		n.queueChange(vcTypes.NetmonChange{
+			Op:     vcTypes.NetmonUpdateRoutes,
+			Routes: routes,
+		}, nil)
+		return nil
+	}
+
 	// Update the routes through the Kata CLI.
 	return n.updateRoutesCLI(routes)
 }
 
-func (n *netmon) handleRTMNewAddr(ev netlink.LinkUpdate) error {
-	n.logger().Debug("Interface update not supported")
+// updateInterface sends the IP addresses of an interface to the runtime,
+// if they differ from the ones stored in the internal list.
+func (n *netmon) updateInterface(index int) error {
+	iface, exist := n.netIfaces[index]
+	if !exist {
+		n.logger().Debugf("Ignoring address update since interface %d not found", index)
+		return nil
+	}
+
+	// The link may have been removed already, in which case the
+	// interface is going to be deleted by the link event.
+	link, err := n.netHandler.LinkByIndex(index)
+	if err != nil {
+		n.logger().WithError(err).Debugf("Ignoring address update of interface %s", iface.Name)
+		return nil
+	}
+
+	addrs, err := n.netHandler.AddrList(link, netlinkFamily)
+	if err != nil {
+		return err
+	}
+
+	newIface := convertInterface(link.Attrs(), link.Type(), addrs)
+	if reflect.DeepEqual(iface.IPAddresses, newIface.IPAddresses) {
+		return nil
+	}
+
+	if n.controlSocket == "" {
+		if err := n.updateInterfaceCLI(newIface); err != nil {
+			return err
+		}
+	} else {
+		n.queueChange(vcTypes.NetmonChange{
+			Op:        vcTypes.NetmonUpdateInterface,
+			Interface: &newIface,
+		}, func() {
+			n.netIfaces[index] = iface
+		})
+	}
+
+	// Update the interface in the internal list.
+	n.netIfaces[index] = newIface
+
+	return nil
+}
+
+// updateNeighbors sends the permanent neighbors of an interface which have
+// not been sent yet to the runtime. Only the static entries are relevant,
+// the dynamic ones are resolved by the guest itself.
+func (n *netmon) updateNeighbors(index int) error {
+	iface, exist := n.netIfaces[index]
+	if !exist {
+		n.logger().Debugf("Ignoring neighbor update since interface %d not found", index)
+		return nil
+	}
+
+	netNeighs, err := n.netHandler.NeighList(index, netlinkFamily)
+	if err != nil {
+		return err
+	}
+
+	var neighs []vcTypes.ARPNeighbor
+	var keys []string
+	for _, netNeigh := range netNeighs {
+		if netNeigh.State != netlink.NUD_PERMANENT || netNeigh.HardwareAddr == nil {
+			continue
+		}
+
+		key := neighborKey(index, netNeigh.IP)
+		if hwAddr, exist := n.netNeighs[key]; exist && hwAddr == netNeigh.HardwareAddr.String() {
+			continue
+		}
+
+		neighs = append(neighs, convertNeighbor(netNeigh, iface.Name))
+		keys = append(keys, key)
+	}
+
+	if len(neighs) == 0 {
+		return nil
+	}
+
+	if n.controlSocket == "" {
+		if err := n.updateNeighborsCLI(neighs); err != nil {
+			return err
+		}
+	} else {
+		n.queueChange(vcTypes.NetmonChange{
+			Op:        vcTypes.NetmonUpdateNeighbors,
+			Neighbors: neighs,
+		}, func() {
+			for _, key := range keys {
+				delete(n.netNeighs, key)
+			}
+		})
+	}
+
+	// Add the neighbors to the internal list.
+	for i, key := range keys {
+		n.netNeighs[key] = neighs[i].LLAddr
+	}
+
 	return nil
 }
 
-func (n *netmon) handleRTMDelAddr(ev netlink.LinkUpdate) error {
-	n.logger().Debug("Interface update not supported")
+func (n *netmon) handleRTMNewAddr(ev netlink.AddrUpdate) error {
+	return n.updateInterface(ev.LinkIndex)
+}
+
+func (n *netmon) handleRTMDelAddr(ev netlink.AddrUpdate) error {
+	return n.updateInterface(ev.LinkIndex)
+}
+
+func (n *netmon) handleRTMNewNeigh(ev netlink.NeighUpdate) error {
+	if ev.State != netlink.NUD_PERMANENT {
+		return nil
+	}
+
+	return n.updateNeighbors(ev.LinkIndex)
+}
+
+func (n *netmon) handleRTMDelNeigh(ev netlink.NeighUpdate) error {
+	// The agent does not provide any way to remove a neighbor, which is
+	// only forgotten here so that it is sent again if added back.
+	key := neighborKey(ev.LinkIndex, ev.IP)
+	if _, exist := n.netNeighs[key]; exist {
+		n.logger().Debugf("Neighbor %s removed, the guest entry is kept", ev.IP)
+		delete(n.netNeighs, key)
+	}
+
 	return nil
 }
 
@@ -513,16 +901,18 @@ func (n *netmon) handleRTMNewLink(ev netlink.LinkUpdate) error {
 	// Convert the interfaces in the appropriate structure format.
 	iface := convertInterface(linkAttrs, ev.Link.Type(), addrs)
 
-	// Add the interface through the Kata CLI.
-	if err := n.addInterfaceCLI(iface); err != nil {
+	// Add the interface through the runtime.
+	if err := n.addInterface(iface, linkAttrs.Index); err != nil {
 		return err
 	}
 
-	// Add the interface to the internal list.
-	n.netIfaces[linkAttrs.Index] = iface
+	// Update the routes.
+	if err := n.updateRoutes(); err != nil {
+		return err
+	}
 
-	// Complete by updating the routes.
-	return n.updateRoutes()
+	// Complete by sending the static neighbors of the interface.
+	return n.updateNeighbors(linkAttrs.Index)
 }
 
 func (n *netmon) handleRTMDelLink(ev netlink.LinkUpdate) error {
@@ -550,13 +940,10 @@ func (n *netmon) handleRTMDelLink(ev netlink.LinkUpdate) error {
 		return nil
 	}
 
-	if err := n.delInterfaceCLI(iface); err != nil {
+	if err := n.delInterface(iface, linkAttrs.Index); err != nil {
 		return err
 	}
 
-	// Delete the interface from the internal list.
-	delete(n.netIfaces, linkAttrs.Index)
-
 	// Complete by updating the routes.
 	return n.updateRoutes()
 }
@@ -589,12 +976,6 @@ func (n *netmon) handleLinkEvent(ev netlink.LinkUpdate) error {
 	case unix.NLMSG_ERROR:
 		n.logger().Error("NLMSG_ERROR")
 		return fmt.Errorf("Error while listening on netlink socket")
-	case unix.RTM_NEWADDR:
-		n.logger().Debug("RTM_NEWADDR")
-		return n.handleRTMNewAddr(ev)
-	case unix.RTM_DELADDR:
-		n.logger().Debug("RTM_DELADDR")
-		return n.handleRTMDelAddr(ev)
 	case unix.RTM_NEWLINK:
 		n.logger().Debug("RTM_NEWLINK")
 		return n.handleRTMNewLink(ev)
@@ -625,7 +1006,40 @@ func (n *netmon) handleRouteEvent(ev netlink.RouteUpdate) error {
 	return nil
 }
 
+func (n *netmon) handleAddrEvent(ev netlink.AddrUpdate) error {
+	n.logger().Debug("handleAddrEvent: netlink event received")
+
+	if ev.NewAddr {
+		n.logger().Debug("RTM_NEWADDR")
+		return n.handleRTMNewAddr(ev)
+	}
+
+	n.logger().Debug("RTM_DELADDR")
+	return n.handleRTMDelAddr(ev)
+}
+
+func (n *netmon) handleNeighEvent(ev netlink.NeighUpdate) error {
+	n.logger().Debug("handleNeighEvent: netlink event received")
+
+	switch ev.Type {
+	case unix.RTM_NEWNEIGH:
+		n.logger().Debug("RTM_NEWNEIGH")
+		return n.handleRTMNewNeigh(ev)
+	case unix.RTM_DELNEIGH:
+		n.logger().Debug("RTM_DELNEIGH")
+		return n.handleRTMDelNeigh(ev)
+	default:
+		n.logger().Warnf("Unknown msg type %v", ev.Type)
+	}
+
+	return nil
+}
+
 func (n *netmon) handleEvents() (err error) {
+	// flushCh fires once the changes of a burst of events have been
+	// batched, it is nil when no change is waiting to be sent.
+	var flushCh <-chan time.Time
+
 	for {
 		select {
 		case ev := <-n.linkUpdateCh:
@@ -636,6 +1050,23 @@ func (n *netmon) handleEvents() (err error) {
 			if err = n.handleRouteEvent(ev); err != nil {
 				return err
 			}
+		case ev := <-n.addrUpdateCh:
+			if err = n.handleAddrEvent(ev); err != nil {
+				return err
+			}
+		case ev := <-n.neighUpdateCh:
+			if err = n.handleNeighEvent(ev); err != nil {
+				return err
+			}
+		case <-flushCh:
+			flushCh = nil
+			if err = n.sendChanges(); err != nil {
+				return err
+			}
+		}
+
+		if flushCh == nil && len(n.changes) > 0 {
+			flushCh = time.After(controlBatchDelay)
 		}
 	}
 }
@@ -123,6 +123,8 @@ func TestCleanup(t *testing.T) {
 		storagePath: filepath.Join(storageParentPath, testSandboxID),
 		linkDoneCh:  make(chan struct{}),
 		rtDoneCh:    make(chan struct{}),
+		addrDoneCh:  make(chan struct{}),
+		neighDoneCh: make(chan struct{}),
 		netHandler:  handler,
 	}
 
@@ -139,6 +141,10 @@ func TestCleanup(t *testing.T) {
 	assert.False(t, ok)
 	_, ok = (<-n.rtDoneCh)
 	assert.False(t, ok)
+	_, ok = (<-n.addrDoneCh)
+	assert.False(t, ok)
+	_, ok = (<-n.neighDoneCh)
+	assert.False(t, ok)
 }
 
 func TestLogger(t *testing.T) {
@@ -177,6 +183,17 @@ func TestConvertInterface(t *testing.T) {
 				IP: net.ParseIP(testIP6Address),
 			},
 		},
+		{
+			IPNet: &net.IPNet{
+				IP: net.ParseIP("fe80::42:acff:fe11:2"),
+			},
+		},
+		{
+			IPNet: &net.IPNet{
+				IP: net.ParseIP("2001:db8:1::3"),
+			},
+			Flags: unix.IFA_F_DADFAILED,
+		},
 	}
 
 	linkAttrs := &netlink.LinkAttrs{
@@ -239,19 +256,20 @@ func TestConvertRoutes(t *testing.T) {
 		},
 	}
 
+	// The on-link routes come first.
 	expected := []vcTypes.Route{
-		{
-			Dest:    testIPAddressWithMask,
-			Gateway: testIPAddress,
-			Source:  testIPAddress,
-			Scope:   uint32(testScope),
-		},
 		{
 			Dest:    testIP6AddressWithMask,
 			Gateway: "",
 			Source:  "",
 			Scope:   uint32(testScope),
 		},
+		{
+			Dest:    testIPAddressWithMask,
+			Gateway: testIPAddress,
+			Source:  testIPAddress,
+			Scope:   uint32(testScope),
+		},
 	}
 
 	got := convertRoutes(routes)
@@ -259,6 +277,46 @@ func TestConvertRoutes(t *testing.T) {
 		"Got %+v\nExpected %+v", got, expected)
 }
 
+func TestListRoutes(t *testing.T) {
+	tearDownNetworkCb := testSetupNetwork(t)
+	defer tearDownNetworkCb()
+
+	handler, err := netlink.NewHandle(netlinkFamily)
+	assert.Nil(t, err)
+	assert.NotNil(t, handler)
+	defer handler.Delete()
+
+	idx, _ := testCreateDummyNetwork(t, handler)
+
+	addr, err := netlink.ParseAddr(testIPAddressWithMask)
+	assert.Nil(t, err)
+	err = handler.AddrAdd(&netlink.Dummy{LinkAttrs: netlink.LinkAttrs{Index: idx}}, addr)
+	assert.Nil(t, err)
+	addr6, err := netlink.ParseAddr(testIP6Address + "/64")
+	assert.Nil(t, err)
+	addr6.Flags = unix.IFA_F_NODAD
+	err = handler.AddrAdd(&netlink.Dummy{LinkAttrs: netlink.LinkAttrs{Index: idx}}, addr6)
+	assert.Nil(t, err)
+
+	err = handler.RouteAdd(&netlink.Route{LinkIndex: idx, Gw: net.ParseIP("2001:db8:1::1")})
+	assert.Nil(t, err)
+
+	routes, err := vcTypes.ListRoutes(handler, nil)
+	assert.Nil(t, err)
+
+	var defaultRoutes []netlink.Route
+	for _, route := range routes {
+		if route.Gw != nil {
+			defaultRoutes = append(defaultRoutes, route)
+		}
+	}
+
+	if !assert.Len(t, defaultRoutes, 1) {
+		return
+	}
+	assert.Equal(t, "::/0", defaultRoutes[0].Dst.String())
+}
+
 type testTeardownNetwork func()
 
 func testSetupNetwork(t *testing.T) testTeardownNetwork {
@@ -305,9 +363,9 @@ func testCreateDummyNetwork(t *testing.T, handler *netlink.Handle) (int, vcTypes
 
 	var ipAddrs []*vcTypes.IPAddress
 
-	// Scan addresses for ipv6 link local address which is automatically assigned
+	// Ignore the ipv6 link local address which is automatically assigned
 	for _, addr := range addrs {
-		if addr.IPNet == nil {
+		if addr.IPNet == nil || addr.IP.IsLinkLocalUnicast() {
 			continue
 		}
 
@@ -352,6 +410,7 @@ func TestScanNetwork(t *testing.T) {
 
 	n := &netmon{
 		netIfaces:  make(map[int]vcTypes.Interface),
+		netNeighs:  make(map[string]string),
 		netHandler: handler,
 	}
 
@@ -472,6 +531,14 @@ func TestActionsCLI(t *testing.T) {
 	err = n.updateRoutesCLI([]vcTypes.Route{})
 	assert.Nil(t, err)
 
+	// Test updateInterfaceCLI
+	err = n.updateInterfaceCLI(vcTypes.Interface{})
+	assert.Nil(t, err)
+
+	// Test updateNeighborsCLI
+	err = n.updateNeighborsCLI([]vcTypes.ARPNeighbor{})
+	assert.Nil(t, err)
+
 	tearDownNetworkCb := testSetupNetwork(t)
 	defer tearDownNetworkCb()
 
@@ -492,19 +559,96 @@ func TestActionsCLI(t *testing.T) {
 }
 
 func TestHandleRTMNewAddr(t *testing.T) {
-	n := &netmon{}
+	n := &netmon{
+		netIfaces: make(map[int]vcTypes.Interface),
+	}
 
-	err := n.handleRTMNewAddr(netlink.LinkUpdate{})
+	// Interface not found
+	err := n.handleRTMNewAddr(netlink.AddrUpdate{LinkIndex: testIfaceIndex})
 	assert.Nil(t, err)
 }
 
 func TestHandleRTMDelAddr(t *testing.T) {
-	n := &netmon{}
+	n := &netmon{
+		netIfaces: make(map[int]vcTypes.Interface),
+	}
+
+	// Interface not found
+	err := n.handleRTMDelAddr(netlink.AddrUpdate{LinkIndex: testIfaceIndex})
+	assert.Nil(t, err)
+}
+
+func TestHandleRTMNewNeigh(t *testing.T) {
+	n := &netmon{
+		netIfaces: make(map[int]vcTypes.Interface),
+	}
+
+	// Dynamic neighbor
+	ev := netlink.NeighUpdate{}
+	ev.LinkIndex = testIfaceIndex
+	ev.State = netlink.NUD_REACHABLE
+	err := n.handleRTMNewNeigh(ev)
+	assert.Nil(t, err)
 
-	err := n.handleRTMDelAddr(netlink.LinkUpdate{})
+	// Interface not found
+	ev.State = netlink.NUD_PERMANENT
+	err = n.handleRTMNewNeigh(ev)
 	assert.Nil(t, err)
 }
 
+func TestHandleRTMDelNeigh(t *testing.T) {
+	ip := net.ParseIP(testIPAddress)
+	n := &netmon{
+		netNeighs: map[string]string{
+			neighborKey(testIfaceIndex, ip): testHwAddr,
+		},
+	}
+
+	ev := netlink.NeighUpdate{}
+	ev.LinkIndex = testIfaceIndex
+	ev.IP = ip
+	err := n.handleRTMDelNeigh(ev)
+	assert.Nil(t, err)
+	assert.Empty(t, n.netNeighs)
+}
+
+func TestConvertNeighbor(t *testing.T) {
+	hwAddr, err := net.ParseMAC(testHwAddr)
+	assert.Nil(t, err)
+
+	neigh := netlink.Neigh{
+		IP:           net.ParseIP(testIPAddress),
+		HardwareAddr: hwAddr,
+		State:        netlink.NUD_PERMANENT,
+	}
+
+	expected := vcTypes.ARPNeighbor{
+		ToIPAddress: &vcTypes.IPAddress{
+			Family:  netlink.FAMILY_V4,
+			Address: testIPAddress,
+		},
+		Device: testIfaceName,
+		LLAddr: testHwAddr,
+		State:  netlink.NUD_PERMANENT,
+	}
+
+	got := convertNeighbor(neigh, testIfaceName)
+	assert.True(t, reflect.DeepEqual(expected, got),
+		"Got %+v\nExpected %+v", got, expected)
+
+	neigh.IP = net.ParseIP(testIP6Address)
+	neigh.HardwareAddr = nil
+	expected.ToIPAddress = &vcTypes.IPAddress{
+		Family:  netlink.FAMILY_V6,
+		Address: testIP6Address,
+	}
+	expected.LLAddr = ""
+
+	got = convertNeighbor(neigh, testIfaceName)
+	assert.True(t, reflect.DeepEqual(expected, got),
+		"Got %+v\nExpected %+v", got, expected)
+}
+
 func TestHandleRTMNewLink(t *testing.T) {
 	n := &netmon{}
 	ev := netlink.LinkUpdate{
@@ -635,16 +779,6 @@ func TestHandleLinkEvent(t *testing.T) {
 	err = n.handleLinkEvent(ev)
 	assert.NotNil(t, err)
 
-	// NEWADDR event
-	ev.Header.Type = unix.RTM_NEWADDR
-	err = n.handleLinkEvent(ev)
-	assert.Nil(t, err)
-
-	// DELADDR event
-	ev.Header.Type = unix.RTM_DELADDR
-	err = n.handleLinkEvent(ev)
-	assert.Nil(t, err)
-
 	// NEWLINK event
 	ev.Header.Type = unix.RTM_NEWLINK
 	ev.Link = &netlink.Dummy{}
@@ -697,3 +831,188 @@ func TestHandleRouteEvent(t *testing.T) {
 	err = n.handleRouteEvent(ev)
 	assert.Nil(t, err)
 }
+
+func TestHandleAddrEvent(t *testing.T) {
+	n := &netmon{
+		netIfaces: make(map[int]vcTypes.Interface),
+	}
+	ev := netlink.AddrUpdate{LinkIndex: testIfaceIndex}
+
+	// DELADDR event
+	err := n.handleAddrEvent(ev)
+	assert.Nil(t, err)
+
+	// NEWADDR event
+	ev.NewAddr = true
+	err = n.handleAddrEvent(ev)
+	assert.Nil(t, err)
+}
+
+func TestHandleNeighEvent(t *testing.T) {
+	n := &netmon{
+		netIfaces: make(map[int]vcTypes.Interface),
+		netNeighs: make(map[string]string),
+	}
+	ev := netlink.NeighUpdate{}
+
+	// Unknown event
+	err := n.handleNeighEvent(ev)
+	assert.Nil(t, err)
+
+	// RTM_NEWNEIGH event
+	ev.Type = unix.RTM_NEWNEIGH
+	ev.State = netlink.NUD_PERMANENT
+	err = n.handleNeighEvent(ev)
+	assert.Nil(t, err)
+
+	// RTM_DELNEIGH event
+	ev.Type = unix.RTM_DELNEIGH
+	err = n.handleNeighEvent(ev)
+	assert.Nil(t, err)
+}
+
+func TestUpdateNeighbors(t *testing.T) {
+	tearDownNetworkCb := testSetupNetwork(t)
+	defer tearDownNetworkCb()
+
+	handler, err := netlink.NewHandle(netlinkFamily)
+	assert.Nil(t, err)
+	assert.NotNil(t, handler)
+	defer handler.Delete()
+
+	idx, iface := testCreateDummyNetwork(t, handler)
+
+	hwAddr, err := net.ParseMAC(testHwAddr)
+	assert.Nil(t, err)
+	err = handler.NeighAdd(&netlink.Neigh{
+		LinkIndex:    idx,
+		Family:       netlink.FAMILY_V4,
+		State:        netlink.NUD_PERMANENT,
+		IP:           net.ParseIP(testIPAddress),
+		HardwareAddr: hwAddr,
+	})
+	assert.Nil(t, err)
+	err = handler.NeighAdd(&netlink.Neigh{
+		LinkIndex:    idx,
+		Family:       netlink.FAMILY_V6,
+		State:        netlink.NUD_PERMANENT,
+		IP:           net.ParseIP(testIP6Address),
+		HardwareAddr: hwAddr,
+	})
+	assert.Nil(t, err)
+
+	n := &netmon{
+		netmonParams: netmonParams{
+			controlSocket: "/foo/bar/control.sock",
+		},
+		netIfaces:  map[int]vcTypes.Interface{idx: iface},
+		netNeighs:  make(map[string]string),
+		netHandler: handler,
+	}
+
+	err = n.updateNeighbors(idx)
+	assert.Nil(t, err)
+	if !assert.Len(t, n.changes, 1) {
+		return
+	}
+	assert.Equal(t, vcTypes.NetmonUpdateNeighbors, n.changes[0].change.Op)
+	if !assert.Len(t, n.changes[0].change.Neighbors, 2) {
+		return
+	}
+	for _, neigh := range n.changes[0].change.Neighbors {
+		assert.Equal(t, iface.Name, neigh.Device)
+		if neigh.ToIPAddress.Family == netlink.FAMILY_V6 {
+			assert.Equal(t, testIP6Address, neigh.ToIPAddress.Address)
+		} else {
+			assert.Equal(t, testIPAddress, neigh.ToIPAddress.Address)
+		}
+	}
+
+	// The neighbor is not sent twice.
+	err = n.updateNeighbors(idx)
+	assert.Nil(t, err)
+	assert.Len(t, n.changes, 1)
+
+	// The neighbor is sent again if the runtime failed to add it.
+	n.changes[0].undo()
+	err = n.updateNeighbors(idx)
+	assert.Nil(t, err)
+	assert.Len(t, n.changes, 2)
+}
+
+func TestSendChanges(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "netmon")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "control.sock")
+	listener, err := net.Listen("unix", socket)
+	assert.NoError(err)
+	defer listener.Close()
+
+	reqCh := make(chan vcTypes.NetmonRequest, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var req vcTypes.NetmonRequest
+		if err := json.NewDecoder(conn).Decode(&req); err != nil {
+			return
+		}
+		reqCh <- req
+
+		// Fail to add the second interface.
+		json.NewEncoder(conn).Encode(vcTypes.NetmonResponse{
+			Errors: []string{"", "failure", ""},
+		})
+	}()
+
+	n := &netmon{
+		netmonParams: netmonParams{
+			controlSocket: socket,
+		},
+		netIfaces: make(map[int]vcTypes.Interface),
+	}
+
+	assert.NoError(n.addInterface(vcTypes.Interface{Name: "eth0"}, 1))
+	n.queueChange(vcTypes.NetmonChange{
+		Op:     vcTypes.NetmonUpdateRoutes,
+		Routes: []vcTypes.Route{{Dest: "10.0.0.0/8"}},
+	}, nil)
+	assert.NoError(n.addInterface(vcTypes.Interface{Name: "eth1"}, 2))
+	n.queueChange(vcTypes.NetmonChange{
+		Op:     vcTypes.NetmonUpdateRoutes,
+		Routes: []vcTypes.Route{{Dest: "192.168.0.0/16"}},
+	}, nil)
+
+	// The last routes update supersedes the queued one.
+	assert.Len(n.changes, 3)
+	assert.Len(n.netIfaces, 2)
+
+	assert.NoError(n.sendChanges())
+	defer n.controlConn.Close()
+	assert.Empty(n.changes)
+
+	req := <-reqCh
+	var ops []vcTypes.NetmonOp
+	for _, c := range req.Changes {
+		ops = append(ops, c.Op)
+	}
+	assert.Equal([]vcTypes.NetmonOp{
+		vcTypes.NetmonAddInterface,
+		vcTypes.NetmonAddInterface,
+		vcTypes.NetmonUpdateRoutes,
+	}, ops)
+	assert.Equal("eth1", req.Changes[1].Interface.Name)
+	assert.Equal("192.168.0.0/16", req.Changes[2].Routes[0].Dest)
+
+	// The interface the runtime failed to add is not tracked anymore.
+	_, exist := n.netIfaces[2]
+	assert.False(exist)
+	assert.Len(n.netIfaces, 1)
+}
@@ -19,6 +19,7 @@ import (
 	"github.com/kata-containers/runtime/virtcontainers/device/config"
 	exp "github.com/kata-containers/runtime/virtcontainers/experimental"
 	"github.com/kata-containers/runtime/virtcontainers/pkg/oci"
+	"github.com/kata-containers/runtime/virtcontainers/pkg/rootless"
 	"github.com/kata-containers/runtime/virtcontainers/utils"
 	"github.com/sirupsen/logrus"
 )
@@ -106,6 +107,8 @@ type hypervisor struct {
 	VirtioFSDaemonList      []string `toml:"valid_virtio_fs_daemon_paths"`
 	VirtioFSCache           string   `toml:"virtio_fs_cache"`
 	VirtioFSExtraArgs       []string `toml:"virtio_fs_extra_args"`
+	VirtioFSDaemonRestart   bool     `toml:"virtio_fs_daemon_restart"`
+	Enable9pVolumes         bool     `toml:"enable_9p_volumes"`
 	PFlashList              []string `toml:"pflashes"`
 	VirtioFSCacheSize       uint32   `toml:"virtio_fs_cache_size"`
 	BlockDeviceCacheSet     bool     `toml:"block_device_cache_set"`
@@ -154,8 +157,10 @@ type runtime struct {
 	DisableGuestSeccomp bool     `toml:"disable_guest_seccomp"`
 	SandboxCgroupOnly   bool     `toml:"sandbox_cgroup_only"`
 	EnableAgentPidNs    bool     `toml:"enable_agent_pidns"`
+	EnableShimMetrics   bool     `toml:"enable_shim_metrics"`
 	Experimental        []string `toml:"experimental"`
 	InterNetworkModel   string   `toml:"internetworking_model"`
+	UserModeNetworkPath string   `toml:"usermode_network_path"`
 }
 
 type shim struct {
@@ -530,6 +535,14 @@ func (a agent) kernelModules() []string {
 	return a.KernelModules
 }
 
+func (r runtime) userModeNetworkPath() string {
+	if r.UserModeNetworkPath == "" {
+		return defaultUserModeNetworkPath
+	}
+
+	return r.UserModeNetworkPath
+}
+
 func (n netmon) enable() bool {
 	return n.Enable
 }
@@ -713,6 +726,8 @@ func newQemuHypervisorConfig(h hypervisor) (vc.HypervisorConfig, error) {
 		VirtioFSCacheSize:       h.VirtioFSCacheSize,
 		VirtioFSCache:           h.defaultVirtioFSCache(),
 		VirtioFSExtraArgs:       h.VirtioFSExtraArgs,
+		VirtioFSDaemonRestart:   h.VirtioFSDaemonRestart,
+		Enable9pVolumes:         h.Enable9pVolumes,
 		PFlash:                  pflashes,
 		MemPrealloc:             h.MemPrealloc,
 		HugePages:               h.HugePages,
@@ -1280,6 +1295,10 @@ func LoadConfiguration(configPath string, ignoreLogging, builtIn bool) (resolved
 	config.SandboxCgroupOnly = tomlConf.Runtime.SandboxCgroupOnly
 	config.DisableNewNetNs = tomlConf.Runtime.DisableNewNetNs
 	config.EnableAgentPidNs = tomlConf.Runtime.EnableAgentPidNs
+	config.EnableShimMetrics = tomlConf.Runtime.EnableShimMetrics
+	config.UserModeNetworkConfig = vc.UserModeNetworkConfig{
+		Path: tomlConf.Runtime.userModeNetworkPath(),
+	}
 	if config.EnableAgentPidNs {
 		kataUtilsLogger.Warn("Feature to allow containers to share PID namespace with the agent has been enabled. Please understand this has security implications and should only be used for debug purposes")
 	}
@@ -1348,7 +1367,17 @@ func checkConfig(config oci.RuntimeConfig) error {
 
 // checkNetNsConfig performs sanity checks on disable_new_netns config.
 // Because it is an expert option and conflicts with some other common configs.
+// It also checks the user-mode network stack is only used by rootless sandboxes,
+// which cannot load the BPF programs of the 'ebpf' model.
 func checkNetNsConfig(config oci.RuntimeConfig) error {
+	if config.InterNetworkModel == vc.NetXConnectUserModeModel && !rootless.IsRootless() {
+		return fmt.Errorf("config 'usermode' internetworking_model only works with rootless")
+	}
+
+	if config.InterNetworkModel == vc.NetXConnectEBPFModel && rootless.IsRootless() {
+		return fmt.Errorf("config 'ebpf' internetworking_model does not work with rootless")
+	}
+
 	if config.DisableNewNetNs {
 		if config.NetmonConfig.Enable {
 			return fmt.Errorf("config disable_new_netns conflicts with enable_netmon")
@@ -22,6 +22,7 @@ import (
 	ktu "github.com/kata-containers/runtime/pkg/katatestutils"
 	vc "github.com/kata-containers/runtime/virtcontainers"
 	"github.com/kata-containers/runtime/virtcontainers/pkg/oci"
+	"github.com/kata-containers/runtime/virtcontainers/pkg/rootless"
 	"github.com/kata-containers/runtime/virtcontainers/utils"
 	"github.com/stretchr/testify/assert"
 )
@@ -212,6 +213,10 @@ func createAllRuntimeConfigFiles(dir, hypervisor string) (config testRuntimeConf
 		NetmonConfig:    netmonConfig,
 		DisableNewNetNs: disableNewNetNs,
 
+		UserModeNetworkConfig: vc.UserModeNetworkConfig{
+			Path: defaultUserModeNetworkPath,
+		},
+
 		EnableAgentPidNs: enableAgentPidNs,
 		FactoryConfig:    factoryConfig,
 	}
@@ -674,6 +679,10 @@ func TestMinimalRuntimeConfig(t *testing.T) {
 
 		NetmonConfig: expectedNetmonConfig,
 
+		UserModeNetworkConfig: vc.UserModeNetworkConfig{
+			Path: defaultUserModeNetworkPath,
+		},
+
 		FactoryConfig: expectedFactoryConfig,
 	}
 	err = SetKernelParams(&expectedConfig)
@@ -1764,6 +1773,48 @@ func TestCheckNetNsConfig(t *testing.T) {
 	assert.Error(err)
 }
 
+func TestCheckNetNsConfigUserMode(t *testing.T) {
+	assert := assert.New(t)
+
+	savedIsRootless := rootless.IsRootless
+	defer func() {
+		rootless.IsRootless = savedIsRootless
+	}()
+
+	config := oci.RuntimeConfig{
+		InterNetworkModel: vc.NetXConnectUserModeModel,
+	}
+
+	rootless.IsRootless = func() bool { return false }
+	err := checkNetNsConfig(config)
+	assert.Error(err)
+
+	rootless.IsRootless = func() bool { return true }
+	err = checkNetNsConfig(config)
+	assert.NoError(err)
+}
+
+func TestCheckNetNsConfigEBPF(t *testing.T) {
+	assert := assert.New(t)
+
+	savedIsRootless := rootless.IsRootless
+	defer func() {
+		rootless.IsRootless = savedIsRootless
+	}()
+
+	config := oci.RuntimeConfig{
+		InterNetworkModel: vc.NetXConnectEBPFModel,
+	}
+
+	rootless.IsRootless = func() bool { return true }
+	err := checkNetNsConfig(config)
+	assert.Error(err)
+
+	rootless.IsRootless = func() bool { return false }
+	err = checkNetNsConfig(config)
+	assert.NoError(err)
+}
+
 func TestCheckFactoryConfig(t *testing.T) {
 	assert := assert.New(t)
 
@@ -10,12 +10,16 @@ import (
 	"context"
 	"fmt"
 	"io/ioutil"
+	"path/filepath"
 	"strconv"
 	"strings"
 
+	units "github.com/docker/go-units"
 	vc "github.com/kata-containers/runtime/virtcontainers"
 	vf "github.com/kata-containers/runtime/virtcontainers/factory"
+	vcAnnotations "github.com/kata-containers/runtime/virtcontainers/pkg/annotations"
 	"github.com/kata-containers/runtime/virtcontainers/pkg/oci"
+	"github.com/kata-containers/runtime/virtcontainers/utils"
 	specs "github.com/opencontainers/runtime-spec/specs-go"
 )
 
@@ -91,7 +95,10 @@ func HandleFactory(ctx context.Context, vci vc.VC, runtimeConfig *oci.RuntimeCon
 // For the given pod ephemeral volume is created only once
 // backed by tmpfs inside the VM. For successive containers
 // of the same pod the already existing volume is reused.
+// The size limit of such volumes is set as the size option of the mount.
 func SetEphemeralStorageType(ociSpec specs.Spec) specs.Spec {
+	sizeLimits := emptyDirSizeLimits(ociSpec.Annotations)
+
 	for idx, mnt := range ociSpec.Mounts {
 		if vc.IsEphemeralStorage(mnt.Source) {
 			ociSpec.Mounts[idx].Type = vc.KataEphemeralDevType
@@ -99,10 +106,82 @@ func SetEphemeralStorageType(ociSpec specs.Spec) specs.Spec {
 		if vc.Isk8sHostEmptyDir(mnt.Source) {
 			ociSpec.Mounts[idx].Type = vc.KataLocalDevType
 		}
+
+		if t := ociSpec.Mounts[idx].Type; t != vc.KataEphemeralDevType && t != vc.KataLocalDevType {
+			continue
+		}
+
+		if size := emptyDirSizeLimit(ociSpec.Mounts[idx], sizeLimits); size > 0 {
+			ociSpec.Mounts[idx].Options = vc.SetMountSizeLimit(ociSpec.Mounts[idx].Options, size)
+		}
 	}
 	return ociSpec
 }
 
+// emptyDirSizeLimits returns the emptyDir size limits given by annotations,
+// indexed by volume name.
+func emptyDirSizeLimits(annotations map[string]string) map[string]uint64 {
+	sizeLimits := make(map[string]uint64)
+
+	for _, limit := range strings.Split(annotations[vcAnnotations.EmptyDirSizeLimits], ",") {
+		limit = strings.TrimSpace(limit)
+		if limit == "" {
+			continue
+		}
+
+		fields := strings.SplitN(limit, "=", 2)
+		if len(fields) != 2 {
+			kataUtilsLogger.WithField("limit", limit).Warn("Ignoring invalid emptyDir size limit")
+			continue
+		}
+
+		size, err := units.RAMInBytes(fields[1])
+		if err != nil {
+			kataUtilsLogger.WithError(err).WithField("limit", limit).Warn("Ignoring invalid emptyDir size limit")
+			continue
+		}
+
+		sizeLimits[fields[0]] = uint64(size)
+	}
+
+	return sizeLimits
+}
+
+// emptyDirSizeLimit returns the size limit of an emptyDir volume, given by
+// the mount options, the annotations, or the size of the tmpfs mounted by
+// kubelet on the host for memory backed volumes.
+func emptyDirSizeLimit(mnt specs.Mount, sizeLimits map[string]uint64) uint64 {
+	size, err := vc.MountSizeLimit(mnt.Options)
+	if err != nil {
+		kataUtilsLogger.WithError(err).WithField("source", mnt.Source).Warn("Ignoring emptyDir size option")
+	}
+	if size > 0 {
+		return size
+	}
+
+	if size, ok := sizeLimits[filepath.Base(mnt.Source)]; ok {
+		return size
+	}
+
+	if mnt.Type != vc.KataEphemeralDevType {
+		return 0
+	}
+
+	options, err := utils.GetMountOptions(mnt.Source)
+	if err != nil {
+		kataUtilsLogger.WithError(err).WithField("source", mnt.Source).Warn("Could not get emptyDir mount options")
+		return 0
+	}
+
+	// tmpfs mounts only have a size option when it is not the default
+	// one, half of the host memory.
+	if size, err = vc.MountSizeLimit(options); err != nil {
+		kataUtilsLogger.WithError(err).WithField("source", mnt.Source).Warn("Ignoring emptyDir size option")
+	}
+
+	return size
+}
+
 // CreateSandbox create a sandbox container
 func CreateSandbox(ctx context.Context, vci vc.VC, ociSpec specs.Spec, runtimeConfig oci.RuntimeConfig, rootFs vc.RootFs,
 	containerID, bundlePath, console string, disableOutput, systemdCgroup, builtIn bool) (_ vc.VCSandbox, _ vc.Process, err error) {
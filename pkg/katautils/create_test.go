@@ -21,6 +21,7 @@ import (
 
 	ktu "github.com/kata-containers/runtime/pkg/katatestutils"
 	vc "github.com/kata-containers/runtime/virtcontainers"
+	vcAnnotations "github.com/kata-containers/runtime/virtcontainers/pkg/annotations"
 	"github.com/kata-containers/runtime/virtcontainers/pkg/compatoci"
 	"github.com/kata-containers/runtime/virtcontainers/pkg/oci"
 	"github.com/kata-containers/runtime/virtcontainers/pkg/vcmock"
@@ -198,6 +199,31 @@ func TestSetEphemeralStorageType(t *testing.T) {
 		"Unexpected mount type, got %s expected ephemeral", mountType)
 }
 
+func TestSetEphemeralStorageTypeSizeLimit(t *testing.T) {
+	assert := assert.New(t)
+
+	ociSpec := specs.Spec{
+		Annotations: map[string]string{
+			vcAnnotations.EmptyDirSizeLimits: "cache=1Gi, invalid, scratch=lots",
+		},
+		Mounts: []specs.Mount{
+			{Source: filepath.Join("/pod", vc.K8sEmptyDir, "cache")},
+			{Source: filepath.Join("/pod", vc.K8sEmptyDir, "data"), Options: []string{"rbind", "size=64Mi"}},
+			{Source: filepath.Join("/pod", vc.K8sEmptyDir, "scratch"), Options: []string{"rbind"}},
+			{Source: "/pod/volumes/cache", Options: []string{"rbind"}},
+		},
+	}
+
+	ociSpec = SetEphemeralStorageType(ociSpec)
+
+	assert.Equal(vc.KataLocalDevType, ociSpec.Mounts[0].Type)
+	assert.Equal([]string{"size=1073741824"}, ociSpec.Mounts[0].Options)
+	assert.Equal([]string{"rbind", "size=67108864"}, ociSpec.Mounts[1].Options)
+	assert.Equal([]string{"rbind"}, ociSpec.Mounts[2].Options)
+	assert.Empty(ociSpec.Mounts[3].Type)
+	assert.Equal([]string{"rbind"}, ociSpec.Mounts[3].Options)
+}
+
 func TestSetKernelParams(t *testing.T) {
 	assert := assert.New(t)
 
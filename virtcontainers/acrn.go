@@ -554,6 +554,10 @@ func (a *Acrn) updateBlockDevice(drive *config.BlockDrive) error {
 	//Explicitly set PCIPath to NULL, so that VirtPath can be used
 	drive.PCIPath = vcTypes.PciPath{}
 
+	if drive.ReadOnly {
+		a.Logger().WithField("drive", drive.ID).Warn("acrn can not attach drives read-only, attaching it read-write")
+	}
+
 	args := []string{"blkrescan", a.acrnConfig.Name, fmt.Sprintf("%d,%s", slot, drive.File)}
 
 	a.Logger().WithFields(logrus.Fields{
@@ -684,6 +688,13 @@ func (a *Acrn) resizeVCPUs(reqVCPUs uint32) (currentVCPUs uint32, newVCPUs uint3
 	return 0, 0, nil
 }
 
+// throttleBlockDevice leaves the limits of a drive to the guest cgroups, ACRN
+// has no I/O limits for the block devices it attaches.
+func (a *Acrn) throttleBlockDevice(drive *config.BlockDrive, throttle config.BlockDriveThrottle) error {
+	a.Logger().WithField("drive", drive.ID).Warn("ACRN can not limit the I/O of a drive, the limits only apply in the guest")
+	return nil
+}
+
 func (a *Acrn) cleanup() error {
 	span, _ := a.trace("cleanup")
 	defer span.Finish()
@@ -695,6 +706,14 @@ func (a *Acrn) getPids() []int {
 	return []int{a.state.PID}
 }
 
+func (a *Acrn) getVirtiofsdPid() int {
+	return 0
+}
+
+func (a *Acrn) restartVirtiofsd() error {
+	return errors.New("acrn does not support virtio-fs")
+}
+
 func (a *Acrn) fromGrpc(ctx context.Context, hypervisorConfig *HypervisorConfig, j []byte) error {
 	return errors.New("acrn is not supported by VM cache")
 }
@@ -239,6 +239,10 @@ type agent interface {
 	// listRoutes will tell the agent to list routes of an existed Sandbox
 	listRoutes() ([]*vcTypes.Route, error)
 
+	// addARPNeighbors will tell the agent to add or replace ARP neighbors of
+	// an existed Sandbox.
+	addARPNeighbors(neighs []*vcTypes.ARPNeighbor) error
+
 	// getGuestDetails will tell the agent to get some information of guest
 	getGuestDetails(*grpc.GuestDetailsRequest) (*grpc.GuestDetailsResponse, error)
 
@@ -248,6 +252,9 @@ type agent interface {
 	// copyFile copies file from host to container's rootfs
 	copyFile(src, dst string) error
 
+	// removeFile removes a file copied into the guest
+	removeFile(dst string) error
+
 	// markDead tell agent that the guest is dead
 	markDead()
 
@@ -75,6 +75,12 @@ func createSandboxFromConfig(ctx context.Context, sandboxConfig SandboxConfig, f
 	span, ctx := trace(ctx, "createSandboxFromConfig")
 	defer span.Finish()
 
+	unlock, err := rwLockNewSandbox(sandboxConfig.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
 	// Create the sandbox.
 	s, err := createSandbox(ctx, sandboxConfig, factory)
 	if err != nil {
@@ -489,6 +495,36 @@ func StartContainer(ctx context.Context, sandboxID, containerID string) (VCConta
 	return s.StartContainer(containerID)
 }
 
+// RestartContainer is the virtcontainers container restarting entry point.
+// RestartContainer runs the process of a created or stopped container again,
+// reusing the rootfs, mounts and devices still prepared for it.
+func RestartContainer(ctx context.Context, sandboxID, containerID string) (VCContainer, error) {
+	span, ctx := trace(ctx, "RestartContainer")
+	defer span.Finish()
+
+	if sandboxID == "" {
+		return nil, vcTypes.ErrNeedSandboxID
+	}
+
+	if containerID == "" {
+		return nil, vcTypes.ErrNeedContainerID
+	}
+
+	unlock, err := rwLockSandbox(sandboxID)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	s, err := fetchSandbox(ctx, sandboxID)
+	if err != nil {
+		return nil, err
+	}
+	defer s.releaseStatelessSandbox()
+
+	return s.RestartContainer(containerID)
+}
+
 // StopContainer is the virtcontainers container stopping entry point.
 // StopContainer stops an already running container.
 func StopContainer(ctx context.Context, sandboxID, containerID string) (VCContainer, error) {
@@ -722,6 +758,64 @@ func UpdateContainer(ctx context.Context, sandboxID, containerID string, resourc
 	return s.UpdateContainer(containerID, resources)
 }
 
+// AddMount is the virtcontainers entry point to add a mount to a
+// running container.
+func AddMount(ctx context.Context, sandboxID, containerID string, mount Mount) error {
+	span, ctx := trace(ctx, "AddMount")
+	defer span.Finish()
+
+	if sandboxID == "" {
+		return vcTypes.ErrNeedSandboxID
+	}
+
+	if containerID == "" {
+		return vcTypes.ErrNeedContainerID
+	}
+
+	unlock, err := rwLockSandbox(sandboxID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	s, err := fetchSandbox(ctx, sandboxID)
+	if err != nil {
+		return err
+	}
+	defer s.releaseStatelessSandbox()
+
+	return s.AddMount(containerID, mount)
+}
+
+// RemoveMount is the virtcontainers entry point to remove a mount from a
+// running container.
+func RemoveMount(ctx context.Context, sandboxID, containerID, destination string) error {
+	span, ctx := trace(ctx, "RemoveMount")
+	defer span.Finish()
+
+	if sandboxID == "" {
+		return vcTypes.ErrNeedSandboxID
+	}
+
+	if containerID == "" {
+		return vcTypes.ErrNeedContainerID
+	}
+
+	unlock, err := rwLockSandbox(sandboxID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	s, err := fetchSandbox(ctx, sandboxID)
+	if err != nil {
+		return err
+	}
+	defer s.releaseStatelessSandbox()
+
+	return s.RemoveMount(containerID, destination)
+}
+
 // StatsContainer is the virtcontainers container stats entry point.
 // StatsContainer returns a detailed container stats.
 func StatsContainer(ctx context.Context, sandboxID, containerID string) (ContainerStats, error) {
@@ -898,6 +992,30 @@ func RemoveInterface(ctx context.Context, sandboxID string, inf *vcTypes.Interfa
 	return toggleInterface(ctx, sandboxID, inf, false)
 }
 
+// UpdateInterface is the virtcontainers update interface entry point.
+func UpdateInterface(ctx context.Context, sandboxID string, inf *vcTypes.Interface) (*vcTypes.Interface, error) {
+	span, ctx := trace(ctx, "UpdateInterface")
+	defer span.Finish()
+
+	if sandboxID == "" {
+		return nil, vcTypes.ErrNeedSandboxID
+	}
+
+	unlock, err := rwLockSandbox(sandboxID)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	s, err := fetchSandbox(ctx, sandboxID)
+	if err != nil {
+		return nil, err
+	}
+	defer s.releaseStatelessSandbox()
+
+	return s.UpdateInterface(inf)
+}
+
 // ListInterfaces is the virtcontainers list interfaces entry point.
 func ListInterfaces(ctx context.Context, sandboxID string) ([]*vcTypes.Interface, error) {
 	span, ctx := trace(ctx, "ListInterfaces")
@@ -970,6 +1088,30 @@ func ListRoutes(ctx context.Context, sandboxID string) ([]*vcTypes.Route, error)
 	return s.ListRoutes()
 }
 
+// UpdateNeighbors is the virtcontainers update ARP neighbors entry point.
+func UpdateNeighbors(ctx context.Context, sandboxID string, neighs []*vcTypes.ARPNeighbor) error {
+	span, ctx := trace(ctx, "UpdateNeighbors")
+	defer span.Finish()
+
+	if sandboxID == "" {
+		return vcTypes.ErrNeedSandboxID
+	}
+
+	unlock, err := rwLockSandbox(sandboxID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	s, err := fetchSandbox(ctx, sandboxID)
+	if err != nil {
+		return err
+	}
+	defer s.releaseStatelessSandbox()
+
+	return s.UpdateNeighbors(neighs)
+}
+
 // CleanupContaienr is used by shimv2 to stop and delete a container exclusively, once there is no container
 // in the sandbox left, do stop the sandbox and delete it. Those serial operations will be done exclusively by
 // locking the sandbox.
@@ -838,6 +838,63 @@ func TestStartContainerNoopAgentSuccessful(t *testing.T) {
 	assert.NotNil(c)
 }
 
+func TestRestartContainerNoopAgentSuccessful(t *testing.T) {
+	defer cleanUp()
+	assert := assert.New(t)
+
+	contID := "100"
+	config := newTestSandboxConfigNoop()
+
+	ctx := context.Background()
+
+	p, _, err := createAndStartSandbox(ctx, config)
+	assert.NoError(err)
+	assert.NotNil(p)
+	contConfig := newTestContainerConfigNoop(contID)
+
+	_, c, err := CreateContainer(ctx, p.ID(), contConfig)
+	assert.NoError(err)
+	assert.NotNil(c)
+
+	c, err = StartContainer(ctx, p.ID(), contID)
+	assert.NoError(err)
+	assert.NotNil(c)
+
+	_, err = RestartContainer(ctx, p.ID(), contID)
+	assert.Error(err)
+
+	c, err = StopContainer(ctx, p.ID(), contID)
+	assert.NoError(err)
+	assert.NotNil(c)
+
+	c, err = RestartContainer(ctx, p.ID(), contID)
+	assert.NoError(err)
+	assert.NotNil(c)
+
+	status, err := StatusContainer(ctx, p.ID(), contID)
+	assert.NoError(err)
+	assert.Equal(types.StateRunning, status.State.State)
+}
+
+func TestRestartContainerFailing(t *testing.T) {
+	defer cleanUp()
+	assert := assert.New(t)
+
+	ctx := context.Background()
+
+	c, err := RestartContainer(ctx, "", "100")
+	assert.Error(err)
+	assert.Nil(c)
+
+	c, err = RestartContainer(ctx, testSandboxID, "")
+	assert.Error(err)
+	assert.Nil(c)
+
+	c, err = RestartContainer(ctx, testSandboxID, "100")
+	assert.Error(err)
+	assert.Nil(c)
+}
+
 func TestStartContainerFailingNoSandbox(t *testing.T) {
 	defer cleanUp()
 
@@ -16,6 +16,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"syscall"
@@ -92,6 +93,8 @@ type clhClient interface {
 	VmAddDiskPut(ctx context.Context, diskConfig chclient.DiskConfig) (chclient.PciDeviceInfo, *http.Response, error)
 	// Remove a device from the VM
 	VmRemoveDevicePut(ctx context.Context, vmRemoveDevice chclient.VmRemoveDevice) (*http.Response, error)
+	// Add a new virtio-fs device to the VM
+	VmAddFsPut(ctx context.Context, fsConfig chclient.FsConfig) (chclient.PciDeviceInfo, *http.Response, error)
 }
 
 type CloudHypervisorVersion struct {
@@ -107,12 +110,16 @@ type CloudHypervisorState struct {
 	state        clhState
 	PID          int
 	VirtiofsdPID int
-	apiSocket    string
+	// VolumeVirtiofsdPIDs are the pids of the virtio-fs daemons of the
+	// volumes shared through a dedicated device, indexed by device ID.
+	VolumeVirtiofsdPIDs map[string]int
+	apiSocket           string
 }
 
 func (s *CloudHypervisorState) reset() {
 	s.PID = 0
 	s.VirtiofsdPID = 0
+	s.VolumeVirtiofsdPIDs = nil
 	s.state = clhNotReady
 }
 
@@ -435,6 +442,9 @@ func (clh *cloudHypervisor) hotplugAddBlockDevice(drive *config.BlockDrive) erro
 			VhostUser: false,
 			Id:        driveID,
 		}
+		if drive.Throttle != (config.BlockDriveThrottle{}) {
+			blkDevice.RateLimiterConfig = clhRateLimiter(drive.Throttle)
+		}
 		_, _, err = cl.VmAddDiskPut(ctx, blkDevice)
 	}
 
@@ -456,6 +466,89 @@ func (clh *cloudHypervisor) hotPlugVFIODevice(device config.VFIODev) error {
 	return err
 }
 
+// hotplugAddVhostUserFSDevice shares a host directory with the guest through
+// a virtio-fs device and a virtio-fs daemon dedicated to it.
+func (clh *cloudHypervisor) hotplugAddVhostUserFSDevice(vAttr *config.VhostUserDeviceAttrs) (err error) {
+	if vAttr.Type != config.VhostUserFS {
+		return fmt.Errorf("cannot hotplug vhost-user device of type %s", vAttr.Type)
+	}
+
+	if vAttr.SocketPath, err = clh.virtioFsVolumeSocketPath(vAttr.DevID); err != nil {
+		return err
+	}
+
+	cache := vAttr.Cache
+	if cache == "" {
+		cache = clh.config.VirtioFSCache
+	}
+
+	daemon := &virtiofsd{
+		path:       clh.config.VirtioFSDaemon,
+		sourcePath: vAttr.SharedDir,
+		socketPath: vAttr.SocketPath,
+		extraArgs:  clh.config.VirtioFSExtraArgs,
+		debug:      clh.config.Debug,
+		cache:      cache,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), clhHotPlugAPITimeout*time.Second)
+	defer cancel()
+
+	pid, err := daemon.Start(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			daemon.Stop()
+		}
+	}()
+
+	// numQueues and queueSize are required, let's use the
+	// default values defined by cloud-hypervisor
+	fs := chclient.FsConfig{
+		Tag:       vAttr.Tag,
+		Socket:    vAttr.SocketPath,
+		Dax:       vAttr.CacheSize != 0,
+		CacheSize: int64(vAttr.CacheSize) << 20,
+		NumQueues: 1,
+		QueueSize: 1024,
+		Id:        vAttr.DevID,
+	}
+
+	if _, _, err = clh.client().VmAddFsPut(ctx, fs); err != nil {
+		return fmt.Errorf("failed to hotplug virtio-fs device %s: %s", vAttr.DevID, openAPIClientError(err))
+	}
+
+	if clh.state.VolumeVirtiofsdPIDs == nil {
+		clh.state.VolumeVirtiofsdPIDs = make(map[string]int)
+	}
+	clh.state.VolumeVirtiofsdPIDs[vAttr.DevID] = pid
+
+	return nil
+}
+
+// stopVolumeVirtiofsd stops the virtio-fs daemon of a volume shared through
+// the dedicated device devID.
+func (clh *cloudHypervisor) stopVolumeVirtiofsd(devID string) {
+	pid, ok := clh.state.VolumeVirtiofsdPIDs[devID]
+	if !ok {
+		return
+	}
+	delete(clh.state.VolumeVirtiofsdPIDs, devID)
+
+	sockPath, err := clh.virtioFsVolumeSocketPath(devID)
+	if err != nil {
+		clh.Logger().WithError(err).WithField("device", devID).Warn("Invalid virtiofsd socket path")
+	}
+
+	daemon := &virtiofsd{PID: pid, socketPath: sockPath}
+	if err := daemon.Stop(); err != nil {
+		clh.Logger().WithError(err).WithField("device", devID).Warn("Could not stop virtiofsd")
+	}
+}
+
 func (clh *cloudHypervisor) hotplugAddDevice(devInfo interface{}, devType deviceType) (interface{}, error) {
 	span, _ := clh.trace("hotplugAddDevice")
 	defer span.Finish()
@@ -467,6 +560,9 @@ func (clh *cloudHypervisor) hotplugAddDevice(devInfo interface{}, devType device
 	case vfioDev:
 		device := devInfo.(*config.VFIODev)
 		return nil, clh.hotPlugVFIODevice(*device)
+	case vhostuserDev:
+		vAttr := devInfo.(*config.VhostUserDeviceAttrs)
+		return nil, clh.hotplugAddVhostUserFSDevice(vAttr)
 	default:
 		return nil, fmt.Errorf("cannot hotplug device: unsupported device type '%v'", devType)
 	}
@@ -484,6 +580,8 @@ func (clh *cloudHypervisor) hotplugRemoveDevice(devInfo interface{}, devType dev
 		deviceID = clhDriveIndexToID(devInfo.(*config.BlockDrive).Index)
 	case vfioDev:
 		deviceID = devInfo.(*config.VFIODev).ID
+	case vhostuserDev:
+		deviceID = devInfo.(*config.VhostUserDeviceAttrs).DevID
 	default:
 		clh.Logger().WithFields(log.Fields{"devInfo": devInfo,
 			"deviceType": devType}).Error("hotplugRemoveDevice: unsupported device")
@@ -499,6 +597,8 @@ func (clh *cloudHypervisor) hotplugRemoveDevice(devInfo interface{}, devType dev
 
 	if err != nil {
 		err = fmt.Errorf("failed to hotplug remove (unplug) device %+v: %s", devInfo, openAPIClientError(err))
+	} else if devType == vhostuserDev {
+		clh.stopVolumeVirtiofsd(deviceID)
 	}
 
 	return nil, err
@@ -573,6 +673,36 @@ func (clh *cloudHypervisor) resizeMemory(reqMemMB uint32, memoryBlockSizeMB uint
 	return uint32(newMem.ToMiB()), memoryDevice{sizeMB: int(hotplugSize.ToMiB())}, nil
 }
 
+// clhTokenBucket returns a token bucket refilled with rate tokens per second,
+// a 0 rate disabling the bucket.
+func clhTokenBucket(rate uint64) chclient.TokenBucket {
+	return chclient.TokenBucket{
+		Size:       int64(rate),
+		RefillTime: 1000,
+	}
+}
+
+// clhRateLimiter returns the rate limiter enforcing the limits of a drive.
+// Cloud Hypervisor rate limiters do not tell reads from writes, so the
+// lowest limit applies to both.
+func clhRateLimiter(throttle config.BlockDriveThrottle) *chclient.RateLimiterConfig {
+	return &chclient.RateLimiterConfig{
+		Bandwidth: clhTokenBucket(throttle.Bps()),
+		Ops:       clhTokenBucket(throttle.IOPS()),
+	}
+}
+
+// throttleBlockDevice leaves the limits of a drive to the guest cgroups when
+// they are not the ones it has been hotplugged with: Cloud Hypervisor only
+// sets the rate limiter of a disk when adding it.
+func (clh *cloudHypervisor) throttleBlockDevice(drive *config.BlockDrive, throttle config.BlockDriveThrottle) error {
+	if drive.Throttle != throttle {
+		clh.Logger().WithField("drive", drive.ID).Warn("Cloud hypervisor can not change the I/O limits of an attached drive, the new limits only apply in the guest")
+	}
+
+	return nil
+}
+
 func (clh *cloudHypervisor) resizeVCPUs(reqVCPUs uint32) (currentVCPUs uint32, newVCPUs uint32, err error) {
 	cl := clh.client()
 
@@ -653,6 +783,7 @@ func (clh *cloudHypervisor) save() (s persistapi.HypervisorState) {
 	s.Pid = clh.state.PID
 	s.Type = string(ClhHypervisor)
 	s.VirtiofsdPid = clh.state.VirtiofsdPID
+	s.VolumeVirtiofsdPids = clh.state.VolumeVirtiofsdPIDs
 	s.APISocket = clh.state.apiSocket
 	return
 }
@@ -660,6 +791,7 @@ func (clh *cloudHypervisor) save() (s persistapi.HypervisorState) {
 func (clh *cloudHypervisor) load(s persistapi.HypervisorState) {
 	clh.state.PID = s.Pid
 	clh.state.VirtiofsdPID = s.VirtiofsdPid
+	clh.state.VolumeVirtiofsdPIDs = s.VolumeVirtiofsdPids
 	clh.state.apiSocket = s.APISocket
 }
 
@@ -676,10 +808,30 @@ func (clh *cloudHypervisor) getPids() []int {
 
 	var pids []int
 	pids = append(pids, clh.state.PID)
+	if clh.state.VirtiofsdPID != 0 {
+		pids = append(pids, clh.state.VirtiofsdPID)
+	}
+
+	var devIDs []string
+	for devID := range clh.state.VolumeVirtiofsdPIDs {
+		devIDs = append(devIDs, devID)
+	}
+	sort.Strings(devIDs)
+	for _, devID := range devIDs {
+		pids = append(pids, clh.state.VolumeVirtiofsdPIDs[devID])
+	}
 
 	return pids
 }
 
+func (clh *cloudHypervisor) getVirtiofsdPid() int {
+	return clh.state.VirtiofsdPID
+}
+
+func (clh *cloudHypervisor) restartVirtiofsd() error {
+	return errors.New("cloud-hypervisor does not reconnect to virtio-fs daemons")
+}
+
 func (clh *cloudHypervisor) addDevice(devInfo interface{}, devType deviceType) error {
 	span, _ := clh.trace("addDevice")
 	defer span.Finish()
@@ -722,6 +874,9 @@ func (clh *cloudHypervisor) capabilities() types.Capabilities {
 	var caps types.Capabilities
 	caps.SetFsSharingSupport()
 	caps.SetBlockDeviceHotplugSupport()
+	if clh.config.VirtioFSDaemon != "" {
+		caps.SetFsSharingHotplugSupport()
+	}
 	return caps
 }
 
@@ -794,6 +949,10 @@ func (clh *cloudHypervisor) terminate() (err error) {
 		return errors.New("virtiofsd config is nil, failed to stop it")
 	}
 
+	for devID := range clh.state.VolumeVirtiofsdPIDs {
+		clh.stopVolumeVirtiofsd(devID)
+	}
+
 	if err := clh.cleanupVM(true); err != nil {
 		return err
 	}
@@ -822,6 +981,12 @@ func (clh *cloudHypervisor) generateSocket(id string, useVsock bool) (interface{
 	}, nil
 }
 
+// virtioFsVolumeSocketPath returns the socket path of the virtio-fs daemon of
+// a volume shared through the dedicated virtio-fs device devID.
+func (clh *cloudHypervisor) virtioFsVolumeSocketPath(devID string) (string, error) {
+	return utils.BuildSocketPath(clh.store.RunVMStoragePath(), clh.id, fmt.Sprintf("virtiofsd-%s.sock", devID))
+}
+
 func (clh *cloudHypervisor) virtioFsSocketPath(id string) (string, error) {
 	return utils.BuildSocketPath(clh.store.RunVMStoragePath(), id, virtioFsSocket)
 }
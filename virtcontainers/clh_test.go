@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"reflect"
 	"testing"
@@ -104,6 +105,11 @@ func (c *clhClientMock) VmAddDiskPut(ctx context.Context, diskConfig chclient.Di
 	return chclient.PciDeviceInfo{}, nil, nil
 }
 
+//nolint:golint
+func (c *clhClientMock) VmAddFsPut(ctx context.Context, fsConfig chclient.FsConfig) (chclient.PciDeviceInfo, *http.Response, error) {
+	return chclient.PciDeviceInfo{}, nil, nil
+}
+
 //nolint:golint
 func (c *clhClientMock) VmRemoveDevicePut(ctx context.Context, vmRemoveDevice chclient.VmRemoveDevice) (*http.Response, error) {
 	return nil, nil
@@ -390,6 +396,28 @@ func TestCloudHypervisorHotplugAddBlockDevice(t *testing.T) {
 	assert.Error(err, "Hotplug block device not using 'virtio-blk' expected error")
 }
 
+func TestCloudHypervisorThrottleBlockDevice(t *testing.T) {
+	assert := assert.New(t)
+
+	rateLimiter := clhRateLimiter(config.BlockDriveThrottle{
+		ReadBps:   2000,
+		WriteBps:  1000,
+		WriteIOPS: 10,
+	})
+	assert.Equal(int64(1000), rateLimiter.Bandwidth.Size)
+	assert.Equal(int64(10), rateLimiter.Ops.Size)
+	assert.Equal(int64(1000), rateLimiter.Ops.RefillTime)
+
+	clh := &cloudHypervisor{}
+	drive := &config.BlockDrive{ID: "drive", Throttle: config.BlockDriveThrottle{ReadBps: 1000}}
+
+	// The limits can not be changed once the drive is attached, the new
+	// ones are left to the guest.
+	assert.NoError(clh.throttleBlockDevice(drive, config.BlockDriveThrottle{ReadBps: 1000}))
+	assert.NoError(clh.throttleBlockDevice(drive, config.BlockDriveThrottle{ReadBps: 2000}))
+	assert.NoError(clh.throttleBlockDevice(drive, config.BlockDriveThrottle{}))
+}
+
 func TestCloudHypervisorHotplugRemoveDevice(t *testing.T) {
 	assert := assert.New(t)
 
@@ -409,3 +437,38 @@ func TestCloudHypervisorHotplugRemoveDevice(t *testing.T) {
 	_, err = clh.hotplugRemoveDevice(nil, netDev)
 	assert.Error(err, "Hotplug remove pmem block device expected error")
 }
+
+func TestCloudHypervisorVolumeVirtiofsd(t *testing.T) {
+	assert := assert.New(t)
+
+	store, err := persist.GetDriver()
+	assert.NoError(err)
+
+	clh := &cloudHypervisor{
+		id:        "clh-volume-virtiofsd",
+		APIClient: &clhClientMock{},
+		store:     store,
+	}
+	clh.state.PID = 1
+
+	daemon := exec.Command("sleep", "60")
+	assert.NoError(daemon.Start())
+	defer daemon.Process.Kill()
+
+	clh.state.VolumeVirtiofsdPIDs = map[string]int{"volume": daemon.Process.Pid}
+	assert.Equal([]int{1, daemon.Process.Pid}, clh.getPids())
+
+	// The daemons of the volumes are persisted.
+	loaded := &cloudHypervisor{}
+	loaded.load(clh.save())
+	assert.Equal(clh.state.VolumeVirtiofsdPIDs, loaded.state.VolumeVirtiofsdPIDs)
+
+	// The daemon of a volume is stopped when its device is unplugged.
+	_, err = clh.hotplugRemoveDevice(&config.VhostUserDeviceAttrs{DevID: "volume", Type: config.VhostUserFS}, vhostuserDev)
+	assert.NoError(err)
+	assert.Empty(clh.state.VolumeVirtiofsdPIDs)
+	assert.Equal([]int{1}, clh.getPids())
+
+	err = daemon.Wait()
+	assert.Error(err)
+}
@@ -13,10 +13,13 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/containerd/cgroups"
+	vcAnnotations "github.com/kata-containers/runtime/virtcontainers/pkg/annotations"
 	vccgroups "github.com/kata-containers/runtime/virtcontainers/pkg/cgroups"
 	vcTypes "github.com/kata-containers/runtime/virtcontainers/pkg/types"
 	"github.com/kata-containers/runtime/virtcontainers/types"
@@ -211,10 +214,25 @@ type NetworkStats struct {
 	TxDropped uint64 `json:"tx_dropped,omitempty"`
 }
 
+// FilesystemStats describe the usage of a filesystem the container
+// writes to inside the VM.
+type FilesystemStats struct {
+	// Path is the path of the filesystem inside the container.
+	Path string `json:"path,omitempty"`
+
+	CapacityBytes  uint64 `json:"capacity_bytes,omitempty"`
+	AvailableBytes uint64 `json:"available_bytes,omitempty"`
+	UsedBytes      uint64 `json:"used_bytes,omitempty"`
+	Inodes         uint64 `json:"inodes,omitempty"`
+	InodesFree     uint64 `json:"inodes_free,omitempty"`
+	InodesUsed     uint64 `json:"inodes_used,omitempty"`
+}
+
 // ContainerStats describes a container stats.
 type ContainerStats struct {
-	CgroupStats  *CgroupStats
-	NetworkStats []*NetworkStats
+	CgroupStats     *CgroupStats
+	NetworkStats    []*NetworkStats
+	FilesystemStats []*FilesystemStats
 }
 
 // ContainerResources describes container resources
@@ -266,9 +284,126 @@ func (c *ContainerConfig) valid() bool {
 		return false
 	}
 
+	if !c.isEphemeral() && c.ephemeralTarget() != "" {
+		return false
+	}
+
 	return true
 }
 
+// isEphemeral returns true if the container is an ephemeral container,
+// meant to be added to an already running sandbox.
+func (c *ContainerConfig) isEphemeral() bool {
+	ephemeral, _ := strconv.ParseBool(c.Annotations[vcAnnotations.EphemeralContainer])
+	return ephemeral
+}
+
+// ephemeralTarget returns the ID of the container whose PID namespace
+// is shared with an ephemeral container, if any.
+func (c *ContainerConfig) ephemeralTarget() string {
+	return c.Annotations[vcAnnotations.EphemeralContainerTarget]
+}
+
+// imageVolumes returns the filesystem types, indexed by destination, of the
+// mounts whose source is a raw disk image file. An empty filesystem type
+// means it has to be detected.
+func (c *ContainerConfig) imageVolumes() map[string]string {
+	volumes := make(map[string]string)
+
+	for _, volume := range strings.Split(c.Annotations[vcAnnotations.ImageVolumes], ",") {
+		volume = strings.TrimSpace(volume)
+		if volume == "" {
+			continue
+		}
+
+		fields := strings.SplitN(volume, "=", 2)
+		fstype := ""
+		if len(fields) == 2 {
+			fstype = fields[1]
+		}
+
+		volumes[filepath.Clean(fields[0])] = fstype
+	}
+
+	return volumes
+}
+
+// volumeSharing describes how a volume opting out of the filesystem sharing
+// of the sandbox is shared with the guest.
+type volumeSharing struct {
+	mechanism string
+
+	// cache is the cache mode of a dedicated virtio-fs device.
+	cache string
+
+	// daxSize is the DAX window size in MiB of a dedicated virtio-fs device.
+	daxSize uint32
+
+	// fstype is the filesystem type of a pmem device.
+	fstype string
+}
+
+// volumeSharing returns how the volumes listed by the VolumeSharing
+// annotation are shared with the guest, indexed by destination.
+func (c *ContainerConfig) volumeSharing() (map[string]volumeSharing, error) {
+	volumes := make(map[string]volumeSharing)
+	if c == nil {
+		return volumes, nil
+	}
+
+	for _, volume := range strings.Split(c.Annotations[vcAnnotations.VolumeSharing], ",") {
+		volume = strings.TrimSpace(volume)
+		if volume == "" {
+			continue
+		}
+
+		fields := strings.SplitN(volume, "=", 2)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("Invalid volume sharing %q", volume)
+		}
+
+		options := strings.Split(fields[1], ":")
+		sharing := volumeSharing{mechanism: options[0]}
+
+		switch sharing.mechanism {
+		case volumeShareVirtioFS, volumeShare9p, volumeShareBlock, volumeShareCopy, volumeSharePmem:
+		default:
+			return nil, fmt.Errorf("Invalid sharing mechanism %q of volume %s", sharing.mechanism, fields[0])
+		}
+
+		for _, option := range options[1:] {
+			kv := strings.SplitN(option, "=", 2)
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("Invalid sharing option %q of volume %s", option, fields[0])
+			}
+
+			switch sharing.mechanism + ":" + kv[0] {
+			case volumeShareVirtioFS + ":cache":
+				switch kv[1] {
+				case typeVirtioFSNoCache, "auto", "always":
+				default:
+					return nil, fmt.Errorf("Invalid virtio-fs cache mode %q of volume %s", kv[1], fields[0])
+				}
+				sharing.cache = kv[1]
+			case volumeShareVirtioFS + ":dax":
+				size, err := strconv.ParseUint(kv[1], 10, 32)
+				if err != nil {
+					return nil, fmt.Errorf("Invalid DAX window size %q of volume %s: %v", kv[1], fields[0], err)
+				}
+				sharing.daxSize = uint32(size)
+			case volumeSharePmem + ":fstype":
+				sharing.fstype = kv[1]
+			default:
+				return nil, fmt.Errorf("Invalid sharing option %q of volume %s", option, fields[0])
+			}
+		}
+
+		volumes[filepath.Clean(fields[0])] = sharing
+	}
+
+	return volumes, nil
+}
+
 // SystemMountsInfo describes additional information for system mounts that the agent
 // needs to handle
 type SystemMountsInfo struct {
@@ -336,6 +471,10 @@ type Container struct {
 
 	systemMountsInfo SystemMountsInfo
 
+	// restarting is set while the container is created again inside the
+	// guest, the rootfs and mounts shared with the guest being reused.
+	restarting bool
+
 	ctx context.Context
 
 	store *store.VCStore
@@ -398,6 +537,27 @@ func (c *Container) GetAnnotations() map[string]string {
 	return c.config.Annotations
 }
 
+// sharesSandboxPidNs returns true if the container joined the sandbox
+// PID namespace instead of creating its own.
+func (c *Container) sharesSandboxPidNs() bool {
+	if c.sandbox.sharePidNs || c.GetAnnotations()[vcAnnotations.ContainerTypeKey] == string(PodSandbox) {
+		return true
+	}
+
+	spec := c.GetPatchedOCISpec()
+	if spec == nil || spec.Linux == nil {
+		return false
+	}
+
+	for _, ns := range spec.Linux.Namespaces {
+		if ns.Type == specs.PIDNamespace {
+			return ns.Path != ""
+		}
+	}
+
+	return false
+}
+
 // GetPatchedOCISpec returns container's OCI specification
 // This OCI specification was patched when the sandbox was created
 // by containerCapabilities(), SetEphemeralStorageType() and others
@@ -446,13 +606,43 @@ func (c *Container) setContainerState(state types.StateString) error {
 	return nil
 }
 
-func (c *Container) shareFiles(m Mount, idx int, hostSharedDir, hostMountDir, guestSharedDir string) (string, bool, error) {
+// refreshedSharedFiles are the destinations of the files shared with the
+// guest which are kept up to date when their source is replaced.
+var refreshedSharedFiles = []string{GuestDNSFile, "/etc/hosts", "/etc/hostname"}
+
+// isRefreshedSharedFile checks if a mount is a file shared with the guest
+// which is kept up to date when its source is replaced.
+func isRefreshedSharedFile(m Mount) bool {
+	fileInfo, err := os.Stat(m.Source)
+	if err != nil || !fileInfo.Mode().IsRegular() {
+		return false
+	}
+
+	for _, dest := range refreshedSharedFiles {
+		if filepath.Clean(m.Destination) == dest {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sharedFileName returns a unique name for a mount shared with the guest.
+func (c *Container) sharedFileName(m Mount) (string, error) {
 	randBytes, err := utils.GenerateRandomBytes(8)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s-%s-%s", c.id, hex.EncodeToString(randBytes), filepath.Base(m.Destination)), nil
+}
+
+func (c *Container) shareFiles(m Mount, idx int, hostSharedDir, hostMountDir, guestSharedDir string) (string, bool, error) {
+	filename, err := c.sharedFileName(m)
 	if err != nil {
 		return "", false, err
 	}
 
-	filename := fmt.Sprintf("%s-%s-%s", c.id, hex.EncodeToString(randBytes), filepath.Base(m.Destination))
 	guestDest := filepath.Join(guestSharedDir, filename)
 
 	// copy file to contaier's rootfs if filesystem sharing is not supported, otherwise
@@ -460,44 +650,156 @@ func (c *Container) shareFiles(m Mount, idx int, hostSharedDir, hostMountDir, gu
 	caps := c.sandbox.hypervisor.capabilities()
 	if !caps.IsFsSharingSupported() {
 		c.Logger().Debug("filesystem sharing is not supported, files will be copied")
+		return c.copyFiles(m, guestDest)
+	}
 
-		fileInfo, err := os.Stat(m.Source)
-		if err != nil {
-			return "", false, err
-		}
+	// The mount is still shared when the container is restarted.
+	if c.restarting && c.mounts[idx].HostPath != "" {
+		return guestDest, false, nil
+	}
 
-		// Ignore the mount if this is not a regular file (excludes
-		// directory, socket, device, ...) as it cannot be handled by
-		// a simple copy. But this should not be treated as an error,
-		// only as a limitation.
-		if !fileInfo.Mode().IsRegular() {
-			c.Logger().WithField("ignored-file", m.Source).Debug("Ignoring non-regular file as FS sharing not supported")
-			return "", true, nil
-		}
+	// These mounts are created in the shared dir
+	mountDest := filepath.Join(hostMountDir, filename)
+	if err := bindMount(c.ctx, m.Source, mountDest, m.ReadOnly, "private"); err != nil {
+		return "", false, err
+	}
+	// Save HostPath mount value into the mount list of the container.
+	c.mounts[idx].HostPath = mountDest
 
-		if err := c.sandbox.agent.copyFile(m.Source, guestDest); err != nil {
-			return "", false, err
+	// The DNS and hosts files are replaced on the host while the
+	// container runs, the bind mount would keep the former ones.
+	if isRefreshedSharedFile(m) {
+		if err := c.sandbox.watchSharedFile(c.id, m.Source, mountDest, m.ReadOnly); err != nil {
+			c.Logger().WithError(err).WithField("source", m.Source).Warn("Could not watch file shared with the guest")
 		}
-	} else {
-		// These mounts are created in the shared dir
-		mountDest := filepath.Join(hostMountDir, filename)
-		if err := bindMount(c.ctx, m.Source, mountDest, m.ReadOnly, "private"); err != nil {
+	}
+	// bindmount remount event is not propagated to mount subtrees, so we have to remount the shared dir mountpoint directly.
+	if m.ReadOnly {
+		mountDest = filepath.Join(hostSharedDir, filename)
+		if err := remountRo(c.ctx, mountDest); err != nil {
 			return "", false, err
 		}
-		// Save HostPath mount value into the mount list of the container.
-		c.mounts[idx].HostPath = mountDest
-		// bindmount remount event is not propagated to mount subtrees, so we have to remount the shared dir mountpoint directly.
-		if m.ReadOnly {
-			mountDest = filepath.Join(hostSharedDir, filename)
-			if err := remountRo(c.ctx, mountDest); err != nil {
-				return "", false, err
-			}
-		}
 	}
 
 	return guestDest, false, nil
 }
 
+// copyFiles copies the files of a mount into the guest at guestDest.
+func (c *Container) copyFiles(m Mount, guestDest string) (string, bool, error) {
+	fileInfo, err := os.Stat(m.Source)
+	if err != nil {
+		return "", false, err
+	}
+
+	// Ignore the mount if this is not a regular file or a read-only
+	// directory (excludes writable directory, socket, device, ...)
+	// as it cannot be handled by a simple copy: the writes to a
+	// copied directory would not reach the host. But this should not
+	// be treated as an error, only as a limitation.
+	if !fileInfo.Mode().IsRegular() && !(fileInfo.IsDir() && m.ReadOnly) {
+		c.Logger().WithField("ignored-file", m.Source).Debug("Ignoring non-regular file as it can not be copied")
+		return "", true, nil
+	}
+
+	// The copy is still in the guest when the container is restarted.
+	if c.restarting {
+		return guestDest, false, nil
+	}
+
+	files, dirs, err := copyVolume(c.sandbox.agent, m.Source, guestDest)
+	if err != nil {
+		return "", false, err
+	}
+
+	// Keep the copy up to date, like Kubernetes ConfigMap and Secret
+	// volumes are when they are shared with the guest.
+	if err := c.sandbox.watchCopiedVolume(c.id, m.Source, guestDest, files, dirs); err != nil {
+		c.Logger().WithError(err).WithField("source", m.Source).Warn("Could not watch volume copied into the guest")
+	}
+
+	return guestDest, false, nil
+}
+
+// shareVirtioFSVolume shares a mount with the guest through a virtio-fs
+// device dedicated to it, with its own cache mode and DAX window.
+func (c *Container) shareVirtioFSVolume(m Mount, idx int, sharing volumeSharing) (string, error) {
+	// The device is still plugged when the container is restarted.
+	if c.restarting && m.ShareTag != "" {
+		return virtioFSVolumePath(m.ShareTag), nil
+	}
+
+	filename, err := c.sharedFileName(m)
+	if err != nil {
+		return "", err
+	}
+
+	// The mount is bind mounted out of the shared directory, to only be
+	// shared through its own device.
+	hostPath := filepath.Join(getSandboxPath(c.sandbox.id), "volumes", filename)
+	if err := bindMount(c.ctx, m.Source, hostPath, m.ReadOnly, "private"); err != nil {
+		return "", err
+	}
+	c.mounts[idx].HostPath = hostPath
+
+	randBytes, err := utils.GenerateRandomBytes(8)
+	if err != nil {
+		return "", err
+	}
+	id := hex.EncodeToString(randBytes)
+
+	dev := &config.VhostUserDeviceAttrs{
+		DevID:     id,
+		Type:      config.VhostUserFS,
+		Tag:       id,
+		Cache:     sharing.cache,
+		CacheSize: sharing.daxSize,
+		SharedDir: hostPath,
+	}
+
+	if _, err := c.sandbox.hypervisor.hotplugAddDevice(dev, vhostuserDev); err != nil {
+		return "", err
+	}
+	c.mounts[idx].ShareTag = id
+
+	return virtioFSVolumePath(id), nil
+}
+
+// sharingMechanism returns the mechanism sharing a mount with the guest,
+// empty for the filesystem sharing of the sandbox. The mechanism the mount
+// opted into is not used when it is not available.
+func (c *Container) sharingMechanism(m Mount, mechanism string) string {
+	caps := c.sandbox.hypervisor.capabilities()
+
+	var available bool
+	switch mechanism {
+	case "":
+		return ""
+	case volumeShareVirtioFS:
+		available = caps.IsFsSharingHotplugSupported()
+	case volumeShare9p:
+		// The sandbox shares files through 9p already.
+		if caps.IsFsSharingSupported() && c.sandbox.config.HypervisorConfig.SharedFS != config.VirtioFS {
+			return ""
+		}
+		available = caps.IsFsSharingSupported() && enabled9pVolumes(c.sandbox.config.HypervisorConfig)
+	case volumeShareCopy:
+		available = true
+	case volumeShareBlock, volumeSharePmem:
+		// The mount could not be attached as a block or pmem device.
+		available = false
+	}
+
+	if !available {
+		c.Logger().WithFields(logrus.Fields{
+			"mount-source": m.Source,
+			"mechanism":    mechanism,
+		}).Warn("Volume sharing mechanism not available, sharing the volume like the sandbox does")
+		return ""
+	}
+
+	return mechanism
+}
+
 // mountSharedDirMounts handles bind-mounts by bindmounting to the host shared
 // directory which is mounted through virtiofs/9pfs in the VM.
 // It also updates the container mount list with the HostPath info, and store
@@ -506,6 +808,12 @@ func (c *Container) shareFiles(m Mount, idx int, hostSharedDir, hostMountDir, gu
 func (c *Container) mountSharedDirMounts(hostSharedDir, hostMountDir, guestSharedDir string) (sharedDirMounts map[string]Mount, ignoredMounts map[string]Mount, err error) {
 	sharedDirMounts = make(map[string]Mount)
 	ignoredMounts = make(map[string]Mount)
+
+	volumes, err := c.config.volumeSharing()
+	if err != nil {
+		return nil, nil, err
+	}
+
 	var devicesToDetach []string
 	defer func() {
 		if err != nil {
@@ -525,6 +833,12 @@ func (c *Container) mountSharedDirMounts(hostSharedDir, hostMountDir, guestShare
 		// Check if mount is a block device file. If it is, the block device will be attached to the host
 		// instead of passing this as a shared mount:
 		if len(m.BlockDeviceID) > 0 {
+			// The device is already attached to the container when it
+			// gets created again inside the guest on restart.
+			if c.hasDevice(m.BlockDeviceID) {
+				continue
+			}
+
 			// Attach this block device, all other devices passed in the config have been attached at this point
 			if err = c.sandbox.devManager.AttachDevice(m.BlockDeviceID, c.sandbox); err != nil {
 				return nil, nil, err
@@ -554,7 +868,19 @@ func (c *Container) mountSharedDirMounts(hostSharedDir, hostMountDir, guestShare
 
 		var ignore bool
 		var guestDest string
-		guestDest, ignore, err = c.shareFiles(m, idx, hostSharedDir, hostMountDir, guestSharedDir)
+		switch c.sharingMechanism(m, volumes[filepath.Clean(m.Destination)].mechanism) {
+		case volumeShareVirtioFS:
+			guestDest, err = c.shareVirtioFSVolume(m, idx, volumes[filepath.Clean(m.Destination)])
+		case volumeShare9p:
+			guestDest, ignore, err = c.shareFiles(m, idx, hostSharedDir, hostMountDir, kataGuest9pSharedDir())
+		case volumeShareCopy:
+			var filename string
+			if filename, err = c.sharedFileName(m); err == nil {
+				guestDest, ignore, err = c.copyFiles(m, copiedVolumePath(filename))
+			}
+		default:
+			guestDest, ignore, err = c.shareFiles(m, idx, hostSharedDir, hostMountDir, guestSharedDir)
+		}
 		if err != nil {
 			return nil, nil, err
 		}
@@ -584,37 +910,213 @@ func (c *Container) unmountHostMounts() error {
 	span, c.ctx = c.trace("unmountHostMounts")
 	defer span.Finish()
 
-	for _, m := range c.mounts {
-		if m.HostPath != "" {
-			span, _ := c.trace("unmount")
-			span.SetTag("host-path", m.HostPath)
-
-			if err := syscall.Unmount(m.HostPath, syscall.MNT_DETACH|UmountNoFollow); err != nil {
-				c.Logger().WithFields(logrus.Fields{
-					"host-path": m.HostPath,
-					"error":     err,
-				}).Warn("Could not umount")
-				return err
-			}
+	// The files shared with the guest are not refreshed anymore once
+	// they are unmounted.
+	if c.sandbox != nil {
+		c.sandbox.volumeWatcher.remove(c.id)
+	}
 
-			if m.Type == "bind" {
-				s, err := os.Stat(m.HostPath)
-				if err != nil {
-					return errors.Wrapf(err, "Could not stat host-path %v", m.HostPath)
-				}
-				// Remove the empty file or directory
-				if s.Mode().IsRegular() && s.Size() == 0 {
-					os.Remove(m.HostPath)
-				}
-				if s.Mode().IsDir() {
-					syscall.Rmdir(m.HostPath)
-				}
+	for idx := range c.mounts {
+		if err := c.unmountHostMount(idx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// unmountHostMount undoes the sharing of a mount with the guest.
+func (c *Container) unmountHostMount(idx int) error {
+	m := c.mounts[idx]
+
+	// Mounts shared through a dedicated virtio-fs device are
+	// unplugged before being unmounted.
+	if m.ShareTag != "" {
+		dev := &config.VhostUserDeviceAttrs{
+			DevID: m.ShareTag,
+			Type:  config.VhostUserFS,
+		}
+
+		if _, err := c.sandbox.hypervisor.hotplugRemoveDevice(dev, vhostuserDev); err != nil {
+			c.Logger().WithError(err).WithField("tag", m.ShareTag).Warn("Could not unplug virtio-fs device")
+			return err
+		}
+		c.mounts[idx].ShareTag = ""
+	}
+
+	if m.HostPath != "" {
+		span, _ := c.trace("unmount")
+		span.SetTag("host-path", m.HostPath)
+		defer span.Finish()
+
+		if err := syscall.Unmount(m.HostPath, syscall.MNT_DETACH|UmountNoFollow); err != nil {
+			c.Logger().WithFields(logrus.Fields{
+				"host-path": m.HostPath,
+				"error":     err,
+			}).Warn("Could not umount")
+			return err
+		}
+
+		if m.Type == "bind" {
+			s, err := os.Stat(m.HostPath)
+			if err != nil {
+				return errors.Wrapf(err, "Could not stat host-path %v", m.HostPath)
+			}
+			// Remove the empty file or directory
+			if s.Mode().IsRegular() && s.Size() == 0 {
+				os.Remove(m.HostPath)
 			}
+			if s.Mode().IsDir() {
+				syscall.Rmdir(m.HostPath)
+			}
+		}
+	}
+
+	return nil
+}
+
+// shareMount shares a mount added to a running container with the guest.
+// The agent can not mount anything into a running container, the mount
+// source is instead bind mounted on the host at its destination in the
+// container rootfs shared with the guest, where the guest sees it through
+// the shared filesystem.
+func (c *Container) shareMount(idx int) error {
+	m := c.mounts[idx]
+
+	caps := c.sandbox.hypervisor.capabilities()
+	if !caps.IsFsSharingSupported() {
+		return fmt.Errorf("Mounts can not be added to a container when filesystem sharing is not supported")
+	}
+
+	if c.state.Fstype != "" && c.state.BlockDeviceID != "" {
+		return fmt.Errorf("Mounts can not be added to a container whose rootfs is a block device")
+	}
 
-			span.Finish()
+	var stat unix.Stat_t
+	if err := unix.Stat(m.Source, &stat); err != nil {
+		return fmt.Errorf("stat %q failed: %v", m.Source, err)
+	}
+
+	if stat.Mode&unix.S_IFBLK == unix.S_IFBLK {
+		return fmt.Errorf("Block device %s can not be added to a running container", m.Source)
+	}
+
+	// The container rootfs is mounted in the mounts directory and only
+	// shows up in the shared directory, the mount point is created from
+	// the former and the mount is done in the latter, so that the guest
+	// sees it.
+	rootfs := filepath.Join(getMountPath(c.sandbox.id), c.id, rootfsDir)
+	mountPoint, err := containerRootfsPath(rootfs, m.Destination)
+	if err != nil {
+		return err
+	}
+
+	if err := ensureDestinationExists(m.Source, mountPoint); err != nil {
+		return fmt.Errorf("Could not create mount point %v: %v", mountPoint, err)
+	}
+
+	hostPath := filepath.Join(getSharePath(c.sandbox.id), c.id, rootfsDir, m.Destination)
+	if err := bindMount(c.ctx, m.Source, hostPath, m.ReadOnly, "private"); err != nil {
+		return err
+	}
+	c.mounts[idx].HostPath = hostPath
+
+	return nil
+}
+
+// containerRootfsPath returns the host path of destination in the container
+// rootfs. The path is refused if it goes through a symbolic link, as the link
+// would be resolved on the host instead of in the container.
+func containerRootfsPath(rootfs, destination string) (string, error) {
+	path := rootfs
+	for _, elem := range strings.Split(filepath.Clean("/"+destination), "/") {
+		if elem == "" {
+			continue
+		}
+
+		path = filepath.Join(path, elem)
+		fi, err := os.Lstat(path)
+		if os.IsNotExist(err) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+
+		if fi.Mode()&os.ModeSymlink != 0 {
+			return "", fmt.Errorf("Mount destination %s goes through symbolic link %s", destination, path)
 		}
 	}
 
+	return filepath.Join(rootfs, filepath.Clean("/"+destination)), nil
+}
+
+// mountIndex returns the index of the mount at destination, -1 if there is
+// none.
+func (c *Container) mountIndex(destination string) int {
+	for i, m := range c.mounts {
+		if filepath.Clean(m.Destination) == filepath.Clean(destination) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// addMount adds a mount to a running container.
+func (c *Container) addMount(m Mount) (err error) {
+	if err := c.checkSandboxRunning("add a mount to"); err != nil {
+		return err
+	}
+
+	if c.state.State != types.StateReady && c.state.State != types.StateRunning {
+		return fmt.Errorf("Container not ready or running, impossible to add a mount")
+	}
+
+	if m.Type != "bind" {
+		return fmt.Errorf("Only bind mounts can be added to a container, not %q", m.Type)
+	}
+
+	if c.mountIndex(m.Destination) >= 0 {
+		return fmt.Errorf("Mount destination %s already used", m.Destination)
+	}
+
+	c.mounts = append(c.mounts, m)
+	idx := len(c.mounts) - 1
+
+	defer func() {
+		if err != nil {
+			if err := c.unmountHostMount(idx); err != nil {
+				c.Logger().WithError(err).WithField("mount-source", m.Source).Warn("Could not roll back mount")
+			}
+			c.mounts = c.mounts[:idx]
+		}
+	}()
+
+	return c.shareMount(idx)
+}
+
+// removeMount removes the mount at destination from a running container.
+func (c *Container) removeMount(destination string) error {
+	if err := c.checkSandboxRunning("remove a mount from"); err != nil {
+		return err
+	}
+
+	if c.state.State != types.StateReady && c.state.State != types.StateRunning {
+		return fmt.Errorf("Container not ready or running, impossible to remove a mount")
+	}
+
+	idx := c.mountIndex(destination)
+	if idx < 0 {
+		return fmt.Errorf("Mount %s not found", destination)
+	}
+
+	if err := c.unmountHostMount(idx); err != nil {
+		return err
+	}
+
+	c.mounts = append(c.mounts[:idx], c.mounts[idx+1:]...)
+
 	return nil
 }
 
@@ -645,10 +1147,29 @@ func filterDevices(c *Container, devices []ContainerDevice) (ret []ContainerDevi
 // is a block device.
 func (c *Container) createBlockDevices() error {
 	if !c.checkBlockDeviceSupport() {
+		// The size limit of a local volume is enforced by a block device.
+		for _, m := range c.mounts {
+			if size, err := MountSizeLimit(m.Options); m.Type == KataLocalDevType && (err != nil || size > 0) {
+				return fmt.Errorf("Can not limit the size of local volume %s: block devices not supported", m.Destination)
+			}
+		}
+
 		c.Logger().Warn("Block device not supported")
 		return nil
 	}
 
+	imageVolumes := c.config.imageVolumes()
+
+	volumes, err := c.config.volumeSharing()
+	if err != nil {
+		return err
+	}
+	for destination, sharing := range volumes {
+		if _, ok := imageVolumes[destination]; !ok && sharing.mechanism == volumeShareBlock {
+			imageVolumes[destination] = ""
+		}
+	}
+
 	// iterate all mounts and create block device if it's block based.
 	for i, m := range c.mounts {
 		if len(m.BlockDeviceID) > 0 {
@@ -658,6 +1179,23 @@ func (c *Container) createBlockDevices() error {
 			continue
 		}
 
+		if m.Type == KataLocalDevType {
+			if err := c.createLocalVolumeDevice(i); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if sharing := volumes[filepath.Clean(m.Destination)]; m.Type == KataPmemDevType || sharing.mechanism == volumeSharePmem {
+			created, err := c.createPmemVolumeDevice(i, sharing.fstype)
+			if err != nil {
+				return err
+			}
+			if created {
+				continue
+			}
+		}
+
 		if m.Type != "bind" {
 			// We only handle for bind-mounts
 			continue
@@ -671,6 +1209,8 @@ func (c *Container) createBlockDevices() error {
 		var di *config.DeviceInfo
 		var err error
 
+		fstype, isImage := imageVolumes[filepath.Clean(m.Destination)]
+
 		// Check if mount is a block device file. If it is, the block device will be attached to the host
 		// instead of passing this as a shared mount.
 		if stat.Mode&unix.S_IFBLK == unix.S_IFBLK {
@@ -682,6 +1222,29 @@ func (c *Container) createBlockDevices() error {
 				Minor:         int64(unix.Minor(stat.Rdev)),
 				ReadOnly:      m.ReadOnly,
 			}
+			// check whether source is a disk image file to attach as a block device
+		} else if isImage && stat.Mode&unix.S_IFMT == unix.S_IFREG {
+			if fstype == "" {
+				fstype, err = imageFsType(m.Source)
+			} else {
+				err = checkRawImage(m.Source)
+			}
+			if err != nil {
+				return err
+			}
+
+			di = &config.DeviceInfo{
+				HostPath:      m.Source,
+				ContainerPath: m.Destination,
+				DevType:       "b",
+				DiskImage:     true,
+				ReadOnly:      m.ReadOnly,
+			}
+
+			// The filesystem of the image is mounted in the guest,
+			// instead of the block device being bind mounted.
+			c.mounts[i].Type = fstype
+			c.mounts[i].Options = imageMountOptions(m.Options)
 			// check whether source can be used as a pmem device
 		} else if di, err = config.PmemDeviceInfo(m.Source, m.Destination); err != nil {
 			c.Logger().WithError(err).
@@ -707,6 +1270,70 @@ func (c *Container) createBlockDevices() error {
 	return nil
 }
 
+// createPmemVolumeDevice exposes the source of a pmem volume to the VM as a
+// persistent memory device, mounted with DAX in the guest. A bind mount
+// opting into it through the VolumeSharing annotation is shared like the
+// sandbox does when the hypervisor can not hotplug such devices.
+func (c *Container) createPmemVolumeDevice(idx int, fstype string) (bool, error) {
+	m := c.mounts[idx]
+
+	caps := c.sandbox.hypervisor.capabilities()
+	if !caps.IsPmemHotplugSupported() {
+		if m.Type == KataPmemDevType {
+			return false, fmt.Errorf("Could not attach pmem volume %s: persistent memory hotplug not supported", m.Destination)
+		}
+		return false, nil
+	}
+
+	di, err := config.PmemVolumeDeviceInfo(m.Source, m.Destination, fstype, m.ReadOnly)
+	if err != nil {
+		return false, err
+	}
+
+	b, err := c.sandbox.devManager.NewDevice(*di)
+	if err != nil {
+		return false, err
+	}
+
+	c.mounts[idx].BlockDeviceID = b.DeviceID()
+
+	return true, nil
+}
+
+// createLocalVolumeDevice backs a local volume with a size limit by a disk
+// image of that size, so that the limit is enforced in the guest.
+func (c *Container) createLocalVolumeDevice(idx int) error {
+	m := c.mounts[idx]
+
+	size, err := MountSizeLimit(m.Options)
+	if err != nil {
+		return err
+	}
+	if size == 0 {
+		return nil
+	}
+
+	image := filepath.Join(m.Source, localVolumeImage)
+	if err := createLocalVolumeImage(image, size); err != nil {
+		return fmt.Errorf("Could not create the image limiting the size of local volume %s: %v", m.Destination, err)
+	}
+
+	// The image is shared by all the containers using the volume.
+	b, err := c.sandbox.devManager.NewDevice(config.DeviceInfo{
+		HostPath:      image,
+		ContainerPath: m.Destination,
+		DevType:       "b",
+		DiskImage:     true,
+	})
+	if err != nil {
+		return fmt.Errorf("Could not create the device limiting the size of local volume %s: %v", m.Destination, err)
+	}
+
+	c.mounts[idx].BlockDeviceID = b.DeviceID()
+
+	return nil
+}
+
 // newContainer creates a Container structure from a sandbox and a container configuration.
 func newContainer(sandbox *Sandbox, contConfig *ContainerConfig) (*Container, error) {
 	span, _ := sandbox.trace("newContainer")
@@ -822,6 +1449,7 @@ func (c *Container) createDevices(contConfig *ContainerConfig) error {
 	// from the configuration. This should happen at create.
 	var storedDevices []ContainerDevice
 	for _, info := range contConfig.DeviceInfos {
+		info.Throttle = blockDeviceThrottle(contConfig.Resources.BlockIO, info.Major, info.Minor)
 		dev, err := c.sandbox.devManager.NewDevice(info)
 		if err != nil {
 			return err
@@ -945,6 +1573,10 @@ func (c *Container) create() (err error) {
 		}
 	}
 
+	if err = c.throttleBlockDevices(nil, c.config.Resources.BlockIO); err != nil {
+		return
+	}
+
 	if !rootless.IsRootless() && !c.sandbox.config.SandboxCgroupOnly {
 		if err = c.cgroupsCreate(); err != nil {
 			return
@@ -1151,6 +1783,81 @@ func (c *Container) stop(force bool) error {
 	return nil
 }
 
+// restart runs the process of a container which is not running again
+// inside the guest. A created container is created again in the guest,
+// reusing the rootfs, mounts and devices already shared with it. The host
+// side of a stopped container has been released by stop(), it is prepared
+// again like create() does. A running container must be stopped first, by
+// whoever waits for its process.
+func (c *Container) restart() (err error) {
+	span, _ := c.trace("restart")
+	defer span.Finish()
+
+	if err := c.checkSandboxRunning("restart"); err != nil {
+		return err
+	}
+
+	switch c.state.State {
+	case types.StateReady:
+		err = c.recreate()
+	case types.StateStopped:
+		err = c.create()
+	default:
+		return fmt.Errorf("Container not ready or stopped, " +
+			"impossible to restart")
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.start()
+}
+
+// recreate removes a created container from the guest and creates it again,
+// reusing the rootfs, mounts and devices shared with the guest. On failure,
+// the host side of the container is released and the container is stopped.
+func (c *Container) recreate() (err error) {
+	if running, _ := isShimRunning(c.process.Pid); running {
+		if err := stopShim(c.process.Pid); err != nil {
+			c.Logger().WithError(err).Warn("failed to stop shim")
+		}
+	}
+
+	if err = c.sandbox.agent.stopContainer(c.sandbox, *c); err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			c.Logger().WithError(err).Error("container restart failed")
+			c.rollbackFailingContainerCreation()
+			if err := c.setContainerState(types.StateStopped); err != nil {
+				c.Logger().WithError(err).Error("rollback failed setContainerState()")
+			}
+		}
+	}()
+
+	c.restarting = true
+	defer func() {
+		c.restarting = false
+	}()
+
+	process, err := c.sandbox.agent.createContainer(c.sandbox, c)
+	if err != nil {
+		return err
+	}
+	c.process = *process
+
+	// Move the new shim into the container cgroup.
+	if !rootless.IsRootless() && !c.sandbox.config.SandboxCgroupOnly {
+		if err = c.cgroupsCreate(); err != nil {
+			return err
+		}
+	}
+
+	return c.setContainerState(types.StateReady)
+}
+
 func (c *Container) enter(cmd types.Cmd) (*Process, error) {
 	if err := c.checkSandboxRunning("enter"); err != nil {
 		return nil, err
@@ -1242,6 +1949,15 @@ func (c *Container) update(resources specs.LinuxResources) error {
 		return fmt.Errorf("Container(%s) not running or ready, impossible to update", state)
 	}
 
+	// The drives are throttled first, so that a failure leaves the other
+	// resources unchanged.
+	if blkio := resources.BlockIO; blkio != nil {
+		if err := c.throttleBlockDevices(c.config.Resources.BlockIO, blkio); err != nil {
+			return err
+		}
+		c.config.Resources.BlockIO = blkio
+	}
+
 	if c.config.Resources.CPU == nil {
 		c.config.Resources.CPU = &specs.LinuxCPU{}
 	}
@@ -1290,6 +2006,103 @@ func (c *Container) update(resources specs.LinuxResources) error {
 	return c.sandbox.agent.updateContainer(c.sandbox, *c, resources)
 }
 
+// blockDriveThrottles returns the limits of the throttle settings of blkio,
+// indexed by the "major:minor" numbers of the host devices they apply to.
+func blockDriveThrottles(blkio *specs.LinuxBlockIO) map[string]*config.BlockDriveThrottle {
+	throttles := make(map[string]*config.BlockDriveThrottle)
+	if blkio == nil {
+		return throttles
+	}
+
+	set := func(devices []specs.LinuxThrottleDevice, limit func(*config.BlockDriveThrottle) *uint64) {
+		for _, d := range devices {
+			key := fmt.Sprintf("%d:%d", d.Major, d.Minor)
+			if throttles[key] == nil {
+				throttles[key] = &config.BlockDriveThrottle{}
+			}
+			*limit(throttles[key]) = d.Rate
+		}
+	}
+
+	set(blkio.ThrottleReadBpsDevice, func(t *config.BlockDriveThrottle) *uint64 { return &t.ReadBps })
+	set(blkio.ThrottleWriteBpsDevice, func(t *config.BlockDriveThrottle) *uint64 { return &t.WriteBps })
+	set(blkio.ThrottleReadIOPSDevice, func(t *config.BlockDriveThrottle) *uint64 { return &t.ReadIOPS })
+	set(blkio.ThrottleWriteIOPSDevice, func(t *config.BlockDriveThrottle) *uint64 { return &t.WriteIOPS })
+
+	return throttles
+}
+
+// blockDeviceThrottle returns the limits of the throttle settings of blkio
+// for the host block device major:minor.
+func blockDeviceThrottle(blkio *specs.LinuxBlockIO, major, minor int64) config.BlockDriveThrottle {
+	if throttle, ok := blockDriveThrottles(blkio)[fmt.Sprintf("%d:%d", major, minor)]; ok {
+		return *throttle
+	}
+
+	return config.BlockDriveThrottle{}
+}
+
+// throttleBlockDevices applies the blkio throttle settings of the container
+// to the drives attached from the host devices they refer to, so that the
+// limits are enforced by the hypervisor and not only by the guest cgroups.
+// The drives throttled by previous and not by blkio are made unlimited, the
+// drives whose limits are not changed are left alone.
+func (c *Container) throttleBlockDevices(previous, blkio *specs.LinuxBlockIO) error {
+	throttles := blockDriveThrottles(blkio)
+	previousThrottles := blockDriveThrottles(previous)
+	for key := range previousThrottles {
+		if throttles[key] == nil {
+			throttles[key] = &config.BlockDriveThrottle{}
+		}
+	}
+	for key, throttle := range throttles {
+		if p := previousThrottles[key]; p != nil && *p == *throttle {
+			delete(throttles, key)
+		}
+	}
+
+	if len(throttles) == 0 {
+		return nil
+	}
+
+	var ids []string
+	for _, d := range c.devices {
+		ids = append(ids, d.ID)
+	}
+	if c.state.BlockDeviceID != "" {
+		ids = append(ids, c.state.BlockDeviceID)
+	}
+
+	for _, id := range ids {
+		device := c.sandbox.devManager.GetDeviceByID(id)
+		if device == nil || device.DeviceType() != config.DeviceBlock {
+			continue
+		}
+
+		major, minor := device.GetMajorMinor()
+		throttle, ok := throttles[fmt.Sprintf("%d:%d", major, minor)]
+		if !ok {
+			continue
+		}
+
+		drive, ok := device.GetDeviceInfo().(*config.BlockDrive)
+		if !ok || drive == nil {
+			continue
+		}
+
+		c.Logger().WithFields(logrus.Fields{
+			"device":   device.GetHostPath(),
+			"throttle": *throttle,
+		}).Info("Throttling block device")
+
+		if err := c.sandbox.hypervisor.throttleBlockDevice(drive, *throttle); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (c *Container) pause() error {
 	if err := c.checkSandboxRunning("pause"); err != nil {
 		return err
@@ -1399,12 +2212,16 @@ func (c *Container) plugDevice(devicePath string) error {
 	}
 
 	if c.checkBlockDeviceSupport() && stat.Mode&unix.S_IFBLK == unix.S_IFBLK {
+		major := int64(unix.Major(stat.Rdev))
+		minor := int64(unix.Minor(stat.Rdev))
 		b, err := c.sandbox.devManager.NewDevice(config.DeviceInfo{
 			HostPath:      devicePath,
 			ContainerPath: filepath.Join(kataGuestSharedDir(), c.id),
 			DevType:       "b",
-			Major:         int64(unix.Major(stat.Rdev)),
-			Minor:         int64(unix.Minor(stat.Rdev)),
+			Major:         major,
+			Minor:         minor,
+			ReadOnly:      c.config.ReadonlyRootfs,
+			Throttle:      blockDeviceThrottle(c.config.Resources.BlockIO, major, minor),
 		})
 		if err != nil {
 			return fmt.Errorf("device manager failed to create rootfs device for %q: %v", devicePath, err)
@@ -1425,6 +2242,29 @@ func (c *Container) isDriveUsed() bool {
 	return !(c.state.Fstype == "")
 }
 
+// guestFilesystems returns the container paths of the writable filesystems
+// which only live inside the VM, hence whose usage the host can't see: the
+// rootfs when it is backed by a block device, and the volumes that are not
+// shared with the host.
+func (c *Container) guestFilesystems() []string {
+	var paths []string
+
+	if c.isDriveUsed() && !c.config.ReadonlyRootfs {
+		paths = append(paths, "/")
+	}
+
+	for _, m := range c.mounts {
+		if m.ReadOnly {
+			continue
+		}
+		if m.BlockDeviceID != "" || m.Type == KataLocalDevType || m.Type == KataEphemeralDevType {
+			paths = append(paths, m.Destination)
+		}
+	}
+
+	return paths
+}
+
 func (c *Container) removeDrive() (err error) {
 	if c.isDriveUsed() {
 		c.Logger().Info("unplugging block device")
@@ -1467,6 +2307,16 @@ func (c *Container) attachDevices(devices []ContainerDevice) error {
 	return nil
 }
 
+// hasDevice returns true if the device is attached to the container.
+func (c *Container) hasDevice(id string) bool {
+	for _, dev := range c.devices {
+		if dev.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
 func (c *Container) detachDevices() error {
 	for _, dev := range c.devices {
 		err := c.sandbox.devManager.DetachDevice(dev.ID, c.sandbox)
@@ -7,6 +7,8 @@ package virtcontainers
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"os/exec"
@@ -21,7 +23,9 @@ import (
 	"github.com/kata-containers/runtime/virtcontainers/device/drivers"
 	"github.com/kata-containers/runtime/virtcontainers/device/manager"
 	"github.com/kata-containers/runtime/virtcontainers/persist"
+	vcAnnotations "github.com/kata-containers/runtime/virtcontainers/pkg/annotations"
 	"github.com/kata-containers/runtime/virtcontainers/types"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -347,6 +351,683 @@ func TestContainerAddDriveDir(t *testing.T) {
 	assert.NotEmpty(container.state.Fstype)
 }
 
+func TestContainerConfigImageVolumes(t *testing.T) {
+	assert := assert.New(t)
+
+	config := &ContainerConfig{}
+	assert.Empty(config.imageVolumes())
+
+	config.Annotations = map[string]string{
+		vcAnnotations.ImageVolumes: "/data, /models/=xfs,/mnt/a:b,,",
+	}
+	assert.Equal(map[string]string{
+		"/data":    "",
+		"/models":  "xfs",
+		"/mnt/a:b": "",
+	}, config.imageVolumes())
+}
+
+// blockHotplugHypervisor is a mock hypervisor supporting block device hotplug.
+type blockHotplugHypervisor struct {
+	mockHypervisor
+}
+
+func (h *blockHotplugHypervisor) capabilities() types.Capabilities {
+	var caps types.Capabilities
+	caps.SetBlockDeviceHotplugSupport()
+	return caps
+}
+
+func TestContainerConfigVolumeSharing(t *testing.T) {
+	assert := assert.New(t)
+
+	c := &ContainerConfig{
+		Annotations: map[string]string{
+			vcAnnotations.VolumeSharing: "/data/=virtio-fs:cache=always:dax=1024, /logs=9p,/config=copy,/disk.img=block,/db=pmem:fstype=xfs",
+		},
+	}
+
+	volumes, err := c.volumeSharing()
+	assert.NoError(err)
+	assert.Equal(map[string]volumeSharing{
+		"/data":     {mechanism: volumeShareVirtioFS, cache: "always", daxSize: 1024},
+		"/logs":     {mechanism: volumeShare9p},
+		"/config":   {mechanism: volumeShareCopy},
+		"/disk.img": {mechanism: volumeShareBlock},
+		"/db":       {mechanism: volumeSharePmem, fstype: "xfs"},
+	}, volumes)
+
+	for _, invalid := range []string{
+		"/data",
+		"/data=nfs",
+		"/data=virtio-fs:cache=sometimes",
+		"/data=virtio-fs:dax=large",
+		"/data=virtio-fs:readonly",
+		"/data=9p:cache=none",
+		"/data=pmem:cache=none",
+		"/data=virtio-fs:fstype=xfs",
+	} {
+		c.Annotations[vcAnnotations.VolumeSharing] = invalid
+		_, err = c.volumeSharing()
+		assert.Error(err, invalid)
+	}
+}
+
+// fsSharingHypervisor is a mock hypervisor with the given capabilities,
+// recording the vhost-user devices hotplugged.
+type fsSharingHypervisor struct {
+	mockHypervisor
+
+	caps    types.Capabilities
+	plugged map[string]*config.VhostUserDeviceAttrs
+}
+
+func (h *fsSharingHypervisor) capabilities() types.Capabilities {
+	return h.caps
+}
+
+func (h *fsSharingHypervisor) hotplugAddDevice(devInfo interface{}, devType deviceType) (interface{}, error) {
+	if dev, ok := devInfo.(*config.VhostUserDeviceAttrs); ok && devType == vhostuserDev {
+		h.plugged[dev.DevID] = dev
+	}
+	return nil, nil
+}
+
+func (h *fsSharingHypervisor) hotplugRemoveDevice(devInfo interface{}, devType deviceType) (interface{}, error) {
+	if dev, ok := devInfo.(*config.VhostUserDeviceAttrs); ok && devType == vhostuserDev {
+		delete(h.plugged, dev.DevID)
+	}
+	return nil, nil
+}
+
+func TestContainerSharingMechanism(t *testing.T) {
+	assert := assert.New(t)
+
+	var sharing, hotplug types.Capabilities
+	sharing.SetFsSharingSupport()
+	hotplug.SetFsSharingSupport()
+	hotplug.SetFsSharingHotplugSupport()
+
+	tests := []struct {
+		caps      types.Capabilities
+		sharedFS  string
+		enable9p  bool
+		mechanism string
+		expected  string
+	}{
+		{sharing, config.VirtioFS, false, "", ""},
+		{hotplug, config.VirtioFS, false, volumeShareVirtioFS, volumeShareVirtioFS},
+		{sharing, config.Virtio9P, false, volumeShareVirtioFS, ""},
+		{sharing, config.VirtioFS, true, volumeShare9p, volumeShare9p},
+		{sharing, config.VirtioFS, false, volumeShare9p, ""},
+		{sharing, config.Virtio9P, false, volumeShare9p, ""},
+		{types.Capabilities{}, config.VirtioFS, true, volumeShare9p, ""},
+		{sharing, config.VirtioFS, false, volumeShareCopy, volumeShareCopy},
+		{sharing, config.VirtioFS, false, volumeShareBlock, ""},
+	}
+
+	for _, test := range tests {
+		c := &Container{
+			sandbox: &Sandbox{
+				hypervisor: &fsSharingHypervisor{caps: test.caps},
+				config: &SandboxConfig{
+					HypervisorConfig: HypervisorConfig{
+						SharedFS:        test.sharedFS,
+						Enable9pVolumes: test.enable9p,
+					},
+				},
+			},
+		}
+
+		assert.Equal(test.expected, c.sharingMechanism(Mount{Source: "/volume"}, test.mechanism), "%+v", test)
+	}
+}
+
+func TestIsRefreshedSharedFile(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "refreshed")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "resolv.conf")
+	assert.NoError(ioutil.WriteFile(file, []byte("nameserver 10.0.0.1\n"), 0644))
+
+	assert.True(isRefreshedSharedFile(Mount{Source: file, Destination: "/etc/resolv.conf"}))
+	assert.True(isRefreshedSharedFile(Mount{Source: file, Destination: "/etc/hosts"}))
+	assert.True(isRefreshedSharedFile(Mount{Source: file, Destination: "/etc//hostname"}))
+	assert.False(isRefreshedSharedFile(Mount{Source: file, Destination: "/etc/config"}))
+	assert.False(isRefreshedSharedFile(Mount{Source: dir, Destination: "/etc/hosts"}))
+	assert.False(isRefreshedSharedFile(Mount{Source: filepath.Join(dir, "missing"), Destination: "/etc/hosts"}))
+}
+
+func TestContainerMountSharedDirMountsVolumeSharing(t *testing.T) {
+	if tc.NotValid(ktu.NeedRoot()) {
+		t.Skip(ktu.TestDisabledNeedRoot)
+	}
+
+	assert := assert.New(t)
+
+	tmpDir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(tmpDir)
+
+	savedHostSharedDir := kataHostSharedDir
+	kataHostSharedDir = func() string {
+		return filepath.Join(tmpDir, "shared")
+	}
+	defer func() {
+		kataHostSharedDir = savedHostSharedDir
+	}()
+
+	dataDir := filepath.Join(tmpDir, "data")
+	configDir := filepath.Join(tmpDir, "config")
+	assert.NoError(os.MkdirAll(dataDir, 0755))
+	assert.NoError(os.MkdirAll(configDir, 0755))
+	assert.NoError(ioutil.WriteFile(filepath.Join(configDir, "key"), []byte("value"), 0644))
+
+	var caps types.Capabilities
+	caps.SetFsSharingSupport()
+	caps.SetFsSharingHotplugSupport()
+	h := &fsSharingHypervisor{caps: caps, plugged: make(map[string]*config.VhostUserDeviceAttrs)}
+	agent := &copyFileAgent{copied: make(map[string]string)}
+
+	sandbox := &Sandbox{
+		ctx:        context.Background(),
+		id:         "sandbox",
+		agent:      agent,
+		hypervisor: h,
+		config:     &SandboxConfig{HypervisorConfig: HypervisorConfig{SharedFS: config.VirtioFS}},
+		stateful:   true,
+	}
+	defer func() {
+		if sandbox.volumeWatcher != nil {
+			sandbox.volumeWatcher.close()
+		}
+	}()
+
+	c := &Container{
+		ctx:     context.Background(),
+		sandbox: sandbox,
+		id:      "container",
+		config: &ContainerConfig{
+			Annotations: map[string]string{
+				vcAnnotations.VolumeSharing: "/data=virtio-fs:cache=always:dax=512,/config=copy",
+			},
+		},
+		mounts: []Mount{
+			{Source: dataDir, Destination: "/data", Type: "bind"},
+			{Source: configDir, Destination: "/config", Type: "bind", ReadOnly: true},
+		},
+	}
+
+	sharedDirMounts, ignoredMounts, err := c.mountSharedDirMounts("", "", kataGuestSharedDir())
+	assert.NoError(err)
+	assert.Empty(ignoredMounts)
+	assert.Len(sharedDirMounts, 2)
+
+	// The data volume has its own virtio-fs device.
+	tag := c.mounts[0].ShareTag
+	assert.NotEmpty(tag)
+	assert.Len(h.plugged, 1)
+	assert.Equal(tag, h.plugged[tag].Tag)
+	assert.Equal("always", h.plugged[tag].Cache)
+	assert.Equal(uint32(512), h.plugged[tag].CacheSize)
+	assert.Equal(c.mounts[0].HostPath, h.plugged[tag].SharedDir)
+	assert.Equal(virtioFSVolumePath(tag), sharedDirMounts["/data"].Source)
+
+	// The config volume is copied out of the shared directory.
+	guestConfig := sharedDirMounts["/config"].Source
+	assert.True(strings.HasPrefix(guestConfig, copiedVolumePath("")))
+	assert.Equal("value", agent.get(filepath.Join(guestConfig, "key")))
+
+	assert.NoError(c.unmountHostMounts())
+	assert.Empty(h.plugged)
+	assert.Empty(c.mounts[0].ShareTag)
+}
+
+func TestContainerRootfsDestinationPath(t *testing.T) {
+	assert := assert.New(t)
+
+	rootfs, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(rootfs)
+
+	assert.NoError(os.MkdirAll(filepath.Join(rootfs, "var", "lib"), 0755))
+	assert.NoError(os.Symlink("/", filepath.Join(rootfs, "host")))
+
+	path, err := containerRootfsPath(rootfs, "/var/lib/data")
+	assert.NoError(err)
+	assert.Equal(filepath.Join(rootfs, "var", "lib", "data"), path)
+
+	path, err = containerRootfsPath(rootfs, "../../data")
+	assert.NoError(err)
+	assert.Equal(filepath.Join(rootfs, "data"), path)
+
+	_, err = containerRootfsPath(rootfs, "/host/data")
+	assert.Error(err)
+}
+
+func TestContainerAddRemoveMount(t *testing.T) {
+	if tc.NotValid(ktu.NeedRoot()) {
+		t.Skip(ktu.TestDisabledNeedRoot)
+	}
+
+	assert := assert.New(t)
+
+	tmpDir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(tmpDir)
+
+	savedHostSharedDir := kataHostSharedDir
+	kataHostSharedDir = func() string {
+		return filepath.Join(tmpDir, "shared")
+	}
+	defer func() {
+		kataHostSharedDir = savedHostSharedDir
+	}()
+
+	dataDir := filepath.Join(tmpDir, "data")
+	assert.NoError(os.MkdirAll(dataDir, 0755))
+
+	var caps types.Capabilities
+	caps.SetFsSharingSupport()
+	h := &fsSharingHypervisor{caps: caps}
+
+	sandbox := &Sandbox{
+		ctx:        context.Background(),
+		id:         "sandbox",
+		agent:      &noopAgent{},
+		hypervisor: h,
+		config:     &SandboxConfig{},
+	}
+	sandbox.state.State = types.StateRunning
+
+	c := &Container{
+		ctx:     context.Background(),
+		sandbox: sandbox,
+		id:      "container",
+		config:  &ContainerConfig{},
+	}
+	c.state.State = types.StateRunning
+
+	rootfs := filepath.Join(getMountPath(sandbox.id), c.id, rootfsDir)
+	assert.NoError(os.MkdirAll(rootfs, 0755))
+
+	m := Mount{Source: dataDir, Destination: "/data", Type: "bind", Options: []string{"rbind"}}
+
+	// Only bind mounts can be added.
+	assert.Error(c.addMount(Mount{Source: "tmpfs", Destination: "/tmp", Type: "tmpfs"}))
+
+	// A mount that can not be shared is rolled back.
+	assert.Error(c.addMount(Mount{Source: filepath.Join(tmpDir, "missing"), Destination: "/missing", Type: "bind"}))
+	assert.Empty(c.mounts)
+
+	// Symbolic links of the container rootfs are not followed.
+	assert.NoError(os.Symlink(tmpDir, filepath.Join(rootfs, "link")))
+	assert.Error(c.addMount(Mount{Source: dataDir, Destination: "/link/data", Type: "bind"}))
+	assert.Empty(c.mounts)
+
+	assert.NoError(c.addMount(m))
+	assert.Len(c.mounts, 1)
+	assert.Equal(filepath.Join(getSharePath(sandbox.id), c.id, rootfsDir, "data"), c.mounts[0].HostPath)
+	_, err = os.Stat(filepath.Join(rootfs, "data"))
+	assert.NoError(err)
+
+	// A destination can only be mounted once.
+	assert.Error(c.addMount(m))
+
+	hostPath := c.mounts[0].HostPath
+	assert.NoError(c.removeMount("/data/"))
+	assert.Empty(c.mounts)
+	_, err = os.Stat(hostPath)
+	assert.True(os.IsNotExist(err))
+
+	assert.Error(c.removeMount("/data"))
+
+	// Mounts can only be added when the filesystem is shared.
+	h.caps = types.Capabilities{}
+	assert.Error(c.addMount(m))
+	assert.Empty(c.mounts)
+	h.caps = caps
+
+	// Mounts can not be changed when the sandbox is not running.
+	sandbox.state.State = types.StateStopped
+	assert.Error(c.addMount(m))
+	assert.Empty(c.mounts)
+}
+
+func TestContainerCreatePmemVolume(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpDir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(tmpDir)
+
+	// The PFN signature is at 4KiB into the file.
+	data := make([]byte, 8192)
+	copy(data[4096:], "NVDIMM_PFN_INFO")
+	var files []string
+	for _, name := range []string{"db.img", "pmem.img"} {
+		file := filepath.Join(tmpDir, name)
+		assert.NoError(ioutil.WriteFile(file, data, 0644))
+		files = append(files, file)
+	}
+
+	var caps types.Capabilities
+	caps.SetBlockDeviceHotplugSupport()
+	h := &fsSharingHypervisor{caps: caps}
+
+	sandbox := &Sandbox{
+		ctx:        context.Background(),
+		id:         "sandbox",
+		devManager: manager.NewDeviceManager(manager.VirtioBlock, false, "", nil),
+		agent:      &kataAgent{},
+		hypervisor: h,
+		config:     &SandboxConfig{},
+	}
+
+	newContainer := func() *Container {
+		return &Container{
+			sandbox: sandbox,
+			id:      "testContainer",
+			config: &ContainerConfig{
+				Annotations: map[string]string{
+					vcAnnotations.VolumeSharing: "/db=pmem:fstype=xfs",
+				},
+			},
+			mounts: []Mount{
+				{
+					Source:      files[0],
+					Destination: "/db",
+					Type:        "bind",
+					Options:     []string{"rbind", "rw"},
+				},
+				{
+					Source:      files[1],
+					Destination: "/pmem",
+					Type:        KataPmemDevType,
+				},
+			},
+		}
+	}
+
+	// Without persistent memory hotplug, the bind mount is shared and the
+	// pmem mount can not be attached.
+	container := newContainer()
+	assert.Error(container.createBlockDevices())
+	assert.Empty(container.mounts[0].BlockDeviceID)
+
+	h.caps.SetPmemHotplugSupport()
+	container = newContainer()
+	assert.NoError(container.createBlockDevices())
+
+	for i, m := range container.mounts {
+		assert.NotEmpty(m.BlockDeviceID)
+		device := sandbox.devManager.GetDeviceByID(m.BlockDeviceID)
+		assert.NotNil(device)
+		assert.Equal(files[i], device.GetHostPath())
+	}
+	assert.NotEqual(container.mounts[0].BlockDeviceID, container.mounts[1].BlockDeviceID)
+
+	// Files without PFN signature can not be attached.
+	assert.NoError(ioutil.WriteFile(files[1], make([]byte, 8192), 0644))
+	container = newContainer()
+	container.mounts = container.mounts[1:]
+	assert.Error(container.createBlockDevices())
+}
+
+func TestContainerCreateImageVolume(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpDir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(tmpDir)
+
+	header := make([]byte, 4096)
+	copy(header[extMagicOffset:], extMagic)
+	image := filepath.Join(tmpDir, "disk.img")
+	assert.NoError(ioutil.WriteFile(image, header, 0644))
+
+	sandbox := &Sandbox{
+		ctx:        context.Background(),
+		id:         "sandbox",
+		devManager: manager.NewDeviceManager(manager.VirtioBlock, false, "", nil),
+		agent:      &kataAgent{},
+		hypervisor: &blockHotplugHypervisor{},
+		config:     &SandboxConfig{},
+	}
+
+	container := Container{
+		sandbox: sandbox,
+		id:      "testContainer",
+		config: &ContainerConfig{
+			Annotations: map[string]string{
+				vcAnnotations.ImageVolumes: "/data",
+			},
+		},
+		mounts: []Mount{
+			{
+				Source:      image,
+				Destination: "/data",
+				Type:        "bind",
+				Options:     []string{"rbind", "ro"},
+				ReadOnly:    true,
+			},
+			{
+				Source:      tmpDir,
+				Destination: "/other",
+				Type:        "bind",
+				Options:     []string{"rbind"},
+			},
+		},
+	}
+
+	assert.NoError(container.createBlockDevices())
+
+	m := container.mounts[0]
+	assert.NotEmpty(m.BlockDeviceID)
+	assert.Equal("ext4", m.Type)
+	assert.Equal([]string{"ro"}, m.Options)
+
+	device := sandbox.devManager.GetDeviceByID(m.BlockDeviceID)
+	assert.NotNil(device)
+	assert.Equal(image, device.GetHostPath())
+
+	// Other mounts are still shared.
+	assert.Empty(container.mounts[1].BlockDeviceID)
+	assert.Equal("bind", container.mounts[1].Type)
+}
+
+func TestContainerCreateSizedLocalVolume(t *testing.T) {
+	assert := assert.New(t)
+
+	if _, err := exec.LookPath("mkfs.ext4"); err != nil {
+		t.Skip("mkfs.ext4 not found")
+	}
+
+	tmpDir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(tmpDir)
+
+	sandbox := &Sandbox{
+		ctx:        context.Background(),
+		id:         "sandbox",
+		devManager: manager.NewDeviceManager(manager.VirtioBlock, false, "", nil),
+		agent:      &kataAgent{},
+		hypervisor: &blockHotplugHypervisor{},
+		config:     &SandboxConfig{},
+	}
+
+	newContainer := func(id string) *Container {
+		return &Container{
+			sandbox: sandbox,
+			id:      id,
+			config:  &ContainerConfig{},
+			mounts: []Mount{
+				{
+					Source:      tmpDir,
+					Destination: "/cache",
+					Type:        KataLocalDevType,
+					Options:     []string{"rbind", "size=16Mi"},
+				},
+				{
+					Source:      tmpDir,
+					Destination: "/scratch",
+					Type:        KataLocalDevType,
+					Options:     []string{"rbind"},
+				},
+			},
+		}
+	}
+
+	first := newContainer("first")
+	assert.NoError(first.createBlockDevices())
+
+	m := first.mounts[0]
+	assert.NotEmpty(m.BlockDeviceID)
+	assert.Equal(KataLocalDevType, m.Type)
+
+	device := sandbox.devManager.GetDeviceByID(m.BlockDeviceID)
+	assert.NotNil(device)
+	assert.Equal(filepath.Join(tmpDir, localVolumeImage), device.GetHostPath())
+
+	// Volumes without a size limit are plain directories.
+	assert.Empty(first.mounts[1].BlockDeviceID)
+
+	// The image is shared by the containers using the volume.
+	second := newContainer("second")
+	assert.NoError(second.createBlockDevices())
+	assert.Equal(m.BlockDeviceID, second.mounts[0].BlockDeviceID)
+
+	// A size limit which can not be applied fails the creation.
+	invalid := newContainer("invalid")
+	invalid.mounts[0].Options = []string{"rbind", "size=lots"}
+	assert.Error(invalid.createBlockDevices())
+
+	sandbox.hypervisor = &mockHypervisor{}
+	unsupported := newContainer("unsupported")
+	assert.Error(unsupported.createBlockDevices())
+
+	unsupported.mounts = unsupported.mounts[1:]
+	assert.NoError(unsupported.createBlockDevices())
+}
+
+func throttleDevice(major, minor int64, rate uint64) specs.LinuxThrottleDevice {
+	d := specs.LinuxThrottleDevice{Rate: rate}
+	d.Major = major
+	d.Minor = minor
+	return d
+}
+
+func TestBlockDriveThrottles(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Empty(blockDriveThrottles(nil))
+
+	blkio := &specs.LinuxBlockIO{
+		ThrottleReadBpsDevice: []specs.LinuxThrottleDevice{
+			throttleDevice(8, 0, 1000),
+		},
+		ThrottleWriteIOPSDevice: []specs.LinuxThrottleDevice{
+			throttleDevice(8, 0, 10),
+			throttleDevice(8, 16, 20),
+		},
+	}
+
+	assert.Equal(map[string]*config.BlockDriveThrottle{
+		"8:0":  {ReadBps: 1000, WriteIOPS: 10},
+		"8:16": {WriteIOPS: 20},
+	}, blockDriveThrottles(blkio))
+}
+
+// throttleHypervisor is a mock hypervisor recording the block device limits.
+type throttleHypervisor struct {
+	mockHypervisor
+	throttles map[string]config.BlockDriveThrottle
+	err       error
+}
+
+func (h *throttleHypervisor) throttleBlockDevice(drive *config.BlockDrive, throttle config.BlockDriveThrottle) error {
+	if h.err != nil {
+		return h.err
+	}
+
+	h.throttles[drive.File] = throttle
+	return nil
+}
+
+func TestContainerThrottleBlockDevices(t *testing.T) {
+	assert := assert.New(t)
+
+	hypervisor := &throttleHypervisor{throttles: make(map[string]config.BlockDriveThrottle)}
+	sandbox := &Sandbox{
+		ctx:        context.Background(),
+		id:         "sandbox",
+		devManager: manager.NewDeviceManager(manager.VirtioBlock, false, "", nil),
+		hypervisor: hypervisor,
+		config:     &SandboxConfig{},
+	}
+
+	container := Container{
+		sandbox: sandbox,
+		id:      "testContainer",
+		config:  &ContainerConfig{},
+	}
+
+	for _, minor := range []int64{0, 16} {
+		path := fmt.Sprintf("/dev/sd%c", 'a'+minor/16)
+		device, err := sandbox.devManager.NewDevice(config.DeviceInfo{
+			HostPath:      path,
+			ContainerPath: path,
+			DevType:       "b",
+			Major:         8,
+			Minor:         minor,
+		})
+		assert.NoError(err)
+		assert.NoError(device.Attach(&api.MockDeviceReceiver{}))
+		container.devices = append(container.devices, ContainerDevice{ID: device.DeviceID(), ContainerPath: path})
+	}
+
+	readBps := func(minor int64, rate uint64) *specs.LinuxBlockIO {
+		return &specs.LinuxBlockIO{
+			ThrottleReadBpsDevice: []specs.LinuxThrottleDevice{
+				throttleDevice(8, minor, rate),
+			},
+		}
+	}
+
+	assert.NoError(container.throttleBlockDevices(nil, readBps(0, 1000)))
+	assert.Equal(map[string]config.BlockDriveThrottle{
+		"/dev/sda": {ReadBps: 1000},
+	}, hypervisor.throttles)
+
+	// The limits of the devices no longer throttled are removed.
+	assert.NoError(container.throttleBlockDevices(readBps(0, 1000), readBps(16, 2000)))
+	assert.Equal(map[string]config.BlockDriveThrottle{
+		"/dev/sda": {},
+		"/dev/sdb": {ReadBps: 2000},
+	}, hypervisor.throttles)
+
+	// The limits which do not change are not applied again.
+	hypervisor.throttles = make(map[string]config.BlockDriveThrottle)
+	assert.NoError(container.throttleBlockDevices(readBps(16, 2000), readBps(16, 2000)))
+	assert.Empty(hypervisor.throttles)
+
+	// The other resources are left alone when the drives can not be
+	// throttled.
+	hypervisor.err = errors.New("throttle failed")
+	sandbox.state.State = types.StateRunning
+	container.state.State = types.StateRunning
+	quota := int64(1000)
+	assert.Error(container.update(specs.LinuxResources{
+		CPU:     &specs.LinuxCPU{Quota: &quota},
+		BlockIO: readBps(0, 3000),
+	}))
+	assert.Nil(container.config.Resources.CPU)
+	assert.Nil(container.config.Resources.BlockIO)
+}
+
 func TestContainerRootfsPath(t *testing.T) {
 
 	testRawFile, loopDev, fakeRootfs, err := testSetupFakeRootfs(t)
@@ -115,6 +115,10 @@ type DeviceInfo struct {
 	// for a nvdimm device in the guest.
 	Pmem bool
 
+	// DiskImage indicates HostPath is a raw disk image file, attached
+	// to the VM as a block device.
+	DiskImage bool
+
 	// If applicable, should this device be considered RO
 	ReadOnly bool
 
@@ -137,6 +141,39 @@ type DeviceInfo struct {
 	// DriverOptions is specific options for each device driver
 	// for example, for BlockDevice, we can set DriverOptions["blockDriver"]="virtio-blk"
 	DriverOptions map[string]string
+
+	// Throttle is the I/O limits of a block device, for the hypervisors
+	// only able to limit a drive when attaching it.
+	Throttle BlockDriveThrottle
+}
+
+// BlockDriveThrottle represents the I/O limits of a block drive, in bytes
+// and operations per second. 0 means unlimited.
+type BlockDriveThrottle struct {
+	ReadBps   uint64
+	WriteBps  uint64
+	ReadIOPS  uint64
+	WriteIOPS uint64
+}
+
+// lowestLimit returns the lowest of two limits, 0 meaning unlimited.
+func lowestLimit(a, b uint64) uint64 {
+	if a == 0 || (b != 0 && b < a) {
+		return b
+	}
+	return a
+}
+
+// Bps returns the lowest of the read and write bandwidth limits, for the
+// hypervisors whose limits do not tell reads from writes.
+func (t BlockDriveThrottle) Bps() uint64 {
+	return lowestLimit(t.ReadBps, t.WriteBps)
+}
+
+// IOPS returns the lowest of the read and write operation limits, for the
+// hypervisors whose limits do not tell reads from writes.
+func (t BlockDriveThrottle) IOPS() uint64 {
+	return lowestLimit(t.ReadIOPS, t.WriteIOPS)
 }
 
 // BlockDrive represents a block storage drive which may be used in case the storage
@@ -182,6 +219,9 @@ type BlockDrive struct {
 	// Pmem enables persistent memory. Use File as backing file
 	// for a nvdimm device in the guest
 	Pmem bool
+
+	// Throttle is the I/O limits the drive is attached with.
+	Throttle BlockDriveThrottle
 }
 
 // VFIODeviceType indicates VFIO device type
@@ -250,6 +290,14 @@ type VhostUserDeviceAttrs struct {
 	CacheSize uint32
 	Cache     string
 
+	// SharedDir is the host directory shared by a hotplugged vhost user
+	// fs device, through a virtio-fs daemon started for it.
+	SharedDir string
+
+	// Reconnect is the number of seconds between attempts to reconnect
+	// to the socket when the daemon restarts, 0 to not reconnect.
+	Reconnect uint32
+
 	// PCIPath is the PCI path used to identify the slot at which
 	// the drive is attached.  It is only meaningful for vhost
 	// user block devices
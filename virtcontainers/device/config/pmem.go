@@ -87,6 +87,45 @@ func PmemDeviceInfo(source, destination string) (*DeviceInfo, error) {
 	return device, nil
 }
 
+// PmemVolumeDeviceInfo returns a DeviceInfo exposing source, a file or a
+// block device with the PFN signature, as a pmem device. fstype is the type
+// of the filesystem of source, ext4 if empty.
+func PmemVolumeDeviceInfo(source, destination, fstype string, readOnly bool) (*DeviceInfo, error) {
+	stat := syscall.Stat_t{}
+	if err := syscall.Stat(source, &stat); err != nil {
+		return nil, err
+	}
+
+	device := &DeviceInfo{
+		HostPath:      source,
+		ContainerPath: destination,
+		DevType:       "b",
+		Pmem:          true,
+		ReadOnly:      readOnly,
+		DriverOptions: make(map[string]string),
+	}
+
+	switch stat.Mode & syscall.S_IFMT {
+	case syscall.S_IFBLK:
+		device.Major = int64(unix.Major(stat.Rdev))
+		device.Minor = int64(unix.Minor(stat.Rdev))
+	case syscall.S_IFREG:
+	default:
+		return nil, fmt.Errorf("%v is neither a file nor a block device", source)
+	}
+
+	if !hasPFNSignature(source) {
+		return nil, fmt.Errorf("%v has not PFN signature", source)
+	}
+
+	if fstype == "" {
+		fstype = "ext4"
+	}
+	device.DriverOptions["fstype"] = fstype
+
+	return device, nil
+}
+
 // returns true if the file/device path has the PFN signature
 // required to use it as PMEM device and enable DAX.
 // See [1] to know more about the PFN signature.
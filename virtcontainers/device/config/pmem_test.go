@@ -47,3 +47,36 @@ func TestHasPFNSignature(t *testing.T) {
 	b = hasPFNSignature(pfnFile)
 	assert.True(b)
 }
+
+func TestPmemVolumeDeviceInfo(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "pmem")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	_, err = PmemVolumeDeviceInfo(filepath.Join(dir, "missing"), "/data", "", false)
+	assert.Error(err)
+
+	_, err = PmemVolumeDeviceInfo(dir, "/data", "", false)
+	assert.Error(err)
+
+	noPFNFile := filepath.Join(dir, "nopfn")
+	assert.NoError(ioutil.WriteFile(noPFNFile, make([]byte, 8192), 0644))
+	_, err = PmemVolumeDeviceInfo(noPFNFile, "/data", "", false)
+	assert.Error(err)
+
+	pfnFile := createPFNFile(assert, dir)
+
+	device, err := PmemVolumeDeviceInfo(pfnFile, "/data", "", true)
+	assert.NoError(err)
+	assert.Equal(pfnFile, device.HostPath)
+	assert.Equal("/data", device.ContainerPath)
+	assert.True(device.Pmem)
+	assert.True(device.ReadOnly)
+	assert.Equal("ext4", device.DriverOptions["fstype"])
+
+	device, err = PmemVolumeDeviceInfo(pfnFile, "/data", "xfs", false)
+	assert.NoError(err)
+	assert.Equal("xfs", device.DriverOptions["fstype"])
+}
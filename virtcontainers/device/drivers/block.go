@@ -67,6 +67,7 @@ func (device *BlockDevice) Attach(devReceiver api.DeviceReceiver) (err error) {
 		Index:    index,
 		Pmem:     device.DeviceInfo.Pmem,
 		ReadOnly: device.DeviceInfo.ReadOnly,
+		Throttle: device.DeviceInfo.Throttle,
 	}
 
 	if fs, ok := device.DeviceInfo.DriverOptions["fstype"]; ok {
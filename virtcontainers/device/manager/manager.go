@@ -96,11 +96,20 @@ func (dm *deviceManager) findDeviceByMajorMinor(major, minor int64) api.Device {
 	return nil
 }
 
+func (dm *deviceManager) findDeviceByHostPath(hostPath string) api.Device {
+	for _, dev := range dm.devices {
+		if dev.GetHostPath() == hostPath {
+			return dev
+		}
+	}
+	return nil
+}
+
 // createDevice creates one device based on DeviceInfo
 func (dm *deviceManager) createDevice(devInfo config.DeviceInfo) (dev api.Device, err error) {
 	// pmem device may points to block devices or raw files,
-	// do not change its HostPath.
-	if !devInfo.Pmem {
+	// do not change its HostPath, neither for disk image files.
+	if !devInfo.Pmem && !devInfo.DiskImage {
 		path, err := config.GetHostPathFunc(devInfo, dm.vhostUserStoreEnabled, dm.vhostUserStorePath)
 		if err != nil {
 			return nil, err
@@ -114,7 +123,12 @@ func (dm *deviceManager) createDevice(devInfo config.DeviceInfo) (dev api.Device
 		}
 	}()
 
-	if existingDev := dm.findDeviceByMajorMinor(devInfo.Major, devInfo.Minor); existingDev != nil {
+	// disk image files and pmem backing files have no major and minor numbers.
+	if devInfo.DiskImage || (devInfo.Pmem && devInfo.Major == 0 && devInfo.Minor == 0) {
+		if existingDev := dm.findDeviceByHostPath(devInfo.HostPath); existingDev != nil {
+			return existingDev, nil
+		}
+	} else if existingDev := dm.findDeviceByMajorMinor(devInfo.Major, devInfo.Minor); existingDev != nil {
 		return existingDev, nil
 	}
 
@@ -205,6 +205,54 @@ func TestAttachBlockDevice(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestAttachDiskImageDevice(t *testing.T) {
+	assert := assert.New(t)
+	dm := &deviceManager{
+		blockDriver: VirtioBlock,
+		devices:     make(map[string]api.Device),
+	}
+
+	tmpDir, err := ioutil.TempDir("", "")
+	assert.Nil(err)
+	defer os.RemoveAll(tmpDir)
+
+	deviceInfo := config.DeviceInfo{
+		HostPath:      filepath.Join(tmpDir, "disk.img"),
+		ContainerPath: "/data",
+		DevType:       "b",
+		DiskImage:     true,
+	}
+
+	device, err := dm.NewDevice(deviceInfo)
+	assert.Nil(err)
+	_, ok := device.(*drivers.BlockDevice)
+	assert.True(ok)
+	// The host path of a disk image is kept as is.
+	assert.Equal(deviceInfo.HostPath, device.GetHostPath())
+
+	devReceiver := &api.MockDeviceReceiver{}
+	err = device.Attach(devReceiver)
+	assert.Nil(err)
+	drive, ok := device.GetDeviceInfo().(*config.BlockDrive)
+	assert.True(ok)
+	assert.Equal(deviceInfo.HostPath, drive.File)
+	assert.Equal("raw", drive.Format)
+
+	// The same image is attached once.
+	sameDevice, err := dm.NewDevice(deviceInfo)
+	assert.Nil(err)
+	assert.Equal(device.DeviceID(), sameDevice.DeviceID())
+
+	// Other images have their own device, even without major and minor numbers.
+	deviceInfo.HostPath = filepath.Join(tmpDir, "other.img")
+	otherDevice, err := dm.NewDevice(deviceInfo)
+	assert.Nil(err)
+	assert.NotEqual(device.DeviceID(), otherDevice.DeviceID())
+
+	err = device.Detach(devReceiver)
+	assert.Nil(err)
+}
+
 func TestAttachVhostUserBlkDevice(t *testing.T) {
 	rootEnabled := true
 	tc := ktu.NewTestConstraint(false)
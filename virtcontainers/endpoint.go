@@ -59,6 +59,9 @@ const (
 
 	// IPVlanEndpointType is ipvlan network interface.
 	IPVlanEndpointType EndpointType = "ipvlan"
+
+	// VFEndpointType is a SR-IOV virtual function network interface.
+	VFEndpointType EndpointType = "vf"
 )
 
 // Set sets an endpoint type based on the input string.
@@ -88,6 +91,9 @@ func (endpointType *EndpointType) Set(value string) error {
 	case "ipvlan":
 		*endpointType = IPVlanEndpointType
 		return nil
+	case "vf":
+		*endpointType = VFEndpointType
+		return nil
 	default:
 		return fmt.Errorf("Unknown endpoint type %s", value)
 	}
@@ -112,6 +118,8 @@ func (endpointType *EndpointType) String() string {
 		return string(TuntapEndpointType)
 	case IPVlanEndpointType:
 		return string(IPVlanEndpointType)
+	case VFEndpointType:
+		return string(VFEndpointType)
 	default:
 		return ""
 	}
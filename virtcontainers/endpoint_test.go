@@ -43,6 +43,10 @@ func TestMacvtapEndpointTypeSet(t *testing.T) {
 	testEndpointTypeSet(t, "macvtap", MacvtapEndpointType)
 }
 
+func TestVFEndpointTypeSet(t *testing.T) {
+	testEndpointTypeSet(t, "vf", VFEndpointType)
+}
+
 func TestEndpointTypeSetFailure(t *testing.T) {
 	var endpointType EndpointType
 
@@ -79,6 +83,11 @@ func TestMacvtapEndpointTypeString(t *testing.T) {
 	testEndpointTypeString(t, &endpointType, string(MacvtapEndpointType))
 }
 
+func TestVFEndpointTypeString(t *testing.T) {
+	endpointType := VFEndpointType
+	testEndpointTypeString(t, &endpointType, string(VFEndpointType))
+}
+
 func TestIncorrectEndpointTypeString(t *testing.T) {
 	var endpointType EndpointType
 	testEndpointTypeString(t, &endpointType, "")
@@ -65,6 +65,9 @@ const (
 	// We attach a pool of placeholder drives before the guest has started, and then
 	// patch the replace placeholder drives with drives with actual contents.
 	fcDiskPoolSize           = 8
+	// Only a few read-only drives are in the pool, as firecracker
+	// VMs have a limited number of devices.
+	fcReadOnlyDiskPoolSize = 4
 	defaultHybridVSocketName = "kata.hvsock"
 
 	// This is the first usable vsock context ID. All the vsocks can use the same
@@ -155,6 +158,14 @@ type firecracker struct {
 
 	fcConfigPath string
 	fcConfig     *types.FcConfig // Parameters configured before VM starts
+
+	// throttledDrives are the drives of the pool whose rate limiters
+	// are set, they must be reset when the drives are unplugged.
+	throttledDrives map[string]bool
+
+	// readOnlyDrives are the IDs of the block drives using the read-only
+	// drives of the pool, indexed by pool drive.
+	readOnlyDrives map[string]string
 }
 
 type firecrackerDevice struct {
@@ -803,13 +814,74 @@ func fcDriveIndexToID(i int) string {
 	return "drive_" + strconv.Itoa(i)
 }
 
+// Whether a drive is read-only can not be changed once the VM booted, so
+// the pool has read-only drives as well.
+func fcReadOnlyDriveIndexToID(i int) string {
+	return "drive_ro_" + strconv.Itoa(i)
+}
+
+// readOnlyPoolDrive returns the ID and the index of the read-only drive of
+// the pool used by the block drive driveID, an empty ID if there is none.
+func (fc *firecracker) readOnlyPoolDrive(driveID string) (string, int) {
+	for i := 0; i < fcReadOnlyDiskPoolSize; i++ {
+		id := fcReadOnlyDriveIndexToID(i)
+		if fc.readOnlyDrives[id] == driveID {
+			return id, i
+		}
+	}
+
+	return "", -1
+}
+
+// poolDriveID returns the ID of the drive of the pool used by drive.
+func (fc *firecracker) poolDriveID(drive *config.BlockDrive) (string, error) {
+	if !drive.ReadOnly {
+		return fcDriveIndexToID(drive.Index), nil
+	}
+
+	if id, _ := fc.readOnlyPoolDrive(drive.ID); id != "" {
+		return id, nil
+	}
+
+	return "", fmt.Errorf("No read-only drive of the pool used by drive %s", drive.ID)
+}
+
+// useReadOnlyPoolDrive picks a free read-only drive of the pool for drive
+// and sets its path in the guest.
+func (fc *firecracker) useReadOnlyPoolDrive(drive *config.BlockDrive) (string, error) {
+	id, i := fc.readOnlyPoolDrive("")
+	if id == "" {
+		return "", fmt.Errorf("All the %d read-only drives of the pool are used", fcReadOnlyDiskPoolSize)
+	}
+
+	// The read-only drives come after the VM rootfs and the read-write
+	// drives in the guest.
+	driveName, err := utils.GetVirtDriveName(fcDiskPoolSize + 1 + i)
+	if err != nil {
+		return "", err
+	}
+	drive.VirtPath = filepath.Join("/dev", driveName)
+
+	if fc.readOnlyDrives == nil {
+		fc.readOnlyDrives = make(map[string]string)
+	}
+	fc.readOnlyDrives[id] = drive.ID
+
+	return id, nil
+}
+
 func (fc *firecracker) createDiskPool() error {
 	span, _ := fc.trace("createDiskPool")
 	defer span.Finish()
 
-	for i := 0; i < fcDiskPoolSize; i++ {
+	// The read-only drives come after the read-write ones.
+	for i := 0; i < fcDiskPoolSize+fcReadOnlyDiskPoolSize; i++ {
 		driveID := fcDriveIndexToID(i)
 		isReadOnly := false
+		if i >= fcDiskPoolSize {
+			driveID = fcReadOnlyDriveIndexToID(i - fcDiskPoolSize)
+			isReadOnly = true
+		}
 		isRootDevice := false
 
 		// Create a temporary file as a placeholder backend for the drive
@@ -923,7 +995,7 @@ func (fc *firecracker) fcAddBlockDrive(drive config.BlockDrive) error {
 	defer span.Finish()
 
 	driveID := drive.ID
-	isReadOnly := false
+	isReadOnly := drive.ReadOnly
 	isRootDevice := false
 
 	jailedDrive, err := fc.fcJailResource(drive.File, driveID)
@@ -944,7 +1016,7 @@ func (fc *firecracker) fcAddBlockDrive(drive config.BlockDrive) error {
 }
 
 // Firecracker supports replacing the host drive used once the VM has booted up
-func (fc *firecracker) fcUpdateBlockDrive(path, id string) error {
+func (fc *firecracker) fcUpdateBlockDrive(path, id string, rateLimiter *models.RateLimiter) error {
 	span, _ := fc.trace("fcUpdateBlockDrive")
 	defer span.Finish()
 
@@ -953,9 +1025,12 @@ func (fc *firecracker) fcUpdateBlockDrive(path, id string) error {
 	driveParams := ops.NewPatchGuestDriveByIDParams()
 	driveParams.SetDriveID(id)
 
+	// The rate limiter is only sent when needed, as firecracker versions
+	// older than 0.23 can only update the path of a drive.
 	driveFc := &models.PartialDrive{
-		DriveID:    &id,
-		PathOnHost: &path, //This is the only property that can be modified
+		DriveID:     &id,
+		PathOnHost:  &path,
+		RateLimiter: rateLimiter,
 	}
 
 	driveParams.SetBody(driveFc)
@@ -1005,16 +1080,27 @@ func (fc *firecracker) addDevice(devInfo interface{}, devType deviceType) error
 
 // hotplugBlockDevice supported in Firecracker VMM
 // hot add or remove a block device.
-func (fc *firecracker) hotplugBlockDevice(drive config.BlockDrive, op operation) (interface{}, error) {
+func (fc *firecracker) hotplugBlockDevice(drive *config.BlockDrive, op operation) (interface{}, error) {
 	var path string
 	var err error
-	driveID := fcDriveIndexToID(drive.Index)
+	var rateLimiter *models.RateLimiter
+	var driveID string
+
+	if op == addDevice && drive.ReadOnly {
+		driveID, err = fc.useReadOnlyPoolDrive(drive)
+	} else {
+		driveID, err = fc.poolDriveID(drive)
+	}
+	if err != nil {
+		return nil, err
+	}
 
 	if op == addDevice {
 		//The drive placeholder has to exist prior to Update
 		path, err = fc.fcJailResource(drive.File, driveID)
 		if err != nil {
 			fc.Logger().WithError(err).WithField("resource", drive.File).Error("Could not jail resource")
+			delete(fc.readOnlyDrives, driveID)
 			return nil, err
 		}
 	} else {
@@ -1023,9 +1109,68 @@ func (fc *firecracker) hotplugBlockDevice(drive config.BlockDrive, op operation)
 		// use previous raw file created at createDiskPool, that way
 		// the resource is released by firecracker and it can be destroyed in the host
 		path = filepath.Join(fc.jailerRoot, driveID)
+
+		// The next drive using this slot of the pool must not inherit
+		// the limits.
+		if fc.throttledDrives[driveID] {
+			rateLimiter = fcRateLimiter(config.BlockDriveThrottle{})
+			delete(fc.throttledDrives, driveID)
+		}
+
+		delete(fc.readOnlyDrives, driveID)
+	}
+
+	return nil, fc.fcUpdateBlockDrive(path, driveID, rateLimiter)
+}
+
+// fcTokenBucket returns a token bucket refilled with rate tokens per second,
+// a 0 rate disabling the bucket.
+func fcTokenBucket(rate uint64) *models.TokenBucket {
+	size := int64(rate)
+	refillTime := int64(1000)
+
+	return &models.TokenBucket{
+		Size:       &size,
+		RefillTime: &refillTime,
 	}
+}
 
-	return nil, fc.fcUpdateBlockDrive(path, driveID)
+// fcRateLimiter returns the rate limiter enforcing the limits of a drive.
+// Firecracker rate limiters do not tell reads from writes, so the lowest
+// limit applies to both.
+func fcRateLimiter(throttle config.BlockDriveThrottle) *models.RateLimiter {
+	return &models.RateLimiter{
+		Bandwidth: fcTokenBucket(throttle.Bps()),
+		Ops:       fcTokenBucket(throttle.IOPS()),
+	}
+}
+
+func (fc *firecracker) throttleBlockDevice(drive *config.BlockDrive, throttle config.BlockDriveThrottle) error {
+	span, _ := fc.trace("throttleBlockDevice")
+	defer span.Finish()
+
+	driveID, err := fc.poolDriveID(drive)
+	if err != nil {
+		return err
+	}
+
+	// The path of the drive must be sent again, it is where
+	// fcJailResource mounted the drive file.
+	path := filepath.Join(fc.jailerRoot, driveID)
+	if fc.jailed {
+		path = filepath.Join("/", driveID)
+	}
+
+	if err := fc.fcUpdateBlockDrive(path, driveID, fcRateLimiter(throttle)); err != nil {
+		return err
+	}
+
+	if fc.throttledDrives == nil {
+		fc.throttledDrives = make(map[string]bool)
+	}
+	fc.throttledDrives[driveID] = throttle != config.BlockDriveThrottle{}
+
+	return nil
 }
 
 // hotplugAddDevice supported in Firecracker VMM
@@ -1035,7 +1180,7 @@ func (fc *firecracker) hotplugAddDevice(devInfo interface{}, devType deviceType)
 
 	switch devType {
 	case blockDev:
-		return fc.hotplugBlockDevice(*devInfo.(*config.BlockDrive), addDevice)
+		return fc.hotplugBlockDevice(devInfo.(*config.BlockDrive), addDevice)
 	default:
 		fc.Logger().WithFields(logrus.Fields{"devInfo": devInfo,
 			"deviceType": devType}).Warn("hotplugAddDevice: unsupported device")
@@ -1051,7 +1196,7 @@ func (fc *firecracker) hotplugRemoveDevice(devInfo interface{}, devType deviceTy
 
 	switch devType {
 	case blockDev:
-		return fc.hotplugBlockDevice(*devInfo.(*config.BlockDrive), removeDevice)
+		return fc.hotplugBlockDevice(devInfo.(*config.BlockDrive), removeDevice)
 	default:
 		fc.Logger().WithFields(logrus.Fields{"devInfo": devInfo,
 			"deviceType": devType}).Error("hotplugRemoveDevice: unsupported device")
@@ -1139,6 +1284,14 @@ func (fc *firecracker) getPids() []int {
 	return []int{fc.info.PID}
 }
 
+func (fc *firecracker) getVirtiofsdPid() int {
+	return 0
+}
+
+func (fc *firecracker) restartVirtiofsd() error {
+	return errors.New("firecracker does not support virtio-fs")
+}
+
 func (fc *firecracker) fromGrpc(ctx context.Context, hypervisorConfig *HypervisorConfig, j []byte) error {
 	return errors.New("firecracker is not supported by VM cache")
 }
@@ -1150,11 +1303,15 @@ func (fc *firecracker) toGrpc() ([]byte, error) {
 func (fc *firecracker) save() (s persistapi.HypervisorState) {
 	s.Pid = fc.info.PID
 	s.Type = string(FirecrackerHypervisor)
+	s.ThrottledDrives = fc.throttledDrives
+	s.ReadOnlyDrives = fc.readOnlyDrives
 	return
 }
 
 func (fc *firecracker) load(s persistapi.HypervisorState) {
 	fc.info.PID = s.Pid
+	fc.throttledDrives = s.ThrottledDrives
+	fc.readOnlyDrives = s.ReadOnlyDrives
 }
 
 func (fc *firecracker) check() error {
@@ -6,8 +6,10 @@
 package virtcontainers
 
 import (
+	"fmt"
 	"testing"
 
+	"github.com/kata-containers/runtime/virtcontainers/device/config"
 	"github.com/kata-containers/runtime/virtcontainers/types"
 	"github.com/stretchr/testify/assert"
 )
@@ -45,3 +47,78 @@ func TestFCTruncateID(t *testing.T) {
 	id = fc.truncateID(testShortID)
 	assert.Equal(expectedID, id)
 }
+
+func TestFCRateLimiter(t *testing.T) {
+	assert := assert.New(t)
+
+	rateLimiter := fcRateLimiter(config.BlockDriveThrottle{
+		ReadBps:   2000,
+		WriteBps:  1000,
+		ReadIOPS:  100,
+		WriteIOPS: 0,
+	})
+
+	// The lowest limit applies to both reads and writes.
+	assert.Equal(int64(1000), *rateLimiter.Bandwidth.Size)
+	assert.Equal(int64(1000), *rateLimiter.Bandwidth.RefillTime)
+	assert.Equal(int64(100), *rateLimiter.Ops.Size)
+
+	// No limit disables the buckets.
+	rateLimiter = fcRateLimiter(config.BlockDriveThrottle{})
+	assert.Equal(int64(0), *rateLimiter.Bandwidth.Size)
+	assert.Equal(int64(0), *rateLimiter.Ops.Size)
+}
+
+func TestFCReadOnlyPoolDrive(t *testing.T) {
+	assert := assert.New(t)
+	fc := firecracker{}
+
+	// Read-write drives use the drive of the pool of their index.
+	id, err := fc.poolDriveID(&config.BlockDrive{ID: "rw", Index: 3})
+	assert.NoError(err)
+	assert.Equal("drive_3", id)
+
+	_, err = fc.poolDriveID(&config.BlockDrive{ID: "ro", ReadOnly: true})
+	assert.Error(err)
+
+	var drives []*config.BlockDrive
+	for i := 0; i < fcReadOnlyDiskPoolSize; i++ {
+		drive := &config.BlockDrive{
+			ID:       fmt.Sprintf("ro%d", i),
+			Index:    i,
+			ReadOnly: true,
+		}
+		id, err := fc.useReadOnlyPoolDrive(drive)
+		assert.NoError(err)
+		assert.Equal(fmt.Sprintf("drive_ro_%d", i), id)
+		drives = append(drives, drive)
+	}
+
+	// The read-only drives come after the rootfs and the read-write drives.
+	assert.Equal("/dev/vdj", drives[0].VirtPath)
+	assert.Equal("/dev/vdk", drives[1].VirtPath)
+
+	id, err = fc.poolDriveID(drives[1])
+	assert.NoError(err)
+	assert.Equal("drive_ro_1", id)
+
+	_, err = fc.useReadOnlyPoolDrive(&config.BlockDrive{ID: "more", ReadOnly: true})
+	assert.Error(err)
+
+	// Released drives are used again.
+	delete(fc.readOnlyDrives, "drive_ro_1")
+	drive := &config.BlockDrive{ID: "more", ReadOnly: true}
+	id, err = fc.useReadOnlyPoolDrive(drive)
+	assert.NoError(err)
+	assert.Equal("drive_ro_1", id)
+	assert.Equal("/dev/vdk", drive.VirtPath)
+
+	// The drives used are kept when the sandbox is reloaded.
+	var fc2 firecracker
+	fc2.load(fc.save())
+	_, err = fc2.useReadOnlyPoolDrive(&config.BlockDrive{ID: "other", ReadOnly: true})
+	assert.Error(err)
+	id, err = fc2.poolDriveID(drive)
+	assert.NoError(err)
+	assert.Equal("drive_ro_1", id)
+}
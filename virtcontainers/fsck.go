@@ -0,0 +1,567 @@
+// Copyright (c) 2020 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/containerd/containerd/mount"
+	"github.com/kata-containers/runtime/virtcontainers/persist"
+	vcAnnotations "github.com/kata-containers/runtime/virtcontainers/pkg/annotations"
+	"github.com/kata-containers/runtime/virtcontainers/types"
+	"github.com/sirupsen/logrus"
+)
+
+// InconsistencyType describes the kind of inconsistency found between the
+// persisted sandboxes and the host resources.
+type InconsistencyType string
+
+const (
+	// OrphanedContainer is a persisted container whose bundle does not exist
+	// anymore, meaning the container manager does not know about it.
+	OrphanedContainer InconsistencyType = "orphaned-container"
+
+	// DeadHypervisor is a persisted sandbox whose hypervisor is not running.
+	DeadHypervisor InconsistencyType = "dead-hypervisor"
+
+	// MissingNetNs is a persisted sandbox whose network namespace does not exist.
+	MissingNetNs InconsistencyType = "missing-netns"
+
+	// LeakedCgroup is a cgroup left behind by a sandbox which is not running.
+	LeakedCgroup InconsistencyType = "leaked-cgroup"
+
+	// LeakedMount is a mount under the shared directory of an unknown sandbox.
+	LeakedMount InconsistencyType = "leaked-mount"
+
+	// LeakedDirectory is a shared or VM directory of an unknown sandbox.
+	LeakedDirectory InconsistencyType = "leaked-directory"
+
+	// LeakedProcess is a process (hypervisor, virtiofsd...) of an unknown sandbox.
+	LeakedProcess InconsistencyType = "leaked-process"
+)
+
+// Inconsistency describes a single inconsistency found by CheckSandboxes.
+type Inconsistency struct {
+	Type        InconsistencyType
+	SandboxID   string
+	ContainerID string
+
+	// Resource identifies the host resource (path or process ID)
+	// the inconsistency relates to.
+	Resource string
+
+	// Repaired is true if the inconsistency has been cleaned up.
+	Repaired bool
+
+	// RepairError holds the reason why the cleanup failed, if any.
+	RepairError string
+}
+
+func (i *Inconsistency) setRepaired(err error) {
+	if err != nil {
+		i.RepairError = err.Error()
+		return
+	}
+	i.Repaired = true
+}
+
+// fsckProcRoot is the procfs root used to look for leaked processes.
+var fsckProcRoot = "/proc"
+
+// CheckSandboxes cross-checks the persisted sandboxes against the host
+// resources (hypervisor processes, network namespaces, shared directory mounts
+// and cgroups) and reports the inconsistencies found. If repair is true, broken
+// sandboxes are cleaned up through the regular container and sandbox deletion
+// path, and resources belonging to unknown sandboxes are released. binaries
+// are the paths of the programs run for a sandbox (hypervisor, virtiofsd...),
+// the processes of unknown sandboxes running another program are left alone.
+func CheckSandboxes(ctx context.Context, repair bool, binaries []string) ([]Inconsistency, error) {
+	span, ctx := trace(ctx, "CheckSandboxes")
+	defer span.Finish()
+
+	store, err := persist.GetDriver()
+	if err != nil {
+		return nil, err
+	}
+
+	sandboxIDs, err := readDirNames(store.RunStoragePath())
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]bool)
+	var result []Inconsistency
+
+	for _, sandboxID := range sandboxIDs {
+		known[sandboxID] = true
+
+		issues, err := checkSandbox(ctx, sandboxID, repair)
+		if err != nil {
+			virtLog.WithError(err).WithField("sandbox", sandboxID).Warn("could not check sandbox")
+			continue
+		}
+		result = append(result, issues...)
+	}
+
+	leaks, err := checkLeakedResources(store.RunVMStoragePath(), known, repair, binaries)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(result, leaks...), nil
+}
+
+// checkSandbox reports the inconsistencies of a single persisted sandbox and,
+// if asked to, cleans up its orphaned containers. A sandbox whose hypervisor
+// died is entirely cleaned up.
+func checkSandbox(ctx context.Context, sandboxID string, repair bool) ([]Inconsistency, error) {
+	unlock, err := rLockSandbox(sandboxID)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := fetchSandbox(ctx, sandboxID)
+	if err != nil {
+		unlock()
+		return nil, err
+	}
+
+	var result []Inconsistency
+
+	// The hypervisor process comes first, its pid may have been
+	// reused by another program since the sandbox was stored.
+	hypervisorPid := 0
+	if pids := s.hypervisor.getPids(); len(pids) > 0 && pids[0] > 0 {
+		if syscall.Kill(pids[0], syscall.Signal(0)) == nil && isSandboxProcess(pids[0], []string{s.config.HypervisorConfig.HypervisorPath}) {
+			hypervisorPid = pids[0]
+		}
+	}
+	hypervisorAlive := hypervisorPid > 0
+
+	sandboxDead := !hypervisorAlive && s.state.State != types.StateStopped
+	if sandboxDead {
+		result = append(result, Inconsistency{
+			Type:      DeadHypervisor,
+			SandboxID: sandboxID,
+			Resource:  string(s.config.HypervisorType),
+		})
+	}
+
+	if netNsPath := s.networkNS.NetNsPath; netNsPath != "" {
+		if _, err := os.Stat(netNsPath); os.IsNotExist(err) {
+			result = append(result, Inconsistency{
+				Type:      MissingNetNs,
+				SandboxID: sandboxID,
+				Resource:  netNsPath,
+			})
+		}
+	}
+
+	if !hypervisorAlive {
+		for _, path := range s.state.CgroupPaths {
+			if _, err := os.Stat(path); err == nil {
+				result = append(result, Inconsistency{
+					Type:      LeakedCgroup,
+					SandboxID: sandboxID,
+					Resource:  path,
+				})
+			}
+		}
+	}
+
+	var orphans []string
+	for _, c := range s.config.Containers {
+		bundlePath := c.Annotations[vcAnnotations.BundlePathKey]
+		if bundlePath == "" {
+			continue
+		}
+
+		if _, err := os.Stat(bundlePath); os.IsNotExist(err) {
+			orphans = append(orphans, c.ID)
+			result = append(result, Inconsistency{
+				Type:        OrphanedContainer,
+				SandboxID:   sandboxID,
+				ContainerID: c.ID,
+				Resource:    bundlePath,
+			})
+		}
+	}
+
+	var containerIDs []string
+	for _, c := range s.config.Containers {
+		containerIDs = append(containerIDs, c.ID)
+	}
+
+	s.releaseStatelessSandbox()
+	unlock()
+
+	if !repair || len(result) == 0 {
+		return result, nil
+	}
+
+	// Everything goes away with a dead hypervisor, otherwise only
+	// the containers the container manager forgot about.
+	if sandboxDead {
+		orphans = containerIDs
+	}
+
+	var repairErr error
+	for _, containerID := range orphans {
+		if err := CleanupContainer(ctx, sandboxID, containerID, true); err != nil {
+			virtLog.WithError(err).WithFields(logrus.Fields{
+				"sandbox":   sandboxID,
+				"container": containerID,
+			}).Warn("could not cleanup container")
+			repairErr = err
+		}
+	}
+
+	for i := range result {
+		switch {
+		case result[i].Type == OrphanedContainer || sandboxDead:
+			result[i].setRepaired(repairErr)
+		case result[i].Type == MissingNetNs && hypervisorAlive:
+			result[i].setRepaired(restoreNetNs(result[i].Resource, hypervisorPid))
+		case hypervisorAlive:
+			result[i].RepairError = "sandbox still running"
+		default:
+			result[i].RepairError = "sandbox stopped, delete it to release its resources"
+		}
+	}
+
+	return result, nil
+}
+
+// checkLeakedResources looks for shared directories, mounts, VM directories and
+// processes of sandboxes which are not known by the persist driver. A sandbox is
+// considered as leaked only if it still owns a shared directory or a process
+// referencing it: VMs of the factory, which are not bound to any sandbox, own
+// a VM directory only and are left alone.
+//
+// A sandbox being created owns its persist directory, and holds its lock,
+// before any other resource. Leaked sandboxes are thus claimed the same way
+// before being repaired, and skipped if created in the meantime.
+func checkLeakedResources(vmStoragePath string, known map[string]bool, repair bool, binaries []string) ([]Inconsistency, error) {
+	sharedDir := kataHostSharedDir()
+
+	leaked := make(map[string]bool)
+
+	ids, err := readDirNames(sharedDir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range ids {
+		if !known[id] {
+			leaked[id] = true
+		}
+	}
+
+	processes, err := findSandboxProcesses(sharedDir, vmStoragePath, binaries)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range processes {
+		if p.shared && !known[p.sandboxID] {
+			leaked[p.sandboxID] = true
+		}
+	}
+
+	mounts, err := mount.Self()
+	if err != nil {
+		return nil, err
+	}
+
+	var sandboxIDs []string
+	for id := range leaked {
+		sandboxIDs = append(sandboxIDs, id)
+	}
+	sort.Strings(sandboxIDs)
+
+	var result []Inconsistency
+
+	for _, id := range sandboxIDs {
+		var release func()
+		if repair {
+			if release, err = claimLeakedSandbox(id); err != nil {
+				virtLog.WithError(err).WithField("sandbox", id).Warn("could not claim leaked sandbox")
+				continue
+			}
+			if release == nil {
+				// Created since the persisted sandboxes were checked.
+				continue
+			}
+		}
+
+		result = append(result, checkLeakedSandbox(id, sharedDir, vmStoragePath, processes, mounts, repair)...)
+
+		if release != nil {
+			release()
+		}
+	}
+
+	return result, nil
+}
+
+// checkLeakedSandbox reports and, if asked to, releases the processes, mounts
+// and directories of a single leaked sandbox.
+func checkLeakedSandbox(id, sharedDir, vmStoragePath string, processes []sandboxProcess, mounts []mount.Info, repair bool) []Inconsistency {
+	var result []Inconsistency
+
+	for _, p := range processes {
+		if p.sandboxID != id {
+			continue
+		}
+
+		issue := Inconsistency{
+			Type:      LeakedProcess,
+			SandboxID: id,
+			Resource:  strconv.Itoa(p.pid),
+		}
+		if repair {
+			issue.setRepaired(syscall.Kill(p.pid, syscall.SIGKILL))
+		}
+		result = append(result, issue)
+	}
+
+	for _, dir := range []string{filepath.Join(sharedDir, id), filepath.Join(vmStoragePath, id)} {
+		if _, err := os.Lstat(dir); err != nil {
+			continue
+		}
+
+		var unmountErr error
+		for _, mp := range mountPointsUnder(mounts, dir) {
+			issue := Inconsistency{
+				Type:      LeakedMount,
+				SandboxID: id,
+				Resource:  mp,
+			}
+			if repair {
+				err := syscall.Unmount(mp, syscall.MNT_DETACH|UmountNoFollow)
+				if err != nil {
+					unmountErr = err
+				}
+				issue.setRepaired(err)
+			}
+			result = append(result, issue)
+		}
+
+		issue := Inconsistency{
+			Type:      LeakedDirectory,
+			SandboxID: id,
+			Resource:  dir,
+		}
+		if repair {
+			if unmountErr != nil {
+				// Never remove a directory which may still
+				// be backed by host data.
+				issue.setRepaired(fmt.Errorf("mounts left under %s: %v", dir, unmountErr))
+			} else {
+				issue.setRepaired(os.RemoveAll(dir))
+			}
+		}
+		result = append(result, issue)
+	}
+
+	return result
+}
+
+// claimLeakedSandbox creates the persist directory of a leaked sandbox and
+// takes its lock, like a sandbox creation does. It returns a nil release
+// function if the directory already exists, i.e. if the sandbox is known.
+// Releasing the sandbox removes its persist directory.
+func claimLeakedSandbox(sandboxID string) (func(), error) {
+	store, err := persist.GetDriver()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(store.RunStoragePath(), DirMode); err != nil {
+		return nil, err
+	}
+
+	if err := os.Mkdir(filepath.Join(store.RunStoragePath(), sandboxID), DirMode); err != nil {
+		if os.IsExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	unlock, err := store.Lock(sandboxID, true)
+	if err != nil {
+		store.Destroy(sandboxID)
+		return nil, err
+	}
+
+	return func() {
+		// Remove the directory first so that a creation waiting
+		// for the lock starts over with a new one.
+		if err := store.Destroy(sandboxID); err != nil {
+			virtLog.WithError(err).WithField("sandbox", sandboxID).Warn("could not remove claimed sandbox")
+		}
+		unlock()
+	}, nil
+}
+
+// restoreNetNs bind mounts the network namespace of a running process
+// back onto the path of a sandbox network namespace.
+func restoreNetNs(netNsPath string, pid int) error {
+	if err := os.MkdirAll(filepath.Dir(netNsPath), DirMode); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(netNsPath, os.O_RDONLY|os.O_CREATE|os.O_EXCL, 0444)
+	if err != nil {
+		return err
+	}
+	f.Close()
+
+	nsPath := filepath.Join(fsckProcRoot, strconv.Itoa(pid), "ns", "net")
+	if err := syscall.Mount(nsPath, netNsPath, "none", syscall.MS_BIND, ""); err != nil {
+		os.Remove(netNsPath)
+		return err
+	}
+
+	return nil
+}
+
+// sandboxProcess is a host process related to a given sandbox.
+type sandboxProcess struct {
+	pid       int
+	sandboxID string
+
+	// shared is true if the process references the sandbox
+	// shared directory, false if it references its VM directory.
+	shared bool
+}
+
+// findSandboxProcesses looks for the processes running one of binaries whose
+// command line references a sandbox specific directory, right under the
+// shared or the VM storage directories. Every process the runtime spawns for
+// a sandbox (hypervisor, virtiofsd...) is given such a path (sockets, shared
+// directory) on its command line, as may any other program (editor, tar...)
+// which has to be left alone.
+func findSandboxProcesses(sharedDir, vmStoragePath string, binaries []string) ([]sandboxProcess, error) {
+	entries, err := readDirNames(fsckProcRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	var processes []sandboxProcess
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry)
+		if err != nil || pid == os.Getpid() {
+			continue
+		}
+
+		if !isSandboxProcess(pid, binaries) {
+			continue
+		}
+
+		cmdline, err := ioutil.ReadFile(filepath.Join(fsckProcRoot, entry, "cmdline"))
+		if err != nil {
+			// The process may have exited in the meantime.
+			continue
+		}
+
+		if id := sandboxIDFromCmdline(string(cmdline), sharedDir); id != "" {
+			processes = append(processes, sandboxProcess{pid: pid, sandboxID: id, shared: true})
+		} else if id := sandboxIDFromCmdline(string(cmdline), vmStoragePath); id != "" {
+			processes = append(processes, sandboxProcess{pid: pid, sandboxID: id})
+		}
+	}
+
+	return processes, nil
+}
+
+// isSandboxProcess checks if a process runs one of binaries. Only the name of
+// the binaries is compared, as the jailer runs a copy of the hypervisor.
+func isSandboxProcess(pid int, binaries []string) bool {
+	exe, err := os.Readlink(filepath.Join(fsckProcRoot, strconv.Itoa(pid), "exe"))
+	if err != nil {
+		return false
+	}
+	exe = strings.TrimSuffix(exe, " (deleted)")
+
+	for _, binary := range binaries {
+		if binary == "" {
+			continue
+		}
+
+		if resolved, err := filepath.EvalSymlinks(binary); err == nil {
+			binary = resolved
+		}
+
+		if filepath.Base(exe) == filepath.Base(binary) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sandboxIDFromCmdline returns the path component following root
+// in a NUL separated command line, if any.
+func sandboxIDFromCmdline(cmdline string, root string) string {
+	prefix := filepath.Clean(root) + "/"
+
+	for _, arg := range strings.Split(cmdline, "\x00") {
+		idx := strings.Index(arg, prefix)
+		if idx < 0 {
+			continue
+		}
+
+		id := arg[idx+len(prefix):]
+		if end := strings.IndexAny(id, "/, "); end >= 0 {
+			id = id[:end]
+		}
+
+		if id != "" {
+			return id
+		}
+	}
+
+	return ""
+}
+
+// mountPointsUnder returns the mount points located under dir, deepest first.
+func mountPointsUnder(mounts []mount.Info, dir string) []string {
+	var mountPoints []string
+	prefix := filepath.Clean(dir) + "/"
+
+	for _, m := range mounts {
+		if m.Mountpoint == filepath.Clean(dir) || strings.HasPrefix(m.Mountpoint, prefix) {
+			mountPoints = append(mountPoints, m.Mountpoint)
+		}
+	}
+
+	sort.Sort(sort.Reverse(sort.StringSlice(mountPoints)))
+
+	return mountPoints
+}
+
+// readDirNames returns the entries of a directory, if it exists.
+func readDirNames(path string) ([]string, error) {
+	dir, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+	defer dir.Close()
+
+	return dir.Readdirnames(0)
+}
@@ -0,0 +1,203 @@
+// Copyright (c) 2020 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/containerd/containerd/mount"
+	ktu "github.com/kata-containers/runtime/pkg/katatestutils"
+	"github.com/kata-containers/runtime/virtcontainers/persist"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+)
+
+func TestSandboxIDFromCmdline(t *testing.T) {
+	assert := assert.New(t)
+
+	root := "/run/vc/vm"
+
+	for _, d := range []struct {
+		cmdline string
+		id      string
+	}{
+		{"", ""},
+		{"qemu\x00-name\x00sandbox-foo", ""},
+		{"qemu\x00-qmp\x00unix:/run/vc/vm/foo/qmp.sock,server,nowait", "foo"},
+		{"virtiofsd\x00--socket-path=/run/vc/vm/bar/vhost-fs.sock", "bar"},
+		{"qemu\x00-device\x00path=/run/vc/vm/baz,id=x", "baz"},
+		{"ls\x00/run/vc/vm/", ""},
+	} {
+		assert.Equal(d.id, sandboxIDFromCmdline(d.cmdline, root), "cmdline: %q", d.cmdline)
+	}
+}
+
+func TestMountPointsUnder(t *testing.T) {
+	assert := assert.New(t)
+
+	mounts := []mount.Info{
+		{Mountpoint: "/"},
+		{Mountpoint: "/run/shared/foo/mounts"},
+		{Mountpoint: "/run/shared/foo/shared"},
+		{Mountpoint: "/run/shared/foo/mounts/ctr/rootfs"},
+		{Mountpoint: "/run/shared/foobar/mounts"},
+	}
+
+	assert.Equal([]string{
+		"/run/shared/foo/shared",
+		"/run/shared/foo/mounts/ctr/rootfs",
+		"/run/shared/foo/mounts",
+	}, mountPointsUnder(mounts, "/run/shared/foo"))
+
+	assert.Empty(mountPointsUnder(mounts, "/run/shared/unknown"))
+}
+
+func TestFindSandboxProcesses(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "kata-fsck-test")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	savedProcRoot := fsckProcRoot
+	defer func() {
+		fsckProcRoot = savedProcRoot
+	}()
+	fsckProcRoot = dir
+
+	for _, p := range []struct {
+		pid     string
+		exe     string
+		cmdline string
+	}{
+		{"10", "/usr/bin/qemu-system-x86_64", "qemu\x00-qmp\x00unix:/run/vc/vm/foo/qmp.sock,server,nowait"},
+		{"11", "/usr/libexec/virtiofsd (deleted)", "virtiofsd\x00-o\x00source=/run/shared/foo/shared"},
+		{"12", "/usr/bin/vim", "vim\x00/run/shared/bar/shared/file"},
+		{"13", "/usr/bin/qemu-system-x86_64", "qemu\x00-name\x00other"},
+	} {
+		procDir := filepath.Join(dir, p.pid)
+		assert.NoError(os.MkdirAll(procDir, DirMode))
+		assert.NoError(os.Symlink(p.exe, filepath.Join(procDir, "exe")))
+		assert.NoError(ioutil.WriteFile(filepath.Join(procDir, "cmdline"), []byte(p.cmdline), 0644))
+	}
+
+	// Only the processes running the programs of the sandboxes are
+	// reported, not the ones which happen to reference their paths.
+	processes, err := findSandboxProcesses("/run/shared", "/run/vc/vm", []string{"/usr/bin/qemu-system-x86_64", "/usr/libexec/virtiofsd"})
+	assert.NoError(err)
+	sort.Slice(processes, func(i, j int) bool { return processes[i].pid < processes[j].pid })
+	assert.Equal([]sandboxProcess{
+		{pid: 10, sandboxID: "foo"},
+		{pid: 11, sandboxID: "foo", shared: true},
+	}, processes)
+
+	processes, err = findSandboxProcesses("/run/shared", "/run/vc/vm", nil)
+	assert.NoError(err)
+	assert.Empty(processes)
+}
+
+func TestCheckLeakedResources(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "kata-fsck-test")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	sharedDir := filepath.Join(dir, "shared")
+	vmDir := filepath.Join(dir, "vm")
+	procDir := filepath.Join(dir, "proc")
+
+	for _, d := range []string{
+		filepath.Join(sharedDir, "known"),
+		filepath.Join(sharedDir, "leaked"),
+		filepath.Join(vmDir, "known"),
+		filepath.Join(vmDir, "leaked"),
+		filepath.Join(vmDir, "factory-vm"),
+		filepath.Join(sharedDir, "creating"),
+		procDir,
+	} {
+		assert.NoError(os.MkdirAll(d, DirMode))
+	}
+
+	savedHostSharedDir := kataHostSharedDir
+	savedProcRoot := fsckProcRoot
+	defer func() {
+		kataHostSharedDir = savedHostSharedDir
+		fsckProcRoot = savedProcRoot
+	}()
+
+	kataHostSharedDir = func() string {
+		return sharedDir
+	}
+	fsckProcRoot = procDir
+
+	known := map[string]bool{"known": true}
+
+	issues, err := checkLeakedResources(vmDir, known, false, nil)
+	assert.NoError(err)
+	assert.Len(issues, 3)
+	for _, issue := range issues {
+		assert.Equal(LeakedDirectory, issue.Type)
+		assert.False(issue.Repaired)
+	}
+
+	// A sandbox being created owns its locked persist directory
+	// before any other resource and must be left alone.
+	unlock, err := rwLockNewSandbox("creating")
+	assert.NoError(err)
+	defer unlock()
+
+	issues, err = checkLeakedResources(vmDir, known, true, nil)
+	assert.NoError(err)
+	assert.Len(issues, 2)
+	for _, issue := range issues {
+		assert.Equal("leaked", issue.SandboxID)
+		assert.True(issue.Repaired)
+		_, err := os.Stat(issue.Resource)
+		assert.True(os.IsNotExist(err))
+	}
+
+	_, err = os.Stat(filepath.Join(sharedDir, "creating"))
+	assert.NoError(err)
+	store, err := persist.GetDriver()
+	assert.NoError(err)
+	_, err = os.Stat(filepath.Join(store.RunStoragePath(), "leaked"))
+	assert.True(os.IsNotExist(err))
+
+	_, err = os.Stat(filepath.Join(vmDir, "factory-vm"))
+	assert.NoError(err)
+	_, err = os.Stat(filepath.Join(sharedDir, "known"))
+	assert.NoError(err)
+}
+
+func TestRestoreNetNs(t *testing.T) {
+	if tc.NotValid(ktu.NeedRoot()) {
+		t.Skip(testDisabledAsNonRoot)
+	}
+
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "kata-fsck-test")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	netNsPath := filepath.Join(dir, "netns", "sandbox")
+	assert.NoError(restoreNetNs(netNsPath, os.Getpid()))
+	defer unix.Unmount(netNsPath, unix.MNT_DETACH)
+
+	var expected, restored unix.Stat_t
+	assert.NoError(unix.Stat(fmt.Sprintf("/proc/%d/ns/net", os.Getpid()), &expected))
+	assert.NoError(unix.Stat(netNsPath, &restored))
+	assert.Equal(expected.Ino, restored.Ino)
+
+	// Never mount over an existing file.
+	assert.Error(restoreNetNs(netNsPath, os.Getpid()))
+}
@@ -330,6 +330,14 @@ type HypervisorConfig struct {
 	// VirtioFSExtraArgs passes options to virtiofsd daemon
 	VirtioFSExtraArgs []string
 
+	// VirtioFSDaemonRestart restarts the virtio-fs daemon when it quits,
+	// for daemons supporting reconnection. Only QEMU reconnects to them.
+	VirtioFSDaemonRestart bool
+
+	// Enable9pVolumes adds a 9p share of the sandbox shared directory next
+	// to the virtio-fs one, for the volumes opting into 9p.
+	Enable9pVolumes bool
+
 	// File based memory backend root directory
 	FileBackedMemRootDir string
 
@@ -497,7 +505,7 @@ func (conf *HypervisorConfig) valid() error {
 		conf.DefaultMaxVCPUs = defaultMaxQemuVCPUs
 	}
 
-	if conf.Msize9p == 0 && conf.SharedFS != config.VirtioFS {
+	if conf.Msize9p == 0 && (conf.SharedFS != config.VirtioFS || conf.Enable9pVolumes) {
 		conf.Msize9p = defaultMsize9p
 	}
 
@@ -795,6 +803,8 @@ type hypervisor interface {
 	hotplugRemoveDevice(devInfo interface{}, devType deviceType) (interface{}, error)
 	resizeMemory(memMB uint32, memoryBlockSizeMB uint32, probe bool) (uint32, memoryDevice, error)
 	resizeVCPUs(vcpus uint32) (uint32, uint32, error)
+	// throttleBlockDevice sets the I/O limits of a hotplugged block drive.
+	throttleBlockDevice(drive *config.BlockDrive, throttle config.BlockDriveThrottle) error
 	getSandboxConsole(sandboxID string) (string, error)
 	disconnect()
 	capabilities() types.Capabilities
@@ -804,6 +814,12 @@ type hypervisor interface {
 	// getPids returns a slice of hypervisor related process ids.
 	// The hypervisor pid must be put at index 0.
 	getPids() []int
+	// getVirtiofsdPid returns the pid of the virtio-fs daemon sharing the
+	// sandbox directory, 0 if there is none.
+	getVirtiofsdPid() int
+	// restartVirtiofsd starts a new virtio-fs daemon on the socket of the
+	// one that quit, for the hypervisor to reconnect to it.
+	restartVirtiofsd() error
 	fromGrpc(ctx context.Context, hypervisorConfig *HypervisorConfig, j []byte) error
 	toGrpc() ([]byte, error)
 	check() error
@@ -92,6 +92,11 @@ func (impl *VCImpl) StartContainer(ctx context.Context, sandboxID, containerID s
 	return StartContainer(ctx, sandboxID, containerID)
 }
 
+// RestartContainer implements the VC function of the same name.
+func (impl *VCImpl) RestartContainer(ctx context.Context, sandboxID, containerID string) (VCContainer, error) {
+	return RestartContainer(ctx, sandboxID, containerID)
+}
+
 // StopContainer implements the VC function of the same name.
 func (impl *VCImpl) StopContainer(ctx context.Context, sandboxID, containerID string) (VCContainer, error) {
 	return StopContainer(ctx, sandboxID, containerID)
@@ -132,6 +137,16 @@ func (impl *VCImpl) UpdateContainer(ctx context.Context, sandboxID, containerID
 	return UpdateContainer(ctx, sandboxID, containerID, resources)
 }
 
+// AddMount implements the VC function of the same name.
+func (impl *VCImpl) AddMount(ctx context.Context, sandboxID, containerID string, mount Mount) error {
+	return AddMount(ctx, sandboxID, containerID, mount)
+}
+
+// RemoveMount implements the VC function of the same name.
+func (impl *VCImpl) RemoveMount(ctx context.Context, sandboxID, containerID, destination string) error {
+	return RemoveMount(ctx, sandboxID, containerID, destination)
+}
+
 // PauseContainer implements the VC function of the same name.
 func (impl *VCImpl) PauseContainer(ctx context.Context, sandboxID, containerID string) error {
 	return PauseContainer(ctx, sandboxID, containerID)
@@ -157,6 +172,11 @@ func (impl *VCImpl) RemoveInterface(ctx context.Context, sandboxID string, inf *
 	return RemoveInterface(ctx, sandboxID, inf)
 }
 
+// UpdateInterface implements the VC function of the same name.
+func (impl *VCImpl) UpdateInterface(ctx context.Context, sandboxID string, inf *vcTypes.Interface) (*vcTypes.Interface, error) {
+	return UpdateInterface(ctx, sandboxID, inf)
+}
+
 // ListInterfaces implements the VC function of the same name.
 func (impl *VCImpl) ListInterfaces(ctx context.Context, sandboxID string) ([]*vcTypes.Interface, error) {
 	return ListInterfaces(ctx, sandboxID)
@@ -172,9 +192,19 @@ func (impl *VCImpl) ListRoutes(ctx context.Context, sandboxID string) ([]*vcType
 	return ListRoutes(ctx, sandboxID)
 }
 
+// UpdateNeighbors implements the VC function of the same name.
+func (impl *VCImpl) UpdateNeighbors(ctx context.Context, sandboxID string, neighs []*vcTypes.ARPNeighbor) error {
+	return UpdateNeighbors(ctx, sandboxID, neighs)
+}
+
 // CleanupContaienr is used by shimv2 to stop and delete a container exclusively, once there is no container
 // in the sandbox left, do stop the sandbox and delete it. Those serial operations will be done exclusively by
 // locking the sandbox.
 func (impl *VCImpl) CleanupContainer(ctx context.Context, sandboxID, containerID string, force bool) error {
 	return CleanupContainer(ctx, sandboxID, containerID, force)
 }
+
+// CheckSandboxes implements the VC function of the same name.
+func (impl *VCImpl) CheckSandboxes(ctx context.Context, repair bool, binaries []string) ([]Inconsistency, error) {
+	return CheckSandboxes(ctx, repair, binaries)
+}
@@ -8,6 +8,7 @@ package virtcontainers
 import (
 	"context"
 	"io"
+	"sync"
 	"syscall"
 
 	"github.com/kata-containers/runtime/virtcontainers/device/api"
@@ -37,12 +38,15 @@ type VC interface {
 	EnterContainer(ctx context.Context, sandboxID, containerID string, cmd types.Cmd) (VCSandbox, VCContainer, *Process, error)
 	KillContainer(ctx context.Context, sandboxID, containerID string, signal syscall.Signal, all bool) error
 	StartContainer(ctx context.Context, sandboxID, containerID string) (VCContainer, error)
+	RestartContainer(ctx context.Context, sandboxID, containerID string) (VCContainer, error)
 	StatusContainer(ctx context.Context, sandboxID, containerID string) (ContainerStatus, error)
 	StatsContainer(ctx context.Context, sandboxID, containerID string) (ContainerStats, error)
 	StatsSandbox(ctx context.Context, sandboxID string) (SandboxStats, []ContainerStats, error)
 	StopContainer(ctx context.Context, sandboxID, containerID string) (VCContainer, error)
 	ProcessListContainer(ctx context.Context, sandboxID, containerID string, options ProcessListOptions) (ProcessList, error)
 	UpdateContainer(ctx context.Context, sandboxID, containerID string, resources specs.LinuxResources) error
+	AddMount(ctx context.Context, sandboxID, containerID string, mount Mount) error
+	RemoveMount(ctx context.Context, sandboxID, containerID, destination string) error
 	PauseContainer(ctx context.Context, sandboxID, containerID string) error
 	ResumeContainer(ctx context.Context, sandboxID, containerID string) error
 
@@ -50,11 +54,15 @@ type VC interface {
 
 	AddInterface(ctx context.Context, sandboxID string, inf *vcTypes.Interface) (*vcTypes.Interface, error)
 	RemoveInterface(ctx context.Context, sandboxID string, inf *vcTypes.Interface) (*vcTypes.Interface, error)
+	UpdateInterface(ctx context.Context, sandboxID string, inf *vcTypes.Interface) (*vcTypes.Interface, error)
 	ListInterfaces(ctx context.Context, sandboxID string) ([]*vcTypes.Interface, error)
 	UpdateRoutes(ctx context.Context, sandboxID string, routes []*vcTypes.Route) ([]*vcTypes.Route, error)
 	ListRoutes(ctx context.Context, sandboxID string) ([]*vcTypes.Route, error)
+	UpdateNeighbors(ctx context.Context, sandboxID string, neighs []*vcTypes.ARPNeighbor) error
 
 	CleanupContainer(ctx context.Context, sandboxID, containerID string, force bool) error
+
+	CheckSandboxes(ctx context.Context, repair bool, binaries []string) ([]Inconsistency, error)
 }
 
 // VCSandbox is the Sandbox interface
@@ -77,6 +85,7 @@ type VCSandbox interface {
 	CreateContainer(contConfig ContainerConfig) (VCContainer, error)
 	DeleteContainer(contID string) (VCContainer, error)
 	StartContainer(containerID string) (VCContainer, error)
+	RestartContainer(containerID string) (VCContainer, error)
 	StopContainer(containerID string, force bool) (VCContainer, error)
 	KillContainer(containerID string, signal syscall.Signal, all bool) error
 	StatusContainer(containerID string) (ContainerStatus, error)
@@ -85,6 +94,8 @@ type VCSandbox interface {
 	ResumeContainer(containerID string) error
 	EnterContainer(containerID string, cmd types.Cmd) (VCContainer, *Process, error)
 	UpdateContainer(containerID string, resources specs.LinuxResources) error
+	AddMount(containerID string, mount Mount) error
+	RemoveMount(containerID, destination string) error
 	ProcessListContainer(containerID string, options ProcessListOptions) (ProcessList, error)
 	WaitProcess(containerID, processID string) (int32, error)
 	SignalProcess(containerID, processID string, signal syscall.Signal, all bool) error
@@ -95,11 +106,15 @@ type VCSandbox interface {
 
 	AddInterface(inf *vcTypes.Interface) (*vcTypes.Interface, error)
 	RemoveInterface(inf *vcTypes.Interface) (*vcTypes.Interface, error)
+	UpdateInterface(inf *vcTypes.Interface) (*vcTypes.Interface, error)
 	ListInterfaces() ([]*vcTypes.Interface, error)
 	UpdateRoutes(routes []*vcTypes.Route) ([]*vcTypes.Route, error)
 	ListRoutes() ([]*vcTypes.Route, error)
+	UpdateNeighbors(neighs []*vcTypes.ARPNeighbor) error
+	ServeNetworkChanges(lock sync.Locker) error
 
 	GetOOMEvent() (string, error)
+	GetHypervisorPids() ([]int, error)
 }
 
 // VCContainer is the Container interface
@@ -52,6 +52,10 @@ const (
 	// containers.
 	KataLocalDevType = "local"
 
+	// KataPmemDevType exposes a host file or block device to the VM as a
+	// persistent memory device, mounted with DAX in the container.
+	KataPmemDevType = "pmem"
+
 	// path to vfio devices
 	vfioPath = "/dev/vfio/"
 
@@ -65,7 +69,9 @@ var (
 	errorMissingOCISpec         = errors.New("Missing OCI specification")
 	defaultKataHostSharedDir    = "/run/kata-containers/shared/sandboxes/"
 	defaultKataGuestSharedDir   = "/run/kata-containers/shared/containers/"
+	defaultKataGuest9pSharedDir = "/run/kata-containers/shared/containers-9p/"
 	mountGuestTag               = "kataShared"
+	mountGuest9pTag             = "kataShared9p"
 	defaultKataGuestSandboxDir  = "/run/kata-containers/sandbox/"
 	type9pFs                    = "9p"
 	typeVirtioFS                = "virtiofs"
@@ -171,6 +177,16 @@ var kataGuestSharedDir = func() string {
 	return defaultKataGuestSharedDir
 }
 
+// kataGuest9pSharedDir is where the shared directory is mounted through 9p
+// in the guest, for the volumes opting into 9p.
+var kataGuest9pSharedDir = func() string {
+	if rootless.IsRootless() {
+		// filepath.Join removes trailing slashes, but it is necessary for mounting
+		return filepath.Join(rootless.GetRootlessDir(), defaultKataGuest9pSharedDir) + "/"
+	}
+	return defaultKataGuest9pSharedDir
+}
+
 // The function is declared this way for mocking in unit tests
 var kataGuestSandboxDir = func() string {
 	if rootless.IsRootless() {
@@ -184,6 +200,34 @@ var kataGuestSandboxStorageDir = func() string {
 	return filepath.Join(defaultKataGuestSandboxDir, "storage")
 }
 
+// virtioFSVolumePath returns where a volume shared through a dedicated
+// virtio-fs device is mounted in the guest.
+func virtioFSVolumePath(tag string) string {
+	return filepath.Join(kataGuestSandboxStorageDir(), kataVirtioFSDevType, tag)
+}
+
+// copiedVolumePath returns where a volume opting into being copied is
+// copied in the guest, out of the shared directory.
+func copiedVolumePath(name string) string {
+	return filepath.Join(kataGuestSandboxDir(), "copied", name)
+}
+
+// enabled9pVolumes tells if the sandbox shares its directory through 9p
+// next to virtio-fs, for the volumes opting into 9p.
+func enabled9pVolumes(conf HypervisorConfig) bool {
+	return conf.SharedFS == config.VirtioFS && conf.Enable9pVolumes
+}
+
+// volumes9pShare returns the 9p share of the shared directory, for the
+// volumes opting into 9p.
+func volumes9pShare(sharePath string) types.Volume {
+	return types.Volume{
+		MountTag: mountGuest9pTag,
+		HostPath: sharePath,
+		SharedFS: config.Virtio9P,
+	}
+}
+
 func ephemeralPath() string {
 	if rootless.IsRootless() {
 		return filepath.Join(kataGuestSandboxDir(), kataEphemeralDevType)
@@ -442,7 +486,17 @@ func (k *kataAgent) configure(h hypervisor, id, sharePath string, builtin bool,
 		return err
 	}
 
-	return h.addDevice(sharedVolume, fsDev)
+	if err = h.addDevice(sharedVolume, fsDev); err != nil {
+		return err
+	}
+
+	// The volumes opting into 9p are shared through a 9p share of the
+	// same directory.
+	if !enabled9pVolumes(h.hypervisorConfig()) {
+		return nil
+	}
+
+	return h.addDevice(volumes9pShare(sharePath), fsDev)
 }
 
 func (k *kataAgent) configureFromGrpc(h hypervisor, id string, builtin bool, config interface{}) error {
@@ -979,6 +1033,16 @@ func setupStorages(sandbox *Sandbox) []*grpc.Storage {
 
 			storages = append(storages, sharedVolume)
 		}
+
+		if enabled9pVolumes(sandbox.config.HypervisorConfig) {
+			storages = append(storages, &grpc.Storage{
+				Driver:     kata9pDevType,
+				Source:     mountGuest9pTag,
+				MountPoint: kataGuest9pSharedDir(),
+				Fstype:     type9pFs,
+				Options:    []string{sharedDir9pOptions[0], sharedDir9pOptions[1], fmt.Sprintf("msize=%d", sandbox.config.HypervisorConfig.Msize9p)},
+			})
+		}
 	}
 
 	if sandbox.shmSize > 0 {
@@ -1080,6 +1144,13 @@ func (k *kataAgent) replaceOCIMountsForStorages(spec *specs.Spec, volumeStorages
 			ociMounts[index].Source = path
 			volumeStorages[i].MountPoint = path
 
+			// The filesystem of a pmem volume is bind mounted
+			// into the container from where the storage is.
+			if m.Type == KataPmemDevType {
+				ociMounts[index].Type = "bind"
+				ociMounts[index].Options = append([]string{"rbind"}, imageMountOptions(m.Options)...)
+			}
+
 			break
 		}
 		if index == len(ociMounts) {
@@ -1350,6 +1421,11 @@ func (k *kataAgent) buildContainerRootfs(sandbox *Sandbox, c *Container, rootPat
 			rootfs.Options = []string{"nouuid"}
 		}
 
+		// The drive of a read-only rootfs is read-only.
+		if c.config.ReadonlyRootfs {
+			rootfs.Options = append(rootfs.Options, "ro")
+		}
+
 		// Ensure container mount destination exists
 		// TODO: remove dependency on shared fs path. shared fs is just one kind of storage source.
 		// we should not always use shared fs path for all kinds of storage. Instead, all storage
@@ -1365,6 +1441,11 @@ func (k *kataAgent) buildContainerRootfs(sandbox *Sandbox, c *Container, rootPat
 	// With virtiofs/9pfs we don't need to ask the agent to mount the rootfs as the shared directory
 	// (kataGuestSharedDir) is already mounted in the guest. We only need to mount the rootfs from
 	// the host and it will show up in the guest.
+	// The rootfs is still mounted when the container is restarted.
+	if c.restarting {
+		return nil, nil
+	}
+
 	if err := bindMountContainerRootfs(k.ctx, getMountPath(sandbox.id), c.id, c.rootFs.Target, false); err != nil {
 		return nil, err
 	}
@@ -1429,11 +1510,23 @@ func (k *kataAgent) createContainer(sandbox *Sandbox, c *Container) (p *Process,
 		return nil, err
 	}
 
+	virtioFSStorages, err := k.handleVirtioFSVolumes(c)
+	if err != nil {
+		return nil, err
+	}
+	ctrStorages = append(ctrStorages, virtioFSStorages...)
+
 	k.handleShm(ociSpec.Mounts, sandbox)
 
 	epheStorages := k.handleEphemeralStorage(ociSpec.Mounts)
 	ctrStorages = append(ctrStorages, epheStorages...)
 
+	sizedLocalStorages, err := k.handleSizedLocalStorage(c, ociSpec.Mounts)
+	if err != nil {
+		return nil, err
+	}
+	ctrStorages = append(ctrStorages, sizedLocalStorages...)
+
 	localStorages := k.handleLocalStorage(ociSpec.Mounts, sandbox.id, c.rootfsSuffix)
 	ctrStorages = append(ctrStorages, localStorages...)
 
@@ -1485,6 +1578,13 @@ func (k *kataAgent) createContainer(sandbox *Sandbox, c *Container) (p *Process,
 			k.Logger().Warn("Container will share PID namespace with the agent")
 		}
 	}
+
+	if c.config.isEphemeral() {
+		if sharedPidNs, agentPidNs, err = k.handleEphemeralPidNamespace(sandbox, c); err != nil {
+			return nil, err
+		}
+	}
+
 	passSeccomp := !sandbox.config.DisableGuestSeccomp && sandbox.seccompSupported
 
 	// We need to constraint the spec to make sure we're not passing
@@ -1541,6 +1641,8 @@ func (k *kataAgent) handleEphemeralStorage(mounts []specs.Mount) []*grpc.Storage
 			mounts[idx].Source = filepath.Join(ephemeralPath(), filepath.Base(mnt.Source))
 			// Set the mount type to "bind"
 			mounts[idx].Type = "bind"
+			// The size limit applies to the tmpfs, not to the bind mount
+			mounts[idx].Options = removeMountSizeLimit(mnt.Options)
 
 			// Create a storage struct so that kata agent is able to create
 			// tmpfs backed volume inside the VM
@@ -1550,6 +1652,11 @@ func (k *kataAgent) handleEphemeralStorage(mounts []specs.Mount) []*grpc.Storage
 				Fstype:     "tmpfs",
 				MountPoint: mounts[idx].Source,
 			}
+
+			if size, err := MountSizeLimit(mnt.Options); err == nil && size > 0 {
+				epheStorage.Options = []string{fmt.Sprintf("%s%d", mountSizeOption, size)}
+			}
+
 			epheStorages = append(epheStorages, epheStorage)
 		}
 	}
@@ -1568,6 +1675,7 @@ func (k *kataAgent) handleLocalStorage(mounts []specs.Mount, sandboxID string, r
 			// In Kubernetes, this is usually the pause container and we depend on it existing for
 			// local directories to work.
 			mounts[idx].Source = filepath.Join(kataGuestSharedDir(), sandboxID, rootfsSuffix, KataLocalDevType, filepath.Base(mnt.Source))
+			mounts[idx].Options = removeMountSizeLimit(mnt.Options)
 
 			// Create a storage struct so that the kata agent is able to create the
 			// directory inside the VM.
@@ -1584,6 +1692,105 @@ func (k *kataAgent) handleLocalStorage(mounts []specs.Mount, sandboxID string, r
 	return localStorages
 }
 
+// handleVirtioFSVolumes handles the volumes shared through a dedicated
+// virtio-fs device, by mounting them in the sandbox storage directory of the VM.
+func (k *kataAgent) handleVirtioFSVolumes(c *Container) ([]*grpc.Storage, error) {
+	var storages []*grpc.Storage
+
+	volumes, err := c.config.volumeSharing()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, m := range c.mounts {
+		if m.ShareTag == "" {
+			continue
+		}
+
+		var options []string
+
+		// Like for the shared directory, DAX is not used without cache.
+		volume := volumes[filepath.Clean(m.Destination)]
+		cache := volume.cache
+		if cache == "" {
+			cache = c.sandbox.config.HypervisorConfig.VirtioFSCache
+		}
+		if volume.daxSize != 0 && cache != typeVirtioFSNoCache {
+			options = append(options, sharedDirVirtioFSDaxOptions)
+		}
+
+		if m.ReadOnly {
+			options = append(options, "ro")
+		}
+
+		storages = append(storages, &grpc.Storage{
+			Driver:     kataVirtioFSDevType,
+			Source:     m.ShareTag,
+			MountPoint: virtioFSVolumePath(m.ShareTag),
+			Fstype:     typeVirtioFS,
+			Options:    options,
+		})
+	}
+
+	return storages, nil
+}
+
+// handleSizedLocalStorage handles local storage with a size limit, backed by
+// a disk image, by mounting the image in the sandbox storage directory of the
+// VM and bind mounting a directory of it into the container.
+func (k *kataAgent) handleSizedLocalStorage(c *Container, mounts []specs.Mount) ([]*grpc.Storage, error) {
+	var localStorages []*grpc.Storage
+
+	for _, m := range c.mounts {
+		if m.Type != KataLocalDevType || m.BlockDeviceID == "" {
+			continue
+		}
+
+		// Add the block device to the list of container devices, to make sure the
+		// device is detached with detachDevices() for a container.
+		if !c.hasDevice(m.BlockDeviceID) {
+			c.devices = append(c.devices, ContainerDevice{ID: m.BlockDeviceID, ContainerPath: m.Destination})
+		}
+
+		device := c.sandbox.devManager.GetDeviceByID(m.BlockDeviceID)
+		if device == nil {
+			k.Logger().WithField("device", m.BlockDeviceID).Error("failed to find device by id")
+			return nil, fmt.Errorf("Failed to find device by id (id=%s)", m.BlockDeviceID)
+		}
+
+		vol, err := k.handleDeviceBlockVolume(c, m, device)
+		if err != nil {
+			return nil, err
+		}
+
+		// The volume is shared by all the containers of the sandbox using it.
+		vol.MountPoint = filepath.Join(kataGuestSandboxStorageDir(), KataLocalDevType, filepath.Base(m.Source))
+		vol.Fstype = "ext4"
+		vol.Options = nil
+
+		// The root of the filesystem is not writable by everyone, create
+		// the directory shared with the containers in it.
+		dataDir := filepath.Join(vol.MountPoint, "data")
+		localStorages = append(localStorages, vol, &grpc.Storage{
+			Driver:     KataLocalDevType,
+			Source:     KataLocalDevType,
+			Fstype:     KataLocalDevType,
+			MountPoint: dataDir,
+			Options:    localDirOptions,
+		})
+
+		for idx, mnt := range mounts {
+			if mnt.Type == KataLocalDevType && filepath.Clean(mnt.Destination) == filepath.Clean(m.Destination) {
+				mounts[idx].Source = dataDir
+				mounts[idx].Type = "bind"
+				mounts[idx].Options = removeMountSizeLimit(mnt.Options)
+			}
+		}
+	}
+
+	return localStorages, nil
+}
+
 // handleDeviceBlockVolume handles volume that is block device file
 // and DeviceBlock type.
 func (k *kataAgent) handleDeviceBlockVolume(c *Container, m Mount, device api.Device) (*grpc.Storage, error) {
@@ -1601,6 +1808,9 @@ func (k *kataAgent) handleDeviceBlockVolume(c *Container, m Mount, device api.De
 		vol.Source = fmt.Sprintf("/dev/pmem%s", blockDrive.NvdimmID)
 		vol.Fstype = blockDrive.Format
 		vol.Options = []string{"dax"}
+		if m.ReadOnly {
+			vol.Options = append(vol.Options, "ro")
+		}
 	case c.sandbox.config.HypervisorConfig.BlockDeviceDriver == config.VirtioBlockCCW:
 		vol.Driver = kataBlkCCWDevType
 		vol.Source = blockDrive.DevNo
@@ -1663,13 +1873,17 @@ func (k *kataAgent) handleBlockVolumes(c *Container) ([]*grpc.Storage, error) {
 	for _, m := range c.mounts {
 		id := m.BlockDeviceID
 
-		if len(id) == 0 {
+		// Local volumes backed by a disk image are handled by
+		// handleSizedLocalStorage.
+		if len(id) == 0 || m.Type == KataLocalDevType {
 			continue
 		}
 
 		// Add the block device to the list of container devices, to make sure the
 		// device is detached with detachDevices() for a container.
-		c.devices = append(c.devices, ContainerDevice{ID: id, ContainerPath: m.Destination})
+		if !c.hasDevice(id) {
+			c.devices = append(c.devices, ContainerDevice{ID: id, ContainerPath: m.Destination})
+		}
 
 		var vol *grpc.Storage
 
@@ -1729,6 +1943,40 @@ func (k *kataAgent) handlePidNamespace(grpcSpec *grpc.Spec, sandbox *Sandbox) bo
 	return sharedPidNs
 }
 
+// handleEphemeralPidNamespace determines which PID namespace an ephemeral container
+// should join. The PID namespace path provided through the OCI spec of such a container
+// refers to a host process, which is meaningless inside the VM, hence the target
+// container is looked up from the container annotations instead. If the target shares
+// the sandbox PID namespace, the ephemeral container joins it too. Otherwise, the agent
+// PID namespace is the only one from which the target processes can be reached.
+// An ephemeral container without any target gets its own PID namespace.
+func (k *kataAgent) handleEphemeralPidNamespace(sandbox *Sandbox, c *Container) (sandboxPidNs bool, agentPidNs bool, err error) {
+	targetID := c.config.ephemeralTarget()
+	if targetID == "" {
+		return false, false, nil
+	}
+
+	target, err := sandbox.findContainer(targetID)
+	if err != nil {
+		return false, false, err
+	}
+
+	if target.sharesSandboxPidNs() {
+		return true, false, nil
+	}
+
+	if !sandbox.config.EnableAgentPidNs {
+		return false, false, fmt.Errorf("Ephemeral container %s cannot join the PID namespace of container %s: sharing the agent PID namespace is not allowed by the runtime configuration", c.id, targetID)
+	}
+
+	k.Logger().WithFields(logrus.Fields{
+		"container": c.id,
+		"target":    targetID,
+	}).Warn("Ephemeral container will share PID namespace with the agent")
+
+	return false, true, nil
+}
+
 // checkAgentPidNs checks if environment variable KATA_AGENT_PIDNS has been set for a containers
 // This variable is used to indicate if the containers pid namespace should be shared
 // with the agent pidns. This approach was taken due to the lack of support for container level annotations.
@@ -1885,6 +2133,7 @@ func (k *kataAgent) onlineCPUMem(cpus uint32, cpuOnly bool) error {
 func (k *kataAgent) statsContainer(sandbox *Sandbox, c Container) (*ContainerStats, error) {
 	req := &grpc.StatsContainerRequest{
 		ContainerId: c.id,
+		Filesystems: c.guestFilesystems(),
 	}
 
 	returnStats, err := k.sendReq(req)
@@ -1911,6 +2160,33 @@ func (k *kataAgent) statsContainer(sandbox *Sandbox, c Container) (*ContainerSta
 	containerStats := &ContainerStats{
 		CgroupStats: &cgroupStats,
 	}
+
+	for _, ns := range stats.NetworkStats {
+		containerStats.NetworkStats = append(containerStats.NetworkStats, &NetworkStats{
+			Name:      ns.Name,
+			RxBytes:   ns.RxBytes,
+			RxPackets: ns.RxPackets,
+			RxErrors:  ns.RxErrors,
+			RxDropped: ns.RxDropped,
+			TxBytes:   ns.TxBytes,
+			TxPackets: ns.TxPackets,
+			TxErrors:  ns.TxErrors,
+			TxDropped: ns.TxDropped,
+		})
+	}
+
+	for _, fs := range stats.FilesystemStats {
+		containerStats.FilesystemStats = append(containerStats.FilesystemStats, &FilesystemStats{
+			Path:           fs.Path,
+			CapacityBytes:  fs.CapacityBytes,
+			AvailableBytes: fs.AvailableBytes,
+			UsedBytes:      fs.UsedBytes,
+			Inodes:         fs.Inodes,
+			InodesFree:     fs.InodesFree,
+			InodesUsed:     fs.InodesUsed,
+		})
+	}
+
 	return containerStats, nil
 }
 
@@ -2169,6 +2445,10 @@ func (k *kataAgent) sendReq(request interface{}) (interface{}, error) {
 	}
 	k.Logger().WithField("name", msgName).WithField("req", message.String()).Debug("sending request")
 
+	defer func(start time.Time) {
+		agentRPCDurations.Observe(time.Since(start).Seconds(), msgName)
+	}(time.Now())
+
 	return handler(ctx, request)
 }
 
@@ -2456,6 +2736,24 @@ func (k *kataAgent) copyFile(src, dst string) error {
 	return nil
 }
 
+// removeFile removes a file copied into the guest. An agent which does not
+// know about unlinking a file leaves it empty instead.
+func (k *kataAgent) removeFile(dst string) error {
+	k.Logger().WithField("dest", dst).Debug("Removing file from guest")
+
+	cpReq := &grpc.CopyFileRequest{
+		Path:    dst,
+		DirMode: uint32(DirMode),
+		Unlink:  true,
+	}
+
+	if _, err := k.sendReq(cpReq); err != nil {
+		return fmt.Errorf("Could not send CopyFile request: %v", err)
+	}
+
+	return nil
+}
+
 func (k *kataAgent) markDead() {
 	k.Logger().Infof("mark agent dead")
 	k.dead = true
@@ -207,7 +207,19 @@ func (p *gRPCProxy) OnlineCPUMem(ctx context.Context, req *pb.OnlineCPUMemReques
 }
 
 func (p *gRPCProxy) StatsContainer(ctx context.Context, req *pb.StatsContainerRequest) (*pb.StatsContainerResponse, error) {
-	return &pb.StatsContainerResponse{}, nil
+	resp := &pb.StatsContainerResponse{
+		NetworkStats: []*pb.NetworkStats{
+			{Name: "eth0", RxBytes: 2048, TxPackets: 16},
+		},
+	}
+	for _, path := range req.Filesystems {
+		resp.FilesystemStats = append(resp.FilesystemStats, &pb.FilesystemStats{
+			Path:          path,
+			CapacityBytes: 4096,
+			UsedBytes:     1024,
+		})
+	}
+	return resp, nil
 }
 
 func (p *gRPCProxy) Check(ctx context.Context, req *pb.CheckRequest) (*pb.HealthCheckResponse, error) {
@@ -365,6 +377,68 @@ func TestKataAgentSendReq(t *testing.T) {
 	assert.Nil(err)
 }
 
+func TestKataAgentStatsContainerFilesystems(t *testing.T) {
+	assert := assert.New(t)
+
+	impl := &gRPCProxy{}
+
+	proxy := mock.ProxyGRPCMock{
+		GRPCImplementer: impl,
+		GRPCRegister:    gRPCRegister,
+	}
+
+	sockDir, err := testGenerateKataProxySockDir()
+	assert.Nil(err)
+	defer os.RemoveAll(sockDir)
+
+	testKataProxyURL := fmt.Sprintf(testKataProxyURLTempl, sockDir)
+	err = proxy.Start(testKataProxyURL)
+	assert.Nil(err)
+	defer proxy.Stop()
+
+	k := &kataAgent{
+		ctx: context.Background(),
+		state: KataAgentState{
+			URL: testKataProxyURL,
+		},
+	}
+
+	c := Container{
+		config: &ContainerConfig{},
+		state: types.ContainerState{
+			Fstype:        "ext4",
+			BlockDeviceID: "rootfs",
+		},
+		mounts: []Mount{
+			{Destination: "/shared", Type: "bind"},
+			{Destination: "/block", Type: "bind", BlockDeviceID: "volume"},
+			{Destination: "/block-ro", Type: "bind", BlockDeviceID: "volume-ro", ReadOnly: true},
+			{Destination: "/local", Type: KataLocalDevType},
+			{Destination: "/ephemeral", Type: KataEphemeralDevType},
+		},
+	}
+
+	stats, err := k.statsContainer(&Sandbox{}, c)
+	assert.NoError(err)
+
+	var paths []string
+	for _, fs := range stats.FilesystemStats {
+		assert.Equal(uint64(4096), fs.CapacityBytes)
+		assert.Equal(uint64(1024), fs.UsedBytes)
+		paths = append(paths, fs.Path)
+	}
+	assert.Equal([]string{"/", "/block", "/local", "/ephemeral"}, paths)
+	assert.Equal([]*NetworkStats{
+		{Name: "eth0", RxBytes: 2048, TxPackets: 16},
+	}, stats.NetworkStats)
+
+	// A rootfs shared with the host is accounted for on the host.
+	c.state = types.ContainerState{}
+	stats, err = k.statsContainer(&Sandbox{}, c)
+	assert.NoError(err)
+	assert.Len(stats.FilesystemStats, 3)
+}
+
 func TestHandleEphemeralStorage(t *testing.T) {
 	k := kataAgent{}
 	var ociMounts []specs.Mount
@@ -382,6 +456,25 @@ func TestHandleEphemeralStorage(t *testing.T) {
 	expected := filepath.Join(ephemeralPath(), filepath.Base(mountSource))
 	assert.Equal(t, epheMountPoint, expected,
 		"Ephemeral mount point didn't match: got %s, expecting %s", epheMountPoint, expected)
+	assert.Empty(t, epheStorages[0].Options)
+}
+
+func TestHandleEphemeralStorageSizeLimit(t *testing.T) {
+	assert := assert.New(t)
+	k := kataAgent{}
+
+	ociMounts := []specs.Mount{
+		{
+			Type:    KataEphemeralDevType,
+			Source:  "/tmp/mountPoint",
+			Options: []string{"rbind", "size=64Mi"},
+		},
+	}
+
+	epheStorages := k.handleEphemeralStorage(ociMounts)
+	assert.Len(epheStorages, 1)
+	assert.Equal([]string{"size=67108864"}, epheStorages[0].Options)
+	assert.Equal([]string{"rbind"}, ociMounts[0].Options)
 }
 
 func TestHandleLocalStorage(t *testing.T) {
@@ -408,6 +501,135 @@ func TestHandleLocalStorage(t *testing.T) {
 	assert.Equal(t, localMountPoint, expected)
 }
 
+func TestHandleVirtioFSVolumes(t *testing.T) {
+	assert := assert.New(t)
+	k := kataAgent{}
+
+	c := &Container{
+		sandbox: &Sandbox{
+			config: &SandboxConfig{HypervisorConfig: HypervisorConfig{VirtioFSCache: typeVirtioFSNoCache}},
+		},
+		config: &ContainerConfig{
+			Annotations: map[string]string{
+				vcAnnotations.VolumeSharing: "/data=virtio-fs:cache=always:dax=1024,/logs=virtio-fs:dax=1024",
+			},
+		},
+		mounts: []Mount{
+			{Destination: "/data", ShareTag: "data"},
+			{Destination: "/logs", ShareTag: "logs", ReadOnly: true},
+			{Destination: "/other"},
+		},
+	}
+
+	storages, err := k.handleVirtioFSVolumes(c)
+	assert.NoError(err)
+	assert.Equal([]*pb.Storage{
+		{
+			Driver:     kataVirtioFSDevType,
+			Source:     "data",
+			MountPoint: virtioFSVolumePath("data"),
+			Fstype:     typeVirtioFS,
+			Options:    []string{sharedDirVirtioFSDaxOptions},
+		},
+		{
+			Driver:     kataVirtioFSDevType,
+			Source:     "logs",
+			MountPoint: virtioFSVolumePath("logs"),
+			Fstype:     typeVirtioFS,
+			Options:    []string{"ro"},
+		},
+	}, storages)
+}
+
+func TestSetupStorages9pVolumes(t *testing.T) {
+	assert := assert.New(t)
+
+	var caps types.Capabilities
+	caps.SetFsSharingSupport()
+
+	sandbox := &Sandbox{
+		hypervisor: &fsSharingHypervisor{caps: caps},
+		config: &SandboxConfig{
+			HypervisorConfig: HypervisorConfig{
+				SharedFS:        config.VirtioFS,
+				Enable9pVolumes: true,
+				Msize9p:         8192,
+			},
+		},
+	}
+
+	storages := setupStorages(sandbox)
+	assert.Len(storages, 2)
+	assert.Equal(kataVirtioFSDevType, storages[0].Driver)
+	assert.Equal(&pb.Storage{
+		Driver:     kata9pDevType,
+		Source:     mountGuest9pTag,
+		MountPoint: kataGuest9pSharedDir(),
+		Fstype:     type9pFs,
+		Options:    []string{"trans=virtio,version=9p2000.L,cache=mmap", "nodev", "msize=8192"},
+	}, storages[1])
+}
+
+func TestHandleSizedLocalStorage(t *testing.T) {
+	assert := assert.New(t)
+
+	devManager := manager.NewDeviceManager(manager.VirtioBlock, false, "", nil)
+	device, err := devManager.NewDevice(config.DeviceInfo{
+		HostPath:      "/tmp/cache/" + localVolumeImage,
+		ContainerPath: "/cache",
+		DevType:       "b",
+		DiskImage:     true,
+	})
+	assert.NoError(err)
+	device.(*drivers.BlockDevice).BlockDrive = &config.BlockDrive{VirtPath: "/dev/vdb"}
+
+	c := &Container{
+		sandbox: &Sandbox{
+			config:     &SandboxConfig{HypervisorConfig: HypervisorConfig{BlockDeviceDriver: config.VirtioBlock}},
+			devManager: devManager,
+		},
+		mounts: []Mount{
+			{
+				Source:        "/tmp/cache",
+				Destination:   "/cache",
+				Type:          KataLocalDevType,
+				Options:       []string{"size=1Gi"},
+				BlockDeviceID: device.DeviceID(),
+			},
+			{
+				Source:      "/tmp/scratch",
+				Destination: "/scratch",
+				Type:        KataLocalDevType,
+			},
+		},
+	}
+
+	ociMounts := []specs.Mount{
+		{Source: "/tmp/cache", Destination: "/cache", Type: KataLocalDevType, Options: []string{"size=1Gi"}},
+		{Source: "/tmp/scratch", Destination: "/scratch", Type: KataLocalDevType},
+	}
+
+	k := kataAgent{}
+	storages, err := k.handleSizedLocalStorage(c, ociMounts)
+	assert.NoError(err)
+	assert.Len(storages, 2)
+
+	mountPoint := filepath.Join(kataGuestSandboxStorageDir(), KataLocalDevType, "cache")
+	assert.Equal("/dev/vdb", storages[0].Source)
+	assert.Equal("ext4", storages[0].Fstype)
+	assert.Equal(mountPoint, storages[0].MountPoint)
+	assert.Equal(KataLocalDevType, storages[1].Driver)
+	assert.Equal(filepath.Join(mountPoint, "data"), storages[1].MountPoint)
+
+	assert.Equal(filepath.Join(mountPoint, "data"), ociMounts[0].Source)
+	assert.Equal("bind", ociMounts[0].Type)
+	assert.Empty(ociMounts[0].Options)
+	assert.True(c.hasDevice(device.DeviceID()))
+
+	// Volumes without a size limit are left to handleLocalStorage.
+	assert.Equal(KataLocalDevType, ociMounts[1].Type)
+}
+
 func TestHandleDeviceBlockVolume(t *testing.T) {
 	k := kataAgent{}
 
@@ -433,6 +655,24 @@ func TestHandleDeviceBlockVolume(t *testing.T) {
 				Options: []string{"dax"},
 			},
 		},
+		{
+			inputDev: &drivers.BlockDevice{
+				BlockDrive: &config.BlockDrive{
+					Pmem:     true,
+					NvdimmID: testNvdimmID,
+					Format:   testBlkDriveFormat,
+				},
+			},
+			inputMount: Mount{
+				ReadOnly: true,
+			},
+			resultVol: &pb.Storage{
+				Driver:  kataNvdimmDevType,
+				Source:  fmt.Sprintf("/dev/pmem%s", testNvdimmID),
+				Fstype:  testBlkDriveFormat,
+				Options: []string{"dax", "ro"},
+			},
+		},
 		{
 			BlockDeviceDriver: config.VirtioBlockCCW,
 			inputMount: Mount{
@@ -619,6 +859,13 @@ func TestHandleBlockVolume(t *testing.T) {
 	assert.Equal(t, vStorage, volumeStorages[0], "Error while handle VhostUserBlk type block volume")
 	assert.Equal(t, bStorage, volumeStorages[1], "Error while handle BlockDevice type block volume")
 	assert.Equal(t, dStorage, volumeStorages[2], "Error while handle direct BlockDevice type block volume")
+	assert.Len(t, c.devices, 3)
+
+	// Handling the volumes again, as done when the container is
+	// restarted, must not duplicate the container devices.
+	_, err = k.handleBlockVolumes(c)
+	assert.Nil(t, err, "Error while handling block volumes")
+	assert.Len(t, c.devices, 3)
 }
 
 func TestAppendDevicesEmptyContainerDeviceList(t *testing.T) {
@@ -927,6 +1174,72 @@ func TestHandlePidNamespace(t *testing.T) {
 	assert.False(testIsPidNamespacePresent(g))
 }
 
+func TestHandleEphemeralPidNamespace(t *testing.T) {
+	assert := assert.New(t)
+
+	sandbox := &Sandbox{
+		config:     &SandboxConfig{},
+		containers: map[string]*Container{},
+	}
+
+	privateSpec := newEmptySpec()
+	privateSpec.Linux.Namespaces = []specs.LinuxNamespace{{Type: specs.PIDNamespace}}
+	sharedSpec := newEmptySpec()
+	sharedSpec.Linux.Namespaces = []specs.LinuxNamespace{{Type: specs.PIDNamespace, Path: "/proc/112/ns/pid"}}
+
+	sandbox.containers["private"] = &Container{
+		sandbox: sandbox,
+		config:  &ContainerConfig{ID: "private", CustomSpec: privateSpec},
+	}
+	sandbox.containers["shared"] = &Container{
+		sandbox: sandbox,
+		config:  &ContainerConfig{ID: "shared", CustomSpec: sharedSpec},
+	}
+
+	newEphemeral := func(target string) *Container {
+		return &Container{
+			id: "debug",
+			config: &ContainerConfig{
+				ID: "debug",
+				Annotations: map[string]string{
+					vcAnnotations.EphemeralContainer:       "true",
+					vcAnnotations.EphemeralContainerTarget: target,
+				},
+			},
+		}
+	}
+
+	k := kataAgent{}
+
+	sandboxPidNs, agentPidNs, err := k.handleEphemeralPidNamespace(sandbox, newEphemeral(""))
+	assert.NoError(err)
+	assert.False(sandboxPidNs)
+	assert.False(agentPidNs)
+
+	_, _, err = k.handleEphemeralPidNamespace(sandbox, newEphemeral("unknown"))
+	assert.Error(err)
+
+	sandboxPidNs, agentPidNs, err = k.handleEphemeralPidNamespace(sandbox, newEphemeral("shared"))
+	assert.NoError(err)
+	assert.True(sandboxPidNs)
+	assert.False(agentPidNs)
+
+	_, _, err = k.handleEphemeralPidNamespace(sandbox, newEphemeral("private"))
+	assert.Error(err)
+
+	sandbox.config.EnableAgentPidNs = true
+	sandboxPidNs, agentPidNs, err = k.handleEphemeralPidNamespace(sandbox, newEphemeral("private"))
+	assert.NoError(err)
+	assert.False(sandboxPidNs)
+	assert.True(agentPidNs)
+
+	sandbox.sharePidNs = true
+	sandboxPidNs, agentPidNs, err = k.handleEphemeralPidNamespace(sandbox, newEphemeral("private"))
+	assert.NoError(err)
+	assert.True(sandboxPidNs)
+	assert.False(agentPidNs)
+}
+
 func TestAgentConfigure(t *testing.T) {
 	assert := assert.New(t)
 
@@ -1190,6 +1503,45 @@ func TestKataCopyFile(t *testing.T) {
 	assert.NoError(err)
 }
 
+func TestKataRemoveFile(t *testing.T) {
+	assert := assert.New(t)
+
+	impl := &gRPCProxy{}
+
+	proxy := mock.ProxyGRPCMock{
+		GRPCImplementer: impl,
+		GRPCRegister:    gRPCRegister,
+	}
+
+	sockDir, err := testGenerateKataProxySockDir()
+	assert.NoError(err)
+	defer os.RemoveAll(sockDir)
+
+	testKataProxyURL := fmt.Sprintf(testKataProxyURLTempl, sockDir)
+	err = proxy.Start(testKataProxyURL)
+	assert.NoError(err)
+	defer proxy.Stop()
+
+	k := &kataAgent{
+		ctx: context.Background(),
+		state: KataAgentState{
+			URL: testKataProxyURL,
+		},
+	}
+
+	err = k.removeFile("/run/kata-containers/shared/containers/file")
+	assert.NoError(err)
+
+	// The request asks for the file to be unlinked.
+	data, err := (&pb.CopyFileRequest{Path: "/run/file", Unlink: true}).Marshal()
+	assert.NoError(err)
+
+	var req pb.CopyFileRequest
+	assert.NoError(req.Unmarshal(data))
+	assert.True(req.Unlink)
+	assert.Equal("/run/file", req.Path)
+}
+
 func TestKataCleanupSandbox(t *testing.T) {
 	assert := assert.New(t)
 
@@ -1446,3 +1798,38 @@ func TestKataAgentDirs(t *testing.T) {
 		assert.Equal(ephemeralPath(), defaultEphemeralPath)
 	}
 }
+
+func TestKataAgentGetDNS(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "kata-dns")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	content := "nameserver 10.96.0.10\nnameserver fd00:10:96::a\nsearch svc.cluster.local\n"
+	resolvConf := filepath.Join(dir, "resolv.conf")
+	assert.NoError(ioutil.WriteFile(resolvConf, []byte(content), 0644))
+
+	sandbox := &Sandbox{
+		config: &SandboxConfig{
+			Containers: []ContainerConfig{
+				{
+					Annotations: map[string]string{
+						vcAnnotations.ContainerTypeKey: string(PodSandbox),
+					},
+					CustomSpec: &specs.Spec{
+						Mounts: []specs.Mount{
+							{Destination: GuestDNSFile, Source: resolvConf},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	k := &kataAgent{}
+	dns, err := k.getDNS(sandbox)
+	assert.NoError(err)
+	assert.Contains(dns, "nameserver 10.96.0.10")
+	assert.Contains(dns, "nameserver fd00:10:96::a")
+}
@@ -0,0 +1,30 @@
+// Copyright (c) 2020 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"io"
+
+	"github.com/kata-containers/runtime/virtcontainers/pkg/metrics"
+)
+
+var (
+	agentRPCDurations = metrics.NewHistogramVec("kata_agent_rpc_duration_seconds",
+		"Latency of the requests sent to the agent.", metrics.DefaultBuckets, "request")
+
+	hotplugCount = metrics.NewCounterVec("kata_hotplug_total",
+		"Number of devices hotplugged into and out of the VM.", "device", "operation")
+)
+
+// WriteMetrics writes the metrics collected by virtcontainers in the current
+// process, in the Prometheus text exposition format.
+func WriteMetrics(w io.Writer) error {
+	if err := agentRPCDurations.Write(w); err != nil {
+		return err
+	}
+
+	return hotplugCount.Write(w)
+}
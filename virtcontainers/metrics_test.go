@@ -0,0 +1,25 @@
+// Copyright (c) 2020 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	assert := assert.New(t)
+
+	agentRPCDurations.Observe(0.002, "grpc.CheckRequest")
+	hotplugCount.Inc("block", "add")
+
+	var buf bytes.Buffer
+	assert.NoError(WriteMetrics(&buf))
+	assert.Contains(buf.String(), `kata_agent_rpc_duration_seconds_bucket{request="grpc.CheckRequest",le="0.005"}`)
+	assert.Contains(buf.String(), `kata_hotplug_total{device="block",operation="add"}`)
+}
@@ -10,12 +10,14 @@ import (
 	"errors"
 	"os"
 
+	"github.com/kata-containers/runtime/virtcontainers/device/config"
 	persistapi "github.com/kata-containers/runtime/virtcontainers/persist/api"
 	"github.com/kata-containers/runtime/virtcontainers/types"
 )
 
 type mockHypervisor struct {
-	mockPid int
+	mockPid      int
+	virtiofsdPid int
 }
 
 func (m *mockHypervisor) capabilities() types.Capabilities {
@@ -91,6 +93,10 @@ func (m *mockHypervisor) resizeVCPUs(cpus uint32) (uint32, uint32, error) {
 	return 0, 0, nil
 }
 
+func (m *mockHypervisor) throttleBlockDevice(drive *config.BlockDrive, throttle config.BlockDriveThrottle) error {
+	return nil
+}
+
 func (m *mockHypervisor) disconnect() {
 }
 
@@ -107,6 +113,15 @@ func (m *mockHypervisor) getPids() []int {
 	return []int{m.mockPid}
 }
 
+func (m *mockHypervisor) getVirtiofsdPid() int {
+	return m.virtiofsdPid
+}
+
+func (m *mockHypervisor) restartVirtiofsd() error {
+	m.virtiofsdPid = os.Getpid()
+	return nil
+}
+
 func (m *mockHypervisor) fromGrpc(ctx context.Context, hypervisorConfig *HypervisorConfig, j []byte) error {
 	return errors.New("mockHypervisor is not supported by VM cache")
 }
@@ -6,10 +6,15 @@
 package virtcontainers
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
 	"sync"
 	"time"
 
+	"github.com/kata-containers/runtime/virtcontainers/utils"
 	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 )
 
 const (
@@ -26,6 +31,11 @@ type monitor struct {
 	wg            sync.WaitGroup
 	running       bool
 	stopCh        chan bool
+
+	// virtiofsdPidfd refers to the virtio-fs daemon watched, and is only
+	// used by the monitor goroutine.
+	virtiofsdPid   int
+	virtiofsdPidfd *os.File
 }
 
 func newMonitor(s *Sandbox) *monitor {
@@ -54,10 +64,12 @@ func (m *monitor) newWatcher() (chan error, error) {
 				select {
 				case <-m.stopCh:
 					tick.Stop()
+					m.closeVirtiofsdPidfd()
 					m.wg.Done()
 					return
 				case <-tick.C:
 					m.watchHypervisor()
+					m.watchVirtiofsd()
 					m.watchAgent()
 				}
 			}
@@ -142,3 +154,101 @@ func (m *monitor) watchHypervisor() error {
 	}
 	return nil
 }
+
+// watchVirtiofsd restarts the virtio-fs daemon of the sandbox when it quits
+// and the sandbox is configured to, or else reports a sandbox error, as the
+// shared filesystem hangs in the guest. The daemon is watched through a
+// pidfd, its pid could be reused by another process.
+func (m *monitor) watchVirtiofsd() {
+	m.sandbox.Lock()
+	pid := m.sandbox.hypervisor.getVirtiofsdPid()
+	daemon := m.sandbox.config.HypervisorConfig.VirtioFSDaemon
+	restart := m.sandbox.config.HypervisorConfig.VirtioFSDaemonRestart
+	m.sandbox.Unlock()
+
+	if pid == 0 {
+		return
+	}
+
+	if pid != m.virtiofsdPid {
+		m.closeVirtiofsdPidfd()
+
+		pidfd, err := openVirtiofsdPidfd(pid, daemon)
+		if err != nil {
+			m.notify(errors.Wrapf(err, "virtiofsd process %d quit", pid))
+			return
+		}
+		m.virtiofsdPid = pid
+		m.virtiofsdPidfd = pidfd
+	}
+
+	exited, err := utils.PidfdExited(m.virtiofsdPidfd)
+	if err != nil {
+		virtLog.WithError(err).WithField("pid", pid).Warn("failed to check virtiofsd process")
+		return
+	}
+
+	if !exited {
+		return
+	}
+
+	if !restart {
+		m.notify(errors.Errorf("virtiofsd process %d quit", pid))
+		return
+	}
+
+	if err := m.restartVirtiofsd(pid); err != nil {
+		m.notify(errors.Wrapf(err, "failed to restart virtiofsd process %d", pid))
+	}
+}
+
+// restartVirtiofsd restarts the virtio-fs daemon which quit, on the same
+// socket, for the hypervisor to reconnect to it.
+func (m *monitor) restartVirtiofsd(pid int) error {
+	m.closeVirtiofsdPidfd()
+
+	m.sandbox.Lock()
+	defer m.sandbox.Unlock()
+
+	if err := m.sandbox.hypervisor.restartVirtiofsd(); err != nil {
+		return err
+	}
+
+	virtLog.WithFields(logrus.Fields{
+		"old-pid": pid,
+		"new-pid": m.sandbox.hypervisor.getVirtiofsdPid(),
+	}).Warn("virtiofsd process restarted")
+
+	return m.sandbox.storeSandbox()
+}
+
+func (m *monitor) closeVirtiofsdPidfd() {
+	if m.virtiofsdPidfd != nil {
+		m.virtiofsdPidfd.Close()
+		m.virtiofsdPidfd = nil
+	}
+	m.virtiofsdPid = 0
+}
+
+// openVirtiofsdPidfd opens a pidfd referring to the virtio-fs daemon pid,
+// checking that pid has not been reused by another program.
+func openVirtiofsdPidfd(pid int, daemon string) (*os.File, error) {
+	pidfd, err := utils.OpenPidfd(pid)
+	if err != nil {
+		return nil, err
+	}
+
+	// The process can only be checked once the pidfd refers to it.
+	exe, err := os.Readlink(fmt.Sprintf("/proc/%d/exe", pid))
+	if err != nil {
+		pidfd.Close()
+		return nil, err
+	}
+
+	if path, err := filepath.EvalSymlinks(daemon); err == nil && path != exe {
+		pidfd.Close()
+		return nil, errors.Errorf("pid %d is now %s", pid, exe)
+	}
+
+	return pidfd, nil
+}
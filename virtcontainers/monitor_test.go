@@ -7,7 +7,10 @@ package virtcontainers
 
 import (
 	"errors"
+	"os"
+	"os/exec"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -58,3 +61,66 @@ func TestMonitorClosedChannel(t *testing.T) {
 
 	m.stop()
 }
+
+func TestMonitorWatchVirtiofsd(t *testing.T) {
+	contID := "505"
+	contConfig := newTestContainerConfigNoop(contID)
+	hConfig := newHypervisorConfig(nil, nil)
+	assert := assert.New(t)
+
+	// create a sandbox
+	s, err := testCreateSandbox(t, testSandboxID, MockHypervisor, hConfig, NoopAgentType, NetworkConfig{}, []ContainerConfig{contConfig}, nil)
+	assert.NoError(err)
+	defer cleanUp()
+
+	// a sleeping process stands for the daemon
+	cmd := exec.Command("sleep", "60")
+	assert.NoError(cmd.Start())
+	defer cmd.Process.Kill()
+
+	s.config.HypervisorConfig.VirtioFSDaemon = cmd.Path
+
+	h, ok := s.hypervisor.(*mockHypervisor)
+	assert.True(ok)
+
+	m := newMonitor(s)
+	// only the test watches the daemon
+	m.checkInterval = time.Hour
+
+	ch, err := m.newWatcher()
+	assert.Nil(err, "newWatcher failed: %v", err)
+	defer m.stop()
+
+	// running daemon
+	h.virtiofsdPid = cmd.Process.Pid
+	m.watchVirtiofsd()
+	assert.Len(ch, 0)
+
+	// a pid which is not the daemon one
+	h.virtiofsdPid = os.Getpid()
+	m.watchVirtiofsd()
+	assert.Error(<-ch)
+
+	// the daemon quits
+	h.virtiofsdPid = cmd.Process.Pid
+	m.watchVirtiofsd()
+	assert.Len(ch, 0)
+	assert.NoError(cmd.Process.Kill())
+	cmd.Wait()
+	m.watchVirtiofsd()
+	assert.Error(<-ch)
+
+	// the daemon quits and is restarted
+	cmd = exec.Command("sleep", "60")
+	assert.NoError(cmd.Start())
+	defer cmd.Process.Kill()
+
+	s.config.HypervisorConfig.VirtioFSDaemonRestart = true
+	h.virtiofsdPid = cmd.Process.Pid
+	m.watchVirtiofsd()
+	assert.NoError(cmd.Process.Kill())
+	cmd.Wait()
+	m.watchVirtiofsd()
+	assert.Len(ch, 0)
+	assert.Equal(os.Getpid(), h.virtiofsdPid)
+}
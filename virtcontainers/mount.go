@@ -6,14 +6,19 @@
 package virtcontainers
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 	"syscall"
 
+	units "github.com/docker/go-units"
 	merr "github.com/hashicorp/go-multierror"
 	"github.com/kata-containers/runtime/virtcontainers/utils"
 	"github.com/sirupsen/logrus"
@@ -37,6 +42,167 @@ var propagationTypes = map[string]uintptr{
 	"ubind":   syscall.MS_UNBINDABLE,
 }
 
+// Magic numbers of the disk image formats and filesystems imageFsType
+// knows about.
+var (
+	qcowMagic = []byte{'Q', 'F', 'I', 0xfb}
+	xfsMagic  = []byte("XFSB")
+	extMagic  = []byte{0x53, 0xef}
+)
+
+// extMagicOffset is the offset of the magic number of ext2/3/4 filesystems.
+const extMagicOffset = 1024 + 56
+
+// readImageHeader reads the first size bytes of a disk image file.
+func readImageHeader(path string, size int) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	header := make([]byte, size)
+	if _, err := io.ReadFull(f, header); err != nil {
+		return nil, fmt.Errorf("Could not read disk image %s: %v", path, err)
+	}
+
+	return header, nil
+}
+
+// checkRawImage checks a disk image file is a raw image. Disk image volumes
+// are attached as raw drives, whatever their format: the guest would see the
+// metadata of a qcow2 image instead of its filesystem.
+func checkRawImage(path string) error {
+	header, err := readImageHeader(path, len(qcowMagic))
+	if err != nil {
+		return err
+	}
+
+	if bytes.Equal(header, qcowMagic) {
+		return fmt.Errorf("Disk image %s is a qcow2 image, only raw images can be attached", path)
+	}
+
+	return nil
+}
+
+// imageFsType returns the type of the filesystem of a raw disk image file.
+func imageFsType(path string) (string, error) {
+	if err := checkRawImage(path); err != nil {
+		return "", err
+	}
+
+	header, err := readImageHeader(path, extMagicOffset+len(extMagic))
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case bytes.HasPrefix(header, xfsMagic):
+		return "xfs", nil
+	case bytes.Equal(header[extMagicOffset:], extMagic):
+		return "ext4", nil
+	}
+
+	return "", fmt.Errorf("Unknown filesystem in disk image %s, its type must be provided", path)
+}
+
+// imageMountOptions returns the options to mount the filesystem of a disk
+// image, from the options of its bind mount.
+func imageMountOptions(options []string) []string {
+	var imageOptions []string
+	for _, o := range options {
+		switch o {
+		case "bind", "rbind", "shared", "rshared", "slave", "rslave",
+			"private", "rprivate", "unbindable", "runbindable":
+			continue
+		}
+		imageOptions = append(imageOptions, o)
+	}
+
+	return imageOptions
+}
+
+// Mechanisms a volume can opt into to be shared with the guest, instead of
+// the filesystem sharing of the sandbox.
+const (
+	volumeShareVirtioFS = "virtio-fs"
+	volumeShare9p       = "9p"
+	volumeShareBlock    = "block"
+	volumeShareCopy     = "copy"
+	volumeSharePmem     = "pmem"
+)
+
+// localVolumeImage is the disk image backing a local volume with a size
+// limit, created in the host directory of the volume.
+const localVolumeImage = ".kata-local-volume.img"
+
+// createLocalVolumeImage creates a sparse ext4 image of size bytes, unless
+// it already exists.
+func createLocalVolumeImage(image string, size uint64) error {
+	if _, err := os.Stat(image); err == nil {
+		return nil
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(image), filepath.Base(image))
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp)
+
+	err = f.Truncate(int64(size))
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	if output, err := exec.Command("mkfs.ext4", "-q", "-F", "-m", "0", tmp).CombinedOutput(); err != nil {
+		return fmt.Errorf("mkfs.ext4 %s failed: %v: %s", tmp, err, strings.TrimSpace(string(output)))
+	}
+
+	return os.Rename(tmp, image)
+}
+
+// mountSizeOption is the option of a mount giving the size limit of the
+// volume, like for tmpfs.
+const mountSizeOption = "size="
+
+// MountSizeLimit returns the size limit in bytes given by the size option
+// of a mount, 0 if there is none.
+func MountSizeLimit(options []string) (uint64, error) {
+	for _, o := range options {
+		if !strings.HasPrefix(o, mountSizeOption) {
+			continue
+		}
+
+		size, err := units.RAMInBytes(strings.TrimPrefix(o, mountSizeOption))
+		if err != nil {
+			return 0, fmt.Errorf("Invalid mount size option %q: %v", o, err)
+		}
+
+		return uint64(size), nil
+	}
+
+	return 0, nil
+}
+
+// SetMountSizeLimit returns the options of a mount with the size option set
+// to size bytes.
+func SetMountSizeLimit(options []string, size uint64) []string {
+	return append(removeMountSizeLimit(options), fmt.Sprintf("%s%d", mountSizeOption, size))
+}
+
+func removeMountSizeLimit(options []string) []string {
+	var newOptions []string
+	for _, o := range options {
+		if !strings.HasPrefix(o, mountSizeOption) {
+			newOptions = append(newOptions, o)
+		}
+	}
+
+	return newOptions
+}
+
 func isSystemMount(m string) bool {
 	for _, p := range systemMountPrefixes {
 		if m == p || strings.HasPrefix(m, p+"/") {
@@ -310,6 +476,10 @@ type Mount struct {
 	// VM in case this mount is a block device file or a directory
 	// backed by a block device.
 	BlockDeviceID string
+
+	// ShareTag is the tag of the virtio-fs device dedicated to sharing
+	// the mount with the guest, if any.
+	ShareTag string
 }
 
 func isSymlink(path string) bool {
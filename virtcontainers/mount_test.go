@@ -57,6 +57,116 @@ func TestIsSystemMount(t *testing.T) {
 	}
 }
 
+func TestImageFsType(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpDir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(tmpDir)
+
+	image := func(offset int, magic []byte) string {
+		header := make([]byte, 4096)
+		copy(header[offset:], magic)
+
+		path := filepath.Join(tmpDir, "disk.img")
+		assert.NoError(ioutil.WriteFile(path, header, 0644))
+		return path
+	}
+
+	fstype, err := imageFsType(image(extMagicOffset, extMagic))
+	assert.NoError(err)
+	assert.Equal("ext4", fstype)
+
+	fstype, err = imageFsType(image(0, xfsMagic))
+	assert.NoError(err)
+	assert.Equal("xfs", fstype)
+
+	_, err = imageFsType(image(0, qcowMagic))
+	assert.Error(err)
+
+	_, err = imageFsType(image(0, nil))
+	assert.Error(err)
+
+	// Too small to hold a filesystem
+	path := filepath.Join(tmpDir, "small.img")
+	assert.NoError(ioutil.WriteFile(path, []byte("small"), 0644))
+	_, err = imageFsType(path)
+	assert.Error(err)
+
+	_, err = imageFsType(filepath.Join(tmpDir, "missing.img"))
+	assert.Error(err)
+}
+
+func TestCheckRawImage(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpDir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(tmpDir)
+
+	path := filepath.Join(tmpDir, "disk.img")
+	assert.NoError(ioutil.WriteFile(path, make([]byte, 4096), 0644))
+	assert.NoError(checkRawImage(path))
+
+	assert.NoError(ioutil.WriteFile(path, append(qcowMagic, make([]byte, 4096)...), 0644))
+	assert.Error(checkRawImage(path))
+}
+
+func TestImageMountOptions(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal([]string{"ro", "nosuid"}, imageMountOptions([]string{"rbind", "ro", "rprivate", "nosuid"}))
+	assert.Nil(imageMountOptions([]string{"bind"}))
+}
+
+func TestMountSizeLimit(t *testing.T) {
+	assert := assert.New(t)
+
+	size, err := MountSizeLimit([]string{"rbind", "size=64Mi"})
+	assert.NoError(err)
+	assert.Equal(uint64(64*1024*1024), size)
+
+	size, err = MountSizeLimit([]string{"rbind"})
+	assert.NoError(err)
+	assert.Zero(size)
+
+	_, err = MountSizeLimit([]string{"size=lots"})
+	assert.Error(err)
+
+	assert.Equal([]string{"rbind", "size=1024"}, SetMountSizeLimit([]string{"size=1", "rbind"}, 1024))
+}
+
+func TestCreateLocalVolumeImage(t *testing.T) {
+	assert := assert.New(t)
+
+	if _, err := exec.LookPath("mkfs.ext4"); err != nil {
+		t.Skip("mkfs.ext4 not found")
+	}
+
+	dir, err := ioutil.TempDir("", "local")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	image := filepath.Join(dir, localVolumeImage)
+	assert.NoError(createLocalVolumeImage(image, 16*1024*1024))
+
+	fstype, err := imageFsType(image)
+	assert.NoError(err)
+	assert.Equal("ext4", fstype)
+
+	// An existing image is reused.
+	assert.NoError(ioutil.WriteFile(filepath.Join(dir, "file"), []byte("data"), 0644))
+	assert.NoError(createLocalVolumeImage(image, 32*1024*1024))
+
+	stat, err := os.Stat(image)
+	assert.NoError(err)
+	assert.Equal(int64(16*1024*1024), stat.Size())
+
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(err)
+	assert.Len(files, 2)
+}
+
 func TestIsHostDevice(t *testing.T) {
 	assert := assert.New(t)
 	tests := []struct {
@@ -6,13 +6,26 @@
 package virtcontainers
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
+	"os"
 	"os/exec"
+	"sync"
 	"syscall"
 
+	vcTypes "github.com/kata-containers/runtime/virtcontainers/pkg/types"
 	"github.com/sirupsen/logrus"
 )
 
+// netmonSocket is the name of the control socket the network monitor sends
+// its changes to, in the sandbox runtime directory.
+const netmonSocket = "netmon.sock"
+
+var errNetmonServerClosed = errors.New("Network monitor server closed")
+
 // NetmonConfig is the structure providing specific configuration
 // for the network monitor.
 type NetmonConfig struct {
@@ -29,6 +42,9 @@ type netmonParams struct {
 	logLevel   string
 	runtime    string
 	sandboxID  string
+	// controlSocket is the control socket the network monitor sends its
+	// changes to, instead of calling into the runtime CLI.
+	controlSocket string
 }
 
 func netmonLogger() *logrus.Entry {
@@ -57,6 +73,9 @@ func prepareNetMonParams(params netmonParams) ([]string, error) {
 	if params.logLevel != "" {
 		args = append(args, []string{"-log", params.logLevel}...)
 	}
+	if params.controlSocket != "" {
+		args = append(args, []string{"-c", params.controlSocket}...)
+	}
 
 	return args, nil
 }
@@ -94,3 +113,177 @@ func stopNetmon(pid int) error {
 
 	return nil
 }
+
+// netmonServer applies the network changes sent by the network monitor
+// through the control socket, when the runtime outlives the sandbox
+// creation as the shim v2 does. Each change is applied directly onto the
+// in-memory sandbox, instead of forking the runtime CLI.
+type netmonServer struct {
+	sandbox  *Sandbox
+	listener net.Listener
+
+	// lock is held while applying the changes, so that they are
+	// serialized with the operations the runtime runs on the sandbox.
+	// The changes wait until the runtime provides it.
+	lock      sync.Locker
+	ready     chan struct{}
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newNetmonServer(s *Sandbox, path string) (*netmonServer, error) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	srv := &netmonServer{
+		sandbox:  s,
+		listener: listener,
+		ready:    make(chan struct{}),
+		closed:   make(chan struct{}),
+	}
+
+	go srv.run()
+
+	return srv, nil
+}
+
+// start lets the changes received through the control socket be applied
+// while holding lock.
+func (srv *netmonServer) start(lock sync.Locker) {
+	select {
+	case <-srv.ready:
+		return
+	default:
+	}
+
+	srv.lock = lock
+	close(srv.ready)
+}
+
+func (srv *netmonServer) run() {
+	for {
+		conn, err := srv.listener.Accept()
+		if err != nil {
+			// The server has been closed.
+			return
+		}
+
+		go srv.serve(conn)
+	}
+}
+
+func (srv *netmonServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	decoder := json.NewDecoder(conn)
+	encoder := json.NewEncoder(conn)
+
+	for {
+		var req vcTypes.NetmonRequest
+		if err := decoder.Decode(&req); err != nil {
+			if err != io.EOF {
+				netmonLogger().WithError(err).Warn("Could not read network monitor request")
+			}
+			return
+		}
+
+		if err := encoder.Encode(srv.apply(req.Changes)); err != nil {
+			netmonLogger().WithError(err).Warn("Could not acknowledge network monitor request")
+			return
+		}
+	}
+}
+
+// apply applies a batch of changes in order, and returns the result of each
+// of them. A failed change does not prevent the following ones to be
+// applied.
+func (srv *netmonServer) apply(changes []vcTypes.NetmonChange) vcTypes.NetmonResponse {
+	resp := vcTypes.NetmonResponse{
+		Errors: make([]string, len(changes)),
+	}
+
+	select {
+	case <-srv.ready:
+	case <-srv.closed:
+	}
+
+	if srv.lock != nil {
+		srv.lock.Lock()
+		defer srv.lock.Unlock()
+	}
+
+	for i, change := range changes {
+		err := errNetmonServerClosed
+		select {
+		case <-srv.closed:
+		default:
+			err = srv.applyChange(change)
+		}
+
+		if err != nil {
+			netmonLogger().WithError(err).WithField("operation", change.Op).Error("Could not apply network change")
+			resp.Errors[i] = err.Error()
+		}
+	}
+
+	return resp
+}
+
+func (srv *netmonServer) applyChange(change vcTypes.NetmonChange) error {
+	switch change.Op {
+	case vcTypes.NetmonAddInterface, vcTypes.NetmonDelInterface, vcTypes.NetmonUpdateInterface:
+		if change.Interface == nil {
+			return fmt.Errorf("Missing interface for %s operation", change.Op)
+		}
+
+		var err error
+		switch change.Op {
+		case vcTypes.NetmonAddInterface:
+			_, err = srv.sandbox.AddInterface(change.Interface)
+		case vcTypes.NetmonDelInterface:
+			_, err = srv.sandbox.RemoveInterface(change.Interface)
+		default:
+			_, err = srv.sandbox.UpdateInterface(change.Interface)
+		}
+		return err
+	case vcTypes.NetmonUpdateRoutes:
+		routes := make([]*vcTypes.Route, len(change.Routes))
+		for i := range change.Routes {
+			routes[i] = &change.Routes[i]
+		}
+
+		_, err := srv.sandbox.UpdateRoutes(routes)
+		return err
+	case vcTypes.NetmonUpdateNeighbors:
+		neighs := make([]*vcTypes.ARPNeighbor, len(change.Neighbors))
+		for i := range change.Neighbors {
+			neighs[i] = &change.Neighbors[i]
+		}
+
+		return srv.sandbox.UpdateNeighbors(neighs)
+	default:
+		return fmt.Errorf("Unknown network change operation %q", change.Op)
+	}
+}
+
+func (srv *netmonServer) close() error {
+	if srv == nil {
+		return nil
+	}
+
+	srv.closeOnce.Do(func() { close(srv.closed) })
+
+	// Closing the listener removes the socket.
+	return srv.listener.Close()
+}
@@ -6,9 +6,17 @@
 package virtcontainers
 
 import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
 	"reflect"
+	"sync"
 	"testing"
+	"time"
 
+	vcTypes "github.com/kata-containers/runtime/virtcontainers/pkg/types"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -52,6 +60,13 @@ func TestPrepareNetMonParams(t *testing.T) {
 		"-s", testSandboxID}
 	assert.True(t, reflect.DeepEqual(expected, got),
 		"Got %+v\nExpected %+v", got, expected)
+
+	// Control socket
+	params.controlSocket = "/foo/bar/netmon.sock"
+	got, err = prepareNetMonParams(params)
+	assert.Nil(t, err)
+	expected = append(expected, "-c", params.controlSocket)
+	assert.Equal(t, expected, got)
 }
 
 func TestStopNetmon(t *testing.T) {
@@ -59,3 +74,74 @@ func TestStopNetmon(t *testing.T) {
 	err := stopNetmon(pid)
 	assert.Nil(t, err)
 }
+
+func TestNetmonServer(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "netmon")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, netmonSocket)
+	srv, err := newNetmonServer(&Sandbox{agent: &noopAgent{}}, socket)
+	assert.NoError(err)
+
+	conn, err := net.Dial("unix", socket)
+	assert.NoError(err)
+	defer conn.Close()
+
+	req := vcTypes.NetmonRequest{
+		Changes: []vcTypes.NetmonChange{
+			{Op: vcTypes.NetmonUpdateRoutes, Routes: []vcTypes.Route{{Dest: "10.0.0.0/8"}}},
+			{Op: vcTypes.NetmonAddInterface},
+			{Op: "foo"},
+		},
+	}
+	assert.NoError(json.NewEncoder(conn).Encode(req))
+
+	// The changes wait for the runtime to provide its lock, and are
+	// applied while holding it.
+	var mu sync.Mutex
+	mu.Lock()
+	srv.start(&mu)
+
+	var resp vcTypes.NetmonResponse
+	assert.NoError(conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond)))
+	err = json.NewDecoder(conn).Decode(&resp)
+	assert.Error(err)
+	assert.True(err.(net.Error).Timeout())
+
+	mu.Unlock()
+	assert.NoError(conn.SetReadDeadline(time.Time{}))
+	assert.NoError(json.NewDecoder(conn).Decode(&resp))
+
+	// Each change is acknowledged, a failure does not prevent the
+	// following changes from being applied.
+	assert.Len(resp.Errors, 3)
+	assert.Empty(resp.Errors[0])
+	assert.NotEmpty(resp.Errors[1])
+	assert.NotEmpty(resp.Errors[2])
+
+	assert.NoError(srv.close())
+	_, err = os.Stat(socket)
+	assert.True(os.IsNotExist(err))
+}
+
+func TestNetmonServerClosed(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "netmon")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	srv, err := newNetmonServer(&Sandbox{agent: &noopAgent{}}, filepath.Join(dir, netmonSocket))
+	assert.NoError(err)
+	assert.NoError(srv.close())
+
+	// The changes waiting for the runtime are not applied once the
+	// server is closed.
+	resp := srv.apply([]vcTypes.NetmonChange{
+		{Op: vcTypes.NetmonUpdateRoutes, Routes: []vcTypes.Route{{Dest: "10.0.0.0/8"}}},
+	})
+	assert.Equal([]string{errNetmonServerClosed.Error()}, resp.Errors)
+}
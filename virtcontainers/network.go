@@ -51,6 +51,17 @@ const (
 	// NetXConnectNoneModel can be used when the VM is in the host network namespace
 	NetXConnectNoneModel
 
+	// NetXConnectUserModeModel attaches a user-mode network stack to the
+	// network namespace, so that a rootless sandbox can reach the outside.
+	// Its tap interface is connected to the VM through tc filter rules.
+	NetXConnectUserModeModel
+
+	// NetXConnectEBPFModel redirects traffic from the network interface
+	// provided by the network plugin to a tap interface, like
+	// NetXConnectTCFilterModel, but through tc BPF programs calling
+	// bpf_redirect() instead of tc mirred actions.
+	NetXConnectEBPFModel
+
 	// NetXConnectInvalidModel is the last item to check valid values by IsValid()
 	NetXConnectInvalidModel
 )
@@ -68,6 +79,10 @@ const (
 	tcFilterNetModelStr = "tcfilter"
 
 	noneNetModelStr = "none"
+
+	userModeNetModelStr = "usermode"
+
+	ebpfNetModelStr = "ebpf"
 )
 
 //SetModel change the model string value
@@ -85,6 +100,12 @@ func (n *NetInterworkingModel) SetModel(modelName string) error {
 	case noneNetModelStr:
 		*n = NetXConnectNoneModel
 		return nil
+	case userModeNetModelStr:
+		*n = NetXConnectUserModeModel
+		return nil
+	case ebpfNetModelStr:
+		*n = NetXConnectEBPFModel
+		return nil
 	}
 	return fmt.Errorf("Unknown type %s", modelName)
 }
@@ -155,11 +176,13 @@ type NetworkInterfacePair struct {
 
 // NetworkConfig is the network configuration related to a network.
 type NetworkConfig struct {
-	NetNSPath         string
-	NetNsCreated      bool
-	DisableNewNetNs   bool
-	NetmonConfig      NetmonConfig
-	InterworkingModel NetInterworkingModel
+	NetNSPath             string
+	NetNsCreated          bool
+	DisableNewNetNs       bool
+	NetmonConfig          NetmonConfig
+	InterworkingModel     NetInterworkingModel
+	UserModeNetworkConfig UserModeNetworkConfig
+	Bandwidth             BandwidthLimits
 }
 
 func networkLogger() *logrus.Entry {
@@ -168,10 +191,11 @@ func networkLogger() *logrus.Entry {
 
 // NetworkNamespace contains all data related to its network namespace.
 type NetworkNamespace struct {
-	NetNsPath    string
-	NetNsCreated bool
-	Endpoints    []Endpoint
-	NetmonPID    int
+	NetNsPath          string
+	NetNsCreated       bool
+	Endpoints          []Endpoint
+	NetmonPID          int
+	UserModeNetworkPID int
 }
 
 // TypedJSONEndpoint is used as an intermediate representation for
@@ -253,6 +277,10 @@ func generateEndpoints(typedEndpoints []TypedJSONEndpoint) ([]Endpoint, error) {
 			var endpoint TuntapEndpoint
 			endpointInf = &endpoint
 
+		case VFEndpointType:
+			var endpoint VFEndpoint
+			endpointInf = &endpoint
+
 		default:
 			networkLogger().WithField("endpoint-type", e.Type).Error("Ignoring unknown endpoint type")
 		}
@@ -426,7 +454,7 @@ func xConnectVMNetwork(endpoint Endpoint, h hypervisor) error {
 	switch netPair.NetInterworkingModel {
 	case NetXConnectMacVtapModel:
 		return tapNetworkPair(endpoint, queues, disableVhostNet)
-	case NetXConnectTCFilterModel:
+	case NetXConnectTCFilterModel, NetXConnectUserModeModel, NetXConnectEBPFModel:
 		return setupTCFiltering(endpoint, queues, disableVhostNet)
 	default:
 		return fmt.Errorf("Invalid internetworking model")
@@ -444,7 +472,7 @@ func xDisconnectVMNetwork(endpoint Endpoint) error {
 	switch netPair.NetInterworkingModel {
 	case NetXConnectMacVtapModel:
 		return untapNetworkPair(endpoint)
-	case NetXConnectTCFilterModel:
+	case NetXConnectTCFilterModel, NetXConnectUserModeModel, NetXConnectEBPFModel:
 		return removeTCFiltering(endpoint)
 	default:
 		return fmt.Errorf("Invalid internetworking model")
@@ -682,11 +710,16 @@ func setupTCFiltering(endpoint Endpoint, queues int, disableVhostNet bool) error
 		return err
 	}
 
-	if err := addRedirectTCFilter(attrs.Index, tapAttrs.Index); err != nil {
+	addRedirectFilter := addRedirectTCFilter
+	if netPair.NetInterworkingModel == NetXConnectEBPFModel {
+		addRedirectFilter = addRedirectBPFFilter
+	}
+
+	if err := addRedirectFilter(attrs.Index, tapAttrs.Index); err != nil {
 		return err
 	}
 
-	if err := addRedirectTCFilter(tapAttrs.Index, attrs.Index); err != nil {
+	if err := addRedirectFilter(tapAttrs.Index, attrs.Index); err != nil {
 		return err
 	}
 
@@ -864,7 +897,12 @@ func removeTCFiltering(endpoint Endpoint) error {
 		return err
 	}
 
-	if err := removeRedirectTCFilter(link); err != nil {
+	removeRedirectFilter := removeRedirectTCFilter
+	if netPair.NetInterworkingModel == NetXConnectEBPFModel {
+		removeRedirectFilter = removeRedirectBPFFilter
+	}
+
+	if err := removeRedirectFilter(link); err != nil {
 		return err
 	}
 
@@ -942,6 +980,101 @@ func deleteNetNS(netNSPath string) error {
 	return nil
 }
 
+// hostNetworkStats returns, for each endpoint, the packets dropped or in
+// error on the host interfaces connecting it to the VM, which the guest
+// counters miss: at the tap, in the virtio queues of the hypervisor, or on
+// the veth. The stats are named like the guest interface and oriented like
+// its counters, the traffic sent by the tap being received by the guest.
+func hostNetworkStats(networkNS NetworkNamespace) ([]*NetworkStats, error) {
+	if networkNS.NetNsPath == "" {
+		return nil, nil
+	}
+
+	var stats []*NetworkStats
+
+	err := doNetNS(networkNS.NetNsPath, func(_ ns.NetNS) error {
+		netHandle, err := netlink.NewHandle()
+		if err != nil {
+			return err
+		}
+		defer netHandle.Delete()
+
+		for _, endpoint := range networkNS.Endpoints {
+			netPair := endpoint.NetworkPair()
+			if netPair == nil {
+				continue
+			}
+
+			endpointStats := &NetworkStats{Name: endpoint.Name()}
+			found := false
+
+			for _, name := range []string{netPair.TAPIface.Name, netPair.VirtIface.Name} {
+				link, err := netHandle.LinkByName(name)
+				if err != nil {
+					// Not all the internetworking models
+					// keep both interfaces.
+					if _, ok := err.(netlink.LinkNotFoundError); ok {
+						continue
+					}
+					return fmt.Errorf("Could not get interface %s: %s", name, err)
+				}
+
+				linkStats := link.Attrs().Statistics
+				if linkStats == nil {
+					continue
+				}
+				found = true
+
+				if name == netPair.TAPIface.Name {
+					endpointStats.RxErrors += linkStats.TxErrors
+					endpointStats.RxDropped += linkStats.TxDropped
+					endpointStats.TxErrors += linkStats.RxErrors
+					endpointStats.TxDropped += linkStats.RxDropped
+				} else {
+					endpointStats.RxErrors += linkStats.RxErrors
+					endpointStats.RxDropped += linkStats.RxDropped
+					endpointStats.TxErrors += linkStats.TxErrors
+					endpointStats.TxDropped += linkStats.TxDropped
+				}
+			}
+
+			if found {
+				stats = append(stats, endpointStats)
+			}
+		}
+
+		return nil
+	})
+
+	return stats, err
+}
+
+// mergeHostNetworkStats adds the drops and errors of the host interfaces to
+// the stats of the guest interface of the same endpoint, or reports them on
+// their own when the guest has no such interface.
+func mergeHostNetworkStats(stats, hostStats []*NetworkStats) []*NetworkStats {
+	for _, host := range hostStats {
+		merged := false
+		for _, guest := range stats {
+			if guest.Name != host.Name {
+				continue
+			}
+			guest.RxErrors += host.RxErrors
+			guest.RxDropped += host.RxDropped
+			guest.TxErrors += host.TxErrors
+			guest.TxDropped += host.TxDropped
+			merged = true
+			break
+		}
+
+		if !merged {
+			stats = append(stats, host)
+		}
+	}
+
+	return stats
+}
+
 func generateVCNetworkStructures(networkNS NetworkNamespace) ([]*vcTypes.Interface, []*vcTypes.Route, []*vcTypes.ARPNeighbor, error) {
 
 	if networkNS.NetNsPath == "" {
@@ -961,6 +1094,10 @@ func generateVCNetworkStructures(networkNS NetworkNamespace) ([]*vcTypes.Interfa
 				continue
 			}
 
+			if !vcTypes.IsGuestAddr(addr) {
+				continue
+			}
+
 			netMask, _ := addr.Mask.Size()
 			ipAddress := vcTypes.IPAddress{
 				Family:  netlink.FAMILY_V4,
@@ -1014,8 +1151,8 @@ func generateVCNetworkStructures(networkNS NetworkNamespace) ([]*vcTypes.Interfa
 		for _, neigh := range endpoint.Properties().Neighbors {
 			var n vcTypes.ARPNeighbor
 
-			// We add only static ARP entries
-			if neigh.State != netlink.NUD_PERMANENT {
+			// We add only static ARP and NDP entries
+			if neigh.State != netlink.NUD_PERMANENT || neigh.HardwareAddr == nil {
 				continue
 			}
 
@@ -1038,6 +1175,13 @@ func generateVCNetworkStructures(networkNS NetworkNamespace) ([]*vcTypes.Interfa
 			neighs = append(neighs, &n)
 		}
 	}
+
+	// The on-link routes must be set before the routes going through a
+	// gateway they lead to.
+	sort.SliceStable(routes, func(i, j int) bool {
+		return routes[i].Gateway == "" && routes[j].Gateway != ""
+	})
+
 	return ifaces, routes, neighs, nil
 }
 
@@ -1087,13 +1231,26 @@ func generateRandomPrivateMacAddr() (string, error) {
 	return hardAddr.String(), nil
 }
 
+// hasGuestAddr checks if an interface is configured with any address to be
+// set up in the guest, as the kernel gives an IPv6 link-local address to any
+// interface.
+func hasGuestAddr(addrs []netlink.Addr) bool {
+	for _, addr := range addrs {
+		if vcTypes.IsGuestAddr(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func networkInfoFromLink(handle *netlink.Handle, link netlink.Link) (NetworkInfo, error) {
 	addrs, err := handle.AddrList(link, netlink.FAMILY_ALL)
 	if err != nil {
 		return NetworkInfo{}, err
 	}
 
-	routes, err := handle.RouteList(link, netlink.FAMILY_ALL)
+	routes, err := vcTypes.ListRoutes(handle, link)
 	if err != nil {
 		return NetworkInfo{}, err
 	}
@@ -1150,7 +1307,7 @@ func createEndpointsFromScan(networkNSPath string, config *NetworkConfig) ([]End
 		// either base tunnel devices that are not namespaced
 		// like gre0, gretap0, sit0, ipip0, tunl0 or incorrectly
 		// setup interfaces.
-		if len(netInfo.Addrs) == 0 {
+		if !hasGuestAddr(netInfo.Addrs) {
 			continue
 		}
 
@@ -1197,7 +1354,18 @@ func createEndpoint(netInfo NetworkInfo, idx int, model NetInterworkingModel, li
 
 	if isPhysical {
 		networkLogger().WithField("interface", netInfo.Iface.Name).Info("Physical network interface found")
-		endpoint, err = createPhysicalEndpoint(netInfo)
+		var physical *PhysicalEndpoint
+		physical, err = createPhysicalEndpoint(netInfo)
+		if err != nil {
+			return nil, err
+		}
+
+		if isVirtualFunction(physical.BDF) {
+			networkLogger().WithField("interface", netInfo.Iface.Name).Info("SR-IOV virtual function found")
+			endpoint, err = createVFEndpoint(physical)
+		} else {
+			endpoint = physical
+		}
 	} else {
 		var socketPath string
 
@@ -1291,6 +1459,12 @@ func (n *Network) Add(ctx context.Context, config *NetworkConfig, s *Sandbox, ho
 					return err
 				}
 			}
+
+			if config.Bandwidth != (BandwidthLimits{}) {
+				if err := setupBandwidthLimits(endpoint, config.Bandwidth); err != nil {
+					return err
+				}
+			}
 		}
 
 		return nil
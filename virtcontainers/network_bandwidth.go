@@ -0,0 +1,243 @@
+// Copyright (c) 2020 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/containernetworking/plugins/pkg/ns"
+	vcAnnotations "github.com/kata-containers/runtime/virtcontainers/pkg/annotations"
+	"github.com/vishvananda/netlink"
+)
+
+const (
+	// tbfLatency is the maximum time a packet waits in the TBF queue, the
+	// same as the one used by the CNI bandwidth plugin.
+	tbfLatency = 25 * time.Millisecond
+
+	// tbfMinBurst is large enough for the segmentation offloaded packets
+	// going through the tap device, which TBF would otherwise drop.
+	tbfMinBurst = 64 * 1024
+)
+
+// BandwidthLimits describes the rates, in bits per second, the traffic of
+// the sandbox is limited to. A zero rate means no limit.
+type BandwidthLimits struct {
+	// Ingress limits the traffic received by the sandbox.
+	Ingress uint64
+	// Egress limits the traffic sent by the sandbox.
+	Egress uint64
+}
+
+// bandwidthSuffixes are the suffixes of the Kubernetes quantities, as used
+// by the bandwidth annotations.
+var bandwidthSuffixes = []struct {
+	suffix     string
+	multiplier float64
+}{
+	{"Ki", 1 << 10},
+	{"Mi", 1 << 20},
+	{"Gi", 1 << 30},
+	{"Ti", 1 << 40},
+	{"Pi", 1 << 50},
+	{"k", 1e3},
+	{"M", 1e6},
+	{"G", 1e9},
+	{"T", 1e12},
+	{"P", 1e15},
+}
+
+// ParseBandwidth parses a rate in bits per second, formatted as a
+// Kubernetes quantity like "10M" or "1Gi".
+func ParseBandwidth(value string) (uint64, error) {
+	value = strings.TrimSpace(value)
+	multiplier := float64(1)
+
+	for _, s := range bandwidthSuffixes {
+		if strings.HasSuffix(value, s.suffix) {
+			value = strings.TrimSuffix(value, s.suffix)
+			multiplier = s.multiplier
+			break
+		}
+	}
+
+	rate, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid bandwidth %q: %v", value, err)
+	}
+
+	if rate <= 0 {
+		return 0, fmt.Errorf("Invalid bandwidth %q: must be positive", value)
+	}
+
+	return uint64(rate * multiplier), nil
+}
+
+// bandwidthFromAnnotations returns the bandwidth limits carried by the
+// annotations of a container, and whether it carries any of them.
+func bandwidthFromAnnotations(annotations map[string]string) (BandwidthLimits, bool, error) {
+	var limits BandwidthLimits
+	found := false
+
+	for key, rate := range map[string]*uint64{
+		vcAnnotations.IngressBandwidth: &limits.Ingress,
+		vcAnnotations.EgressBandwidth:  &limits.Egress,
+	} {
+		value, ok := annotations[key]
+		if !ok {
+			continue
+		}
+
+		var err error
+		if *rate, err = ParseBandwidth(value); err != nil {
+			return BandwidthLimits{}, false, fmt.Errorf("Error parsing annotation %s: %v", key, err)
+		}
+		found = true
+	}
+
+	return limits, found, nil
+}
+
+// newTBF returns the TBF root qdisc limiting the traffic sent by the
+// interface with the specified index, following the CNI bandwidth plugin.
+func newTBF(index int, rate uint64) *netlink.Tbf {
+	rateInBytes := rate / 8
+
+	burst := rateInBytes * uint64(tbfLatency) / uint64(time.Second)
+	if burst < tbfMinBurst {
+		burst = tbfMinBurst
+	}
+
+	return &netlink.Tbf{
+		QdiscAttrs: netlink.QdiscAttrs{
+			LinkIndex: index,
+			Handle:    netlink.MakeHandle(1, 0),
+			Parent:    netlink.HANDLE_ROOT,
+		},
+		Rate:   rateInBytes,
+		Limit:  uint32(rateInBytes*uint64(tbfLatency)/uint64(time.Second) + burst),
+		Buffer: uint32(netlink.Xmittime(rateInBytes, uint32(burst))),
+	}
+}
+
+// setTBF limits the traffic sent by "link" to "rate", or removes the limit
+// previously set if "rate" is zero.
+//
+// This is equivalent to calling:
+// `tc qdisc replace dev link root handle 1: tbf rate <rate> burst <burst> latency 25ms`
+func setTBF(netHandle *netlink.Handle, link netlink.Link, rate uint64) error {
+	if rate > 0 {
+		if err := netHandle.QdiscReplace(newTBF(link.Attrs().Index, rate)); err != nil {
+			return fmt.Errorf("Failed to limit bandwidth of %s: %s", link.Attrs().Name, err)
+		}
+		return nil
+	}
+
+	qdiscs, err := netHandle.QdiscList(link)
+	if err != nil {
+		return err
+	}
+
+	for _, qdisc := range qdiscs {
+		tbf, ok := qdisc.(*netlink.Tbf)
+		if !ok || tbf.Parent != netlink.HANDLE_ROOT {
+			continue
+		}
+
+		if err := netHandle.QdiscDel(tbf); err != nil {
+			return fmt.Errorf("Failed to remove bandwidth limit of %s: %s", link.Attrs().Name, err)
+		}
+	}
+
+	return nil
+}
+
+// setupBandwidthLimits shapes the traffic of an endpoint on the interfaces
+// connecting it to the VM, as the traffic redirected to the VM bypasses the
+// qdiscs set up by the network plugin. It must be called from the network
+// namespace of the sandbox.
+func setupBandwidthLimits(endpoint Endpoint, limits BandwidthLimits) error {
+	netPair := endpoint.NetworkPair()
+	if netPair == nil {
+		return nil
+	}
+
+	netHandle, err := netlink.NewHandle()
+	if err != nil {
+		return err
+	}
+	defer netHandle.Delete()
+
+	link, err := getLinkForEndpoint(endpoint, netHandle)
+	if err != nil {
+		return err
+	}
+
+	switch netPair.NetInterworkingModel {
+	case NetXConnectTCFilterModel, NetXConnectUserModeModel, NetXConnectEBPFModel:
+		tapLink, err := getLinkByName(netHandle, netPair.TAPIface.Name, &netlink.Tuntap{})
+		if err != nil {
+			return fmt.Errorf("Could not get TAP interface: %s", err)
+		}
+
+		// The traffic sent by the VM is redirected to the egress of the
+		// veth, while the traffic received by the VM leaves through the
+		// tap device.
+		if err := setTBF(netHandle, tapLink, limits.Ingress); err != nil {
+			return err
+		}
+		return setTBF(netHandle, link, limits.Egress)
+	case NetXConnectMacVtapModel:
+		tapLink, err := getLinkByName(netHandle, netPair.TAPIface.Name, &netlink.Macvtap{})
+		if err != nil {
+			return fmt.Errorf("Could not get TAP interface %s: %s", netPair.TAPIface.Name, err)
+		}
+
+		// The traffic received by the VM is handed over to the macvtap
+		// device by the veth, without going through any egress qdisc.
+		if limits.Ingress > 0 {
+			networkLogger().WithField("interface", netPair.VirtIface.Name).
+				Warn("Ingress bandwidth limit not supported with macvtap internetworking model")
+		}
+		return setTBF(netHandle, tapLink, limits.Egress)
+	}
+
+	return nil
+}
+
+// updateBandwidthLimits applies new bandwidth limits to all the endpoints of
+// the sandbox.
+func (s *Sandbox) updateBandwidthLimits(limits BandwidthLimits) error {
+	if limits == s.config.NetworkConfig.Bandwidth {
+		return nil
+	}
+
+	s.Logger().WithField("bandwidth", fmt.Sprintf("%+v", limits)).Info("Updating bandwidth limits")
+
+	return s.setBandwidthLimits(limits)
+}
+
+// setBandwidthLimits applies the bandwidth limits to all the endpoints of
+// the sandbox, whether they changed or not.
+func (s *Sandbox) setBandwidthLimits(limits BandwidthLimits) error {
+	if err := doNetNS(s.networkNS.NetNsPath, func(_ ns.NetNS) error {
+		for _, endpoint := range s.networkNS.Endpoints {
+			if err := setupBandwidthLimits(endpoint, limits); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	s.config.NetworkConfig.Bandwidth = limits
+
+	return nil
+}
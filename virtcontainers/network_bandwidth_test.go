@@ -0,0 +1,182 @@
+// Copyright (c) 2020 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"testing"
+
+	"github.com/containernetworking/plugins/pkg/ns"
+	ktu "github.com/kata-containers/runtime/pkg/katatestutils"
+	vcAnnotations "github.com/kata-containers/runtime/virtcontainers/pkg/annotations"
+	"github.com/stretchr/testify/assert"
+	"github.com/vishvananda/netlink"
+)
+
+func TestParseBandwidth(t *testing.T) {
+	assert := assert.New(t)
+
+	for value, expected := range map[string]uint64{
+		"1000": 1000,
+		"10k":  10000,
+		"10M":  10000000,
+		"1.5G": 1500000000,
+		"1T":   1000000000000,
+		"1Ki":  1024,
+		"10Mi": 10 * 1024 * 1024,
+		"1Gi":  1 << 30,
+		" 2M ": 2000000,
+	} {
+		rate, err := ParseBandwidth(value)
+		assert.NoError(err, value)
+		assert.Equal(expected, rate, value)
+	}
+
+	for _, value := range []string{"", "M", "fast", "-1M", "0", "10Q"} {
+		_, err := ParseBandwidth(value)
+		assert.Error(err, value)
+	}
+}
+
+func TestBandwidthFromAnnotations(t *testing.T) {
+	assert := assert.New(t)
+
+	limits, found, err := bandwidthFromAnnotations(map[string]string{})
+	assert.NoError(err)
+	assert.False(found)
+	assert.Equal(BandwidthLimits{}, limits)
+
+	limits, found, err = bandwidthFromAnnotations(map[string]string{
+		vcAnnotations.EgressBandwidth: "1M",
+	})
+	assert.NoError(err)
+	assert.True(found)
+	assert.Equal(BandwidthLimits{Egress: 1000000}, limits)
+
+	limits, found, err = bandwidthFromAnnotations(map[string]string{
+		vcAnnotations.IngressBandwidth: "2M",
+		vcAnnotations.EgressBandwidth:  "1M",
+	})
+	assert.NoError(err)
+	assert.True(found)
+	assert.Equal(BandwidthLimits{Ingress: 2000000, Egress: 1000000}, limits)
+
+	_, _, err = bandwidthFromAnnotations(map[string]string{
+		vcAnnotations.IngressBandwidth: "fast",
+	})
+	assert.Error(err)
+}
+
+func TestNewTBF(t *testing.T) {
+	assert := assert.New(t)
+
+	// 8Mbit/s only bursts 25KB in 25ms, below the minimum burst.
+	tbf := newTBF(3, 8000000)
+	assert.Equal(3, tbf.LinkIndex)
+	assert.Equal(uint32(netlink.HANDLE_ROOT), tbf.Parent)
+	assert.Equal(netlink.MakeHandle(1, 0), tbf.Handle)
+	assert.Equal(uint64(1000000), tbf.Rate)
+	assert.Equal(uint32(25000+tbfMinBurst), tbf.Limit)
+	assert.Equal(uint32(netlink.Xmittime(1000000, tbfMinBurst)), tbf.Buffer)
+
+	// 80Gbit/s bursts 250MB in 25ms.
+	tbf = newTBF(3, 80000000000)
+	assert.Equal(uint64(10000000000), tbf.Rate)
+	assert.Equal(uint32(2*250000000), tbf.Limit)
+}
+
+func TestSandboxBandwidthLimits(t *testing.T) {
+	if tc.NotValid(ktu.NeedRoot()) {
+		t.Skip(testDisabledAsNonRoot)
+	}
+
+	assert := assert.New(t)
+
+	netNSPath, err := createNetNS()
+	assert.NoError(err)
+	defer deleteNetNS(netNSPath)
+
+	endpoint, err := createVethNetworkEndpoint(1, "eth0", NetXConnectTCFilterModel)
+	assert.NoError(err)
+
+	err = doNetNS(netNSPath, func(_ ns.NetNS) error {
+		veth := &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: "eth0"}, PeerName: "peer0"}
+		if err := netlink.LinkAdd(veth); err != nil {
+			return err
+		}
+		return setupTCFiltering(endpoint, 1, true)
+	})
+	assert.NoError(err)
+
+	s := &Sandbox{
+		config: &SandboxConfig{},
+		networkNS: NetworkNamespace{
+			NetNsPath: netNSPath,
+			Endpoints: []Endpoint{endpoint},
+		},
+	}
+
+	// rates of the root TBF qdiscs of the tap and the veth, in bytes
+	rates := func() (tap, veth uint64) {
+		err := doNetNS(netNSPath, func(_ ns.NetNS) error {
+			for name, rate := range map[string]*uint64{
+				endpoint.NetPair.TAPIface.Name: &tap,
+				"eth0":                         &veth,
+			} {
+				link, err := netlink.LinkByName(name)
+				if err != nil {
+					return err
+				}
+				qdiscs, err := netlink.QdiscList(link)
+				if err != nil {
+					return err
+				}
+				for _, qdisc := range qdiscs {
+					if tbf, ok := qdisc.(*netlink.Tbf); ok && tbf.Parent == netlink.HANDLE_ROOT {
+						*rate = tbf.Rate
+					}
+				}
+			}
+			return nil
+		})
+		assert.NoError(err)
+		return
+	}
+
+	limits := BandwidthLimits{Ingress: 2000000, Egress: 1000000}
+	assert.NoError(s.updateBandwidthLimits(limits))
+	assert.Equal(limits, s.config.NetworkConfig.Bandwidth)
+	tap, veth := rates()
+	assert.Equal(uint64(250000), tap)
+	assert.Equal(uint64(125000), veth)
+
+	// the limit of the tap is lost, and applied again on update
+	err = doNetNS(netNSPath, func(_ ns.NetNS) error {
+		link, err := netlink.LinkByName(endpoint.NetPair.TAPIface.Name)
+		if err != nil {
+			return err
+		}
+		netHandle, err := netlink.NewHandle()
+		if err != nil {
+			return err
+		}
+		defer netHandle.Delete()
+		return setTBF(netHandle, link, 0)
+	})
+	assert.NoError(err)
+	tap, _ = rates()
+	assert.Zero(tap)
+
+	assert.NoError(s.setBandwidthLimits(s.config.NetworkConfig.Bandwidth))
+	tap, veth = rates()
+	assert.Equal(uint64(250000), tap)
+	assert.Equal(uint64(125000), veth)
+
+	// the limits are removed
+	assert.NoError(s.updateBandwidthLimits(BandwidthLimits{}))
+	tap, veth = rates()
+	assert.Zero(tap)
+	assert.Zero(veth)
+}
@@ -0,0 +1,167 @@
+// Copyright (c) 2020 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"unsafe"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// redirectBPFName is the name of the tc BPF filters redirecting the
+	// traffic between the network interface and the tap interface.
+	redirectBPFName = "kata_redirect"
+
+	// redirectBPFLicense is the license of the redirect BPF program.
+	// bpf_redirect() is not restricted to GPL programs.
+	redirectBPFLicense = "Apache-2.0"
+
+	// BPF instruction opcodes, from include/uapi/linux/bpf.h.
+	bpfOpMovImm = 0xb7 // BPF_ALU64 | BPF_MOV | BPF_K
+	bpfOpCall   = 0x85 // BPF_JMP | BPF_CALL
+	bpfOpExit   = 0x95 // BPF_JMP | BPF_EXIT
+
+	// bpfFuncRedirect is the identifier of the bpf_redirect() helper.
+	bpfFuncRedirect = 23
+
+	// bpfLogSize is the size of the buffer receiving the verifier log when
+	// the program is rejected.
+	bpfLogSize = 4096
+)
+
+// bpfInsn is a BPF instruction, as struct bpf_insn.
+type bpfInsn struct {
+	Code uint8
+	Regs uint8
+	Off  int16
+	Imm  int32
+}
+
+// newBPFInsn returns an instruction with "dst" as destination register. The
+// destination register is held by the low nibble of the register byte on
+// little endian hosts, and by the high nibble on big endian ones.
+func newBPFInsn(code uint8, dst uint8, imm int32) bpfInsn {
+	regs := dst & 0xf
+	if nl.NativeEndian() == binary.BigEndian {
+		regs <<= 4
+	}
+
+	return bpfInsn{Code: code, Regs: regs, Imm: imm}
+}
+
+// redirectBPFProgram returns the instructions of a tc classifier redirecting
+// every packet to the egress of the interface with index "destIndex":
+//
+//	r1 = destIndex
+//	r2 = 0
+//	call bpf_redirect
+//	exit
+//
+// bpf_redirect() returns TC_ACT_REDIRECT, the verdict of the classifier run
+// in direct action mode.
+func redirectBPFProgram(destIndex int) []bpfInsn {
+	return []bpfInsn{
+		newBPFInsn(bpfOpMovImm, 1, int32(destIndex)),
+		newBPFInsn(bpfOpMovImm, 2, 0),
+		newBPFInsn(bpfOpCall, 0, bpfFuncRedirect),
+		newBPFInsn(bpfOpExit, 0, 0),
+	}
+}
+
+// loadRedirectBPF loads the program redirecting the traffic to the interface
+// with index "destIndex", and returns its file descriptor.
+func loadRedirectBPF(destIndex int) (int, error) {
+	var insns bytes.Buffer
+	if err := binary.Write(&insns, nl.NativeEndian(), redirectBPFProgram(destIndex)); err != nil {
+		return -1, err
+	}
+
+	code := insns.Bytes()
+	license := append([]byte(redirectBPFLicense), 0)
+	logBuf := make([]byte, bpfLogSize)
+
+	attr := netlink.BPFAttr{
+		ProgType: uint32(netlink.BPF_PROG_TYPE_SCHED_CLS),
+		InsnCnt:  uint32(len(code) / int(unsafe.Sizeof(bpfInsn{}))),
+		Insns:    uintptr(unsafe.Pointer(&code[0])),
+		License:  uintptr(unsafe.Pointer(&license[0])),
+		LogLevel: 1,
+		LogSize:  uint32(len(logBuf)),
+		LogBuf:   uintptr(unsafe.Pointer(&logBuf[0])),
+	}
+
+	fd, _, errno := unix.Syscall(unix.SYS_BPF, unix.BPF_PROG_LOAD, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr))
+	if errno != 0 {
+		return -1, fmt.Errorf("Failed to load redirect BPF program: %s: %s", errno, string(bytes.TrimRight(logBuf, "\x00")))
+	}
+
+	return int(fd), nil
+}
+
+// addRedirectBPFFilter adds a tc BPF filter for device with index "sourceIndex".
+// All traffic for interface with index "sourceIndex" is redirected to interface with
+// index "destIndex", without going through the tc actions as the mirred
+// action of addRedirectTCFilter does.
+//
+// This is equivalent to calling:
+// `tc filter add dev source parent ffff: protocol all bpf direct-action obj <redirect to dest>`
+func addRedirectBPFFilter(sourceIndex, destIndex int) error {
+	fd, err := loadRedirectBPF(destIndex)
+	if err != nil {
+		return err
+	}
+	// The filter holds its own reference to the program.
+	defer unix.Close(fd)
+
+	filter := &netlink.BpfFilter{
+		FilterAttrs: netlink.FilterAttrs{
+			LinkIndex: sourceIndex,
+			Parent:    netlink.MakeHandle(0xffff, 0),
+			Protocol:  unix.ETH_P_ALL,
+		},
+		Fd:           fd,
+		Name:         redirectBPFName,
+		DirectAction: true,
+	}
+
+	if err := netlink.FilterAdd(filter); err != nil {
+		return fmt.Errorf("Failed to add BPF filter for index %d : %s", sourceIndex, err)
+	}
+
+	return nil
+}
+
+// removeRedirectBPFFilter removes all tc BPF filters created on ingress qdisc for "link".
+func removeRedirectBPFFilter(link netlink.Link) error {
+	if link == nil {
+		return nil
+	}
+
+	// Handle 0xffff is used for ingress
+	filters, err := netlink.FilterList(link, netlink.MakeHandle(0xffff, 0))
+	if err != nil {
+		return err
+	}
+
+	for _, f := range filters {
+		bpf, ok := f.(*netlink.BpfFilter)
+
+		if !ok {
+			continue
+		}
+
+		if err := netlink.FilterDel(bpf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+// Copyright (c) 2020 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	ktu "github.com/kata-containers/runtime/pkg/katatestutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+)
+
+func TestRedirectBPFProgram(t *testing.T) {
+	assert := assert.New(t)
+
+	insns := redirectBPFProgram(42)
+	assert.Len(insns, 4)
+
+	var buf bytes.Buffer
+	err := binary.Write(&buf, nl.NativeEndian(), insns)
+	assert.NoError(err)
+	// struct bpf_insn is 8 bytes long.
+	assert.Equal(8*len(insns), buf.Len())
+
+	if nl.NativeEndian() == binary.LittleEndian {
+		assert.Equal([]byte{0xb7, 0x01, 0, 0, 42, 0, 0, 0}, buf.Bytes()[0:8])
+		assert.Equal([]byte{0xb7, 0x02, 0, 0, 0, 0, 0, 0}, buf.Bytes()[8:16])
+		assert.Equal([]byte{0x85, 0x00, 0, 0, 23, 0, 0, 0}, buf.Bytes()[16:24])
+		assert.Equal([]byte{0x95, 0x00, 0, 0, 0, 0, 0, 0}, buf.Bytes()[24:32])
+	} else {
+		assert.Equal([]byte{0xb7, 0x10, 0, 0, 0, 0, 0, 42}, buf.Bytes()[0:8])
+	}
+}
+
+func TestAddRemoveRedirectBPFFilter(t *testing.T) {
+	if tc.NotValid(ktu.NeedRoot()) {
+		t.Skip(testDisabledAsNonRoot)
+	}
+
+	assert := assert.New(t)
+
+	netHandle, err := netlink.NewHandle()
+	assert.NoError(err)
+	defer netHandle.Delete()
+
+	tapLink, _, err := createLink(netHandle, "testtap0", &netlink.Tuntap{}, 1)
+	assert.NoError(err)
+	defer netHandle.LinkDel(tapLink)
+
+	index := tapLink.Attrs().Index
+	assert.NoError(addQdiscIngress(index))
+
+	err = addRedirectBPFFilter(index, index)
+	if err != nil {
+		// The kernel may not support BPF classifiers.
+		t.Skip(err)
+	}
+
+	filters, err := netlink.FilterList(tapLink, netlink.MakeHandle(0xffff, 0))
+	assert.NoError(err)
+	assert.Len(filters, 1)
+
+	assert.NoError(removeRedirectBPFFilter(tapLink))
+
+	filters, err = netlink.FilterList(tapLink, netlink.MakeHandle(0xffff, 0))
+	assert.NoError(err)
+	assert.Empty(filters)
+
+	assert.NoError(removeQdiscIngress(tapLink))
+}
+
+func TestEBPFRedirectNetwork(t *testing.T) {
+	if tc.NotValid(ktu.NeedRoot()) {
+		t.Skip(testDisabledAsNonRoot)
+	}
+
+	assert := assert.New(t)
+
+	netHandle, err := netlink.NewHandle()
+	assert.NoError(err)
+	defer netHandle.Delete()
+
+	// Create a test veth interface.
+	vethName := "foo"
+	veth := &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: vethName, TxQLen: 200, MTU: 1400}, PeerName: "bar"}
+
+	err = netlink.LinkAdd(veth)
+	assert.NoError(err)
+
+	endpoint, err := createVethNetworkEndpoint(1, vethName, NetXConnectEBPFModel)
+	assert.NoError(err)
+
+	link, err := netlink.LinkByName(vethName)
+	assert.NoError(err)
+
+	err = netHandle.LinkSetUp(link)
+	assert.NoError(err)
+
+	err = setupTCFiltering(endpoint, 1, true)
+	assert.NoError(err)
+
+	filters, err := netlink.FilterList(link, netlink.MakeHandle(0xffff, 0))
+	assert.NoError(err)
+	if assert.Len(filters, 1) {
+		assert.IsType(&netlink.BpfFilter{}, filters[0])
+	}
+
+	err = removeTCFiltering(endpoint)
+	assert.NoError(err)
+
+	// Remove the veth created for testing.
+	err = netHandle.LinkDel(link)
+	assert.NoError(err)
+}
@@ -12,10 +12,12 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/containernetworking/plugins/pkg/ns"
 	ktu "github.com/kata-containers/runtime/pkg/katatestutils"
 	vcTypes "github.com/kata-containers/runtime/virtcontainers/pkg/types"
 	"github.com/stretchr/testify/assert"
 	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
 )
 
 func TestCreateDeleteNetNS(t *testing.T) {
@@ -42,11 +44,15 @@ func TestGenerateInterfacesAndRoutes(t *testing.T) {
 	address1 := &net.IPNet{IP: net.IPv4(172, 17, 0, 2), Mask: net.CIDRMask(16, 32)}
 	address2 := &net.IPNet{IP: net.IPv4(182, 17, 0, 2), Mask: net.CIDRMask(16, 32)}
 	address3 := &net.IPNet{IP: net.ParseIP("2001:db8:1::242:ac11:2"), Mask: net.CIDRMask(64, 128)}
+	linkLocal := &net.IPNet{IP: net.ParseIP("fe80::42:acff:fe11:2"), Mask: net.CIDRMask(64, 128)}
+	dadFailed := &net.IPNet{IP: net.ParseIP("2001:db8:1::242:ac11:3"), Mask: net.CIDRMask(64, 128)}
 
 	addrs := []netlink.Addr{
 		{IPNet: address1, Label: "phyaddr1"},
 		{IPNet: address2, Label: "phyaddr2"},
 		{IPNet: address3, Label: "phyaddr3"},
+		{IPNet: linkLocal, Label: "phyaddr4"},
+		{IPNet: dadFailed, Label: "phyaddr5", Flags: unix.IFA_F_DADFAILED},
 	}
 
 	// Create a couple of routes:
@@ -68,6 +74,9 @@ func TestGenerateInterfacesAndRoutes(t *testing.T) {
 
 	neighs := []netlink.Neigh{
 		{LinkIndex: 329, IP: net.IPv4(192, 168, 0, 101), State: netlink.NUD_PERMANENT, HardwareAddr: arpMAC},
+		{LinkIndex: 329, IP: net.ParseIP("2001:db8:1::101"), State: netlink.NUD_PERMANENT, HardwareAddr: arpMAC},
+		{LinkIndex: 329, IP: net.ParseIP("2001:db8:1::102"), State: netlink.NUD_PERMANENT},
+		{LinkIndex: 329, IP: net.ParseIP("2001:db8:1::103"), State: netlink.NUD_REACHABLE, HardwareAddr: arpMAC},
 	}
 
 	networkInfo := NetworkInfo{
@@ -105,10 +114,11 @@ func TestGenerateInterfacesAndRoutes(t *testing.T) {
 		{Device: "eth0", Name: "eth0", IPAddresses: expectedAddresses, Mtu: 1500, HwAddr: "02:00:ca:fe:00:04"},
 	}
 
+	// The on-link routes come first.
 	expectedRoutes := []*vcTypes.Route{
+		{Dest: "2001:db8:1::/64", Gateway: "", Device: "eth0", Source: ""},
 		{Dest: "", Gateway: "172.17.0.1", Device: "eth0", Source: "", Scope: uint32(254)},
 		{Dest: "172.17.0.0/16", Gateway: "172.17.0.1", Device: "eth0", Source: "172.17.0.2"},
-		{Dest: "2001:db8:1::/64", Gateway: "", Device: "eth0", Source: ""},
 		{Dest: "", Gateway: "2001:db8:1::1", Device: "eth0", Source: ""},
 	}
 
@@ -119,6 +129,12 @@ func TestGenerateInterfacesAndRoutes(t *testing.T) {
 			LLAddr:      "6a:92:3a:59:70:aa",
 			ToIPAddress: &vcTypes.IPAddress{Address: "192.168.0.101", Family: netlink.FAMILY_V4},
 		},
+		{
+			Device:      "eth0",
+			State:       netlink.NUD_PERMANENT,
+			LLAddr:      "6a:92:3a:59:70:aa",
+			ToIPAddress: &vcTypes.IPAddress{Address: "2001:db8:1::101", Family: netlink.FAMILY_V6},
+		},
 	}
 
 	for _, r := range resRoutes {
@@ -144,6 +160,8 @@ func TestNetInterworkingModelIsValid(t *testing.T) {
 		{"Default Model", NetXConnectDefaultModel, true},
 		{"TC Filter Model", NetXConnectTCFilterModel, true},
 		{"Macvtap Model", NetXConnectMacVtapModel, true},
+		{"User Mode Model", NetXConnectUserModeModel, true},
+		{"eBPF Model", NetXConnectEBPFModel, true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -166,6 +184,8 @@ func TestNetInterworkingModelSetModel(t *testing.T) {
 		{"macvtap Model", macvtapNetModelStr, false},
 		{"tcfilter Model", tcFilterNetModelStr, false},
 		{"none Model", noneNetModelStr, false},
+		{"usermode Model", userModeNetModelStr, false},
+		{"ebpf Model", ebpfNetModelStr, false},
 	}
 
 	for _, tt := range tests {
@@ -301,3 +321,55 @@ func TestTcRedirectNetwork(t *testing.T) {
 	err = netHandle.LinkDel(link)
 	assert.NoError(err)
 }
+
+func TestHostNetworkStats(t *testing.T) {
+	if tc.NotValid(ktu.NeedRoot()) {
+		t.Skip(testDisabledAsNonRoot)
+	}
+
+	assert := assert.New(t)
+
+	stats, err := hostNetworkStats(NetworkNamespace{})
+	assert.NoError(err)
+	assert.Empty(stats)
+
+	netNSPath, err := createNetNS()
+	assert.NoError(err)
+	defer deleteNetNS(netNSPath)
+
+	endpoint, err := createVethNetworkEndpoint(1, "eth0", NetXConnectTCFilterModel)
+	assert.NoError(err)
+
+	err = doNetNS(netNSPath, func(_ ns.NetNS) error {
+		veth := &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: "eth0"}, PeerName: "peer0"}
+		return netlink.LinkAdd(veth)
+	})
+	assert.NoError(err)
+
+	// The TAP interface does not exist.
+	stats, err = hostNetworkStats(NetworkNamespace{
+		NetNsPath: netNSPath,
+		Endpoints: []Endpoint{endpoint},
+	})
+	assert.NoError(err)
+	assert.Equal([]*NetworkStats{{Name: "eth0"}}, stats)
+}
+
+func TestMergeHostNetworkStats(t *testing.T) {
+	assert := assert.New(t)
+
+	stats := []*NetworkStats{
+		{Name: "lo", RxBytes: 10, TxBytes: 10},
+		{Name: "eth0", RxBytes: 100, RxDropped: 1, TxBytes: 200, TxErrors: 2},
+	}
+	hostStats := []*NetworkStats{
+		{Name: "eth0", RxDropped: 3, RxErrors: 4, TxDropped: 5},
+		{Name: "eth1", TxErrors: 6},
+	}
+
+	assert.Equal([]*NetworkStats{
+		{Name: "lo", RxBytes: 10, TxBytes: 10},
+		{Name: "eth0", RxBytes: 100, RxDropped: 4, RxErrors: 4, TxBytes: 200, TxErrors: 2, TxDropped: 5},
+		{Name: "eth1", TxErrors: 6},
+	}, mergeHostNetworkStats(stats, hostStats))
+}
@@ -126,6 +126,11 @@ func (n *noopAgent) listRoutes() ([]*vcTypes.Route, error) {
 	return nil, nil
 }
 
+// addARPNeighbors is the Noop agent ARP neighbors add implementation. It does nothing.
+func (n *noopAgent) addARPNeighbors(neighs []*vcTypes.ARPNeighbor) error {
+	return nil
+}
+
 // check is the Noop agent health checker. It does nothing.
 func (n *noopAgent) check() error {
 	return nil
@@ -223,6 +228,11 @@ func (n *noopAgent) copyFile(src, dst string) error {
 	return nil
 }
 
+// removeFile is the Noop agent remove file. It does nothing.
+func (n *noopAgent) removeFile(dst string) error {
+	return nil
+}
+
 func (n *noopAgent) markDead() {
 }
 
@@ -238,6 +238,14 @@ func TestNoopCopyFile(t *testing.T) {
 	assert.Nil(err)
 }
 
+func TestNoopRemoveFile(t *testing.T) {
+	assert := assert.New(t)
+	n := &noopAgent{}
+
+	err := n.removeFile("")
+	assert.Nil(err)
+}
+
 func TestNoopGetOOMEvent(t *testing.T) {
 	assert := assert.New(t)
 	n := &noopAgent{}
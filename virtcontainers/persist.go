@@ -140,10 +140,12 @@ func (s *Sandbox) dumpMounts(cs map[string]persistapi.ContainerState) {
 			state.Mounts = append(state.Mounts, persistapi.Mount{
 				Source:        m.Source,
 				Destination:   m.Destination,
+				Type:          m.Type,
 				Options:       m.Options,
 				HostPath:      m.HostPath,
 				ReadOnly:      m.ReadOnly,
 				BlockDeviceID: m.BlockDeviceID,
+				ShareTag:      m.ShareTag,
 			})
 		}
 
@@ -166,9 +168,10 @@ func (s *Sandbox) dumpAgent(ss *persistapi.SandboxState) {
 
 func (s *Sandbox) dumpNetwork(ss *persistapi.SandboxState) {
 	ss.Network = persistapi.NetworkInfo{
-		NetNsPath:    s.networkNS.NetNsPath,
-		NetmonPID:    s.networkNS.NetmonPID,
-		NetNsCreated: s.networkNS.NetNsCreated,
+		NetNsPath:          s.networkNS.NetNsPath,
+		NetmonPID:          s.networkNS.NetmonPID,
+		UserModeNetworkPID: s.networkNS.UserModeNetworkPID,
+		NetNsCreated:       s.networkNS.NetNsCreated,
 	}
 	for _, e := range s.networkNS.Endpoints {
 		ss.Network.Endpoints = append(ss.Network.Endpoints, e.save())
@@ -191,6 +194,8 @@ func (s *Sandbox) dumpConfig(ss *persistapi.SandboxState) {
 			NetNsCreated:      sconfig.NetworkConfig.NetNsCreated,
 			DisableNewNetNs:   sconfig.NetworkConfig.DisableNewNetNs,
 			InterworkingModel: int(sconfig.NetworkConfig.InterworkingModel),
+			IngressBandwidth:  sconfig.NetworkConfig.Bandwidth.Ingress,
+			EgressBandwidth:   sconfig.NetworkConfig.Bandwidth.Egress,
 		},
 
 		ShmSize:             sconfig.ShmSize,
@@ -239,6 +244,8 @@ func (s *Sandbox) dumpConfig(ss *persistapi.SandboxState) {
 		VirtioFSDaemonList:      sconfig.HypervisorConfig.VirtioFSDaemonList,
 		VirtioFSCache:           sconfig.HypervisorConfig.VirtioFSCache,
 		VirtioFSExtraArgs:       sconfig.HypervisorConfig.VirtioFSExtraArgs[:],
+		VirtioFSDaemonRestart:   sconfig.HypervisorConfig.VirtioFSDaemonRestart,
+		Enable9pVolumes:         sconfig.HypervisorConfig.Enable9pVolumes,
 		BlockDeviceCacheSet:     sconfig.HypervisorConfig.BlockDeviceCacheSet,
 		BlockDeviceCacheDirect:  sconfig.HypervisorConfig.BlockDeviceCacheDirect,
 		BlockDeviceCacheNoflush: sconfig.HypervisorConfig.BlockDeviceCacheNoflush,
@@ -378,10 +385,12 @@ func (c *Container) loadContMounts(cs persistapi.ContainerState) {
 		c.mounts = append(c.mounts, Mount{
 			Source:        m.Source,
 			Destination:   m.Destination,
+			Type:          m.Type,
 			Options:       m.Options,
 			HostPath:      m.HostPath,
 			ReadOnly:      m.ReadOnly,
 			BlockDeviceID: m.BlockDeviceID,
+			ShareTag:      m.ShareTag,
 		})
 	}
 }
@@ -396,9 +405,10 @@ func (c *Container) loadContProcess(cs persistapi.ContainerState) {
 
 func (s *Sandbox) loadNetwork(netInfo persistapi.NetworkInfo) {
 	s.networkNS = NetworkNamespace{
-		NetNsPath:    netInfo.NetNsPath,
-		NetmonPID:    netInfo.NetmonPID,
-		NetNsCreated: netInfo.NetNsCreated,
+		NetNsPath:          netInfo.NetNsPath,
+		NetmonPID:          netInfo.NetmonPID,
+		UserModeNetworkPID: netInfo.UserModeNetworkPID,
+		NetNsCreated:       netInfo.NetNsCreated,
 	}
 
 	for _, e := range netInfo.Endpoints {
@@ -418,6 +428,8 @@ func (s *Sandbox) loadNetwork(netInfo persistapi.NetworkInfo) {
 			ep = &TapEndpoint{}
 		case IPVlanEndpointType:
 			ep = &IPVlanEndpoint{}
+		case VFEndpointType:
+			ep = &VFEndpoint{}
 		default:
 			s.Logger().WithField("endpoint-type", e.Type).Error("unknown endpoint type")
 			continue
@@ -488,6 +500,10 @@ func loadSandboxConfig(id string) (*SandboxConfig, error) {
 			NetNsCreated:      savedConf.NetworkConfig.NetNsCreated,
 			DisableNewNetNs:   savedConf.NetworkConfig.DisableNewNetNs,
 			InterworkingModel: NetInterworkingModel(savedConf.NetworkConfig.InterworkingModel),
+			Bandwidth: BandwidthLimits{
+				Ingress: savedConf.NetworkConfig.IngressBandwidth,
+				Egress:  savedConf.NetworkConfig.EgressBandwidth,
+			},
 		},
 
 		ShmSize:             savedConf.ShmSize,
@@ -537,6 +553,8 @@ func loadSandboxConfig(id string) (*SandboxConfig, error) {
 		VirtioFSDaemonList:      hconf.VirtioFSDaemonList,
 		VirtioFSCache:           hconf.VirtioFSCache,
 		VirtioFSExtraArgs:       hconf.VirtioFSExtraArgs[:],
+		VirtioFSDaemonRestart:   hconf.VirtioFSDaemonRestart,
+		Enable9pVolumes:         hconf.Enable9pVolumes,
 		BlockDeviceCacheSet:     hconf.BlockDeviceCacheSet,
 		BlockDeviceCacheDirect:  hconf.BlockDeviceCacheDirect,
 		BlockDeviceCacheNoflush: hconf.BlockDeviceCacheNoflush,
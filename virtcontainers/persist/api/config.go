@@ -113,6 +113,14 @@ type HypervisorConfig struct {
 	// VirtioFSExtraArgs passes options to virtiofsd daemon
 	VirtioFSExtraArgs []string
 
+	// for daemons supporting reconnection. Only QEMU reconnects to them.
+	// for daemons and hypervisors supporting reconnection.
+	VirtioFSDaemonRestart bool
+
+	// Enable9pVolumes adds a 9p share of the sandbox shared directory next
+	// to the virtio-fs one, for the volumes opting into 9p.
+	Enable9pVolumes bool
+
 	// File based memory backend root directory
 	FileBackedMemRootDir string
 
@@ -233,6 +241,10 @@ type NetworkConfig struct {
 	NetNsCreated      bool
 	DisableNewNetNs   bool
 	InterworkingModel int
+	// IngressBandwidth and EgressBandwidth are the bandwidth limits, in
+	// bits per second, of the traffic received and sent by the sandbox.
+	IngressBandwidth uint64
+	EgressBandwidth  uint64
 }
 
 type ContainerConfig struct {
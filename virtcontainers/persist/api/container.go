@@ -54,6 +54,10 @@ type Mount struct {
 	// VM in case this mount is a block device file or a directory
 	// backed by a block device.
 	BlockDeviceID string
+
+	// ShareTag is the tag of the virtio-fs device dedicated to sharing
+	// the mount with the guest, if any.
+	ShareTag string
 }
 
 // RootfsState saves state of container rootfs
@@ -42,7 +42,13 @@ type HypervisorState struct {
 	VirtiofsdPid         int
 	HotplugVFIOOnRootBus bool
 	PCIeRootPort         int
+	StaleNvdimms         []string
+	VolumeVirtiofsdPids  map[string]int
 
 	// clh sepcific: refer to 'virtcontainers/clh.go:CloudHypervisorState'
 	APISocket string
+
+	// fc specific: refer to 'virtcontainers/fc.go:firecracker'
+	ThrottledDrives map[string]bool
+	ReadOnlyDrives  map[string]string
 }
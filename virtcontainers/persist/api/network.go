@@ -46,6 +46,20 @@ type PhysicalEndpoint struct {
 	VendorDeviceID string
 }
 
+// VFEndpoint is a SR-IOV virtual function, and its configuration on its
+// physical function.
+type VFEndpoint struct {
+	IfaceName      string
+	HardAddr       string
+	BDF            string
+	Driver         string
+	VendorDeviceID string
+	PFName         string
+	VFIndex        int
+	VLAN           int
+	SpoofCheck     bool
+}
+
 type MacvtapEndpoint struct {
 	// This is for showing information.
 	// Remove this field won't impact anything.
@@ -92,12 +106,14 @@ type NetworkEndpoint struct {
 	Tap            *TapEndpoint            `json:",omitempty"`
 	IPVlan         *IPVlanEndpoint         `json:",omitempty"`
 	Tuntap         *TuntapEndpoint         `json:",omitempty"`
+	VF             *VFEndpoint             `json:",omitempty"`
 }
 
 // NetworkInfo contains network information of sandbox
 type NetworkInfo struct {
-	NetNsPath    string
-	NetmonPID    int
-	NetNsCreated bool
-	Endpoints    []NetworkEndpoint
+	NetNsPath          string
+	NetmonPID          int
+	UserModeNetworkPID int
+	NetNsCreated       bool
+	Endpoints          []NetworkEndpoint
 }
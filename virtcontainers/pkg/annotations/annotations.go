@@ -192,6 +192,10 @@ const (
 	// VirtioFSExtraArgs is a sandbox annotation to pass options to virtiofsd daemon
 	VirtioFSExtraArgs = kataAnnotHypervisorPrefix + "virtio_fs_extra_args"
 
+	// Enable9pVolumes is a sandbox annotation to add a 9p share next to the
+	// virtio-fs one, for the volumes opting into 9p
+	Enable9pVolumes = kataAnnotHypervisorPrefix + "enable_9p_volumes"
+
 	//
 	//	Block Device related annotations
 	//
@@ -237,6 +241,11 @@ const (
 
 	// DisableNewNetNs is a sandbox annotation that determines if create a netns for hypervisor process.
 	DisableNewNetNs = kataAnnotRuntimePrefix + "disable_new_netns"
+
+	// PortForwards is a sandbox annotation that specifies the host ports forwarded to the sandbox
+	// by the user-mode network stack, as a comma separated list of [host_addr:]host_port:guest_port[/proto]
+	// entries, the protocol being either tcp (default) or udp.
+	PortForwards = kataAnnotRuntimePrefix + "port_forwards"
 )
 
 // Agent related annotations
@@ -271,6 +280,71 @@ const (
 	ContainerPipeSizeKernelParam = "agent." + ContainerPipeSizeOption
 )
 
+// Container related annotations
+const (
+	kataAnnotContainerPrefix = kataAnnotationsPrefix + "container."
+
+	// EphemeralContainer is a container annotation marking the container as an
+	// ephemeral container, added to an already running sandbox (e.g. for debugging).
+	EphemeralContainer = kataAnnotContainerPrefix + "ephemeral"
+
+	// EphemeralContainerTarget is a container annotation holding the ID of the
+	// container whose PID namespace an ephemeral container should join.
+	EphemeralContainerTarget = kataAnnotContainerPrefix + "ephemeral_target"
+
+	// ImageVolumes is a container annotation listing, comma separated, the
+	// destinations of the bind mounts whose source is a raw disk image file.
+	// Such images are attached to the VM as raw block devices and their
+	// filesystem is mounted in the guest, instead of being shared as files.
+	// Other image formats, like qcow2, are rejected. The filesystem type can
+	// be given as "destination=fstype", e.g. "/data,/models=xfs", it is
+	// detected otherwise.
+	ImageVolumes = kataAnnotContainerPrefix + "image_volumes"
+
+	// EmptyDirSizeLimits is a container annotation listing, comma separated,
+	// the size limits of the Kubernetes emptyDir volumes of the container as
+	// "volume-name=size", e.g. "cache=1Gi". The limits are enforced in the
+	// guest, where the emptyDir volumes are created.
+	EmptyDirSizeLimits = kataAnnotContainerPrefix + "empty_dir_size_limits"
+
+	// VolumeSharing is a container annotation listing, comma separated, the
+	// volumes not shared with the guest through the filesystem sharing of
+	// the sandbox, as "destination=mechanism[:option=value...]". The
+	// mechanism is one of:
+	//   - "virtio-fs": a virtio-fs instance dedicated to the volume, with
+	//     the "cache" mode and the "dax" window size in MiB as options.
+	//     Only Cloud Hypervisor, and QEMU when the sandbox shares files
+	//     through virtio-fs, can hotplug such devices.
+	//   - "9p": the 9p share, when the sandbox shares files through virtio-fs
+	//     and has 9p volumes enabled.
+	//   - "block": the disk image or block device file is attached as a
+	//     block device, like ImageVolumes.
+	//   - "copy": the files are copied into the guest. The copy is kept up
+	//     to date by the shim v2 only, the files removed from the volume are
+	//     removed from the guest.
+	//   - "pmem": the file or block device, which must have the PFN
+	//     signature, is attached as a persistent memory device and mounted
+	//     with DAX, with the "fstype" of its filesystem as option, ext4 by
+	//     default. Mounts of the "pmem" type are attached the same way.
+	// e.g. "/data=virtio-fs:cache=always:dax=1024,/config=copy".
+	VolumeSharing = kataAnnotContainerPrefix + "volume_sharing"
+)
+
+// Kubernetes related annotations
+const (
+	// IngressBandwidth is the Kubernetes pod annotation limiting the rate of
+	// the traffic received by the pod, as a quantity of bits per second
+	// (e.g. "10M"). It is enforced by the CNI bandwidth plugin on the veth
+	// of the pod, and by the runtime on the interfaces connected to the VM,
+	// including the hot plugged ones. A change of the annotation applies
+	// when the next container of the pod is created.
+	IngressBandwidth = "kubernetes.io/ingress-bandwidth"
+
+	// EgressBandwidth is the Kubernetes pod annotation limiting the rate of
+	// the traffic sent by the pod, as a quantity of bits per second.
+	EgressBandwidth = "kubernetes.io/egress-bandwidth"
+)
+
 const (
 	// SHA512 is the SHA-512 (64) hash algorithm
 	SHA512 string = "sha512"
@@ -0,0 +1,15 @@
+/*
+ * Cloud Hypervisor API
+ *
+ * Local HTTP based API for managing and inspecting a cloud-hypervisor virtual machine.
+ *
+ * API version: 0.3.0
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package openapi
+// RateLimiterConfig Defines an IO rate limiter with independent bytes/s and ops/s limits.
+type RateLimiterConfig struct {
+	Bandwidth TokenBucket `json:"bandwidth,omitempty"`
+	Ops TokenBucket `json:"ops,omitempty"`
+}
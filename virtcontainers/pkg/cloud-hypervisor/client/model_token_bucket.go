@@ -0,0 +1,19 @@
+/*
+ * Cloud Hypervisor API
+ *
+ * Local HTTP based API for managing and inspecting a cloud-hypervisor virtual machine.
+ *
+ * API version: 0.3.0
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package openapi
+// TokenBucket Defines a token bucket with a maximum capacity (size), an initial burst size (one_time_burst) and an interval for refilling purposes (refill_time).
+type TokenBucket struct {
+	// The total number of tokens this bucket can hold.
+	Size int64 `json:"size"`
+	// The initial size of a token bucket.
+	OneTimeBurst int64 `json:"one_time_burst,omitempty"`
+	// The amount of milliseconds it takes for the bucket to refill.
+	RefillTime int64 `json:"refill_time"`
+}
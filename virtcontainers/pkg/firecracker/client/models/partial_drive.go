@@ -24,6 +24,9 @@ type PartialDrive struct {
 	// Host level path for the guest drive
 	// Required: true
 	PathOnHost *string `json:"path_on_host"`
+
+	// rate limiter
+	RateLimiter *RateLimiter `json:"rate_limiter,omitempty"`
 }
 
 // Validate validates this partial drive
@@ -38,6 +41,10 @@ func (m *PartialDrive) Validate(formats strfmt.Registry) error {
 		res = append(res, err)
 	}
 
+	if err := m.validateRateLimiter(formats); err != nil {
+		res = append(res, err)
+	}
+
 	if len(res) > 0 {
 		return errors.CompositeValidationError(res...)
 	}
@@ -62,6 +69,24 @@ func (m *PartialDrive) validatePathOnHost(formats strfmt.Registry) error {
 	return nil
 }
 
+func (m *PartialDrive) validateRateLimiter(formats strfmt.Registry) error {
+
+	if swag.IsZero(m.RateLimiter) { // not required
+		return nil
+	}
+
+	if m.RateLimiter != nil {
+		if err := m.RateLimiter.Validate(formats); err != nil {
+			if ve, ok := err.(*errors.Validation); ok {
+				return ve.ValidateName("rate_limiter")
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
 // MarshalBinary interface implementation
 func (m *PartialDrive) MarshalBinary() ([]byte, error) {
 	if m == nil {
@@ -0,0 +1,289 @@
+// Copyright (c) 2020 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package metrics implements the few Prometheus metric types needed by the
+// runtime, and writes them in the Prometheus text exposition format. It is
+// meant to be used where pulling the full Prometheus client library is not
+// worth it.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the HTTP content type of the text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Metric types.
+const (
+	Counter   = "counter"
+	Gauge     = "gauge"
+	Histogram = "histogram"
+)
+
+// DefaultBuckets are histogram buckets suited to latencies in seconds.
+var DefaultBuckets = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Sample is a single value of a metric, computed when the metric is written.
+type Sample struct {
+	Labels map[string]string
+	Value  float64
+}
+
+// WriteMetric writes a metric of the given type made of the given samples.
+func WriteMetric(w io.Writer, name, help, metricType string, samples []Sample) error {
+	if err := writeHeader(w, name, help, metricType); err != nil {
+		return err
+	}
+
+	for _, s := range samples {
+		var names []string
+		for n := range s.Labels {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+
+		var values []string
+		for _, n := range names {
+			values = append(values, s.Labels[n])
+		}
+
+		if err := writeSample(w, name, names, values, "", "", s.Value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CounterVec is a counter partitioned by label values.
+type CounterVec struct {
+	sync.Mutex
+
+	name   string
+	help   string
+	labels []string
+	values map[string]*counterValue
+}
+
+type counterValue struct {
+	labelValues []string
+	value       float64
+}
+
+// NewCounterVec returns a counter partitioned by the given labels.
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{
+		name:   name,
+		help:   help,
+		labels: labels,
+		values: make(map[string]*counterValue),
+	}
+}
+
+// Inc increments the counter for the given label values.
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Lock()
+	defer c.Unlock()
+
+	key := strings.Join(labelValues, "\xff")
+	v, ok := c.values[key]
+	if !ok {
+		v = &counterValue{labelValues: labelValues}
+		c.values[key] = v
+	}
+	v.value++
+}
+
+// Write writes the counter in the text exposition format.
+func (c *CounterVec) Write(w io.Writer) error {
+	c.Lock()
+	defer c.Unlock()
+
+	if err := writeHeader(w, c.name, c.help, Counter); err != nil {
+		return err
+	}
+
+	keys := make(map[string]bool)
+	for k := range c.values {
+		keys[k] = true
+	}
+
+	for _, key := range sortedKeys(keys) {
+		v := c.values[key]
+		if err := writeSample(w, c.name, c.labels, v.labelValues, "", "", v.value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// HistogramVec is a histogram partitioned by label values.
+type HistogramVec struct {
+	sync.Mutex
+
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+	values  map[string]*histogramValue
+}
+
+type histogramValue struct {
+	labelValues []string
+	counts      []uint64
+	count       uint64
+	sum         float64
+}
+
+// NewHistogramVec returns a histogram with the given upper bounds of its
+// buckets, in increasing order, partitioned by the given labels.
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	return &HistogramVec{
+		name:    name,
+		help:    help,
+		labels:  labels,
+		buckets: buckets,
+		values:  make(map[string]*histogramValue),
+	}
+}
+
+// Observe adds an observation to the histogram for the given label values.
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	h.Lock()
+	defer h.Unlock()
+
+	key := strings.Join(labelValues, "\xff")
+	v, ok := h.values[key]
+	if !ok {
+		v = &histogramValue{
+			labelValues: labelValues,
+			counts:      make([]uint64, len(h.buckets)),
+		}
+		h.values[key] = v
+	}
+
+	for i, bound := range h.buckets {
+		if value <= bound {
+			v.counts[i]++
+		}
+	}
+	v.count++
+	v.sum += value
+}
+
+// Write writes the histogram in the text exposition format.
+func (h *HistogramVec) Write(w io.Writer) error {
+	h.Lock()
+	defer h.Unlock()
+
+	if err := writeHeader(w, h.name, h.help, Histogram); err != nil {
+		return err
+	}
+
+	keys := make(map[string]bool)
+	for k := range h.values {
+		keys[k] = true
+	}
+
+	for _, key := range sortedKeys(keys) {
+		v := h.values[key]
+
+		for i, bound := range h.buckets {
+			if err := writeSample(w, h.name+"_bucket", h.labels, v.labelValues, "le", formatFloat(bound), float64(v.counts[i])); err != nil {
+				return err
+			}
+		}
+
+		if err := writeSample(w, h.name+"_bucket", h.labels, v.labelValues, "le", formatFloat(math.Inf(1)), float64(v.count)); err != nil {
+			return err
+		}
+
+		if err := writeSample(w, h.name+"_sum", h.labels, v.labelValues, "", "", v.sum); err != nil {
+			return err
+		}
+
+		if err := writeSample(w, h.name+"_count", h.labels, v.labelValues, "", "", float64(v.count)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeHeader(w io.Writer, name, help, metricType string) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, escapeHelp(help), name, metricType)
+	return err
+}
+
+func writeSample(w io.Writer, name string, labels, labelValues []string, extraLabel, extraValue string, value float64) error {
+	var pairs []string
+	for i, l := range labels {
+		var v string
+		if i < len(labelValues) {
+			v = labelValues[i]
+		}
+		pairs = append(pairs, fmt.Sprintf("%s=%q", l, escapeLabelValue(v)))
+	}
+
+	if extraLabel != "" {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", extraLabel, extraValue))
+	}
+
+	var err error
+	if len(pairs) == 0 {
+		_, err = fmt.Fprintf(w, "%s %s\n", name, formatFloat(value))
+	} else {
+		_, err = fmt.Fprintf(w, "%s{%s} %s\n", name, strings.Join(pairs, ","), formatFloat(value))
+	}
+
+	return err
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+}
+
+// escapeLabelValue drops the non printable characters of a label value, the
+// other ones are quoted by %q the way the exposition format expects.
+func escapeLabelValue(v string) string {
+	return strings.Map(func(r rune) rune {
+		if !strconv.IsPrint(r) && r != '\n' {
+			return -1
+		}
+		return r
+	}, v)
+}
+
+func escapeHelp(help string) string {
+	help = strings.Replace(help, `\`, `\\`, -1)
+	return strings.Replace(help, "\n", `\n`, -1)
+}
+
+func sortedKeys(values map[string]bool) []string {
+	var keys []string
+	for k := range values {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,66 @@
+// Copyright (c) 2020 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package metrics
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteMetric(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	err := WriteMetric(&buf, "kata_test", "Test\nmetric.", Gauge, []Sample{
+		{Labels: map[string]string{"b": "2", "a": `x"y`}, Value: 1.5},
+		{Value: 3},
+	})
+	assert.NoError(err)
+	assert.Equal(`# HELP kata_test Test\nmetric.
+# TYPE kata_test gauge
+kata_test{a="x\"y",b="2"} 1.5
+kata_test 3
+`, buf.String())
+}
+
+func TestCounterVec(t *testing.T) {
+	assert := assert.New(t)
+
+	c := NewCounterVec("kata_test_total", "Test counter.", "op")
+	c.Inc("b")
+	c.Inc("a")
+	c.Inc("b")
+
+	var buf bytes.Buffer
+	assert.NoError(c.Write(&buf))
+	assert.Equal(`# HELP kata_test_total Test counter.
+# TYPE kata_test_total counter
+kata_test_total{op="a"} 1
+kata_test_total{op="b"} 2
+`, buf.String())
+}
+
+func TestHistogramVec(t *testing.T) {
+	assert := assert.New(t)
+
+	h := NewHistogramVec("kata_test_seconds", "Test histogram.", []float64{0.1, 1}, "op")
+	h.Observe(0.05, "a")
+	h.Observe(0.5, "a")
+	h.Observe(2, "a")
+
+	var buf bytes.Buffer
+	assert.NoError(h.Write(&buf))
+	assert.Equal(`# HELP kata_test_seconds Test histogram.
+# TYPE kata_test_seconds histogram
+kata_test_seconds_bucket{op="a",le="0.1"} 1
+kata_test_seconds_bucket{op="a",le="1"} 2
+kata_test_seconds_bucket{op="a",le="+Inf"} 3
+kata_test_seconds_sum{op="a"} 2.55
+kata_test_seconds_count{op="a"} 3
+`, buf.String())
+}
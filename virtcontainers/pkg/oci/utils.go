@@ -97,6 +97,8 @@ type RuntimeConfig struct {
 
 	NetmonConfig vc.NetmonConfig
 
+	UserModeNetworkConfig vc.UserModeNetworkConfig
+
 	AgentType   vc.AgentType
 	AgentConfig interface{}
 
@@ -127,6 +129,9 @@ type RuntimeConfig struct {
 	//Determines if containers are allowed to join the pid namespace of the kata agent
 	EnableAgentPidNs bool
 
+	//Determines if the shim serves Prometheus metrics
+	EnableShimMetrics bool
+
 	//Experimental features enabled
 	Experimental []exp.Feature
 }
@@ -313,6 +318,10 @@ func networkConfig(ocispec specs.Spec, config RuntimeConfig) (vc.NetworkConfig,
 		Enable: config.NetmonConfig.Enable,
 	}
 
+	netConf.UserModeNetworkConfig = vc.UserModeNetworkConfig{
+		Path: config.UserModeNetworkConfig.Path,
+	}
+
 	return netConf, nil
 }
 
@@ -786,6 +795,15 @@ func addHypervisorVirtioFsOverrides(ocispec specs.Spec, sbConfig *vc.SandboxConf
 		sbConfig.HypervisorConfig.VirtioFSCacheSize = uint32(cacheSize)
 	}
 
+	if value, ok := ocispec.Annotations[vcAnnotations.Enable9pVolumes]; ok {
+		enable, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("Error parsing annotation for enable_9p_volumes: Please specify boolean value 'true|false'")
+		}
+
+		sbConfig.HypervisorConfig.Enable9pVolumes = enable
+	}
+
 	if value, ok := ocispec.Annotations[vcAnnotations.Msize9p]; ok {
 		msize9p, err := strconv.ParseUint(value, 10, 32)
 		if err != nil || msize9p == 0 {
@@ -847,9 +865,85 @@ func addRuntimeConfigOverrides(ocispec specs.Spec, sbConfig *vc.SandboxConfig, r
 		sbConfig.NetworkConfig.InterworkingModel = runtimeConfig.InterNetworkModel
 	}
 
+	if value, ok := ocispec.Annotations[vcAnnotations.PortForwards]; ok {
+		forwards, err := parsePortForwards(value)
+		if err != nil {
+			return fmt.Errorf("Error parsing annotation %s: %v", vcAnnotations.PortForwards, err)
+		}
+
+		sbConfig.NetworkConfig.UserModeNetworkConfig.PortForwards = forwards
+	}
+
+	if value, ok := ocispec.Annotations[vcAnnotations.IngressBandwidth]; ok {
+		rate, err := vc.ParseBandwidth(value)
+		if err != nil {
+			return fmt.Errorf("Error parsing annotation %s: %v", vcAnnotations.IngressBandwidth, err)
+		}
+
+		sbConfig.NetworkConfig.Bandwidth.Ingress = rate
+	}
+
+	if value, ok := ocispec.Annotations[vcAnnotations.EgressBandwidth]; ok {
+		rate, err := vc.ParseBandwidth(value)
+		if err != nil {
+			return fmt.Errorf("Error parsing annotation %s: %v", vcAnnotations.EgressBandwidth, err)
+		}
+
+		sbConfig.NetworkConfig.Bandwidth.Egress = rate
+	}
+
 	return nil
 }
 
+// parsePortForwards parses a comma separated list of port forwards, each one
+// being formatted as [host_addr:]host_port:guest_port[/proto].
+func parsePortForwards(value string) ([]vc.PortForward, error) {
+	var forwards []vc.PortForward
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		fwd := vc.PortForward{Proto: "tcp"}
+		if i := strings.LastIndex(entry, "/"); i >= 0 {
+			fwd.Proto = entry[i+1:]
+			entry = entry[:i]
+		}
+		if fwd.Proto != "tcp" && fwd.Proto != "udp" {
+			return nil, fmt.Errorf("Invalid protocol %q for port forward %q", fwd.Proto, entry)
+		}
+
+		fields := strings.Split(entry, ":")
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("Invalid port forward %q", entry)
+		}
+
+		n := len(fields)
+		hostPort, err := strconv.ParseUint(fields[n-2], 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid host port for port forward %q: %v", entry, err)
+		}
+		guestPort, err := strconv.ParseUint(fields[n-1], 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid guest port for port forward %q: %v", entry, err)
+		}
+
+		fwd.HostPort = uint16(hostPort)
+		fwd.GuestPort = uint16(guestPort)
+
+		// The host address may be an IPv6 address, enclosed in brackets.
+		if n > 2 {
+			fwd.HostAddr = strings.Trim(strings.Join(fields[:n-2], ":"), "[]")
+		}
+
+		forwards = append(forwards, fwd)
+	}
+
+	return forwards, nil
+}
+
 func addAgentConfigOverrides(ocispec specs.Spec, config *vc.SandboxConfig) error {
 	c, ok := config.AgentConfig.(vc.KataAgentConfig)
 	if !ok {
@@ -1017,6 +1111,13 @@ func ContainerConfig(ocispec specs.Spec, bundlePath, cid, console string, detach
 
 	containerConfig.Annotations[vcAnnotations.ContainerTypeKey] = string(cType)
 
+	for _, key := range []string{vcAnnotations.EphemeralContainer, vcAnnotations.EphemeralContainerTarget, vcAnnotations.ImageVolumes, vcAnnotations.VolumeSharing,
+		vcAnnotations.IngressBandwidth, vcAnnotations.EgressBandwidth} {
+		if value, ok := ocispec.Annotations[key]; ok {
+			containerConfig.Annotations[key] = value
+		}
+	}
+
 	return containerConfig, nil
 }
 
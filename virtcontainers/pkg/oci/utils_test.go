@@ -864,6 +864,7 @@ func TestAddHypervisorAnnotations(t *testing.T) {
 	ocispec.Annotations[vcAnnotations.VirtioFSDaemon] = "/bin/false"
 	ocispec.Annotations[vcAnnotations.VirtioFSCache] = "/home/cache"
 	ocispec.Annotations[vcAnnotations.Msize9p] = "512"
+	ocispec.Annotations[vcAnnotations.Enable9pVolumes] = "true"
 	ocispec.Annotations[vcAnnotations.MachineType] = "q35"
 	ocispec.Annotations[vcAnnotations.MachineAccelerators] = "nofw"
 	ocispec.Annotations[vcAnnotations.CPUFeatures] = "pmu=off"
@@ -898,6 +899,7 @@ func TestAddHypervisorAnnotations(t *testing.T) {
 	assert.Equal(config.HypervisorConfig.VirtioFSDaemon, "/bin/false")
 	assert.Equal(config.HypervisorConfig.VirtioFSCache, "/home/cache")
 	assert.Equal(config.HypervisorConfig.Msize9p, uint32(512))
+	assert.Equal(config.HypervisorConfig.Enable9pVolumes, true)
 	assert.Equal(config.HypervisorConfig.HypervisorMachineType, "q35")
 	assert.Equal(config.HypervisorConfig.MachineAccelerators, "nofw")
 	assert.Equal(config.HypervisorConfig.CPUFeatures, "pmu=off")
@@ -1014,12 +1016,49 @@ func TestAddRuntimeAnnotations(t *testing.T) {
 	ocispec.Annotations[vcAnnotations.SandboxCgroupOnly] = "true"
 	ocispec.Annotations[vcAnnotations.DisableNewNetNs] = "true"
 	ocispec.Annotations[vcAnnotations.InterNetworkModel] = "macvtap"
+	ocispec.Annotations[vcAnnotations.PortForwards] = "8080:80"
+	ocispec.Annotations[vcAnnotations.IngressBandwidth] = "10M"
+	ocispec.Annotations[vcAnnotations.EgressBandwidth] = "1Gi"
 
 	addAnnotations(ocispec, &config, runtimeConfig)
 	assert.Equal(config.DisableGuestSeccomp, true)
 	assert.Equal(config.SandboxCgroupOnly, true)
 	assert.Equal(config.NetworkConfig.DisableNewNetNs, true)
 	assert.Equal(config.NetworkConfig.InterworkingModel, vc.NetXConnectMacVtapModel)
+	assert.Equal(config.NetworkConfig.UserModeNetworkConfig.PortForwards, []vc.PortForward{
+		{Proto: "tcp", HostPort: 8080, GuestPort: 80},
+	})
+	assert.Equal(config.NetworkConfig.Bandwidth, vc.BandwidthLimits{Ingress: 10000000, Egress: 1 << 30})
+
+	ocispec.Annotations[vcAnnotations.IngressBandwidth] = "fast"
+	err := addAnnotations(ocispec, &config, runtimeConfig)
+	assert.Error(err)
+	delete(ocispec.Annotations, vcAnnotations.IngressBandwidth)
+
+	ocispec.Annotations[vcAnnotations.PortForwards] = "8080"
+	err = addAnnotations(ocispec, &config, runtimeConfig)
+	assert.Error(err)
+}
+
+func TestParsePortForwards(t *testing.T) {
+	assert := assert.New(t)
+
+	forwards, err := parsePortForwards("8080:80, 127.0.0.1:5353:53/udp,[::1]:2222:22/tcp")
+	assert.NoError(err)
+	assert.Equal([]vc.PortForward{
+		{Proto: "tcp", HostPort: 8080, GuestPort: 80},
+		{Proto: "udp", HostAddr: "127.0.0.1", HostPort: 5353, GuestPort: 53},
+		{Proto: "tcp", HostAddr: "::1", HostPort: 2222, GuestPort: 22},
+	}, forwards)
+
+	forwards, err = parsePortForwards("")
+	assert.NoError(err)
+	assert.Empty(forwards)
+
+	for _, value := range []string{"80", "foo:80", "8080:70000", "8080:80/sctp"} {
+		_, err = parsePortForwards(value)
+		assert.Error(err, value)
+	}
 }
 
 func TestIsCRIOContainerManager(t *testing.T) {
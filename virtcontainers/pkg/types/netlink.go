@@ -0,0 +1,54 @@
+// Copyright (c) 2020 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package types
+
+import (
+	"net"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// DefaultRouteIPv6 is the destination given to the IPv6 default routes,
+// which netlink reports without any destination, like the IPv4 ones.
+var DefaultRouteIPv6 = &net.IPNet{IP: net.IPv6zero, Mask: net.CIDRMask(0, 8*net.IPv6len)}
+
+// ListRoutes lists the IPv4 and IPv6 routes of "link", or of all the links
+// if it is nil. The IPv6 default routes are given an explicit destination,
+// so that they can be told apart from the IPv4 ones when they have no
+// gateway.
+func ListRoutes(handle *netlink.Handle, link netlink.Link) ([]netlink.Route, error) {
+	var routes []netlink.Route
+
+	for _, family := range []int{netlink.FAMILY_V4, netlink.FAMILY_V6} {
+		familyRoutes, err := handle.RouteList(link, family)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range familyRoutes {
+			if family == netlink.FAMILY_V6 && familyRoutes[i].Dst == nil {
+				familyRoutes[i].Dst = DefaultRouteIPv6
+			}
+		}
+
+		routes = append(routes, familyRoutes...)
+	}
+
+	return routes, nil
+}
+
+// IsGuestAddr checks if an address has to be set up in the guest. The IPv6
+// link-local addresses are generated by the guest kernel from the MAC
+// address of the interface, and the addresses which failed the duplicate
+// address detection are not usable.
+func IsGuestAddr(addr netlink.Addr) bool {
+	if addr.IP.To4() == nil && addr.IP.IsLinkLocalUnicast() {
+		return false
+	}
+
+	return addr.Flags&unix.IFA_F_DADFAILED == 0
+}
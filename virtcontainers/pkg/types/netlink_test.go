@@ -0,0 +1,37 @@
+// Copyright (c) 2020 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+func TestIsGuestAddr(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, d := range []struct {
+		addr  string
+		flags int
+		guest bool
+	}{
+		{"172.17.0.2/16", 0, true},
+		{"169.254.1.2/16", 0, true},
+		{"2001:db8::2/64", 0, true},
+		{"2001:db8::2/64", unix.IFA_F_NODAD, true},
+		{"2001:db8::2/64", unix.IFA_F_DADFAILED, false},
+		{"fe80::1/64", 0, false},
+	} {
+		addr, err := netlink.ParseAddr(d.addr)
+		assert.NoError(err)
+		addr.Flags = d.flags
+
+		assert.Equal(d.guest, IsGuestAddr(*addr), d.addr)
+	}
+}
@@ -45,3 +45,44 @@ type ARPNeighbor struct {
 	State       int
 	Flags       int
 }
+
+// NetmonOp is the operation of a network change reported by the network
+// monitor to the runtime.
+type NetmonOp string
+
+const (
+	// NetmonAddInterface adds an interface to the sandbox.
+	NetmonAddInterface NetmonOp = "add-iface"
+
+	// NetmonDelInterface removes an interface from the sandbox.
+	NetmonDelInterface NetmonOp = "del-iface"
+
+	// NetmonUpdateRoutes replaces the routes of the sandbox.
+	NetmonUpdateRoutes NetmonOp = "update-routes"
+
+	// NetmonUpdateInterface updates the IP addresses of a sandbox interface.
+	NetmonUpdateInterface NetmonOp = "update-iface"
+
+	// NetmonUpdateNeighbors adds or replaces static neighbors of the sandbox.
+	NetmonUpdateNeighbors NetmonOp = "update-neighbors"
+)
+
+// NetmonChange describes a network change reported by the network monitor.
+type NetmonChange struct {
+	Op        NetmonOp
+	Interface *Interface
+	Routes    []Route
+	Neighbors []ARPNeighbor
+}
+
+// NetmonRequest is a batch of network changes sent by the network monitor
+// through the runtime control socket.
+type NetmonRequest struct {
+	Changes []NetmonChange
+}
+
+// NetmonResponse acknowledges each change of a NetmonRequest, in order. An
+// empty error means the change has been applied.
+type NetmonResponse struct {
+	Errors []string
+}
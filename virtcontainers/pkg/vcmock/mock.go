@@ -146,6 +146,15 @@ func (m *VCMock) StartContainer(ctx context.Context, sandboxID, containerID stri
 	return nil, fmt.Errorf("%s: %s (%+v): sandboxID: %v, containerID: %v", mockErrorPrefix, getSelf(), m, sandboxID, containerID)
 }
 
+// RestartContainer implements the VC function of the same name.
+func (m *VCMock) RestartContainer(ctx context.Context, sandboxID, containerID string) (vc.VCContainer, error) {
+	if m.RestartContainerFunc != nil {
+		return m.RestartContainerFunc(ctx, sandboxID, containerID)
+	}
+
+	return nil, fmt.Errorf("%s: %s (%+v): sandboxID: %v, containerID: %v", mockErrorPrefix, getSelf(), m, sandboxID, containerID)
+}
+
 // StopContainer implements the VC function of the same name.
 func (m *VCMock) StopContainer(ctx context.Context, sandboxID, containerID string) (vc.VCContainer, error) {
 	if m.StopContainerFunc != nil {
@@ -218,6 +227,24 @@ func (m *VCMock) UpdateContainer(ctx context.Context, sandboxID, containerID str
 	return fmt.Errorf("%s: %s (%+v): sandboxID: %v, containerID: %v", mockErrorPrefix, getSelf(), m, sandboxID, containerID)
 }
 
+// AddMount implements the VC function of the same name.
+func (m *VCMock) AddMount(ctx context.Context, sandboxID, containerID string, mount vc.Mount) error {
+	if m.AddMountFunc != nil {
+		return m.AddMountFunc(ctx, sandboxID, containerID, mount)
+	}
+
+	return fmt.Errorf("%s: %s (%+v): sandboxID: %v, containerID: %v", mockErrorPrefix, getSelf(), m, sandboxID, containerID)
+}
+
+// RemoveMount implements the VC function of the same name.
+func (m *VCMock) RemoveMount(ctx context.Context, sandboxID, containerID, destination string) error {
+	if m.RemoveMountFunc != nil {
+		return m.RemoveMountFunc(ctx, sandboxID, containerID, destination)
+	}
+
+	return fmt.Errorf("%s: %s (%+v): sandboxID: %v, containerID: %v", mockErrorPrefix, getSelf(), m, sandboxID, containerID)
+}
+
 // PauseContainer implements the VC function of the same name.
 func (m *VCMock) PauseContainer(ctx context.Context, sandboxID, containerID string) error {
 	if m.PauseContainerFunc != nil {
@@ -263,6 +290,15 @@ func (m *VCMock) RemoveInterface(ctx context.Context, sandboxID string, inf *vcT
 	return nil, fmt.Errorf("%s: %s (%+v): sandboxID: %v", mockErrorPrefix, getSelf(), m, sandboxID)
 }
 
+// UpdateInterface implements the VC function of the same name.
+func (m *VCMock) UpdateInterface(ctx context.Context, sandboxID string, inf *vcTypes.Interface) (*vcTypes.Interface, error) {
+	if m.UpdateInterfaceFunc != nil {
+		return m.UpdateInterfaceFunc(ctx, sandboxID, inf)
+	}
+
+	return nil, fmt.Errorf("%s: %s (%+v): sandboxID: %v", mockErrorPrefix, getSelf(), m, sandboxID)
+}
+
 // ListInterfaces implements the VC function of the same name.
 func (m *VCMock) ListInterfaces(ctx context.Context, sandboxID string) ([]*vcTypes.Interface, error) {
 	if m.ListInterfacesFunc != nil {
@@ -290,9 +326,27 @@ func (m *VCMock) ListRoutes(ctx context.Context, sandboxID string) ([]*vcTypes.R
 	return nil, fmt.Errorf("%s: %s (%+v): sandboxID: %v", mockErrorPrefix, getSelf(), m, sandboxID)
 }
 
+// UpdateNeighbors implements the VC function of the same name.
+func (m *VCMock) UpdateNeighbors(ctx context.Context, sandboxID string, neighs []*vcTypes.ARPNeighbor) error {
+	if m.UpdateNeighborsFunc != nil {
+		return m.UpdateNeighborsFunc(ctx, sandboxID, neighs)
+	}
+
+	return fmt.Errorf("%s: %s (%+v): sandboxID: %v", mockErrorPrefix, getSelf(), m, sandboxID)
+}
+
 func (m *VCMock) CleanupContainer(ctx context.Context, sandboxID, containerID string, force bool) error {
 	if m.CleanupContainerFunc != nil {
 		return m.CleanupContainerFunc(ctx, sandboxID, containerID, true)
 	}
 	return fmt.Errorf("%s: %s (%+v): sandboxID: %v", mockErrorPrefix, getSelf(), m, sandboxID)
 }
+
+// CheckSandboxes implements the VC function of the same name.
+func (m *VCMock) CheckSandboxes(ctx context.Context, repair bool, binaries []string) ([]vc.Inconsistency, error) {
+	if m.CheckSandboxesFunc != nil {
+		return m.CheckSandboxesFunc(ctx, repair, binaries)
+	}
+
+	return nil, fmt.Errorf("%s: %s (%+v)", mockErrorPrefix, getSelf(), m)
+}
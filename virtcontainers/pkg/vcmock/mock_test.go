@@ -452,6 +452,33 @@ func TestVCMockStartContainer(t *testing.T) {
 	assert.True(IsMockError(err))
 }
 
+func TestVCMockRestartContainer(t *testing.T) {
+	assert := assert.New(t)
+
+	m := &VCMock{}
+	assert.Nil(m.RestartContainerFunc)
+
+	ctx := context.Background()
+	_, err := m.RestartContainer(ctx, testSandboxID, testContainerID)
+	assert.Error(err)
+	assert.True(IsMockError(err))
+
+	m.RestartContainerFunc = func(ctx context.Context, sandboxID, containerID string) (vc.VCContainer, error) {
+		return &Container{}, nil
+	}
+
+	container, err := m.RestartContainer(ctx, testSandboxID, testContainerID)
+	assert.NoError(err)
+	assert.Equal(container, &Container{})
+
+	// reset
+	m.RestartContainerFunc = nil
+
+	_, err = m.RestartContainer(ctx, testSandboxID, testContainerID)
+	assert.Error(err)
+	assert.True(IsMockError(err))
+}
+
 func TestVCMockStatusContainer(t *testing.T) {
 	assert := assert.New(t)
 
@@ -651,6 +678,60 @@ func TestVCMockResumeContainer(t *testing.T) {
 	assert.True(IsMockError(err))
 }
 
+func TestVCMockAddMount(t *testing.T) {
+	assert := assert.New(t)
+
+	m := &VCMock{}
+	config := &vc.SandboxConfig{}
+	assert.Nil(m.AddMountFunc)
+
+	ctx := context.Background()
+	err := m.AddMount(ctx, config.ID, config.ID, vc.Mount{})
+	assert.Error(err)
+	assert.True(IsMockError(err))
+
+	m.AddMountFunc = func(ctx context.Context, sid, cid string, mount vc.Mount) error {
+		return nil
+	}
+
+	err = m.AddMount(ctx, config.ID, config.ID, vc.Mount{})
+	assert.NoError(err)
+
+	// reset
+	m.AddMountFunc = nil
+
+	err = m.AddMount(ctx, config.ID, config.ID, vc.Mount{})
+	assert.Error(err)
+	assert.True(IsMockError(err))
+}
+
+func TestVCMockRemoveMount(t *testing.T) {
+	assert := assert.New(t)
+
+	m := &VCMock{}
+	config := &vc.SandboxConfig{}
+	assert.Nil(m.RemoveMountFunc)
+
+	ctx := context.Background()
+	err := m.RemoveMount(ctx, config.ID, config.ID, "/volume")
+	assert.Error(err)
+	assert.True(IsMockError(err))
+
+	m.RemoveMountFunc = func(ctx context.Context, sid, cid, destination string) error {
+		return nil
+	}
+
+	err = m.RemoveMount(ctx, config.ID, config.ID, "/volume")
+	assert.NoError(err)
+
+	// reset
+	m.RemoveMountFunc = nil
+
+	err = m.RemoveMount(ctx, config.ID, config.ID, "/volume")
+	assert.Error(err)
+	assert.True(IsMockError(err))
+}
+
 func TestVCMockSetVMFactory(t *testing.T) {
 	assert := assert.New(t)
 
@@ -817,3 +898,29 @@ func TestVCMockListRoutes(t *testing.T) {
 	assert.Error(err)
 	assert.True(IsMockError(err))
 }
+
+func TestVCMockCheckSandboxes(t *testing.T) {
+	assert := assert.New(t)
+
+	m := &VCMock{}
+	assert.Nil(m.CheckSandboxesFunc)
+
+	ctx := context.Background()
+	_, err := m.CheckSandboxes(ctx, false, nil)
+	assert.Error(err)
+	assert.True(IsMockError(err))
+
+	m.CheckSandboxesFunc = func(ctx context.Context, repair bool, binaries []string) ([]vc.Inconsistency, error) {
+		return []vc.Inconsistency{}, nil
+	}
+
+	_, err = m.CheckSandboxes(ctx, false, nil)
+	assert.NoError(err)
+
+	// reset
+	m.CheckSandboxesFunc = nil
+
+	_, err = m.CheckSandboxes(ctx, false, nil)
+	assert.Error(err)
+	assert.True(IsMockError(err))
+}
@@ -7,6 +7,7 @@ package vcmock
 
 import (
 	"io"
+	"sync"
 	"syscall"
 
 	vc "github.com/kata-containers/runtime/virtcontainers"
@@ -108,6 +109,11 @@ func (s *Sandbox) StartContainer(contID string) (vc.VCContainer, error) {
 	return &Container{}, nil
 }
 
+// RestartContainer implements the VCSandbox function of the same name.
+func (s *Sandbox) RestartContainer(contID string) (vc.VCContainer, error) {
+	return &Container{}, nil
+}
+
 // StopContainer implements the VCSandbox function of the same name.
 func (s *Sandbox) StopContainer(contID string, force bool) (vc.VCContainer, error) {
 	return &Container{}, nil
@@ -120,6 +126,12 @@ func (s *Sandbox) KillContainer(contID string, signal syscall.Signal, all bool)
 
 // StatusContainer implements the VCSandbox function of the same name.
 func (s *Sandbox) StatusContainer(contID string) (vc.ContainerStatus, error) {
+	for _, c := range s.MockContainers {
+		if c.MockID == contID {
+			return vc.ContainerStatus{ID: contID, State: c.MockState}, nil
+		}
+	}
+
 	return vc.ContainerStatus{}, nil
 }
 
@@ -140,7 +152,7 @@ func (s *Sandbox) ResumeContainer(contID string) error {
 
 // Status implements the VCSandbox function of the same name.
 func (s *Sandbox) Status() vc.SandboxStatus {
-	return vc.SandboxStatus{}
+	return vc.SandboxStatus{ID: s.MockID, State: s.MockState}
 }
 
 // EnterContainer implements the VCSandbox function of the same name.
@@ -158,6 +170,16 @@ func (s *Sandbox) UpdateContainer(containerID string, resources specs.LinuxResou
 	return nil
 }
 
+// AddMount implements the VCSandbox function of the same name.
+func (s *Sandbox) AddMount(containerID string, mount vc.Mount) error {
+	return nil
+}
+
+// RemoveMount implements the VCSandbox function of the same name.
+func (s *Sandbox) RemoveMount(containerID, destination string) error {
+	return nil
+}
+
 // ProcessListContainer implements the VCSandbox function of the same name.
 func (s *Sandbox) ProcessListContainer(containerID string, options vc.ProcessListOptions) (vc.ProcessList, error) {
 	return nil, nil
@@ -198,6 +220,11 @@ func (s *Sandbox) RemoveInterface(inf *vcTypes.Interface) (*vcTypes.Interface, e
 	return nil, nil
 }
 
+// UpdateInterface implements the VCSandbox function of the same name.
+func (s *Sandbox) UpdateInterface(inf *vcTypes.Interface) (*vcTypes.Interface, error) {
+	return nil, nil
+}
+
 // ListInterfaces implements the VCSandbox function of the same name.
 func (s *Sandbox) ListInterfaces() ([]*vcTypes.Interface, error) {
 	return nil, nil
@@ -213,6 +240,21 @@ func (s *Sandbox) ListRoutes() ([]*vcTypes.Route, error) {
 	return nil, nil
 }
 
+// UpdateNeighbors implements the VCSandbox function of the same name.
+func (s *Sandbox) UpdateNeighbors(neighs []*vcTypes.ARPNeighbor) error {
+	return nil
+}
+
+// ServeNetworkChanges implements the VCSandbox function of the same name.
+func (s *Sandbox) ServeNetworkChanges(lock sync.Locker) error {
+	return nil
+}
+
 func (s *Sandbox) GetOOMEvent() (string, error) {
 	return "", nil
 }
+
+// GetHypervisorPids implements the VCSandbox function of the same name.
+func (s *Sandbox) GetHypervisorPids() ([]int, error) {
+	return []int{}, nil
+}
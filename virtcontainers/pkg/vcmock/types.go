@@ -25,6 +25,7 @@ type Sandbox struct {
 	MockAnnotations map[string]string
 	MockContainers  []*Container
 	MockNetNs       string
+	MockState       types.SandboxState
 }
 
 // Container is a fake Container type used for testing
@@ -36,6 +37,7 @@ type Container struct {
 	MockPid         int
 	MockSandbox     *Sandbox
 	MockAnnotations map[string]string
+	MockState       types.ContainerState
 }
 
 // VCMock is a type that provides an implementation of the VC interface.
@@ -60,19 +62,25 @@ type VCMock struct {
 	EnterContainerFunc       func(ctx context.Context, sandboxID, containerID string, cmd types.Cmd) (vc.VCSandbox, vc.VCContainer, *vc.Process, error)
 	KillContainerFunc        func(ctx context.Context, sandboxID, containerID string, signal syscall.Signal, all bool) error
 	StartContainerFunc       func(ctx context.Context, sandboxID, containerID string) (vc.VCContainer, error)
+	RestartContainerFunc     func(ctx context.Context, sandboxID, containerID string) (vc.VCContainer, error)
 	StatusContainerFunc      func(ctx context.Context, sandboxID, containerID string) (vc.ContainerStatus, error)
 	StopContainerFunc        func(ctx context.Context, sandboxID, containerID string) (vc.VCContainer, error)
 	ProcessListContainerFunc func(ctx context.Context, sandboxID, containerID string, options vc.ProcessListOptions) (vc.ProcessList, error)
 	UpdateContainerFunc      func(ctx context.Context, sandboxID, containerID string, resources specs.LinuxResources) error
 	PauseContainerFunc       func(ctx context.Context, sandboxID, containerID string) error
 	ResumeContainerFunc      func(ctx context.Context, sandboxID, containerID string) error
+	AddMountFunc             func(ctx context.Context, sandboxID, containerID string, mount vc.Mount) error
+	RemoveMountFunc          func(ctx context.Context, sandboxID, containerID, destination string) error
 
 	AddDeviceFunc func(ctx context.Context, sandboxID string, info config.DeviceInfo) (api.Device, error)
 
 	AddInterfaceFunc     func(ctx context.Context, sandboxID string, inf *vcTypes.Interface) (*vcTypes.Interface, error)
 	RemoveInterfaceFunc  func(ctx context.Context, sandboxID string, inf *vcTypes.Interface) (*vcTypes.Interface, error)
+	UpdateInterfaceFunc  func(ctx context.Context, sandboxID string, inf *vcTypes.Interface) (*vcTypes.Interface, error)
 	ListInterfacesFunc   func(ctx context.Context, sandboxID string) ([]*vcTypes.Interface, error)
 	UpdateRoutesFunc     func(ctx context.Context, sandboxID string, routes []*vcTypes.Route) ([]*vcTypes.Route, error)
 	ListRoutesFunc       func(ctx context.Context, sandboxID string) ([]*vcTypes.Route, error)
+	UpdateNeighborsFunc  func(ctx context.Context, sandboxID string, neighs []*vcTypes.ARPNeighbor) error
 	CleanupContainerFunc func(ctx context.Context, sandboxID, containerID string, force bool) error
+	CheckSandboxesFunc   func(ctx context.Context, repair bool, binaries []string) ([]vc.Inconsistency, error)
 }
@@ -17,6 +17,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -73,6 +74,12 @@ type QemuState struct {
 	HotplugVFIOOnRootBus bool
 	VirtiofsdPid         int
 	PCIeRootPort         int
+	// StaleNvdimms lists the backing files of the nvdimm devices left
+	// plugged once removed, as QEMU can not unplug them.
+	StaleNvdimms []string
+	// VolumeVirtiofsdPids are the pids of the virtio-fs daemons of the
+	// volumes shared through a dedicated device, indexed by device ID.
+	VolumeVirtiofsdPids map[string]int
 }
 
 // qemu is an Hypervisor interface implementation for the Linux qemu hypervisor.
@@ -185,7 +192,24 @@ func (q *qemu) capabilities() types.Capabilities {
 	span, _ := q.trace("capabilities")
 	defer span.Finish()
 
-	return q.arch.capabilities()
+	caps := q.arch.capabilities()
+
+	// The virtio-fs daemons need the guest memory to be shared, which it
+	// only is when the sandbox shares files through virtio-fs.
+	if q.config.SharedFS == config.VirtioFS {
+		caps.SetFsSharingHotplugSupport()
+	}
+
+	// nvdimm devices can only be hotplugged when the machine supports them.
+	if machine, err := q.arch.machine(); err == nil {
+		for _, option := range strings.Split(machine.Options, ",") {
+			if option == qemuNvdimmOption {
+				caps.SetPmemHotplugSupport()
+			}
+		}
+	}
+
+	return caps
 }
 
 func (q *qemu) hypervisorConfig() HypervisorConfig {
@@ -632,15 +656,18 @@ func (q *qemu) vhostFSSocketPath(id string) (string, error) {
 	return utils.BuildSocketPath(q.store.RunVMStoragePath(), id, vhostFSSocket)
 }
 
-func (q *qemu) virtiofsdArgs(fd uintptr) []string {
+func (q *qemu) vhostFSVolumeSocketPath(devID string) (string, error) {
+	return utils.BuildSocketPath(q.store.RunVMStoragePath(), q.id, fmt.Sprintf("vhost-fs-%s.sock", devID))
+}
+
+func (q *qemu) virtiofsdArgs(fd uintptr, sourcePath, cache string) []string {
 	// The daemon will terminate when the vhost-user socket
 	// connection with QEMU closes.  Therefore we do not keep track
 	// of this child process after returning from this function.
-	sourcePath := filepath.Join(getSharePath(q.id))
 	args := []string{
 		fmt.Sprintf("--fd=%v", fd),
 		"-o", "source=" + sourcePath,
-		"-o", "cache=" + q.config.VirtioFSCache,
+		"-o", "cache=" + cache,
 		"--syslog", "-o", "no_posix_lock"}
 	if q.config.Debug {
 		args = append(args, "-d")
@@ -654,21 +681,62 @@ func (q *qemu) virtiofsdArgs(fd uintptr) []string {
 	return args
 }
 
-func (q *qemu) setupVirtiofsd() (err error) {
-	var listener *net.UnixListener
-	var fd *os.File
+func (q *qemu) setupVirtiofsd() error {
+	sockPath, err := q.vhostFSSocketPath(q.id)
+	if err != nil {
+		return err
+	}
+
+	// Stop the sandbox if virtiofsd quits, unless the sandbox monitor
+	// restarts it.
+	var onQuit func()
+	if !q.config.VirtioFSDaemonRestart {
+		onQuit = func() { q.stopSandbox() }
+	}
+
+	pid, err := q.startVirtiofsd(sockPath, getSharePath(q.id), q.config.VirtioFSCache, onQuit)
+	if err != nil {
+		return err
+	}
+	q.state.VirtiofsdPid = pid
+
+	return nil
+}
+
+func (q *qemu) getVirtiofsdPid() int {
+	return q.state.VirtiofsdPid
+}
+
+func (q *qemu) restartVirtiofsd() error {
+	if q.config.SharedFS != config.VirtioFS || q.state.VirtiofsdPid == 0 {
+		return errors.New("no virtio-fs daemon to restart")
+	}
 
 	sockPath, err := q.vhostFSSocketPath(q.id)
 	if err != nil {
 		return err
 	}
 
+	// The socket of the previous daemon is left behind.
+	if err := os.Remove(sockPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return q.setupVirtiofsd()
+}
+
+// startVirtiofsd starts a virtio-fs daemon sharing sourcePath on sockPath
+// and returns its pid. onQuit is called when the daemon quits, if not nil.
+func (q *qemu) startVirtiofsd(sockPath, sourcePath, cache string, onQuit func()) (pid int, err error) {
+	var listener *net.UnixListener
+	var fd *os.File
+
 	listener, err = net.ListenUnix("unix", &net.UnixAddr{
 		Name: sockPath,
 		Net:  "unix",
 	})
 	if err != nil {
-		return err
+		return 0, err
 	}
 	listener.SetUnlinkOnClose(false)
 
@@ -676,37 +744,38 @@ func (q *qemu) setupVirtiofsd() (err error) {
 	listener.Close() // no longer needed since fd is a dup
 	listener = nil
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer fd.Close()
 
 	const sockFd = 3 // Cmd.ExtraFiles[] fds are numbered starting from 3
-	cmd := exec.Command(q.config.VirtioFSDaemon, q.virtiofsdArgs(sockFd)...)
+	cmd := exec.Command(q.config.VirtioFSDaemon, q.virtiofsdArgs(sockFd, sourcePath, cache)...)
 	cmd.ExtraFiles = append(cmd.ExtraFiles, fd)
 	stderr, err := cmd.StderrPipe()
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	err = cmd.Start()
 	if err != nil {
-		return fmt.Errorf("virtiofs daemon %v returned with error: %v", q.config.VirtioFSDaemon, err)
+		return 0, fmt.Errorf("virtiofs daemon %v returned with error: %v", q.config.VirtioFSDaemon, err)
 	}
-	q.state.VirtiofsdPid = cmd.Process.Pid
 	fd.Close()
 
-	// Monitor virtiofsd's stderr and stop sandbox if virtiofsd quits
+	// Monitor virtiofsd's stderr
 	go func() {
 		scanner := bufio.NewScanner(stderr)
 		for scanner.Scan() {
 			q.Logger().WithField("source", "virtiofsd").Info(scanner.Text())
 		}
-		q.Logger().Info("virtiofsd quits")
+		q.Logger().WithField("source-path", sourcePath).Info("virtiofsd quits")
 		// Wait to release resources of virtiofsd process
 		cmd.Process.Wait()
-		q.stopSandbox()
+		if onQuit != nil {
+			onQuit()
+		}
 	}()
-	return err
+	return cmd.Process.Pid, nil
 }
 
 func (q *qemu) getMemArgs() (bool, string, string, error) {
@@ -1084,6 +1153,11 @@ func (q *qemu) qmpShutdown() {
 func (q *qemu) hotplugAddBlockDevice(drive *config.BlockDrive, op operation, devID string) (err error) {
 	// drive can be a pmem device, in which case it's used as backing file for a nvdimm device
 	if q.config.BlockDeviceDriver == config.Nvdimm || drive.Pmem {
+		// The guest still maps the file of a removed nvdimm device.
+		if q.isStaleNvdimm(drive.File) {
+			return fmt.Errorf("nvdimm device backed by %v is still plugged, it can not be plugged again", drive.File)
+		}
+
 		var blocksize int64
 		file, err := os.Open(drive.File)
 		if err != nil {
@@ -1239,6 +1313,96 @@ func (q *qemu) hotplugAddVhostUserBlkDevice(vAttr *config.VhostUserDeviceAttrs,
 	return nil
 }
 
+// hotplugAddVhostUserFSDevice shares a host directory with the guest through
+// a vhost-user-fs device and a virtio-fs daemon dedicated to it.
+func (q *qemu) hotplugAddVhostUserFSDevice(vAttr *config.VhostUserDeviceAttrs, devID string) (err error) {
+	// The daemon could not access the guest memory.
+	if q.config.SharedFS != config.VirtioFS {
+		return fmt.Errorf("qemu can only hotplug virtio-fs devices when the sandbox shares files through virtio-fs")
+	}
+
+	if vAttr.SocketPath, err = q.vhostFSVolumeSocketPath(vAttr.DevID); err != nil {
+		return err
+	}
+
+	cache := vAttr.Cache
+	if cache == "" {
+		cache = q.config.VirtioFSCache
+	}
+
+	pid, err := q.startVirtiofsd(vAttr.SocketPath, vAttr.SharedDir, cache, nil)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			q.stopVolumeVirtiofsd(vAttr.DevID, pid)
+		}
+	}()
+
+	err = q.qmpMonitorCh.qmp.ExecuteCharDevUnixSocketAdd(q.qmpMonitorCh.ctx, vAttr.DevID, vAttr.SocketPath, false, false)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			q.qmpMonitorCh.qmp.ExecuteChardevDel(q.qmpMonitorCh.ctx, vAttr.DevID)
+		}
+	}()
+
+	addr, bridge, err := q.arch.addDeviceToBridge(vAttr.DevID, types.PCI)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			q.arch.removeDeviceFromBridge(vAttr.DevID)
+		}
+	}()
+
+	bridgeSlot, err := vcTypes.PciSlotFromInt(bridge.Addr)
+	if err != nil {
+		return err
+	}
+	devSlot, err := vcTypes.PciSlotFromString(addr)
+	if err != nil {
+		return err
+	}
+	vAttr.PCIPath, err = vcTypes.PciPathFromSlots(bridgeSlot, devSlot)
+	if err != nil {
+		return err
+	}
+
+	if err = q.qmpMonitorCh.qmp.ExecutePCIVhostUserFSDevAdd(q.qmpMonitorCh.ctx, devID, vAttr.DevID, vAttr.Tag, addr, bridge.ID, vAttr.CacheSize); err != nil {
+		return err
+	}
+
+	if q.state.VolumeVirtiofsdPids == nil {
+		q.state.VolumeVirtiofsdPids = make(map[string]int)
+	}
+	q.state.VolumeVirtiofsdPids[vAttr.DevID] = pid
+
+	return nil
+}
+
+// stopVolumeVirtiofsd stops the virtio-fs daemon of a volume shared through
+// the dedicated device devID, which usually quits on its own once QEMU
+// closes its socket.
+func (q *qemu) stopVolumeVirtiofsd(devID string, pid int) {
+	delete(q.state.VolumeVirtiofsdPids, devID)
+
+	if err := syscall.Kill(pid, syscall.SIGKILL); err != nil && err != syscall.ESRCH {
+		q.Logger().WithError(err).WithField("device", devID).Warn("Could not stop virtiofsd")
+	}
+
+	if sockPath, err := q.vhostFSVolumeSocketPath(devID); err == nil {
+		os.Remove(sockPath)
+	}
+}
+
 func (q *qemu) hotplugBlockDevice(drive *config.BlockDrive, op operation) error {
 	err := q.qmpSetup()
 	if err != nil {
@@ -1249,6 +1413,11 @@ func (q *qemu) hotplugBlockDevice(drive *config.BlockDrive, op operation) error
 
 	if op == addDevice {
 		err = q.hotplugAddBlockDevice(drive, op, devID)
+	} else if q.config.BlockDeviceDriver == config.Nvdimm || drive.Pmem {
+		// QEMU can not unplug nvdimm devices, they go away with the VM.
+		// Their backing file can not be plugged again until then.
+		q.Logger().WithField("drive", drive.ID).Warn("Leaving nvdimm device plugged")
+		q.state.StaleNvdimms = append(q.state.StaleNvdimms, drive.File)
 	} else {
 		if q.config.BlockDeviceDriver == config.VirtioBlock {
 			if err := q.arch.removeDeviceFromBridge(drive.ID); err != nil {
@@ -1268,6 +1437,35 @@ func (q *qemu) hotplugBlockDevice(drive *config.BlockDrive, op operation) error
 	return err
 }
 
+func (q *qemu) isStaleNvdimm(file string) bool {
+	for _, f := range q.state.StaleNvdimms {
+		if f == file {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (q *qemu) throttleBlockDevice(drive *config.BlockDrive, throttle config.BlockDriveThrottle) error {
+	span, _ := q.trace("throttleBlockDevice")
+	defer span.Finish()
+
+	// nvdimm devices are memory for QEMU, they have no block layer.
+	if q.config.BlockDeviceDriver == config.Nvdimm || drive.Pmem {
+		return fmt.Errorf("qemu can not limit the I/O of the nvdimm device %s", drive.ID)
+	}
+
+	if err := q.qmpSetup(); err != nil {
+		return err
+	}
+
+	devID := "virtio-" + drive.ID
+
+	return q.qmpMonitorCh.qmp.ExecuteBlockSetIOThrottle(q.qmpMonitorCh.ctx, devID,
+		throttle.ReadBps, throttle.WriteBps, throttle.ReadIOPS, throttle.WriteIOPS)
+}
+
 func (q *qemu) hotplugVhostUserDevice(vAttr *config.VhostUserDeviceAttrs, op operation) error {
 	err := q.qmpSetup()
 	if err != nil {
@@ -1280,6 +1478,8 @@ func (q *qemu) hotplugVhostUserDevice(vAttr *config.VhostUserDeviceAttrs, op ope
 		switch vAttr.Type {
 		case config.VhostUserBlk:
 			return q.hotplugAddVhostUserBlkDevice(vAttr, op, devID)
+		case config.VhostUserFS:
+			return q.hotplugAddVhostUserFSDevice(vAttr, devID)
 		default:
 			return fmt.Errorf("Incorrect vhost-user device type found")
 		}
@@ -1295,6 +1495,10 @@ func (q *qemu) hotplugVhostUserDevice(vAttr *config.VhostUserDeviceAttrs, op ope
 		if err := q.qmpMonitorCh.qmp.ExecuteChardevDel(q.qmpMonitorCh.ctx, vAttr.DevID); err != nil {
 			return err
 		}
+
+		if pid, ok := q.state.VolumeVirtiofsdPids[vAttr.DevID]; ok {
+			q.stopVolumeVirtiofsd(vAttr.DevID, pid)
+		}
 	}
 
 	return nil
@@ -1759,7 +1963,12 @@ func (q *qemu) addDevice(devInfo interface{}, devType deviceType) error {
 
 	switch v := devInfo.(type) {
 	case types.Volume:
-		if q.config.SharedFS == config.VirtioFS {
+		sharedFS := v.SharedFS
+		if sharedFS == "" {
+			sharedFS = q.config.SharedFS
+		}
+
+		if sharedFS == config.VirtioFS {
 			q.Logger().WithField("volume-type", "virtio-fs").Info("adding volume")
 
 			var randBytes []byte
@@ -1781,6 +1990,9 @@ func (q *qemu) addDevice(devInfo interface{}, devType deviceType) error {
 				CacheSize: q.config.VirtioFSCacheSize,
 				Cache:     q.config.VirtioFSCache,
 			}
+			if q.config.VirtioFSDaemonRestart {
+				vhostDev.Reconnect = 1
+			}
 			vhostDev.SocketPath = sockPath
 			vhostDev.DevID = id
 
@@ -2178,6 +2390,15 @@ func (q *qemu) getPids() []int {
 		pids = append(pids, q.state.VirtiofsdPid)
 	}
 
+	var devIDs []string
+	for devID := range q.state.VolumeVirtiofsdPids {
+		devIDs = append(devIDs, devID)
+	}
+	sort.Strings(devIDs)
+	for _, devID := range devIDs {
+		pids = append(pids, q.state.VolumeVirtiofsdPids[devID])
+	}
+
 	return pids
 }
 
@@ -2249,6 +2470,8 @@ func (q *qemu) save() (s persistapi.HypervisorState) {
 	s.HotpluggedMemory = q.state.HotpluggedMemory
 	s.HotplugVFIOOnRootBus = q.state.HotplugVFIOOnRootBus
 	s.PCIeRootPort = q.state.PCIeRootPort
+	s.StaleNvdimms = q.state.StaleNvdimms
+	s.VolumeVirtiofsdPids = q.state.VolumeVirtiofsdPids
 
 	for _, bridge := range q.arch.getBridges() {
 		s.Bridges = append(s.Bridges, persistapi.Bridge{
@@ -2273,6 +2496,8 @@ func (q *qemu) load(s persistapi.HypervisorState) {
 	q.state.HotplugVFIOOnRootBus = s.HotplugVFIOOnRootBus
 	q.state.VirtiofsdPid = s.VirtiofsdPid
 	q.state.PCIeRootPort = s.PCIeRootPort
+	q.state.StaleNvdimms = s.StaleNvdimms
+	q.state.VolumeVirtiofsdPids = s.VolumeVirtiofsdPids
 
 	for _, bridge := range s.Bridges {
 		q.state.Bridges = append(q.state.Bridges, types.NewBridge(types.Type(bridge.Type), bridge.ID, bridge.DeviceAddr, bridge.Addr))
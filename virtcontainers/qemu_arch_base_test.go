@@ -443,6 +443,34 @@ func TestQemuArchBaseAppendVhostUserDevice(t *testing.T) {
 	testQemuArchBaseAppend(t, vhostUserDevice, expectedOut)
 }
 
+func TestQemuArchBaseAppendVhostUserFSDevice(t *testing.T) {
+	socketPath := "nonexistentpath.sock"
+	id := "deadbeef"
+
+	expectedOut := []govmmQemu.Device{
+		govmmQemu.VhostUserDevice{
+			SocketPath:    socketPath,
+			CharDevID:     fmt.Sprintf("char-%s", id),
+			TypeDevID:     fmt.Sprintf("fs-%s", id),
+			Tag:           "kataShared",
+			CacheSize:     1024,
+			Reconnect:     1,
+			VhostUserType: govmmQemu.VhostUserFS,
+		},
+	}
+
+	vhostUserDevice := config.VhostUserDeviceAttrs{
+		Type:      config.VhostUserFS,
+		Tag:       "kataShared",
+		CacheSize: 1024,
+		Reconnect: 1,
+	}
+	vhostUserDevice.DevID = id
+	vhostUserDevice.SocketPath = socketPath
+
+	testQemuArchBaseAppend(t, vhostUserDevice, expectedOut)
+}
+
 func TestQemuArchBaseAppendVFIODevice(t *testing.T) {
 	bdf := "02:10.1"
 
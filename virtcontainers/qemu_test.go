@@ -6,17 +6,23 @@
 package virtcontainers
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"syscall"
 	"testing"
 
 	govmmQemu "github.com/kata-containers/govmm/qemu"
 	"github.com/kata-containers/runtime/virtcontainers/device/config"
 	"github.com/kata-containers/runtime/virtcontainers/persist"
+	persistapi "github.com/kata-containers/runtime/virtcontainers/persist/api"
 	"github.com/kata-containers/runtime/virtcontainers/types"
 	"github.com/kata-containers/runtime/virtcontainers/utils"
 	"github.com/pkg/errors"
@@ -241,6 +247,30 @@ func TestQemuAddDeviceFsDev(t *testing.T) {
 	testQemuAddDevice(t, volume, fsDev, expectedOut)
 }
 
+func TestQemuAddDeviceFsDev9pVolumes(t *testing.T) {
+	assert := assert.New(t)
+	q := &qemu{
+		ctx:    context.Background(),
+		arch:   &qemuArchBase{},
+		config: HypervisorConfig{SharedFS: config.VirtioFS},
+	}
+
+	// The 9p share is added next to the virtio-fs one.
+	err := q.addDevice(volumes9pShare("testHostPath"), fsDev)
+	assert.NoError(err)
+	assert.Exactly([]govmmQemu.Device{
+		govmmQemu.FSDevice{
+			Driver:        govmmQemu.Virtio9P,
+			FSDriver:      govmmQemu.Local,
+			ID:            fmt.Sprintf("extra-9p-%s", mountGuest9pTag),
+			Path:          "testHostPath",
+			MountTag:      mountGuest9pTag,
+			SecurityModel: govmmQemu.None,
+			Multidev:      govmmQemu.Remap,
+		},
+	}, q.qemuConfig.Devices)
+}
+
 func TestQemuAddDeviceVhostUserBlk(t *testing.T) {
 	socketPath := "/test/socket/path"
 	devID := "testDevID"
@@ -347,6 +377,20 @@ func TestQemuCapabilities(t *testing.T) {
 
 	caps := q.capabilities()
 	assert.True(caps.IsBlockDeviceHotplugSupported())
+	assert.False(caps.IsFsSharingHotplugSupported())
+	assert.False(caps.IsPmemHotplugSupported())
+
+	q.arch = &qemuArchBase{
+		machineType: QemuPC,
+		supportedQemuMachines: []govmmQemu.Machine{
+			{
+				Type:    QemuPC,
+				Options: "accel=kvm,kernel_irqchip,nvdimm",
+			},
+		},
+	}
+	caps = q.capabilities()
+	assert.True(caps.IsPmemHotplugSupported())
 }
 
 func TestQemuQemuPath(t *testing.T) {
@@ -413,6 +457,32 @@ func TestHotplugUnsupportedDeviceType(t *testing.T) {
 	assert.Error(err)
 }
 
+func TestQemuHotplugStaleNvdimm(t *testing.T) {
+	assert := assert.New(t)
+
+	q := &qemu{
+		ctx:    context.Background(),
+		config: newQemuConfig(),
+	}
+	q.qmpMonitorCh.qmp = &govmmQemu.QMP{}
+
+	drive := &config.BlockDrive{
+		File: "/dev/pmem0",
+		ID:   "drive",
+		Pmem: true,
+	}
+
+	// The nvdimm device is left plugged.
+	assert.NoError(q.hotplugBlockDevice(drive, removeDevice))
+	assert.Equal([]string{drive.File}, q.state.StaleNvdimms)
+
+	// Its backing file can not be plugged again, even once reloaded.
+	var q2 qemu
+	q2.load(persistapi.HypervisorState{StaleNvdimms: q.state.StaleNvdimms})
+	q2.qmpMonitorCh.qmp = &govmmQemu.QMP{}
+	assert.Error(q2.hotplugBlockDevice(drive, addDevice))
+}
+
 func TestQMPSetupShutdown(t *testing.T) {
 	assert := assert.New(t)
 
@@ -575,12 +645,12 @@ func TestQemuVirtiofsdArgs(t *testing.T) {
 	}()
 
 	result := "--fd=123 -o source=test-share-dir/foo/shared -o cache=none --syslog -o no_posix_lock -d"
-	args := q.virtiofsdArgs(123)
+	args := q.virtiofsdArgs(123, getSharePath(q.id), q.config.VirtioFSCache)
 	assert.Equal(strings.Join(args, " "), result)
 
 	q.config.Debug = false
 	result = "--fd=123 -o source=test-share-dir/foo/shared -o cache=none --syslog -o no_posix_lock -f"
-	args = q.virtiofsdArgs(123)
+	args = q.virtiofsdArgs(123, getSharePath(q.id), q.config.VirtioFSCache)
 	assert.Equal(strings.Join(args, " "), result)
 }
 
@@ -620,3 +690,261 @@ func TestQemuGetpids(t *testing.T) {
 	assert.True(pids[0] == 100)
 	assert.True(pids[1] == 200)
 }
+
+// qmpMock is a QMP server accepting every command, which records the
+// commands it receives.
+type qmpMock struct {
+	sync.Mutex
+	listener net.Listener
+	commands []map[string]interface{}
+}
+
+func startQMPMock(path string) (*qmpMock, error) {
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &qmpMock{listener: l}
+	go m.serve()
+
+	return m, nil
+}
+
+func (m *qmpMock) serve() {
+	for {
+		conn, err := m.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go func() {
+			defer conn.Close()
+
+			fmt.Fprintln(conn, `{"QMP": {"version": {"qemu": {"micro": 0, "minor": 0, "major": 5}}, "capabilities": []}}`)
+
+			scanner := bufio.NewScanner(conn)
+			for scanner.Scan() {
+				var command map[string]interface{}
+				if err := json.Unmarshal(scanner.Bytes(), &command); err != nil {
+					return
+				}
+
+				m.Lock()
+				m.commands = append(m.commands, command)
+				m.Unlock()
+
+				fmt.Fprintln(conn, `{"return": {}}`)
+
+				// device_del waits for the device to be removed.
+				if command["execute"] == "device_del" {
+					args, _ := command["arguments"].(map[string]interface{})
+					fmt.Fprintf(conn, `{"event": "DEVICE_DELETED", "data": {"device": %q}}`+"\n", args["id"])
+				}
+			}
+		}()
+	}
+}
+
+// command returns the last command named name received.
+func (m *qmpMock) command(name string) map[string]interface{} {
+	m.Lock()
+	defer m.Unlock()
+
+	for i := len(m.commands) - 1; i >= 0; i-- {
+		if m.commands[i]["execute"] == name {
+			return m.commands[i]
+		}
+	}
+
+	return nil
+}
+
+func (m *qmpMock) stop() {
+	m.listener.Close()
+}
+
+func TestQemuThrottleBlockDevice(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "qmp")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	qmpPath := filepath.Join(dir, "qmp.sock")
+	m, err := startQMPMock(qmpPath)
+	assert.NoError(err)
+	defer m.stop()
+
+	q := &qemu{
+		ctx:    context.Background(),
+		config: newQemuConfig(),
+		qmpMonitorCh: qmpChannel{
+			ctx:  context.Background(),
+			path: qmpPath,
+		},
+	}
+	defer q.qmpShutdown()
+
+	drive := &config.BlockDrive{ID: "drive"}
+	throttle := config.BlockDriveThrottle{ReadBps: 1000, WriteIOPS: 10}
+	assert.NoError(q.throttleBlockDevice(drive, throttle))
+
+	command := m.command("block_set_io_throttle")
+	assert.NotNil(command)
+	assert.Equal(map[string]interface{}{
+		"id":      "virtio-drive",
+		"bps":     float64(0),
+		"bps_rd":  float64(1000),
+		"bps_wr":  float64(0),
+		"iops":    float64(0),
+		"iops_rd": float64(0),
+		"iops_wr": float64(10),
+	}, command["arguments"])
+
+	// nvdimm devices can not be throttled.
+	assert.Error(q.throttleBlockDevice(&config.BlockDrive{ID: "pmem", Pmem: true}, throttle))
+}
+
+func TestQemuRestartVirtiofsd(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "qemu-virtiofs")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	daemonPath := filepath.Join(dir, "virtiofsd")
+	assert.NoError(ioutil.WriteFile(daemonPath, []byte("#!/bin/sh\nexec sleep 60\n"), 0755))
+
+	store, err := persist.GetDriver()
+	assert.NoError(err)
+
+	qemuConfig := newQemuConfig()
+	qemuConfig.SharedFS = config.VirtioFS
+	qemuConfig.VirtioFSDaemon = daemonPath
+	qemuConfig.VirtioFSCache = "auto"
+	qemuConfig.VirtioFSDaemonRestart = true
+
+	q := &qemu{
+		id:     "qemu-restart-virtiofsd",
+		ctx:    context.Background(),
+		config: qemuConfig,
+		store:  store,
+	}
+
+	// no daemon started
+	assert.Error(q.restartVirtiofsd())
+
+	assert.NoError(os.MkdirAll(filepath.Join(store.RunVMStoragePath(), q.id), DirMode))
+	defer os.RemoveAll(filepath.Join(store.RunVMStoragePath(), q.id))
+
+	assert.NoError(q.setupVirtiofsd())
+	pid := q.getVirtiofsdPid()
+	assert.NotZero(pid)
+	defer func() { syscall.Kill(q.getVirtiofsdPid(), syscall.SIGKILL) }()
+
+	// kill the daemon, as if it crashed
+	assert.NoError(syscall.Kill(pid, syscall.SIGKILL))
+
+	assert.NoError(q.restartVirtiofsd())
+	newPid := q.getVirtiofsdPid()
+	assert.NotEqual(pid, newPid)
+	assert.NoError(syscall.Kill(newPid, 0))
+
+	sockPath, err := q.vhostFSSocketPath(q.id)
+	assert.NoError(err)
+	_, err = os.Stat(sockPath)
+	assert.NoError(err)
+}
+
+func TestQemuHotplugVhostUserFSDevice(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "qemu-virtiofs")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	qmpPath := filepath.Join(dir, "qmp.sock")
+	m, err := startQMPMock(qmpPath)
+	assert.NoError(err)
+	defer m.stop()
+
+	daemonPath := filepath.Join(dir, "virtiofsd")
+	assert.NoError(ioutil.WriteFile(daemonPath, []byte("#!/bin/sh\nexec sleep 60\n"), 0755))
+
+	store, err := persist.GetDriver()
+	assert.NoError(err)
+
+	qemuConfig := newQemuConfig()
+	qemuConfig.SharedFS = config.VirtioFS
+	qemuConfig.VirtioFSDaemon = daemonPath
+	qemuConfig.VirtioFSCache = "auto"
+
+	q := &qemu{
+		id:     "qemu-volume-virtiofsd",
+		ctx:    context.Background(),
+		config: qemuConfig,
+		store:  store,
+		arch: &qemuArchBase{
+			Bridges: []types.Bridge{types.NewBridge(types.PCI, "pci-bridge-0", make(map[uint32]string), 2)},
+		},
+		qmpMonitorCh: qmpChannel{
+			ctx:  context.Background(),
+			path: qmpPath,
+		},
+	}
+	defer q.qmpShutdown()
+
+	caps := q.capabilities()
+	assert.True(caps.IsFsSharingHotplugSupported())
+
+	assert.NoError(os.MkdirAll(filepath.Join(store.RunVMStoragePath(), q.id), DirMode))
+	defer os.RemoveAll(filepath.Join(store.RunVMStoragePath(), q.id))
+
+	dev := &config.VhostUserDeviceAttrs{
+		DevID:     "volume",
+		Type:      config.VhostUserFS,
+		Tag:       "volume",
+		CacheSize: 1024,
+		SharedDir: dir,
+	}
+	_, err = q.hotplugAddDevice(dev, vhostuserDev)
+	assert.NoError(err)
+
+	command := m.command("device_add")
+	assert.NotNil(command)
+	assert.Equal(map[string]interface{}{
+		"driver":     "vhost-user-fs-pci",
+		"id":         "virtio-volume",
+		"chardev":    "volume",
+		"tag":        "volume",
+		"addr":       "01",
+		"bus":        "pci-bridge-0",
+		"cache-size": "1024M",
+	}, command["arguments"])
+
+	pid, ok := q.state.VolumeVirtiofsdPids["volume"]
+	assert.True(ok)
+
+	q.qemuConfig.PidFile = filepath.Join(dir, "pid")
+	assert.NoError(ioutil.WriteFile(q.qemuConfig.PidFile, []byte("100"), 0644))
+	assert.Equal([]int{100, pid}, q.getPids())
+
+	// The daemon of the volume is persisted.
+	loaded := &qemu{}
+	loaded.load(q.save())
+	assert.Equal(q.state.VolumeVirtiofsdPids, loaded.state.VolumeVirtiofsdPids)
+
+	// The daemon of the volume is stopped when its device is unplugged.
+	_, err = q.hotplugRemoveDevice(dev, vhostuserDev)
+	assert.NoError(err)
+	assert.Empty(q.state.VolumeVirtiofsdPids)
+	assert.NotNil(m.command("chardev-remove"))
+
+	// The guest memory is only shared with virtio-fs.
+	q.config.SharedFS = config.Virtio9P
+	caps = q.capabilities()
+	assert.False(caps.IsFsSharingHotplugSupported())
+	_, err = q.hotplugAddDevice(dev, vhostuserDev)
+	assert.Error(err)
+}
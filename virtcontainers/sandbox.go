@@ -13,6 +13,7 @@ import (
 	"math"
 	"net"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"syscall"
@@ -73,6 +74,21 @@ type SandboxStatus struct {
 type SandboxStats struct {
 	CgroupStats CgroupStats
 	Cpus        int
+	// Virtiofsd is the usage of the virtio-fs daemon of the sandbox,
+	// zero if it has none.
+	Virtiofsd ProcessStats
+	// NetworkStats are the drops and errors of the host interfaces
+	// connecting the sandbox endpoints to the VM, per guest interface.
+	NetworkStats []*NetworkStats
+}
+
+// ProcessStats describes the resource usage of a host process.
+type ProcessStats struct {
+	Pid int
+	// CPUTime is the user and system CPU time, in seconds.
+	CPUTime float64
+	// ResidentMemory is the resident set size, in bytes.
+	ResidentMemory int
 }
 
 // SandboxConfig is a Sandbox configuration.
@@ -195,6 +211,10 @@ type Sandbox struct {
 	network Network
 	monitor *monitor
 
+	// netmonServer receives the changes of the network monitor, when
+	// the sandbox is stateful.
+	netmonServer *netmonServer
+
 	config *SandboxConfig
 
 	devManager api.DeviceManager
@@ -219,6 +239,10 @@ type Sandbox struct {
 
 	cgroupMgr *vccgroups.Manager
 
+	// volumeWatcher keeps the volumes copied into the guest up to date,
+	// when filesystem sharing is not supported.
+	volumeWatcher *volumeWatcher
+
 	ctx context.Context
 }
 
@@ -300,6 +324,8 @@ func (s *Sandbox) Release() error {
 	if s.monitor != nil {
 		s.monitor.stop()
 	}
+	s.volumeWatcher.close()
+	s.netmonServer.close()
 	s.hypervisor.disconnect()
 	return s.agent.disconnect()
 }
@@ -708,6 +734,42 @@ func rwLockSandbox(sandboxID string) (func() error, error) {
 	return store.Lock(sandboxID, true)
 }
 
+// rwLockNewSandbox creates the persist directory of a sandbox being created
+// and takes its exclusive lock, so that CheckSandboxes does not consider the
+// resources created before the sandbox is stored as leaked. Unlocking removes
+// the directory if the sandbox has not been stored in it.
+func rwLockNewSandbox(sandboxID string) (func() error, error) {
+	store, err := persist.GetDriver()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get fs persist driver: %v", err)
+	}
+
+	sandboxDir := filepath.Join(store.RunStoragePath(), sandboxID)
+
+	for {
+		if err := os.MkdirAll(sandboxDir, DirMode); err != nil {
+			return nil, err
+		}
+
+		unlock, err := store.Lock(sandboxID, true)
+		if err != nil {
+			return nil, err
+		}
+
+		// The directory may have been removed by CheckSandboxes
+		// while waiting for the lock.
+		if _, err := os.Stat(sandboxDir); err == nil {
+			return func() error {
+				// Only succeeds if the directory is empty.
+				os.Remove(sandboxDir)
+				return unlock()
+			}, nil
+		}
+
+		unlock()
+	}
+}
+
 // fetchSandbox fetches a sandbox config from a sandbox ID and returns a sandbox.
 func fetchSandbox(ctx context.Context, sandboxID string) (sandbox *Sandbox, err error) {
 	virtLog.Info("fetch sandbox")
@@ -861,9 +923,26 @@ func (s *Sandbox) startNetworkMonitor() error {
 		sandboxID:  s.id,
 	}
 
+	// The runtime CLI can't reach a sandbox kept in memory by the
+	// runtime process, the network monitor talks to the latter through
+	// a control socket.
+	if s.stateful {
+		socket, err := s.netmonSocketPath()
+		if err != nil {
+			return err
+		}
+
+		if s.netmonServer, err = newNetmonServer(s, socket); err != nil {
+			return err
+		}
+		params.controlSocket = socket
+	}
+
 	return s.network.Run(s.networkNS.NetNsPath, func() error {
 		pid, err := startNetmon(params)
 		if err != nil {
+			s.netmonServer.close()
+			s.netmonServer = nil
 			return err
 		}
 
@@ -873,6 +952,52 @@ func (s *Sandbox) startNetworkMonitor() error {
 	})
 }
 
+// startUserModeNetwork attaches the user-mode network stack to the network
+// namespace, before the latter is scanned for the interfaces to connect to
+// the VM.
+func (s *Sandbox) startUserModeNetwork() error {
+	span, _ := s.trace("startUserModeNetwork")
+	defer span.Finish()
+
+	runPath := filepath.Join(s.newStore.RunStoragePath(), s.id)
+	if err := os.MkdirAll(runPath, DirMode); err != nil {
+		return err
+	}
+
+	socket, err := utils.BuildSocketPath(runPath, userModeNetworkSocket)
+	if err != nil {
+		return err
+	}
+
+	config := s.config.NetworkConfig.UserModeNetworkConfig
+	params := userModeNetworkParams{
+		path:      config.Path,
+		netNSPath: s.networkNS.NetNsPath,
+		apiSocket: socket,
+	}
+
+	// The rootless network namespace is owned by the user namespace of
+	// the runtime, the stack can't configure it from the initial one.
+	if rootless.IsRootless() {
+		params.userNSPath = fmt.Sprintf("/proc/%d/ns/user", os.Getpid())
+	}
+
+	pid, err := startUserModeNetwork(params)
+	if err != nil {
+		return err
+	}
+
+	s.networkNS.UserModeNetworkPID = pid
+
+	if err := addPortForwards(socket, config.PortForwards); err != nil {
+		stopUserModeNetwork(pid)
+		s.networkNS.UserModeNetworkPID = 0
+		return err
+	}
+
+	return nil
+}
+
 func (s *Sandbox) createNetwork() error {
 	if s.config.NetworkConfig.DisableNewNetNs ||
 		s.config.NetworkConfig.NetNSPath == "" {
@@ -887,6 +1012,12 @@ func (s *Sandbox) createNetwork() error {
 		NetNsCreated: s.config.NetworkConfig.NetNsCreated,
 	}
 
+	if s.config.NetworkConfig.InterworkingModel == NetXConnectUserModeModel {
+		if err := s.startUserModeNetwork(); err != nil {
+			return err
+		}
+	}
+
 	// In case there is a factory, network interfaces are hotplugged
 	// after vm is started.
 	if s.factory == nil {
@@ -907,6 +1038,58 @@ func (s *Sandbox) createNetwork() error {
 	return nil
 }
 
+func (s *Sandbox) netmonSocketPath() (string, error) {
+	runPath := filepath.Join(s.newStore.RunStoragePath(), s.id)
+	if err := os.MkdirAll(runPath, DirMode); err != nil {
+		return "", err
+	}
+
+	return utils.BuildSocketPath(runPath, netmonSocket)
+}
+
+// ServeNetworkChanges applies the changes sent by the network monitor of a
+// stateful sandbox while holding lock, so that they are serialized with the
+// operations the caller runs on the sandbox under the same lock. When the
+// sandbox has been fetched by a new runtime process, the control socket is
+// listened on again, and the network monitor is started again if it is gone.
+func (s *Sandbox) ServeNetworkChanges(lock sync.Locker) error {
+	if !s.stateful || !s.config.NetworkConfig.NetmonConfig.Enable {
+		return nil
+	}
+
+	if s.netmonServer == nil {
+		pid := s.networkNS.NetmonPID
+		if pid <= 0 {
+			return nil
+		}
+
+		if syscall.Kill(pid, syscall.Signal(0)) == nil {
+			socket, err := s.netmonSocketPath()
+			if err != nil {
+				return err
+			}
+
+			if s.netmonServer, err = newNetmonServer(s, socket); err != nil {
+				return err
+			}
+		} else {
+			s.Logger().WithField("netmon-pid", pid).Warn("Network monitor is gone, starting it again")
+
+			if err := s.startNetworkMonitor(); err != nil {
+				return err
+			}
+
+			if err := s.storeSandbox(); err != nil {
+				return err
+			}
+		}
+	}
+
+	s.netmonServer.start(lock)
+
+	return nil
+}
+
 func (s *Sandbox) postCreatedNetwork() error {
 
 	return s.network.PostAdd(s.ctx, &s.networkNS, s.factory != nil)
@@ -920,9 +1103,18 @@ func (s *Sandbox) removeNetwork() error {
 		if err := stopNetmon(s.networkNS.NetmonPID); err != nil {
 			return err
 		}
+
+		if err := s.netmonServer.close(); err != nil {
+			s.Logger().WithError(err).Warn("Could not close network monitor server")
+		}
+		s.netmonServer = nil
+	}
+
+	if err := s.network.Remove(s.ctx, &s.networkNS, s.hypervisor); err != nil {
+		return err
 	}
 
-	return s.network.Remove(s.ctx, &s.networkNS, s.hypervisor)
+	return stopUserModeNetwork(s.networkNS.UserModeNetworkPID)
 }
 
 func (s *Sandbox) generateNetInfo(inf *vcTypes.Interface) (NetworkInfo, error) {
@@ -970,7 +1162,14 @@ func (s *Sandbox) AddInterface(inf *vcTypes.Interface) (*vcTypes.Interface, erro
 	endpoint.SetProperties(netInfo)
 	if err := doNetNS(s.networkNS.NetNsPath, func(_ ns.NetNS) error {
 		s.Logger().WithField("endpoint-type", endpoint.Type()).Info("Hot attaching endpoint")
-		return endpoint.HotAttach(s.hypervisor)
+		if err := endpoint.HotAttach(s.hypervisor); err != nil {
+			return err
+		}
+
+		if s.config.NetworkConfig.Bandwidth != (BandwidthLimits{}) {
+			return setupBandwidthLimits(endpoint, s.config.NetworkConfig.Bandwidth)
+		}
+		return nil
 	}); err != nil {
 		return nil, err
 	}
@@ -1006,6 +1205,38 @@ func (s *Sandbox) RemoveInterface(inf *vcTypes.Interface) (*vcTypes.Interface, e
 	return nil, nil
 }
 
+// UpdateInterface updates the IP addresses of a nic of the sandbox.
+func (s *Sandbox) UpdateInterface(inf *vcTypes.Interface) (*vcTypes.Interface, error) {
+	for _, endpoint := range s.networkNS.Endpoints {
+		if endpoint.HardwareAddr() != inf.HwAddr {
+			continue
+		}
+
+		var addrs []netlink.Addr
+		for _, ipAddr := range inf.IPAddresses {
+			addr, err := netlink.ParseAddr(ipAddr.Address + "/" + ipAddr.Mask)
+			if err != nil {
+				return nil, err
+			}
+			addrs = append(addrs, *addr)
+		}
+
+		// Keep the endpoint in sync, so that the addresses are restored
+		// along with the interface.
+		netInfo := endpoint.Properties()
+		netInfo.Addrs = addrs
+		endpoint.SetProperties(netInfo)
+		if err := s.Save(); err != nil {
+			return nil, err
+		}
+
+		inf.PciPath = endpoint.PciPath()
+		return s.agent.updateInterface(inf)
+	}
+
+	return nil, fmt.Errorf("Interface with hardware address %s not found", inf.HwAddr)
+}
+
 // ListInterfaces lists all nics and their configurations in the sandbox.
 func (s *Sandbox) ListInterfaces() ([]*vcTypes.Interface, error) {
 	return s.agent.listInterfaces()
@@ -1021,6 +1252,11 @@ func (s *Sandbox) ListRoutes() ([]*vcTypes.Route, error) {
 	return s.agent.listRoutes()
 }
 
+// UpdateNeighbors adds or replaces static ARP neighbors of the sandbox.
+func (s *Sandbox) UpdateNeighbors(neighs []*vcTypes.ARPNeighbor) error {
+	return s.agent.addARPNeighbors(neighs)
+}
+
 // startVM starts the VM.
 func (s *Sandbox) startVM() (err error) {
 	span, ctx := s.trace("startVM")
@@ -1148,6 +1384,24 @@ func (s *Sandbox) fetchContainers() error {
 // This should be called only when the sandbox is already created.
 // It will add new container config to sandbox.config.Containers
 func (s *Sandbox) CreateContainer(contConfig ContainerConfig) (VCContainer, error) {
+	if contConfig.isEphemeral() {
+		if err := s.checkEphemeralContainer(&contConfig); err != nil {
+			return nil, err
+		}
+	}
+
+	// The bandwidth annotations of the pod may have changed since the
+	// sandbox was created.
+	limits, found, err := bandwidthFromAnnotations(contConfig.Annotations)
+	if err != nil {
+		return nil, err
+	}
+	if found && s.networkNS.NetNsPath != "" {
+		if err := s.updateBandwidthLimits(limits); err != nil {
+			return nil, err
+		}
+	}
+
 	// Create the container object, add devices to the sandbox's device-manager:
 	c, err := newContainer(s, &contConfig)
 	if err != nil {
@@ -1217,6 +1471,30 @@ func (s *Sandbox) CreateContainer(contConfig ContainerConfig) (VCContainer, erro
 	return c, nil
 }
 
+// checkEphemeralContainer validates an ephemeral container configuration
+// against the running sandbox it is going to be added to.
+func (s *Sandbox) checkEphemeralContainer(contConfig *ContainerConfig) error {
+	if s.state.State != types.StateRunning {
+		return fmt.Errorf("Sandbox not running, impossible to create ephemeral container %s", contConfig.ID)
+	}
+
+	targetID := contConfig.ephemeralTarget()
+	if targetID == "" {
+		return nil
+	}
+
+	target, err := s.findContainer(targetID)
+	if err != nil {
+		return err
+	}
+
+	if target.state.State != types.StateRunning {
+		return fmt.Errorf("Target container %s of ephemeral container %s is not running", targetID, contConfig.ID)
+	}
+
+	return nil
+}
+
 // StartContainer starts a container in the sandbox
 func (s *Sandbox) StartContainer(containerID string) (VCContainer, error) {
 	// Fetch the container.
@@ -1247,6 +1525,31 @@ func (s *Sandbox) StartContainer(containerID string) (VCContainer, error) {
 	return c, nil
 }
 
+// RestartContainer restarts a created or stopped container in the sandbox.
+// The container process is run again by the agent, reusing the rootfs,
+// mounts and devices still prepared for the container, which makes it much
+// faster than deleting and creating the container again. A running container
+// must be stopped first.
+func (s *Sandbox) RestartContainer(containerID string) (VCContainer, error) {
+	// Fetch the container.
+	c, err := s.findContainer(containerID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = c.restart(); err != nil {
+		return nil, err
+	}
+
+	if err = s.storeSandbox(); err != nil {
+		return nil, err
+	}
+
+	s.Logger().WithField("container", containerID).Info("Container is restarted")
+
+	return c, nil
+}
+
 // StopContainer stops a container in the sandbox
 func (s *Sandbox) StopContainer(containerID string, force bool) (VCContainer, error) {
 	// Fetch the container.
@@ -1393,6 +1696,14 @@ func (s *Sandbox) UpdateContainer(containerID string, resources specs.LinuxResou
 		return err
 	}
 
+	// The bandwidth limits are applied again, as the qdiscs of the
+	// endpoints may have been replaced since they were set up.
+	if s.config.NetworkConfig.Bandwidth != (BandwidthLimits{}) && s.networkNS.NetNsPath != "" {
+		if err := s.setBandwidthLimits(s.config.NetworkConfig.Bandwidth); err != nil {
+			return err
+		}
+	}
+
 	if err := s.cgroupsUpdate(); err != nil {
 		return err
 	}
@@ -1403,6 +1714,36 @@ func (s *Sandbox) UpdateContainer(containerID string, resources specs.LinuxResou
 	return nil
 }
 
+// AddMount adds a mount to a running container.
+func (s *Sandbox) AddMount(containerID string, m Mount) error {
+	// Fetch the container.
+	c, err := s.findContainer(containerID)
+	if err != nil {
+		return err
+	}
+
+	if err := c.addMount(m); err != nil {
+		return err
+	}
+
+	return s.storeSandbox()
+}
+
+// RemoveMount removes the mount at destination from a running container.
+func (s *Sandbox) RemoveMount(containerID, destination string) error {
+	// Fetch the container.
+	c, err := s.findContainer(containerID)
+	if err != nil {
+		return err
+	}
+
+	if err := c.removeMount(destination); err != nil {
+		return err
+	}
+
+	return s.storeSandbox()
+}
+
 // StatsContainer return the stats of a running container
 func (s *Sandbox) StatsContainer(containerID string) (ContainerStats, error) {
 	// Fetch the container.
@@ -1415,6 +1756,17 @@ func (s *Sandbox) StatsContainer(containerID string) (ContainerStats, error) {
 	if err != nil {
 		return ContainerStats{}, err
 	}
+
+	// The network is shared by the whole sandbox, the host side drops
+	// and errors are added to the guest counters of the sandbox container.
+	if c.id == s.id {
+		hostStats, err := hostNetworkStats(s.networkNS)
+		if err != nil {
+			s.Logger().WithError(err).Warn("Could not get host network stats")
+		}
+		stats.NetworkStats = mergeHostNetworkStats(stats.NetworkStats, hostStats)
+	}
+
 	return *stats, nil
 }
 
@@ -1455,9 +1807,39 @@ func (s *Sandbox) Stats() (SandboxStats, error) {
 	}
 	stats.Cpus = len(tids.vcpus)
 
+	// The daemon usage is left out when it can not be read, the daemon
+	// quitting is reported by the sandbox monitor.
+	if pid := s.hypervisor.getVirtiofsdPid(); pid != 0 {
+		if stats.Virtiofsd, err = processStats(pid); err != nil {
+			s.Logger().WithError(err).WithField("pid", pid).Warn("Could not read virtiofsd stats")
+		}
+	}
+
+	if stats.NetworkStats, err = hostNetworkStats(s.networkNS); err != nil {
+		return stats, err
+	}
+
 	return stats, nil
 }
 
+func processStats(pid int) (ProcessStats, error) {
+	proc, err := utils.NewProc(pid)
+	if err != nil {
+		return ProcessStats{}, err
+	}
+
+	stat, err := proc.NewStat()
+	if err != nil {
+		return ProcessStats{}, err
+	}
+
+	return ProcessStats{
+		Pid:            pid,
+		CPUTime:        stat.CPUTime(),
+		ResidentMemory: stat.ResidentMemory(),
+	}, nil
+}
+
 // PauseContainer pauses a running container.
 func (s *Sandbox) PauseContainer(containerID string) error {
 	// Fetch the container.
@@ -1589,6 +1971,11 @@ func (s *Sandbox) Stop(force bool) error {
 		}
 	}
 
+	if err := s.volumeWatcher.close(); err != nil {
+		s.Logger().WithError(err).Warn("Could not close volume watcher")
+	}
+	s.volumeWatcher = nil
+
 	if err := s.stopVM(); err != nil && !force {
 		return err
 	}
@@ -1678,10 +2065,16 @@ func (s *Sandbox) unsetSandboxBlockIndex(index int) error {
 
 // HotplugAddDevice is used for add a device to sandbox
 // Sandbox implement DeviceReceiver interface from device/api/interface.go
-func (s *Sandbox) HotplugAddDevice(device api.Device, devType config.DeviceType) error {
+func (s *Sandbox) HotplugAddDevice(device api.Device, devType config.DeviceType) (err error) {
 	span, _ := s.trace("HotplugAddDevice")
 	defer span.Finish()
 
+	defer func() {
+		if err == nil {
+			hotplugCount.Inc(string(devType), "add")
+		}
+	}()
+
 	if s.config.SandboxCgroupOnly {
 		// We are about to add a device to the hypervisor,
 		// the device cgroup MUST be updated since the hypervisor
@@ -1736,7 +2129,13 @@ func (s *Sandbox) HotplugAddDevice(device api.Device, devType config.DeviceType)
 
 // HotplugRemoveDevice is used for removing a device from sandbox
 // Sandbox implement DeviceReceiver interface from device/api/interface.go
-func (s *Sandbox) HotplugRemoveDevice(device api.Device, devType config.DeviceType) error {
+func (s *Sandbox) HotplugRemoveDevice(device api.Device, devType config.DeviceType) (err error) {
+	defer func() {
+		if err == nil {
+			hotplugCount.Inc(string(devType), "remove")
+		}
+	}()
+
 	defer func() {
 		if s.config.SandboxCgroupOnly {
 			// Remove device from cgroup, the hypervisor
@@ -1915,6 +2314,9 @@ func (s *Sandbox) updateResources() error {
 
 func (s *Sandbox) calculateSandboxMemory() int64 {
 	memorySandbox := int64(0)
+	// Ephemeral volumes are backed by guest memory and shared by the
+	// containers using them.
+	ephemeralVolumes := make(map[string]bool)
 	for _, c := range s.config.Containers {
 		// Do not hot add again non-running containers resources
 		if cont, ok := s.containers[c.ID]; ok && cont.state.State == types.StateStopped {
@@ -1922,9 +2324,25 @@ func (s *Sandbox) calculateSandboxMemory() int64 {
 			continue
 		}
 
+		// Ephemeral containers run with the resources already given to the sandbox
+		if c.isEphemeral() {
+			continue
+		}
+
 		if m := c.Resources.Memory; m != nil && m.Limit != nil {
 			memorySandbox += *m.Limit
 		}
+
+		for _, m := range c.Mounts {
+			if m.Type != KataEphemeralDevType || ephemeralVolumes[m.Source] {
+				continue
+			}
+
+			if size, err := MountSizeLimit(m.Options); err == nil && size > 0 {
+				ephemeralVolumes[m.Source] = true
+				memorySandbox += int64(size)
+			}
+		}
 	}
 	return memorySandbox
 }
@@ -1940,6 +2358,11 @@ func (s *Sandbox) calculateSandboxCPUs() (uint32, error) {
 			continue
 		}
 
+		// Ephemeral containers run with the resources already given to the sandbox
+		if c.isEphemeral() {
+			continue
+		}
+
 		if cpu := c.Resources.CPU; cpu != nil {
 			if cpu.Period != nil && cpu.Quota != nil {
 				mCPU += utils.CalculateMilliCPUs(*cpu.Quota, *cpu.Period)
@@ -2174,6 +2597,11 @@ func (s *Sandbox) cpuResources() *specs.LinuxCPU {
 			continue
 		}
 
+		if c.config.isEphemeral() {
+			// skip ephemeral containers
+			continue
+		}
+
 		if c.config.Resources.CPU == nil {
 			continue
 		}
@@ -2282,6 +2710,77 @@ func (s *Sandbox) GetOOMEvent() (string, error) {
 	return s.agent.getOOMEvent()
 }
 
+func (s *Sandbox) startVolumeWatcher() error {
+	if s.volumeWatcher != nil {
+		return nil
+	}
+
+	w, err := newVolumeWatcher(s.agent)
+	if err != nil {
+		return err
+	}
+	s.volumeWatcher = w
+
+	return nil
+}
+
+// watchVolumes tells whether the volumes copied into the guest and the files
+// shared through a bind mount can be kept up to date. The watcher lives in
+// the runtime process, only a stateful sandbox, run by the shim v2, outlives
+// the creation of its containers.
+func (s *Sandbox) watchVolumes(containerID, source string) bool {
+	if s.stateful {
+		return true
+	}
+
+	s.Logger().WithFields(logrus.Fields{
+		"container": containerID,
+		"source":    source,
+	}).Info("Updates of the volume will not reach the guest, the sandbox is not stateful")
+
+	return false
+}
+
+// watchCopiedVolume keeps a volume copied into the guest in sync with its
+// source on the host, files being the paths of the files copied in the
+// guest.
+func (s *Sandbox) watchCopiedVolume(containerID, source, guestPath string, files, dirs []string) error {
+	if !s.watchVolumes(containerID, source) {
+		return nil
+	}
+
+	if err := s.startVolumeWatcher(); err != nil {
+		return err
+	}
+
+	return s.volumeWatcher.add(containerID, source, guestPath, files, dirs)
+}
+
+// watchSharedFile keeps a file shared with the guest through a bind mount at
+// mountPath up to date when its source on the host is replaced.
+func (s *Sandbox) watchSharedFile(containerID, source, mountPath string, readOnly bool) error {
+	if !s.watchVolumes(containerID, source) {
+		return nil
+	}
+
+	if err := s.startVolumeWatcher(); err != nil {
+		return err
+	}
+
+	return s.volumeWatcher.addSharedFile(containerID, source, mountPath, readOnly)
+}
+
+// GetHypervisorPids returns the pids of the hypervisor processes of the
+// sandbox, the VMM first followed by its helper daemons like virtiofsd.
+func (s *Sandbox) GetHypervisorPids() ([]int, error) {
+	pids := s.hypervisor.getPids()
+	if len(pids) == 0 || pids[0] == 0 {
+		return nil, fmt.Errorf("Invalid hypervisor PID: %+v", pids)
+	}
+
+	return pids, nil
+}
+
 // getSandboxCPUSet returns the union of each of the sandbox's containers' CPU sets'
 // cpus and mems as a string in canonical linux CPU/mems list format
 func (s *Sandbox) getSandboxCPUSet() (string, string, error) {
@@ -2292,6 +2791,10 @@ func (s *Sandbox) getSandboxCPUSet() (string, string, error) {
 	cpuResult := cpuset.NewCPUSet()
 	memResult := cpuset.NewCPUSet()
 	for _, ctr := range s.config.Containers {
+		if ctr.isEphemeral() {
+			continue
+		}
+
 		if ctr.Resources.CPU != nil {
 			currCPUSet, err := cpuset.Parse(ctr.Resources.CPU.Cpus)
 			if err != nil {
@@ -120,6 +120,9 @@ func TestCalculateSandboxCPUs(t *testing.T) {
 	unconstrainedCpusets0_1.Resources.CPU = &specs.LinuxCPU{Cpus: "0-1"}
 	unconstrainedCpusets2.Resources.CPU = &specs.LinuxCPU{Cpus: "2"}
 	constrainedCpusets0_7.Resources.CPU = &specs.LinuxCPU{Period: &period, Quota: &quota, Cpus: "0-7"}
+	ephemeral := newTestContainerConfigNoop("cont-00006")
+	ephemeral.Annotations = map[string]string{annotations.EphemeralContainer: "true"}
+	ephemeral.Resources.CPU = &specs.LinuxCPU{Period: &period, Quota: &quota}
 	tests := []struct {
 		name       string
 		containers []ContainerConfig
@@ -134,6 +137,7 @@ func TestCalculateSandboxCPUs(t *testing.T) {
 		{"unconstrained-1-cpuset", []ContainerConfig{unconstrained, unconstrained, unconstrainedCpusets0_1}, 2},
 		{"unconstrained-2-cpuset", []ContainerConfig{unconstrainedCpusets0_1, unconstrainedCpusets2}, 3},
 		{"constrained-cpuset", []ContainerConfig{constrainedCpusets0_7}, 4},
+		{"1-constrained-1-ephemeral", []ContainerConfig{constrained, ephemeral}, 4},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -151,6 +155,11 @@ func TestCalculateSandboxMem(t *testing.T) {
 	constrained := newTestContainerConfigNoop("cont-00001")
 	limit := int64(4000)
 	constrained.Resources.Memory = &specs.LinuxMemory{Limit: &limit}
+	ephemeral := newTestContainerConfigNoop("cont-00002")
+	ephemeral.Annotations = map[string]string{annotations.EphemeralContainer: "true"}
+	ephemeral.Resources.Memory = &specs.LinuxMemory{Limit: &limit}
+	tmpfs := newTestContainerConfigNoop("cont-00003")
+	tmpfs.Mounts = []Mount{{Source: "/tmp/cache", Destination: "/cache", Type: KataEphemeralDevType, Options: []string{"size=1000"}}}
 
 	tests := []struct {
 		name       string
@@ -163,6 +172,9 @@ func TestCalculateSandboxMem(t *testing.T) {
 		{"2-constrained", []ContainerConfig{constrained, constrained}, limit * 2},
 		{"3-mix-constraints", []ContainerConfig{unconstrained, constrained, constrained}, limit * 2},
 		{"3-constrained", []ContainerConfig{constrained, constrained, constrained}, limit * 3},
+		{"1-constrained-1-ephemeral", []ContainerConfig{constrained, ephemeral}, limit},
+		{"1-constrained-1-tmpfs", []ContainerConfig{constrained, tmpfs}, limit + 1000},
+		{"2-tmpfs-shared-volume", []ContainerConfig{tmpfs, tmpfs}, 1000},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -173,6 +185,37 @@ func TestCalculateSandboxMem(t *testing.T) {
 	}
 }
 
+func TestCheckEphemeralContainer(t *testing.T) {
+	assert := assert.New(t)
+
+	sandbox := &Sandbox{
+		containers: map[string]*Container{
+			"target": {id: "target"},
+		},
+	}
+
+	contConfig := newTestContainerConfigNoop("debug")
+	contConfig.Annotations = map[string]string{
+		annotations.EphemeralContainer:       "true",
+		annotations.EphemeralContainerTarget: "target",
+	}
+
+	sandbox.state.State = types.StateReady
+	assert.Error(sandbox.checkEphemeralContainer(&contConfig))
+
+	sandbox.state.State = types.StateRunning
+	assert.Error(sandbox.checkEphemeralContainer(&contConfig))
+
+	sandbox.containers["target"].state.State = types.StateRunning
+	assert.NoError(sandbox.checkEphemeralContainer(&contConfig))
+
+	contConfig.Annotations[annotations.EphemeralContainerTarget] = "unknown"
+	assert.Error(sandbox.checkEphemeralContainer(&contConfig))
+
+	delete(contConfig.Annotations, annotations.EphemeralContainerTarget)
+	assert.NoError(sandbox.checkEphemeralContainer(&contConfig))
+}
+
 func TestCreateSandboxEmptyID(t *testing.T) {
 	hConfig := newHypervisorConfig(nil, nil)
 	_, err := testCreateSandbox(t, "", MockHypervisor, hConfig, NoopAgentType, NetworkConfig{}, nil, nil)
@@ -879,6 +922,47 @@ func TestStartContainer(t *testing.T) {
 	assert.Nil(t, err, "Start container failed: %v", err)
 }
 
+func TestRestartContainer(t *testing.T) {
+	s, err := testCreateSandbox(t, testSandboxID, MockHypervisor, newHypervisorConfig(nil, nil), NoopAgentType, NetworkConfig{}, nil, nil)
+	assert.Nil(t, err, "VirtContainers should not allow empty sandboxes")
+	defer cleanUp()
+
+	contID := "999"
+	_, err = s.RestartContainer(contID)
+	assert.NotNil(t, err, "Restarting non-existing container should fail")
+
+	contConfig := newTestContainerConfigNoop(contID)
+	_, err = s.CreateContainer(contConfig)
+	assert.Nil(t, err, "Failed to create container %+v in sandbox %+v: %v", contConfig, s, err)
+
+	_, err = s.RestartContainer(contID)
+	assert.NotNil(t, err, "Restarting container in a sandbox not running should fail")
+
+	err = s.Start()
+	assert.Nil(t, err, "Failed to start sandbox: %v", err)
+
+	_, err = s.RestartContainer(contID)
+	assert.NotNil(t, err, "Restarting running container should fail")
+
+	_, err = s.StopContainer(contID, false)
+	assert.Nil(t, err, "Stop container failed: %v", err)
+
+	c, err := s.RestartContainer(contID)
+	assert.Nil(t, err, "Restart stopped container failed: %v", err)
+	assert.Equal(t, types.StateRunning, c.(*Container).state.State)
+
+	// A container created in a running sandbox is ready.
+	contID = "1000"
+	contConfig = newTestContainerConfigNoop(contID)
+	_, err = s.CreateContainer(contConfig)
+	assert.Nil(t, err, "Failed to create container %+v in sandbox %+v: %v", contConfig, s, err)
+
+	c, err = s.RestartContainer(contID)
+	assert.Nil(t, err, "Restart created container failed: %v", err)
+	assert.Equal(t, types.StateRunning, c.(*Container).state.State)
+	assert.False(t, c.(*Container).restarting)
+}
+
 func TestStatusContainer(t *testing.T) {
 	s, err := testCreateSandbox(t, testSandboxID, MockHypervisor, newHypervisorConfig(nil, nil), NoopAgentType, NetworkConfig{}, nil, nil)
 	assert.Nil(t, err, "VirtContainers should not allow empty sandboxes")
@@ -1669,3 +1753,19 @@ func TestSandboxStoreClean(t *testing.T) {
 	assert.Error(err)
 	assert.True(os.IsNotExist(err))
 }
+
+func TestSandboxGetHypervisorPids(t *testing.T) {
+	assert := assert.New(t)
+
+	s := &Sandbox{
+		hypervisor: &mockHypervisor{},
+	}
+
+	_, err := s.GetHypervisorPids()
+	assert.Error(err)
+
+	s.hypervisor = &mockHypervisor{mockPid: 1234}
+	pids, err := s.GetHypervisorPids()
+	assert.NoError(err)
+	assert.Equal([]int{1234}, pids)
+}
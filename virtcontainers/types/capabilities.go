@@ -10,6 +10,8 @@ const (
 	blockDeviceHotplugSupport
 	multiQueueSupport
 	fsSharingSupported
+	fsSharingHotplugSupport
+	pmemHotplugSupport
 )
 
 // Capabilities describe a virtcontainers hypervisor capabilities
@@ -57,3 +59,25 @@ func (caps *Capabilities) IsFsSharingSupported() bool {
 func (caps *Capabilities) SetFsSharingSupport() {
 	caps.flags |= fsSharingSupported
 }
+
+// IsFsSharingHotplugSupported tells if an hypervisor can hotplug filesystem
+// sharing devices, to share a host directory with the guest on its own.
+func (caps *Capabilities) IsFsSharingHotplugSupported() bool {
+	return caps.flags&fsSharingHotplugSupport != 0
+}
+
+// SetFsSharingHotplugSupport sets the filesystem sharing hotplug capability to true.
+func (caps *Capabilities) SetFsSharingHotplugSupport() {
+	caps.flags |= fsSharingHotplugSupport
+}
+
+// IsPmemHotplugSupported tells if an hypervisor can hotplug persistent memory
+// devices.
+func (caps *Capabilities) IsPmemHotplugSupported() bool {
+	return caps.flags&pmemHotplugSupport != 0
+}
+
+// SetPmemHotplugSupport sets the persistent memory hotplug capability to true.
+func (caps *Capabilities) SetPmemHotplugSupport() {
+	caps.flags |= pmemHotplugSupport
+}
@@ -43,3 +43,21 @@ func TestMultiQueueCapability(t *testing.T) {
 	caps.SetMultiQueueSupport()
 	assert.True(caps.IsMultiQueueSupported())
 }
+
+func TestFsSharingHotplugCapability(t *testing.T) {
+	assert := assert.New(t)
+	var caps Capabilities
+
+	assert.False(caps.IsFsSharingHotplugSupported())
+	caps.SetFsSharingHotplugSupport()
+	assert.True(caps.IsFsSharingHotplugSupported())
+}
+
+func TestPmemHotplugCapability(t *testing.T) {
+	assert := assert.New(t)
+	var caps Capabilities
+
+	assert.False(caps.IsPmemHotplugSupported())
+	caps.SetPmemHotplugSupport()
+	assert.True(caps.IsPmemHotplugSupported())
+}
@@ -122,6 +122,10 @@ type Volume struct {
 
 	// HostPath is the host filesystem path for this volume.
 	HostPath string
+
+	// SharedFS is the filesystem sharing mechanism of the volume, the one
+	// of the hypervisor when empty.
+	SharedFS string
 }
 
 // Volumes is a Volume list.
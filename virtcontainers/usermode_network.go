@@ -0,0 +1,246 @@
+// Copyright (c) 2020 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// userModeNetworkSocket is the name of the API socket of the user-mode
+	// network stack, in the sandbox runtime directory.
+	userModeNetworkSocket = "slirp4netns.sock"
+
+	// userModeNetworkIface is the name of the tap interface the user-mode
+	// network stack creates inside the network namespace.
+	userModeNetworkIface = "eth0"
+
+	// userModeNetworkMTU is the MTU of the tap interface, large enough to
+	// avoid the user-mode stack being the bottleneck.
+	userModeNetworkMTU = 65520
+
+	// userModeNetworkTimeout is how long the user-mode network stack takes
+	// to configure the network namespace, or to answer an API request.
+	userModeNetworkTimeout = 10 * time.Second
+)
+
+// UserModeNetworkConfig is the structure providing specific configuration
+// for the user-mode network stack.
+type UserModeNetworkConfig struct {
+	Path         string
+	PortForwards []PortForward
+}
+
+// PortForward describes a host port forwarded by the user-mode network
+// stack to the sandbox.
+type PortForward struct {
+	Proto     string
+	HostAddr  string
+	HostPort  uint16
+	GuestPort uint16
+}
+
+// userModeNetworkParams is the structure providing specific parameters
+// needed for the execution of the user-mode network stack binary.
+type userModeNetworkParams struct {
+	path      string
+	netNSPath string
+	apiSocket string
+
+	// userNSPath is the user namespace owning the network namespace,
+	// which the stack joins to configure the latter when rootless.
+	userNSPath string
+}
+
+// userModeNetworkRequest is a request sent through the API socket of the
+// user-mode network stack.
+type userModeNetworkRequest struct {
+	Execute   string      `json:"execute"`
+	Arguments interface{} `json:"arguments,omitempty"`
+}
+
+// userModeNetworkResponse is the response of the user-mode network stack
+// to an API request.
+type userModeNetworkResponse struct {
+	Error *struct {
+		Desc string `json:"desc"`
+	} `json:"error,omitempty"`
+}
+
+type hostFwdArguments struct {
+	Proto     string `json:"proto"`
+	HostAddr  string `json:"host_addr,omitempty"`
+	HostPort  uint16 `json:"host_port"`
+	GuestPort uint16 `json:"guest_port"`
+}
+
+func userModeNetworkLogger() *logrus.Entry {
+	return virtLog.WithField("subsystem", "usermode-network")
+}
+
+func prepareUserModeNetworkParams(params userModeNetworkParams) ([]string, error) {
+	if params.path == "" {
+		return []string{}, fmt.Errorf("User-mode network path is empty")
+	}
+	if params.netNSPath == "" {
+		return []string{}, fmt.Errorf("User-mode network namespace path is empty")
+	}
+
+	// The file descriptor 3 is the first one of the extra files, through
+	// which the process notifies the network namespace is configured.
+	args := []string{params.path,
+		"--configure",
+		"--mtu", fmt.Sprintf("%d", userModeNetworkMTU),
+		"--disable-host-loopback",
+		"--ready-fd", "3",
+		"--netns-type", "path",
+	}
+
+	if params.userNSPath != "" {
+		args = append(args, []string{"--userns-path", params.userNSPath}...)
+	}
+
+	if params.apiSocket != "" {
+		args = append(args, []string{"--api-socket", params.apiSocket}...)
+	}
+
+	args = append(args, params.netNSPath, userModeNetworkIface)
+
+	return args, nil
+}
+
+// startUserModeNetwork starts the user-mode network stack, and waits for it
+// to create and configure its tap interface inside the network namespace.
+func startUserModeNetwork(params userModeNetworkParams) (int, error) {
+	args, err := prepareUserModeNetworkParams(params)
+	if err != nil {
+		return -1, err
+	}
+
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		return -1, err
+	}
+	defer readyR.Close()
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.ExtraFiles = []*os.File{readyW}
+	err = cmd.Start()
+	readyW.Close()
+	if err != nil {
+		return -1, err
+	}
+
+	readyCh := make(chan error, 1)
+	go func() {
+		b := make([]byte, 1)
+		if _, err := readyR.Read(b); err == io.EOF {
+			readyCh <- fmt.Errorf("User-mode network stack exited before being ready")
+		} else {
+			readyCh <- err
+		}
+	}()
+
+	select {
+	case err = <-readyCh:
+	case <-time.After(userModeNetworkTimeout):
+		err = fmt.Errorf("Timeout waiting for the user-mode network stack")
+	}
+
+	if err != nil {
+		stopUserModeNetwork(cmd.Process.Pid)
+		return -1, err
+	}
+
+	return cmd.Process.Pid, nil
+}
+
+func stopUserModeNetwork(pid int) error {
+	if pid <= 0 {
+		return nil
+	}
+
+	sig := syscall.SIGKILL
+
+	userModeNetworkLogger().WithFields(
+		logrus.Fields{
+			"usermode-network-pid":    pid,
+			"usermode-network-signal": sig,
+		}).Info("Stopping user-mode network stack")
+
+	if err := syscall.Kill(pid, sig); err != nil && err != syscall.ESRCH {
+		return err
+	}
+
+	return nil
+}
+
+// userModeNetworkAPI sends a request through the API socket of the user-mode
+// network stack, which handles a single request per connection.
+func userModeNetworkAPI(socket string, req userModeNetworkRequest) error {
+	conn, err := net.DialTimeout("unix", socket, userModeNetworkTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(userModeNetworkTimeout)); err != nil {
+		return err
+	}
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return err
+	}
+
+	var resp userModeNetworkResponse
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return err
+	}
+
+	if resp.Error != nil {
+		return fmt.Errorf("%s request failed: %s", req.Execute, resp.Error.Desc)
+	}
+
+	return nil
+}
+
+// addPortForwards forwards the host ports to the sandbox through the API
+// socket of the user-mode network stack.
+func addPortForwards(socket string, forwards []PortForward) error {
+	for _, fwd := range forwards {
+		proto := fwd.Proto
+		if proto == "" {
+			proto = "tcp"
+		}
+
+		req := userModeNetworkRequest{
+			Execute: "add_hostfwd",
+			Arguments: hostFwdArguments{
+				Proto:     proto,
+				HostAddr:  fwd.HostAddr,
+				HostPort:  fwd.HostPort,
+				GuestPort: fwd.GuestPort,
+			},
+		}
+
+		if err := userModeNetworkAPI(socket, req); err != nil {
+			return err
+		}
+
+		userModeNetworkLogger().WithField("port-forward", fmt.Sprintf("%+v", fwd)).Info("Port forwarded")
+	}
+
+	return nil
+}
@@ -0,0 +1,141 @@
+// Copyright (c) 2020 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testUserModeNetworkPath = "/foo/bar/slirp4netns"
+	testNetNSPath           = "/foo/bar/netns"
+)
+
+func TestPrepareUserModeNetworkParams(t *testing.T) {
+	assert := assert.New(t)
+
+	// Empty user-mode network path
+	params := userModeNetworkParams{}
+	got, err := prepareUserModeNetworkParams(params)
+	assert.Error(err)
+	assert.Equal([]string{}, got)
+
+	// Empty network namespace path
+	params.path = testUserModeNetworkPath
+	got, err = prepareUserModeNetworkParams(params)
+	assert.Error(err)
+	assert.Equal([]string{}, got)
+
+	// Successful case
+	params.netNSPath = testNetNSPath
+	params.apiSocket = "/foo/bar/slirp4netns.sock"
+	got, err = prepareUserModeNetworkParams(params)
+	assert.NoError(err)
+	expected := []string{testUserModeNetworkPath,
+		"--configure",
+		"--mtu", "65520",
+		"--disable-host-loopback",
+		"--ready-fd", "3",
+		"--netns-type", "path",
+		"--api-socket", params.apiSocket,
+		testNetNSPath, userModeNetworkIface}
+	assert.Equal(expected, got)
+
+	// Rootless case, joining the user namespace owning the network namespace
+	params.userNSPath = "/proc/1234/ns/user"
+	got, err = prepareUserModeNetworkParams(params)
+	assert.NoError(err)
+	expected = []string{testUserModeNetworkPath,
+		"--configure",
+		"--mtu", "65520",
+		"--disable-host-loopback",
+		"--ready-fd", "3",
+		"--netns-type", "path",
+		"--userns-path", params.userNSPath,
+		"--api-socket", params.apiSocket,
+		testNetNSPath, userModeNetworkIface}
+	assert.Equal(expected, got)
+}
+
+func TestStartUserModeNetworkFailure(t *testing.T) {
+	pid, err := startUserModeNetwork(userModeNetworkParams{
+		path:      testUserModeNetworkPath,
+		netNSPath: testNetNSPath,
+	})
+	assert.Error(t, err)
+	assert.Equal(t, -1, pid)
+}
+
+func TestStopUserModeNetwork(t *testing.T) {
+	err := stopUserModeNetwork(-1)
+	assert.NoError(t, err)
+}
+
+func TestAddPortForwards(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "usermode-network")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, userModeNetworkSocket)
+	listener, err := net.Listen("unix", socket)
+	assert.NoError(err)
+	defer listener.Close()
+
+	reqCh := make(chan map[string]interface{}, 2)
+	go func() {
+		for i := 0; i < 2; i++ {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			var req map[string]interface{}
+			json.NewDecoder(conn).Decode(&req)
+			reqCh <- req
+
+			// Fail to forward the second port.
+			if i == 0 {
+				conn.Write([]byte(`{"return":{"id":1}}`))
+			} else {
+				conn.Write([]byte(`{"error":{"desc":"bad request"}}`))
+			}
+			conn.Close()
+		}
+	}()
+
+	forwards := []PortForward{
+		{HostPort: 8080, GuestPort: 80},
+		{Proto: "udp", HostAddr: "127.0.0.1", HostPort: 5353, GuestPort: 53},
+	}
+
+	err = addPortForwards(socket, forwards)
+	assert.Error(err)
+
+	req := <-reqCh
+	assert.Equal("add_hostfwd", req["execute"])
+	assert.Equal(map[string]interface{}{
+		"proto":      "tcp",
+		"host_port":  float64(8080),
+		"guest_port": float64(80),
+	}, req["arguments"])
+
+	req = <-reqCh
+	assert.Equal(map[string]interface{}{
+		"proto":      "udp",
+		"host_addr":  "127.0.0.1",
+		"host_port":  float64(5353),
+		"guest_port": float64(53),
+	}, req["arguments"])
+}
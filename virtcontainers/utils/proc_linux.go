@@ -6,12 +6,15 @@
 package utils
 
 import (
+	"fmt"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 	"strconv"
 
 	"github.com/pkg/errors"
 	"github.com/prometheus/procfs"
+	"golang.org/x/sys/unix"
 )
 
 const taskPath = "task"
@@ -55,3 +58,25 @@ func (p *Proc) Children() ([]*Proc, error) {
 
 	return children, nil
 }
+
+// OpenPidfd returns a pidfd referring to the process pid. Unlike the pid,
+// the pidfd can not refer to another process once the process quits.
+func OpenPidfd(pid int) (*os.File, error) {
+	fd, _, errno := unix.Syscall(unix.SYS_PIDFD_OPEN, uintptr(pid), 0, 0)
+	if errno != 0 {
+		return nil, errors.Wrapf(errno, "Fail to open pidfd of pid %v", pid)
+	}
+
+	return os.NewFile(fd, fmt.Sprintf("pidfd:%d", pid)), nil
+}
+
+// PidfdExited tells whether the process referred to by pidfd has quit.
+func PidfdExited(pidfd *os.File) (bool, error) {
+	fds := []unix.PollFd{{Fd: int32(pidfd.Fd()), Events: unix.POLLIN}}
+	n, err := unix.Poll(fds, 0)
+	if err != nil {
+		return false, errors.Wrapf(err, "Fail to poll %v", pidfd.Name())
+	}
+
+	return n > 0, nil
+}
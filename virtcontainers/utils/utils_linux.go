@@ -99,45 +99,56 @@ const (
 	procDeviceIndex = iota
 	procPathIndex
 	procTypeIndex
+	procOptionsIndex
 )
 
 // GetDevicePathAndFsType gets the device for the mount point and the file system type
 // of the mount.
 func GetDevicePathAndFsType(mountPoint string) (devicePath, fsType string, err error) {
-	if mountPoint == "" {
-		err = fmt.Errorf("Mount point cannot be empty")
+	fields, err := getProcMountFields(mountPoint)
+	if err != nil {
 		return
 	}
 
-	var file *os.File
+	return fields[procDeviceIndex], fields[procTypeIndex], nil
+}
 
-	file, err = os.Open(procMountsFile)
+// GetMountOptions gets the options of the file system mounted on the mount point.
+func GetMountOptions(mountPoint string) ([]string, error) {
+	fields, err := getProcMountFields(mountPoint)
 	if err != nil {
-		return
+		return nil, err
+	}
+
+	return strings.Split(fields[procOptionsIndex], ","), nil
+}
+
+func getProcMountFields(mountPoint string) ([]string, error) {
+	if mountPoint == "" {
+		return nil, fmt.Errorf("Mount point cannot be empty")
+	}
+
+	file, err := os.Open(procMountsFile)
+	if err != nil {
+		return nil, err
 	}
 
 	defer file.Close()
 
 	reader := bufio.NewReader(file)
 	for {
-		var line string
-
-		line, err = reader.ReadString('\n')
+		line, err := reader.ReadString('\n')
 		if err == io.EOF {
-			err = fmt.Errorf("Mount %s not found", mountPoint)
-			return
+			return nil, fmt.Errorf("Mount %s not found", mountPoint)
 		}
 
 		fields := strings.Fields(line)
 		if len(fields) != fieldsPerLine {
-			err = fmt.Errorf("Incorrect no of fields (expected %d, got %d)) :%s", fieldsPerLine, len(fields), line)
-			return
+			return nil, fmt.Errorf("Incorrect no of fields (expected %d, got %d)) :%s", fieldsPerLine, len(fields), line)
 		}
 
 		if mountPoint == fields[procPathIndex] {
-			devicePath = fields[procDeviceIndex]
-			fsType = fields[procTypeIndex]
-			return
+			return fields, nil
 		}
 	}
 }
@@ -7,6 +7,7 @@ package utils
 
 import (
 	"errors"
+	"os/exec"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -49,3 +50,41 @@ func TestGetDevicePathAndFsTypeSuccessful(t *testing.T) {
 	assert.Equal(path, "proc")
 	assert.Equal(fstype, "proc")
 }
+
+func TestGetMountOptions(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := GetMountOptions("")
+	assert.Error(err)
+
+	options, err := GetMountOptions("/proc")
+	assert.NoError(err)
+	assert.Contains(options, "rw")
+}
+
+func TestPidfd(t *testing.T) {
+	assert := assert.New(t)
+
+	cmd := exec.Command("sleep", "60")
+	assert.NoError(cmd.Start())
+	defer cmd.Process.Kill()
+
+	pidfd, err := OpenPidfd(cmd.Process.Pid)
+	assert.NoError(err)
+	defer pidfd.Close()
+
+	exited, err := PidfdExited(pidfd)
+	assert.NoError(err)
+	assert.False(exited)
+
+	assert.NoError(cmd.Process.Kill())
+	cmd.Wait()
+
+	exited, err = PidfdExited(pidfd)
+	assert.NoError(err)
+	assert.True(exited)
+
+	// The process is gone.
+	_, err = OpenPidfd(cmd.Process.Pid)
+	assert.Error(err)
+}
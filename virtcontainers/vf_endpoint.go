@@ -0,0 +1,412 @@
+// Copyright (c) 2020 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kata-containers/runtime/virtcontainers/device/config"
+	"github.com/kata-containers/runtime/virtcontainers/device/drivers"
+	persistapi "github.com/kata-containers/runtime/virtcontainers/persist/api"
+	"github.com/kata-containers/runtime/virtcontainers/pkg/cgroups"
+	vcTypes "github.com/kata-containers/runtime/virtcontainers/pkg/types"
+	"github.com/kata-containers/runtime/virtcontainers/utils"
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+)
+
+// vfHostNetNSPath is the network namespace of the physical functions, while
+// the virtual function endpoints are handled from the sandbox one.
+var vfHostNetNSPath = "/proc/1/ns/net"
+
+// VFEndpoint gathers a SR-IOV virtual function network interface, passed
+// through to the VM with VFIO, and the configuration of the virtual function
+// on its physical function.
+type VFEndpoint struct {
+	IfaceName          string
+	HardAddr           string
+	EndpointProperties NetworkInfo
+	EndpointType       EndpointType
+	BDF                string
+	Driver             string
+	VendorDeviceID     string
+	PCIPath            vcTypes.PciPath
+
+	// PFName is the name of the physical function network interface,
+	// in the host network namespace.
+	PFName string
+	// VFIndex is the index of the virtual function on its physical function.
+	VFIndex int
+	// VLAN is the VLAN the traffic of the virtual function is tagged with
+	// by the physical function, 0 if none.
+	VLAN int
+	// SpoofCheck is the spoof checking setting of the virtual function
+	// before the endpoint enabled it.
+	SpoofCheck bool
+}
+
+// Properties returns the properties of the virtual function interface.
+func (endpoint *VFEndpoint) Properties() NetworkInfo {
+	return endpoint.EndpointProperties
+}
+
+// HardwareAddr returns the mac address of the virtual function interface.
+func (endpoint *VFEndpoint) HardwareAddr() string {
+	return endpoint.HardAddr
+}
+
+// Name returns name of the virtual function interface.
+func (endpoint *VFEndpoint) Name() string {
+	return endpoint.IfaceName
+}
+
+// Type indentifies the endpoint as a virtual function endpoint.
+func (endpoint *VFEndpoint) Type() EndpointType {
+	return endpoint.EndpointType
+}
+
+// PciPath returns the PCI path of the endpoint.
+func (endpoint *VFEndpoint) PciPath() vcTypes.PciPath {
+	return endpoint.PCIPath
+}
+
+// SetPciPath sets the PCI path of the endpoint.
+func (endpoint *VFEndpoint) SetPciPath(pciPath vcTypes.PciPath) {
+	endpoint.PCIPath = pciPath
+}
+
+// SetProperties sets the properties of the virtual function endpoint.
+func (endpoint *VFEndpoint) SetProperties(properties NetworkInfo) {
+	endpoint.EndpointProperties = properties
+}
+
+// NetworkPair returns the network pair of the endpoint.
+func (endpoint *VFEndpoint) NetworkPair() *NetworkInterfacePair {
+	return nil
+}
+
+// Attach for virtual function endpoint configures the virtual function on
+// its physical function, binds it to vfio-pci and adds device to the
+// hypervisor with vfio-passthrough.
+func (endpoint *VFEndpoint) Attach(s *Sandbox) error {
+	vfioPath, err := endpoint.bindToVFIO()
+	if err != nil {
+		return err
+	}
+
+	c, err := cgroups.DeviceToCgroupDevice(vfioPath)
+	if err != nil {
+		return err
+	}
+
+	d := config.DeviceInfo{
+		ContainerPath: c.Path,
+		DevType:       string(c.Type),
+		Major:         c.Major,
+		Minor:         c.Minor,
+		ColdPlug:      true,
+	}
+
+	_, err = s.AddDevice(d)
+	return err
+}
+
+// Detach for virtual function endpoint binds the virtual function back to its
+// host driver, and moves its interface back to the network namespace it was
+// found in, so that the network plugin can release it.
+func (endpoint *VFEndpoint) Detach(netNsCreated bool, netNsPath string) error {
+	if err := endpoint.bindToHost(); err != nil {
+		return err
+	}
+
+	// The interface is back in the host network namespace when the one
+	// created by virtcontainers is deleted.
+	if netNsCreated || netNsPath == "" {
+		return nil
+	}
+
+	return endpoint.moveToNetNS(netNsPath)
+}
+
+// HotAttach for virtual function endpoint configures the virtual function on
+// its physical function, binds it to vfio-pci and hotplugs it to the VM.
+func (endpoint *VFEndpoint) HotAttach(h hypervisor) error {
+	if _, err := endpoint.bindToVFIO(); err != nil {
+		return err
+	}
+
+	if _, err := h.hotplugAddDevice(endpoint.vfioDev(), vfioDev); err != nil {
+		networkLogger().WithError(err).Error("Error attach virtual function ep")
+		if err := endpoint.bindToHost(); err != nil {
+			networkLogger().WithError(err).Warn("Error binding back virtual function to host")
+		}
+		return err
+	}
+
+	return nil
+}
+
+// HotDetach for virtual function endpoint hot unplugs it from the VM, and
+// binds it back to its host driver.
+func (endpoint *VFEndpoint) HotDetach(h hypervisor, netNsCreated bool, netNsPath string) error {
+	if _, err := h.hotplugRemoveDevice(endpoint.vfioDev(), vfioDev); err != nil {
+		networkLogger().WithError(err).Error("Error detach virtual function ep")
+		return err
+	}
+
+	return endpoint.Detach(netNsCreated, netNsPath)
+}
+
+func (endpoint *VFEndpoint) vfioDev() *config.VFIODev {
+	return &config.VFIODev{
+		ID:       utils.MakeNameID("vfio", endpoint.BDF, maxDevIDSize),
+		Type:     config.VFIODeviceNormalType,
+		BDF:      strings.SplitN(endpoint.BDF, ":", 2)[1],
+		SysfsDev: filepath.Join(config.SysBusPciDevicesPath, endpoint.BDF),
+	}
+}
+
+// bindToVFIO sets the MAC address and VLAN of the virtual function, and
+// enables spoof checking on it, so that the guest cannot use another MAC
+// address or VLAN than the ones given by the network plugin. These settings
+// are held by the physical function, which outlives the host driver of the
+// virtual function.
+func (endpoint *VFEndpoint) bindToVFIO() (string, error) {
+	if err := endpoint.configurePF(true); err != nil {
+		return "", err
+	}
+
+	return drivers.BindDevicetoVFIO(endpoint.BDF, endpoint.Driver, endpoint.VendorDeviceID)
+}
+
+// bindToHost binds the virtual function back to its host driver, and
+// restores its spoof checking setting.
+func (endpoint *VFEndpoint) bindToHost() error {
+	if err := drivers.BindDevicetoHost(endpoint.BDF, endpoint.Driver, endpoint.VendorDeviceID); err != nil {
+		return err
+	}
+
+	return endpoint.configurePF(endpoint.SpoofCheck)
+}
+
+func (endpoint *VFEndpoint) configurePF(spoofCheck bool) error {
+	return doHostNetlink(func(netHandle *netlink.Handle) error {
+		pfLink, err := netHandle.LinkByName(endpoint.PFName)
+		if err != nil {
+			return fmt.Errorf("Could not get physical function %s: %s", endpoint.PFName, err)
+		}
+
+		hardAddr, err := net.ParseMAC(endpoint.HardAddr)
+		if err != nil {
+			return err
+		}
+
+		if err := netHandle.LinkSetVfHardwareAddr(pfLink, endpoint.VFIndex, hardAddr); err != nil {
+			return fmt.Errorf("Could not set MAC address %s of VF %d of %s: %s", endpoint.HardAddr, endpoint.VFIndex, endpoint.PFName, err)
+		}
+
+		if err := netHandle.LinkSetVfVlan(pfLink, endpoint.VFIndex, endpoint.VLAN); err != nil {
+			return fmt.Errorf("Could not set VLAN %d of VF %d of %s: %s", endpoint.VLAN, endpoint.VFIndex, endpoint.PFName, err)
+		}
+
+		if err := netHandle.LinkSetVfSpoofchk(pfLink, endpoint.VFIndex, spoofCheck); err != nil {
+			return fmt.Errorf("Could not set spoof checking of VF %d of %s: %s", endpoint.VFIndex, endpoint.PFName, err)
+		}
+
+		return nil
+	})
+}
+
+// moveToNetNS moves the interface the host driver created for the virtual
+// function to the network namespace "netNsPath", with its original name.
+func (endpoint *VFEndpoint) moveToNetNS(netNsPath string) error {
+	netdevs, err := ioutil.ReadDir(filepath.Join(sysPCIDevicesPath, endpoint.BDF, "net"))
+	if err != nil {
+		return err
+	}
+	if len(netdevs) == 0 {
+		return fmt.Errorf("No network interface found for VF %s", endpoint.BDF)
+	}
+	name := netdevs[0].Name()
+
+	nsHandle, err := netns.GetFromPath(netNsPath)
+	if err != nil {
+		return err
+	}
+	defer nsHandle.Close()
+
+	if err := doHostNetlink(func(netHandle *netlink.Handle) error {
+		link, err := netHandle.LinkByName(name)
+		if err != nil {
+			return err
+		}
+		return netHandle.LinkSetNsFd(link, int(nsHandle))
+	}); err != nil {
+		return fmt.Errorf("Could not move VF interface %s to %s: %s", name, netNsPath, err)
+	}
+
+	netHandle, err := netlink.NewHandleAt(nsHandle)
+	if err != nil {
+		return err
+	}
+	defer netHandle.Delete()
+
+	link, err := netHandle.LinkByName(name)
+	if err != nil {
+		return err
+	}
+
+	if name == endpoint.IfaceName {
+		return nil
+	}
+
+	return netHandle.LinkSetName(link, endpoint.IfaceName)
+}
+
+// doHostNetlink runs "cb" with a netlink handle in the host network namespace.
+func doHostNetlink(cb func(*netlink.Handle) error) error {
+	nsHandle, err := netns.GetFromPath(vfHostNetNSPath)
+	if err != nil {
+		return err
+	}
+	defer nsHandle.Close()
+
+	netHandle, err := netlink.NewHandleAt(nsHandle)
+	if err != nil {
+		return err
+	}
+	defer netHandle.Delete()
+
+	return cb(netHandle)
+}
+
+// isVirtualFunction checks if the PCI device "bdf" is a SR-IOV virtual
+// function, which has a link to its physical function.
+func isVirtualFunction(bdf string) bool {
+	_, err := os.Lstat(filepath.Join(sysPCIDevicesPath, bdf, "physfn"))
+	return err == nil
+}
+
+// getPhysicalFunction returns the network interface name of the physical
+// function of the virtual function "bdf", and the index of the latter.
+func getPhysicalFunction(bdf string) (string, int, error) {
+	pfPath := filepath.Join(sysPCIDevicesPath, bdf, "physfn")
+
+	netdevs, err := ioutil.ReadDir(filepath.Join(pfPath, "net"))
+	if err != nil {
+		return "", -1, err
+	}
+	if len(netdevs) == 0 {
+		return "", -1, fmt.Errorf("No network interface found for the physical function of %s", bdf)
+	}
+
+	virtfns, err := filepath.Glob(filepath.Join(pfPath, "virtfn*"))
+	if err != nil {
+		return "", -1, err
+	}
+
+	for _, virtfn := range virtfns {
+		link, err := os.Readlink(virtfn)
+		if err != nil {
+			return "", -1, err
+		}
+
+		if filepath.Base(link) != bdf {
+			continue
+		}
+
+		index, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(virtfn), "virtfn"))
+		if err != nil {
+			return "", -1, err
+		}
+
+		return netdevs[0].Name(), index, nil
+	}
+
+	return "", -1, fmt.Errorf("Could not find %s among the virtual functions of %s", bdf, netdevs[0].Name())
+}
+
+// createVFEndpoint creates the endpoint of a virtual function, from the
+// physical endpoint of its interface.
+func createVFEndpoint(physical *PhysicalEndpoint) (*VFEndpoint, error) {
+	pfName, index, err := getPhysicalFunction(physical.BDF)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := &VFEndpoint{
+		IfaceName:      physical.IfaceName,
+		HardAddr:       physical.HardAddr,
+		EndpointType:   VFEndpointType,
+		BDF:            physical.BDF,
+		Driver:         physical.Driver,
+		VendorDeviceID: physical.VendorDeviceID,
+		PFName:         pfName,
+		VFIndex:        index,
+	}
+
+	// Keep the VLAN and spoof checking set by the network plugin.
+	if err := doHostNetlink(func(netHandle *netlink.Handle) error {
+		pfLink, err := netHandle.LinkByName(pfName)
+		if err != nil {
+			return err
+		}
+
+		for _, vf := range pfLink.Attrs().Vfs {
+			if vf.ID == index {
+				endpoint.VLAN = vf.Vlan
+				endpoint.SpoofCheck = vf.Spoofchk
+				break
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("Could not get physical function %s: %s", pfName, err)
+	}
+
+	return endpoint, nil
+}
+
+func (endpoint *VFEndpoint) save() persistapi.NetworkEndpoint {
+	return persistapi.NetworkEndpoint{
+		Type: string(endpoint.Type()),
+
+		VF: &persistapi.VFEndpoint{
+			IfaceName:      endpoint.IfaceName,
+			HardAddr:       endpoint.HardAddr,
+			BDF:            endpoint.BDF,
+			Driver:         endpoint.Driver,
+			VendorDeviceID: endpoint.VendorDeviceID,
+			PFName:         endpoint.PFName,
+			VFIndex:        endpoint.VFIndex,
+			VLAN:           endpoint.VLAN,
+			SpoofCheck:     endpoint.SpoofCheck,
+		},
+	}
+}
+
+func (endpoint *VFEndpoint) load(s persistapi.NetworkEndpoint) {
+	endpoint.EndpointType = VFEndpointType
+
+	if s.VF != nil {
+		endpoint.IfaceName = s.VF.IfaceName
+		endpoint.HardAddr = s.VF.HardAddr
+		endpoint.BDF = s.VF.BDF
+		endpoint.Driver = s.VF.Driver
+		endpoint.VendorDeviceID = s.VF.VendorDeviceID
+		endpoint.PFName = s.VF.PFName
+		endpoint.VFIndex = s.VF.VFIndex
+		endpoint.VLAN = s.VF.VLAN
+		endpoint.SpoofCheck = s.VF.SpoofCheck
+	}
+}
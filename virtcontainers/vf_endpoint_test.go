@@ -0,0 +1,129 @@
+// Copyright (c) 2020 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kata-containers/runtime/virtcontainers/device/config"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testPFBDF = "0000:3b:00.0"
+	testVFBDF = "0000:3b:02.1"
+)
+
+// createTestSRIOVSysfs creates the sysfs entries of a physical function
+// named "ens1f0", with the test virtual function as its second one.
+func createTestSRIOVSysfs(t *testing.T, dir string) {
+	assert := assert.New(t)
+
+	pfPath := filepath.Join(dir, testPFBDF)
+	assert.NoError(os.MkdirAll(filepath.Join(pfPath, "net", "ens1f0"), 0755))
+
+	for bdf, virtfn := range map[string]string{"0000:3b:02.0": "virtfn0", testVFBDF: "virtfn1"} {
+		vfPath := filepath.Join(dir, bdf)
+		assert.NoError(os.MkdirAll(vfPath, 0755))
+		assert.NoError(os.Symlink(vfPath, filepath.Join(pfPath, virtfn)))
+		assert.NoError(os.Symlink(pfPath, filepath.Join(vfPath, "physfn")))
+	}
+}
+
+func TestIsVirtualFunction(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "sriov")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	savedSysPCIDevicesPath := sysPCIDevicesPath
+	sysPCIDevicesPath = dir
+	defer func() {
+		sysPCIDevicesPath = savedSysPCIDevicesPath
+	}()
+
+	createTestSRIOVSysfs(t, dir)
+
+	assert.True(isVirtualFunction(testVFBDF))
+	assert.False(isVirtualFunction(testPFBDF))
+	assert.False(isVirtualFunction("0000:00:00.0"))
+}
+
+func TestGetPhysicalFunction(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "sriov")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	savedSysPCIDevicesPath := sysPCIDevicesPath
+	sysPCIDevicesPath = dir
+	defer func() {
+		sysPCIDevicesPath = savedSysPCIDevicesPath
+	}()
+
+	createTestSRIOVSysfs(t, dir)
+
+	pfName, index, err := getPhysicalFunction(testVFBDF)
+	assert.NoError(err)
+	assert.Equal("ens1f0", pfName)
+	assert.Equal(1, index)
+
+	_, _, err = getPhysicalFunction(testPFBDF)
+	assert.Error(err)
+
+	// A virtual function which is not listed by its physical function.
+	orphanPath := filepath.Join(dir, "0000:3b:02.2")
+	assert.NoError(os.MkdirAll(orphanPath, 0755))
+	assert.NoError(os.Symlink(filepath.Join(dir, testPFBDF), filepath.Join(orphanPath, "physfn")))
+
+	_, _, err = getPhysicalFunction("0000:3b:02.2")
+	assert.Error(err)
+}
+
+func TestVFEndpointVfioDev(t *testing.T) {
+	assert := assert.New(t)
+
+	endpoint := &VFEndpoint{
+		IfaceName: "net1",
+		BDF:       testVFBDF,
+	}
+
+	dev := endpoint.vfioDev()
+	assert.Equal(config.VFIODeviceNormalType, dev.Type)
+	assert.Equal("3b:02.1", dev.BDF)
+	assert.Equal(filepath.Join(config.SysBusPciDevicesPath, testVFBDF), dev.SysfsDev)
+	assert.NotEmpty(dev.ID)
+	assert.True(len(dev.ID) <= maxDevIDSize)
+}
+
+func TestVFEndpointSaveLoad(t *testing.T) {
+	assert := assert.New(t)
+
+	endpoint := &VFEndpoint{
+		IfaceName:      "net1",
+		HardAddr:       "02:00:ca:fe:00:04",
+		EndpointType:   VFEndpointType,
+		BDF:            testVFBDF,
+		Driver:         "iavf",
+		VendorDeviceID: "8086 154c",
+		PFName:         "ens1f0",
+		VFIndex:        1,
+		VLAN:           100,
+		SpoofCheck:     true,
+	}
+
+	saved := endpoint.save()
+	assert.Equal(string(VFEndpointType), saved.Type)
+
+	loaded := &VFEndpoint{}
+	loaded.load(saved)
+	assert.Equal(endpoint, loaded)
+}
@@ -126,12 +126,19 @@ func (v *virtiofsd) Start(ctx context.Context) (int, error) {
 		v.wait = waitVirtiofsReady
 	}
 
+	// Release the resources of the process when it quits, for the
+	// sandbox monitor to notice it.
+	go cmd.Process.Wait()
+
+	v.PID = cmd.Process.Pid
+	pid = v.PID
+
 	return pid, socketFD.Close()
 }
 
 func (v *virtiofsd) Stop() error {
 	if err := v.kill(); err != nil {
-		return nil
+		v.Logger().WithError(err).Warn("killing virtiofsd failed")
 	}
 
 	if v.socketPath == "" {
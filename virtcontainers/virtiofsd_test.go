@@ -73,11 +73,15 @@ func TestVirtiofsdStart(t *testing.T) {
 				},
 			}
 			var ctx context.Context
-			_, err := v.Start(ctx)
+			pid, err := v.Start(ctx)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("virtiofsd.Start() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
+			if err == nil {
+				assert.NotZero(pid)
+				assert.Equal(pid, v.PID)
+			}
 		})
 	}
 }
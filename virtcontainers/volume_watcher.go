@@ -0,0 +1,503 @@
+// Copyright (c) 2020 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+// maxCopiedVolumeSize is the maximum size of a directory volume copied into
+// the guest when filesystem sharing is not supported. Copying directories is
+// meant for small volumes like Kubernetes ConfigMaps and Secrets, which are
+// limited to 1MiB. Single files are copied whatever their size.
+const maxCopiedVolumeSize = 8 << 20
+
+const volumeWatchMask = unix.IN_CREATE | unix.IN_DELETE | unix.IN_MODIFY | unix.IN_CLOSE_WRITE |
+	unix.IN_MOVED_FROM | unix.IN_MOVED_TO | unix.IN_ATTRIB
+
+// volumeFile is a regular file of a volume copied into the guest.
+type volumeFile struct {
+	hostPath  string
+	guestPath string
+	size      int64
+}
+
+// listVolume returns the regular files of a volume, and the host directories
+// to watch to be notified of their updates. Symbolic links are followed, and
+// the entries whose name starts with ".." are skipped: Kubernetes updates
+// ConfigMap and Secret volumes atomically by swapping a "..data" symbolic
+// link to a new timestamped directory, the files of the volume being
+// symbolic links through "..data".
+func listVolume(source, guestPath string) ([]volumeFile, []string, error) {
+	info, err := os.Stat(source)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !info.IsDir() {
+		files := []volumeFile{{hostPath: source, guestPath: guestPath, size: info.Size()}}
+		return files, []string{filepath.Dir(source)}, nil
+	}
+
+	var files []volumeFile
+	var dirs []string
+	if err := walkVolumeDir(source, guestPath, make(map[string]bool), &files, &dirs); err != nil {
+		return nil, nil, err
+	}
+
+	return files, dirs, nil
+}
+
+func walkVolumeDir(hostPath, guestPath string, visited map[string]bool, files *[]volumeFile, dirs *[]string) error {
+	realPath, err := filepath.EvalSymlinks(hostPath)
+	if err != nil {
+		return err
+	}
+
+	// Do not loop on symbolic links to a parent directory.
+	if visited[realPath] {
+		return nil
+	}
+	visited[realPath] = true
+	*dirs = append(*dirs, realPath)
+
+	entries, err := ioutil.ReadDir(hostPath)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), "..") {
+			continue
+		}
+
+		entryHostPath := filepath.Join(hostPath, entry.Name())
+		entryGuestPath := filepath.Join(guestPath, entry.Name())
+
+		info, err := os.Stat(entryHostPath)
+		if os.IsNotExist(err) {
+			// Dangling symbolic link
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case info.IsDir():
+			if err := walkVolumeDir(entryHostPath, entryGuestPath, visited, files, dirs); err != nil {
+				return err
+			}
+		case info.Mode().IsRegular():
+			*files = append(*files, volumeFile{hostPath: entryHostPath, guestPath: entryGuestPath, size: info.Size()})
+		}
+	}
+
+	return nil
+}
+
+// copyVolume copies a file or a directory into the guest, and returns the
+// paths of the files copied in the guest and the host directories to watch
+// to keep the copy up to date.
+func copyVolume(a agent, source, guestPath string) ([]string, []string, error) {
+	info, err := os.Stat(source)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	files, dirs, err := listVolume(source, guestPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var size int64
+	for _, f := range files {
+		size += f.size
+	}
+
+	if info.IsDir() && size > maxCopiedVolumeSize {
+		return nil, nil, fmt.Errorf("Volume %s is too large to be copied into the guest as filesystem sharing is not supported: %d bytes, maximum is %d bytes",
+			source, size, maxCopiedVolumeSize)
+	}
+
+	var copied []string
+	for _, f := range files {
+		if err := a.copyFile(f.hostPath, f.guestPath); err != nil {
+			return nil, nil, err
+		}
+		copied = append(copied, f.guestPath)
+	}
+
+	return copied, dirs, nil
+}
+
+// refreshSharedFile updates a file shared with the guest through a bind
+// mount when its source has been replaced. The kubelet and the container
+// managers rewrite the resolv.conf and hosts files atomically, by renaming a
+// new file over the former one, which the bind mount keeps referring to. Its
+// content is overwritten in place for the guest to see the update.
+func refreshSharedFile(source, mountPath string, readOnly bool) ([]string, error) {
+	dirs := []string{filepath.Dir(source)}
+
+	content, err := ioutil.ReadFile(source)
+	if err != nil {
+		return nil, err
+	}
+
+	shared, err := ioutil.ReadFile(mountPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if bytes.Equal(content, shared) {
+		return dirs, nil
+	}
+
+	// Only the bind mount of the mounts directory is made writable, the
+	// one of the directory shared with the guest stays read-only.
+	if readOnly {
+		if err := remount(context.Background(), unix.MS_BIND, mountPath); err != nil {
+			return nil, err
+		}
+		defer remountRo(context.Background(), mountPath)
+	}
+
+	// The file is not truncated before being written, as the guest would
+	// read it empty in between. The new content is written over the former
+	// one, and the file is truncated to its size afterwards.
+	f, err := os.OpenFile(mountPath, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if _, err := f.WriteAt(content, 0); err != nil {
+		return nil, err
+	}
+
+	if err := f.Truncate(int64(len(content))); err != nil {
+		return nil, err
+	}
+
+	return dirs, nil
+}
+
+// copiedVolume is a volume of a container copied into the guest, or a file
+// shared with the guest through a bind mount which has to be refreshed when
+// its source is replaced.
+type copiedVolume struct {
+	containerID string
+	source      string
+	guestPath   string
+	mountPath   string
+	readOnly    bool
+	files       []string
+	wds         []int
+	removed     bool
+
+	// syncLock is held while the volume is synced, for its removal to
+	// wait for the update in progress.
+	syncLock sync.Mutex
+}
+
+// volumeWatcher keeps the volumes copied into the guest in sync with their
+// source on the host, using inotify.
+type volumeWatcher struct {
+	sync.Mutex
+
+	agent agent
+	fd    int
+	file  *os.File
+
+	// volumes are the volumes watched, indexed by watch descriptor.
+	volumes map[int][]*copiedVolume
+}
+
+func newVolumeWatcher(a agent) (*volumeWatcher, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &volumeWatcher{
+		agent: a,
+		fd:    fd,
+		// As the descriptor is non-blocking, reading from the file goes
+		// through the runtime poller and is interrupted by close().
+		file:    os.NewFile(uintptr(fd), "inotify"),
+		volumes: make(map[int][]*copiedVolume),
+	}
+
+	go w.run()
+
+	return w, nil
+}
+
+func (w *volumeWatcher) Logger() *logrus.Entry {
+	return virtLog.WithField("subsystem", "volume-watcher")
+}
+
+// add starts watching a volume copied into the guest, files being the paths
+// of the files copied in the guest.
+func (w *volumeWatcher) add(containerID, source, guestPath string, files, dirs []string) error {
+	w.Lock()
+	defer w.Unlock()
+
+	v := &copiedVolume{
+		containerID: containerID,
+		source:      source,
+		guestPath:   guestPath,
+		files:       files,
+	}
+
+	if err := w.watch(v, dirs); err != nil {
+		w.unwatch(v)
+		return err
+	}
+
+	return nil
+}
+
+// addSharedFile starts watching a file shared with the guest through a bind
+// mount at mountPath.
+func (w *volumeWatcher) addSharedFile(containerID, source, mountPath string, readOnly bool) error {
+	w.Lock()
+	defer w.Unlock()
+
+	v := &copiedVolume{
+		containerID: containerID,
+		source:      source,
+		mountPath:   mountPath,
+		readOnly:    readOnly,
+	}
+
+	if err := w.watch(v, []string{filepath.Dir(source)}); err != nil {
+		w.unwatch(v)
+		return err
+	}
+
+	return nil
+}
+
+// remove stops watching the volumes of a container, and waits for their
+// updates in progress, so that their mounts can be removed.
+func (w *volumeWatcher) remove(containerID string) {
+	if w == nil {
+		return
+	}
+
+	w.Lock()
+	var volumes []*copiedVolume
+	for _, vs := range w.volumes {
+		for _, v := range vs {
+			if v.containerID == containerID && !v.removed {
+				v.removed = true
+				volumes = append(volumes, v)
+			}
+		}
+	}
+
+	for _, v := range volumes {
+		w.unwatch(v)
+	}
+	w.Unlock()
+
+	for _, v := range volumes {
+		v.syncLock.Lock()
+		v.syncLock.Unlock()
+	}
+}
+
+func (w *volumeWatcher) close() error {
+	if w == nil {
+		return nil
+	}
+
+	return w.file.Close()
+}
+
+// watch adds the watches of a volume, w must be locked.
+func (w *volumeWatcher) watch(v *copiedVolume, dirs []string) error {
+	for _, dir := range dirs {
+		wd, err := unix.InotifyAddWatch(w.fd, dir, volumeWatchMask)
+		if err != nil {
+			return fmt.Errorf("Could not watch %s: %v", dir, err)
+		}
+
+		// Adding a watch on a directory already watched returns the
+		// same descriptor.
+		known := false
+		for _, d := range v.wds {
+			if d == wd {
+				known = true
+				break
+			}
+		}
+		if known {
+			continue
+		}
+
+		v.wds = append(v.wds, wd)
+		w.volumes[wd] = append(w.volumes[wd], v)
+	}
+
+	return nil
+}
+
+// unwatch removes the watches of a volume not shared with other volumes,
+// w must be locked.
+func (w *volumeWatcher) unwatch(v *copiedVolume) {
+	for _, wd := range v.wds {
+		var volumes []*copiedVolume
+		for _, other := range w.volumes[wd] {
+			if other != v {
+				volumes = append(volumes, other)
+			}
+		}
+
+		if len(volumes) > 0 {
+			w.volumes[wd] = volumes
+			continue
+		}
+
+		delete(w.volumes, wd)
+		if _, err := unix.InotifyRmWatch(w.fd, uint32(wd)); err != nil {
+			w.Logger().WithError(err).WithField("source", v.source).Debug("Could not remove watch")
+		}
+	}
+
+	v.wds = nil
+}
+
+// forget drops a watch descriptor removed by the kernel, w must be locked.
+func (w *volumeWatcher) forget(wd int) {
+	for _, v := range w.volumes[wd] {
+		var wds []int
+		for _, d := range v.wds {
+			if d != wd {
+				wds = append(wds, d)
+			}
+		}
+		v.wds = wds
+	}
+
+	delete(w.volumes, wd)
+}
+
+func (w *volumeWatcher) run() {
+	buf := make([]byte, (unix.SizeofInotifyEvent+unix.PathMax)*16)
+
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			// The watcher has been closed.
+			if pathErr, ok := err.(*os.PathError); ok && pathErr.Err == os.ErrClosed {
+				return
+			}
+			w.Logger().WithError(err).Warn("Could not read inotify events")
+			return
+		}
+
+		updated := make(map[*copiedVolume]bool)
+
+		w.Lock()
+		for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+			event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			wd := int(event.Wd)
+
+			for _, v := range w.volumes[wd] {
+				updated[v] = true
+			}
+
+			if event.Mask&unix.IN_IGNORED != 0 {
+				w.forget(wd)
+			}
+
+			offset += unix.SizeofInotifyEvent + int(event.Len)
+		}
+		w.Unlock()
+
+		for v := range updated {
+			w.sync(v)
+		}
+	}
+}
+
+// sync copies a volume into the guest again, or refreshes a shared file,
+// removes the files removed from the volume and watches the directories added
+// to the volume.
+func (w *volumeWatcher) sync(v *copiedVolume) {
+	logger := w.Logger().WithFields(logrus.Fields{
+		"container": v.containerID,
+		"source":    v.source,
+	})
+
+	v.syncLock.Lock()
+	defer v.syncLock.Unlock()
+
+	w.Lock()
+	removed := v.removed
+	w.Unlock()
+
+	if removed {
+		return
+	}
+
+	var files, dirs []string
+	var err error
+	if v.mountPath != "" {
+		dirs, err = refreshSharedFile(v.source, v.mountPath, v.readOnly)
+	} else {
+		files, dirs, err = copyVolume(w.agent, v.source, v.guestPath)
+	}
+	if err != nil {
+		logger.WithError(err).Warn("Could not update volume copied into the guest")
+		return
+	}
+
+	w.Lock()
+	defer w.Unlock()
+
+	if v.removed {
+		return
+	}
+
+	if v.mountPath == "" {
+		copied := make(map[string]bool)
+		for _, f := range files {
+			copied[f] = true
+		}
+
+		// Files still to remove are kept, to retry on the next update.
+		for _, f := range v.files {
+			if copied[f] {
+				continue
+			}
+			if err := w.agent.removeFile(f); err != nil {
+				logger.WithError(err).WithField("file", f).Warn("Could not remove file removed from volume copied into the guest")
+				files = append(files, f)
+			}
+		}
+		v.files = files
+	}
+
+	logger.Debug("Volume copied into the guest updated")
+
+	if err := w.watch(v, dirs); err != nil {
+		logger.WithError(err).Warn("Could not watch volume copied into the guest")
+	}
+}
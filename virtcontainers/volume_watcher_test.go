@@ -0,0 +1,273 @@
+// Copyright (c) 2020 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	ktu "github.com/kata-containers/runtime/pkg/katatestutils"
+	"github.com/stretchr/testify/assert"
+)
+
+// copyFileAgent records the files copied into the guest.
+type copyFileAgent struct {
+	noopAgent
+
+	sync.Mutex
+	copied map[string]string
+}
+
+func (a *copyFileAgent) copyFile(src, dst string) error {
+	data, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+
+	a.Lock()
+	defer a.Unlock()
+
+	a.copied[dst] = string(data)
+	return nil
+}
+
+func (a *copyFileAgent) removeFile(dst string) error {
+	a.Lock()
+	defer a.Unlock()
+
+	delete(a.copied, dst)
+	return nil
+}
+
+func (a *copyFileAgent) get(dst string) string {
+	a.Lock()
+	defer a.Unlock()
+
+	return a.copied[dst]
+}
+
+func (a *copyFileAgent) exists(dst string) bool {
+	a.Lock()
+	defer a.Unlock()
+
+	_, ok := a.copied[dst]
+	return ok
+}
+
+// newConfigMapVolume creates a volume laid out the way Kubernetes lays out
+// ConfigMap and Secret volumes.
+func newConfigMapVolume(t *testing.T, dir string, files map[string]string) {
+	assert := assert.New(t)
+
+	dataDir := filepath.Join(dir, "..2020_01_01_00_00_00.000000000")
+	assert.NoError(os.MkdirAll(dataDir, 0755))
+
+	for name, content := range files {
+		assert.NoError(ioutil.WriteFile(filepath.Join(dataDir, name), []byte(content), 0644))
+		assert.NoError(os.Symlink(filepath.Join("..data", name), filepath.Join(dir, name)))
+	}
+
+	assert.NoError(os.Symlink(filepath.Base(dataDir), filepath.Join(dir, "..data")))
+}
+
+func TestListVolume(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "volume")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	newConfigMapVolume(t, dir, map[string]string{"key": "value"})
+
+	files, dirs, err := listVolume(dir, "/guest/volume")
+	assert.NoError(err)
+	assert.Equal([]volumeFile{{
+		hostPath:  filepath.Join(dir, "key"),
+		guestPath: "/guest/volume/key",
+		size:      int64(len("value")),
+	}}, files)
+
+	realDir, err := filepath.EvalSymlinks(dir)
+	assert.NoError(err)
+	assert.Equal([]string{realDir}, dirs)
+
+	// A single file is watched through its parent directory.
+	files, dirs, err = listVolume(filepath.Join(dir, "key"), "/guest/key")
+	assert.NoError(err)
+	assert.Len(files, 1)
+	assert.Equal("/guest/key", files[0].guestPath)
+	assert.Equal([]string{dir}, dirs)
+
+	_, _, err = listVolume(filepath.Join(dir, "missing"), "/guest/missing")
+	assert.Error(err)
+}
+
+func TestCopyVolumeTooLarge(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "volume")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	f, err := os.Create(filepath.Join(dir, "large"))
+	assert.NoError(err)
+	assert.NoError(f.Truncate(maxCopiedVolumeSize + 1))
+	assert.NoError(f.Close())
+
+	a := &copyFileAgent{copied: make(map[string]string)}
+	_, _, err = copyVolume(a, dir, "/guest/volume")
+	assert.Error(err)
+	assert.Empty(a.copied)
+
+	// A single file is copied whatever its size.
+	files, _, err := copyVolume(a, filepath.Join(dir, "large"), "/guest/large")
+	assert.NoError(err)
+	assert.Equal([]string{"/guest/large"}, files)
+	assert.True(a.exists("/guest/large"))
+}
+
+func TestVolumeWatcher(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "volume")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	newConfigMapVolume(t, dir, map[string]string{"key": "old", "removed": "value"})
+
+	a := &copyFileAgent{copied: make(map[string]string)}
+	files, dirs, err := copyVolume(a, dir, "/guest/volume")
+	assert.NoError(err)
+	assert.Len(files, 2)
+	assert.Equal("old", a.get("/guest/volume/key"))
+	assert.Equal("value", a.get("/guest/volume/removed"))
+
+	w, err := newVolumeWatcher(a)
+	assert.NoError(err)
+	defer w.close()
+
+	assert.NoError(w.add("container", dir, "/guest/volume", files, dirs))
+
+	// Update the volume the way Kubernetes does, by swapping "..data".
+	newDataDir := filepath.Join(dir, "..2020_01_01_00_01_00.000000000")
+	assert.NoError(os.Mkdir(newDataDir, 0755))
+	assert.NoError(ioutil.WriteFile(filepath.Join(newDataDir, "key"), []byte("new"), 0644))
+	assert.NoError(os.Symlink(filepath.Base(newDataDir), filepath.Join(dir, "..data_tmp")))
+	assert.NoError(os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")))
+	assert.NoError(os.Remove(filepath.Join(dir, "removed")))
+
+	for i := 0; i < 500 && (a.get("/guest/volume/key") != "new" || a.exists("/guest/volume/removed")); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal("new", a.get("/guest/volume/key"))
+	assert.False(a.exists("/guest/volume/removed"))
+
+	w.remove("container")
+
+	w.Lock()
+	assert.Empty(w.volumes)
+	w.Unlock()
+}
+
+func TestVolumeWatcherSharedFile(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "volume")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	// The mount path stands for the bind mount of the former source.
+	source := filepath.Join(dir, "resolv.conf")
+	mountPath := filepath.Join(dir, "shared-resolv.conf")
+	assert.NoError(ioutil.WriteFile(source, []byte("nameserver 10.0.0.1\n"), 0644))
+	assert.NoError(ioutil.WriteFile(mountPath, []byte("nameserver 10.0.0.1\n"), 0644))
+
+	w, err := newVolumeWatcher(&copyFileAgent{copied: make(map[string]string)})
+	assert.NoError(err)
+	defer w.close()
+
+	assert.NoError(w.addSharedFile("container", source, mountPath, false))
+
+	// Replace the source the way the kubelet does, by renaming a new file.
+	tmp := filepath.Join(dir, ".resolv.conf.tmp")
+	assert.NoError(ioutil.WriteFile(tmp, []byte("nameserver 10.0.0.2\n"), 0644))
+	assert.NoError(os.Rename(tmp, source))
+
+	read := func() string {
+		content, _ := ioutil.ReadFile(mountPath)
+		return string(content)
+	}
+
+	for i := 0; i < 500 && read() != "nameserver 10.0.0.2\n"; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal("nameserver 10.0.0.2\n", read())
+
+	w.remove("container")
+
+	w.Lock()
+	assert.Empty(w.volumes)
+	w.Unlock()
+}
+
+func TestRefreshSharedFileShorter(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "volume")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	source := filepath.Join(dir, "resolv.conf")
+	mountPath := filepath.Join(dir, "shared-resolv.conf")
+	assert.NoError(ioutil.WriteFile(source, []byte("nameserver 10.0.0.2\n"), 0644))
+	assert.NoError(ioutil.WriteFile(mountPath, []byte("nameserver 10.0.0.1\nnameserver 10.0.0.3\n"), 0644))
+
+	_, err = refreshSharedFile(source, mountPath, false)
+	assert.NoError(err)
+
+	// The former content past the new one is dropped.
+	content, err := ioutil.ReadFile(mountPath)
+	assert.NoError(err)
+	assert.Equal("nameserver 10.0.0.2\n", string(content))
+}
+
+func TestRefreshSharedFileReadOnly(t *testing.T) {
+	assert := assert.New(t)
+	if tc.NotValid(ktu.NeedRoot()) {
+		t.Skip(testDisabledAsNonRoot)
+	}
+
+	dir, err := ioutil.TempDir("", "volume")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	source := filepath.Join(dir, "hosts")
+	mountPath := filepath.Join(dir, "shared-hosts")
+	assert.NoError(ioutil.WriteFile(source, []byte("10.0.0.1 old\n"), 0644))
+	assert.NoError(bindMount(context.Background(), source, mountPath, true, "private"))
+	defer syscall.Unmount(mountPath, syscall.MNT_DETACH)
+
+	tmp := filepath.Join(dir, ".hosts.tmp")
+	assert.NoError(ioutil.WriteFile(tmp, []byte("10.0.0.2 new\n"), 0644))
+	assert.NoError(os.Rename(tmp, source))
+
+	dirs, err := refreshSharedFile(source, mountPath, true)
+	assert.NoError(err)
+	assert.Equal([]string{dir}, dirs)
+
+	content, err := ioutil.ReadFile(mountPath)
+	assert.NoError(err)
+	assert.Equal("10.0.0.2 new\n", string(content))
+
+	// The bind mount is read-only again.
+	assert.Error(ioutil.WriteFile(mountPath, []byte("10.0.0.3 other\n"), 0644))
+}